@@ -92,9 +92,12 @@ func (m *Node) GetTags() []string {
 }
 
 type Way struct {
-	Tags             []string `protobuf:"bytes,1,rep,name=tags" json:"tags,omitempty"`
-	Refs             []int64  `protobuf:"varint,2,rep,packed,name=refs" json:"refs,omitempty"`
-	XXX_unrecognized []byte   `json:"-"`
+	Tags []string `protobuf:"bytes,1,rep,name=tags" json:"tags,omitempty"`
+	Refs []int64  `protobuf:"varint,2,rep,packed,name=refs" json:"refs,omitempty"`
+	// optional coords of all refs (e.g. from PBF files with LocationsOnWays)
+	Lats             []int64 `protobuf:"zigzag64,3,rep,packed,name=lats" json:"lats,omitempty"`
+	Lons             []int64 `protobuf:"zigzag64,4,rep,packed,name=lons" json:"lons,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *Way) Reset()         { *m = Way{} }
@@ -115,6 +118,20 @@ func (m *Way) GetRefs() []int64 {
 	return nil
 }
 
+func (m *Way) GetLats() []int64 {
+	if m != nil {
+		return m.Lats
+	}
+	return nil
+}
+
+func (m *Way) GetLons() []int64 {
+	if m != nil {
+		return m.Lons
+	}
+	return nil
+}
+
 type Relation struct {
 	Tags             []string              `protobuf:"bytes,1,rep,name=tags" json:"tags,omitempty"`
 	MemberIds        []int64               `protobuf:"varint,2,rep,name=member_ids" json:"member_ids,omitempty"`
@@ -66,6 +66,17 @@ func deltaUnpack(data []int64) {
 func MarshalWay(way *element.Way) ([]byte, error) {
 	// TODO reuse Way to avoid make(Tags) for each way in tagsAsArray
 	pbfWay := &Way{}
+	if len(way.Nodes) > 0 && len(way.Nodes) == len(way.Refs) {
+		// store coords of ways from PBF files with LocationsOnWays
+		pbfWay.Lats = make([]int64, len(way.Nodes))
+		pbfWay.Lons = make([]int64, len(way.Nodes))
+		for i, nd := range way.Nodes {
+			pbfWay.Lats[i] = int64(CoordToInt(nd.Lat))
+			pbfWay.Lons[i] = int64(CoordToInt(nd.Long))
+		}
+		deltaPack(pbfWay.Lats)
+		deltaPack(pbfWay.Lons)
+	}
 	deltaPack(way.Refs)
 	pbfWay.Refs = way.Refs
 	pbfWay.Tags = tagsAsArray(way.Tags)
@@ -83,6 +94,16 @@ func UnmarshalWay(data []byte) (way *element.Way, err error) {
 	deltaUnpack(pbfWay.Refs)
 	way.Refs = pbfWay.Refs
 	way.Tags = tagsFromArray(pbfWay.Tags)
+	if len(pbfWay.Lats) > 0 && len(pbfWay.Lats) == len(way.Refs) && len(pbfWay.Lons) == len(way.Refs) {
+		deltaUnpack(pbfWay.Lats)
+		deltaUnpack(pbfWay.Lons)
+		way.Nodes = make([]element.Node, len(way.Refs))
+		for i := range way.Nodes {
+			way.Nodes[i].Id = way.Refs[i]
+			way.Nodes[i].Lat = IntToCoord(uint32(pbfWay.Lats[i]))
+			way.Nodes[i].Long = IntToCoord(uint32(pbfWay.Lons[i]))
+		}
+	}
 	return way, nil
 }
 
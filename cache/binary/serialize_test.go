@@ -1,6 +1,7 @@
 package binary
 
 import (
+	"math"
 	"testing"
 
 	"github.com/omniscale/imposm3/element"
@@ -69,6 +70,42 @@ func TestMarshalWay(t *testing.T) {
 
 }
 
+func TestMarshalWayWithNodes(t *testing.T) {
+	way := &element.Way{}
+	way.Id = 12345
+	way.Refs = []int64{3, 1, 2}
+	way.Nodes = []element.Node{
+		{OSMElem: element.OSMElem{Id: 3}, Long: 8.5, Lat: 53.1},
+		{OSMElem: element.OSMElem{Id: 1}, Long: -8.5, Lat: -53.1},
+		{OSMElem: element.OSMElem{Id: 2}, Long: 179.9, Lat: 0},
+	}
+	expected := append([]element.Node(nil), way.Nodes...)
+
+	data, _ := MarshalWay(way)
+	way, _ = UnmarshalWay(data)
+
+	if !compareRefs(way.Refs, []int64{3, 1, 2}) {
+		t.Error("refs do not match", way.Refs)
+	}
+	if len(way.Nodes) != len(expected) {
+		t.Fatal("nodes do not match", way.Nodes)
+	}
+	for i, nd := range way.Nodes {
+		if nd.Id != expected[i].Id ||
+			math.Abs(nd.Long-expected[i].Long) > 1e-6 ||
+			math.Abs(nd.Lat-expected[i].Lat) > 1e-6 {
+			t.Errorf("node does not match %v != %v", nd, expected[i])
+		}
+	}
+
+	way.Nodes = nil
+	data, _ = MarshalWay(way)
+	way, _ = UnmarshalWay(data)
+	if way.Nodes != nil {
+		t.Error("unexpected nodes", way.Nodes)
+	}
+}
+
 func TestMarshalRelation(t *testing.T) {
 	rel := &element.Relation{}
 	rel.Id = 12345
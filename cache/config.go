@@ -28,6 +28,8 @@ type osmCacheOptions struct {
 	InsertedWays cacheOptions
 	CoordsIndex  cacheOptions
 	WaysIndex    cacheOptions
+	Versions     cacheOptions
+	Pending      cacheOptions
 }
 
 const defaultConfig = `
@@ -82,6 +84,20 @@ const defaultConfig = `
         "BlockSizeK": 0,
         "MaxOpenFiles": 64,
         "BlockRestartInterval": 128
+    },
+    "Versions": {
+        "CacheSizeM": 16,
+        "WriteBufferSizeM": 64,
+        "BlockSizeK": 0,
+        "MaxOpenFiles": 64,
+        "BlockRestartInterval": 128
+    },
+    "Pending": {
+        "CacheSizeM": 0,
+        "WriteBufferSizeM": 0,
+        "BlockSizeK": 0,
+        "MaxOpenFiles": 0,
+        "BlockRestartInterval": 0
     }
 }
 `
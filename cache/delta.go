@@ -5,6 +5,7 @@ import (
 	"sort"
 	"sync"
 
+	"github.com/jmhodges/levigo"
 	"github.com/omniscale/imposm3/cache/binary"
 	"github.com/omniscale/imposm3/element"
 )
@@ -159,6 +160,73 @@ func (self *DeltaCoordsCache) DeleteCoord(id int64) error {
 	return nil
 }
 
+// Iter returns a channel with all cached coords. Each slice contains the
+// coords of one bunch, bunches and coords are ordered by ID.
+func (self *DeltaCoordsCache) Iter() chan []element.Node {
+	coords := make(chan []element.Node)
+	go func() {
+		if err := self.Flush(); err != nil {
+			panic(err)
+		}
+		ro := levigo.NewReadOptions()
+		ro.SetFillCache(false)
+		it := self.db.NewIterator(ro)
+		// we need to Close the iter before closing the
+		// chan (and thus signaling that we are done)
+		// to avoid race where db is closed before the iterator
+		defer close(coords)
+		defer it.Close()
+		it.SeekToFirst()
+		for ; it.Valid(); it.Next() {
+			nodes, err := binary.UnmarshalDeltaNodes(it.Value(), nil)
+			if err != nil {
+				panic(err)
+			}
+			coords <- nodes
+		}
+	}()
+	return coords
+}
+
+// Prune removes all coords for which keep returns false. It rewrites
+// each bunch only once and returns the number of removed coords.
+// keep is called without any bunch lock, but it must not query the
+// coords cache itself.
+func (self *DeltaCoordsCache) Prune(keep func(nd *element.Node) bool) (int, error) {
+	if err := self.Flush(); err != nil {
+		return 0, err
+	}
+	ro := levigo.NewReadOptions()
+	ro.SetFillCache(false)
+	defer ro.Close()
+	it := self.db.NewIterator(ro)
+	defer it.Close()
+
+	removed := 0
+	var nodes []element.Node
+	var err error
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		nodes, err = binary.UnmarshalDeltaNodes(it.Value(), nodes)
+		if err != nil {
+			return removed, err
+		}
+		kept := nodes[:0]
+		for i := range nodes {
+			if keep(&nodes[i]) {
+				kept = append(kept, nodes[i])
+			}
+		}
+		if len(kept) == len(nodes) {
+			continue
+		}
+		removed += len(nodes) - len(kept)
+		if err := self.putCoordsPacked(idFromKeyBuf(it.Key()), kept); err != nil {
+			return removed, err
+		}
+	}
+	return removed, it.GetError()
+}
+
 func (self *DeltaCoordsCache) FillWay(way *element.Way) error {
 	if way == nil {
 		return nil
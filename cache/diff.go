@@ -25,6 +25,8 @@ type DiffCache struct {
 	Coords    *CoordsRefIndex    // Stores which ways a coord references
 	CoordsRel *CoordsRelRefIndex // Stores which relations a coord references
 	Ways      *WaysRefIndex      // Stores which relations a way references
+	Relations *RelationsRefIndex // Stores which relations a relation references
+	Pending   *PendingCache      // Stores elements with references to uncached elements
 	opened    bool
 }
 
@@ -46,6 +48,14 @@ func (c *DiffCache) Close() {
 		c.Ways.Close()
 		c.Ways = nil
 	}
+	if c.Relations != nil {
+		c.Relations.Close()
+		c.Relations = nil
+	}
+	if c.Pending != nil {
+		c.Pending.Close()
+		c.Pending = nil
+	}
 }
 
 func (c *DiffCache) Flush() {
@@ -58,6 +68,19 @@ func (c *DiffCache) Flush() {
 	if c.Ways != nil {
 		c.Ways.Flush()
 	}
+	if c.Relations != nil {
+		c.Relations.Flush()
+	}
+}
+
+// Compact compacts the LevelDB files of all ref indices.
+func (c *DiffCache) Compact() {
+	c.Flush()
+	c.Coords.compact()
+	c.CoordsRel.compact()
+	c.Ways.compact()
+	c.Relations.compact()
+	c.Pending.compact()
 }
 
 func (c *DiffCache) Open() error {
@@ -77,6 +100,16 @@ func (c *DiffCache) Open() error {
 		c.Close()
 		return err
 	}
+	c.Relations, err = newRelationsRefIndex(filepath.Join(c.Dir, "relations_index"))
+	if err != nil {
+		c.Close()
+		return err
+	}
+	c.Pending, err = newPendingCache(filepath.Join(c.Dir, "pending"))
+	if err != nil {
+		c.Close()
+		return err
+	}
 	c.opened = true
 	return nil
 }
@@ -94,6 +127,12 @@ func (c *DiffCache) Exists() bool {
 	if _, err := os.Stat(filepath.Join(c.Dir, "ways_index")); !os.IsNotExist(err) {
 		return true
 	}
+	if _, err := os.Stat(filepath.Join(c.Dir, "relations_index")); !os.IsNotExist(err) {
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(c.Dir, "pending")); !os.IsNotExist(err) {
+		return true
+	}
 	return false
 }
 
@@ -110,6 +149,12 @@ func (c *DiffCache) Remove() error {
 	if err := os.RemoveAll(filepath.Join(c.Dir, "ways_index")); err != nil {
 		return err
 	}
+	if err := os.RemoveAll(filepath.Join(c.Dir, "relations_index")); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(c.Dir, "pending")); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -224,6 +269,9 @@ type CoordsRelRefIndex struct {
 type WaysRefIndex struct {
 	bunchRefCache
 }
+type RelationsRefIndex struct {
+	bunchRefCache
+}
 
 func newCoordsRefIndex(dir string) (*CoordsRefIndex, error) {
 	cache, err := newRefIndex(dir, &globalCacheOptions.CoordsIndex)
@@ -249,6 +297,14 @@ func newWaysRefIndex(dir string) (*WaysRefIndex, error) {
 	return &WaysRefIndex{*cache}, nil
 }
 
+func newRelationsRefIndex(dir string) (*RelationsRefIndex, error) {
+	cache, err := newRefIndex(dir, &globalCacheOptions.WaysIndex)
+	if err != nil {
+		return nil, err
+	}
+	return &RelationsRefIndex{*cache}, nil
+}
+
 func (index *bunchRefCache) getBunchId(id int64) int64 {
 	return id / 64
 }
@@ -420,6 +476,18 @@ func (index *WaysRefIndex) AddFromMembers(relId int64, members []element.Member)
 	}
 }
 
+func (index *RelationsRefIndex) AddFromMembers(relId int64, members []element.Member) {
+	for _, member := range members {
+		if member.Type == element.RELATION {
+			if index.linearImport {
+				index.addc <- idRef{id: member.Id, ref: relId}
+			} else {
+				index.Add(member.Id, relId)
+			}
+		}
+	}
+}
+
 // SetLinearImport optimizes the cache for write operations.
 // Get/Delete operations will panic during linear import.
 func (index *bunchRefCache) SetLinearImport(val bool) {
@@ -0,0 +1,410 @@
+package export
+
+import (
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/logging"
+	"github.com/omniscale/imposm3/parser/osmxml"
+	"github.com/omniscale/imposm3/parser/pbf"
+	diffstate "github.com/omniscale/imposm3/update/state"
+)
+
+var log = logging.NewLogger("export")
+
+var flags = flag.NewFlagSet("cache export", flag.ExitOnError)
+
+var (
+	cachedir = flags.String("cachedir", "/tmp/imposm3", "cache directory")
+	diffdir  = flags.String("diffdir", "", "diff directory with last.state.txt for the file header (defaults to -cachedir)")
+	output   = flags.String("o", "", "output file (.osm.pbf, .osm or .osc, optionally with .gz)")
+	bbox     = flags.String("bbox", "", "only export elements within minx,miny,maxx,maxy (EPSG:4326)")
+	polygons = flags.String("geojson", "", "only export elements within the polygons of this GeoJSON file (EPSG:4326)")
+	complete = flags.Bool("complete", true, "include all nodes of ways and all members of relations")
+)
+
+func Usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s cache export:\n\n", os.Args[0])
+	flags.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "\nExport cached nodes/ways/relations as OSM PBF or XML file.")
+	os.Exit(1)
+}
+
+// osmWriter is implemented by pbf.Writer and osmxml.Writer.
+type osmWriter interface {
+	WriteNode(*element.Node) error
+	WriteWay(*element.Way) error
+	WriteRelation(*element.Relation) error
+	Close() error
+}
+
+type fileWriter struct {
+	osmWriter
+	closers []io.Closer
+}
+
+func (w *fileWriter) Close() error {
+	if err := w.osmWriter.Close(); err != nil {
+		return err
+	}
+	for _, c := range w.closers {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openWriter creates an osmWriter for filename. The format is
+// detected by the file extension.
+func openWriter(filename string, state *diffstate.DiffState) (*fileWriter, error) {
+	name := filename
+	isGzip := strings.HasSuffix(name, ".gz")
+	if isGzip {
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	isPbf := strings.HasSuffix(name, ".pbf")
+	isOsc := strings.HasSuffix(name, ".osc")
+	if !isPbf && !isOsc && !strings.HasSuffix(name, ".osm") {
+		return nil, errors.New("unknown output format for " + filename)
+	}
+	if isPbf && isGzip {
+		return nil, errors.New("PBF files are already compressed, remove .gz from " + filename)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	fw := &fileWriter{}
+	var w io.Writer = f
+	if isGzip {
+		gz := gzip.NewWriter(f)
+		fw.closers = append(fw.closers, gz)
+		w = gz
+	}
+	fw.closers = append(fw.closers, f)
+
+	if isPbf {
+		header := pbf.Header{}
+		if state != nil {
+			header.Time = state.Time
+			header.Sequence = int64(state.Sequence)
+		}
+		fw.osmWriter, err = pbf.NewWriter(w, header)
+	} else {
+		fw.osmWriter, err = osmxml.NewWriter(w, isOsc)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return fw, nil
+}
+
+func parseBbox(s string) (geos.Bounds, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return geos.Bounds{}, errors.New("bbox requires four values: minx,miny,maxx,maxy")
+	}
+	var values [4]float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return geos.Bounds{}, err
+		}
+		values[i] = v
+	}
+	if values[0] >= values[2] || values[1] >= values[3] {
+		return geos.Bounds{}, errors.New("invalid bbox, min values need to be smaller than max values")
+	}
+	return geos.Bounds{MinX: values[0], MinY: values[1], MaxX: values[2], MaxY: values[3]}, nil
+}
+
+func Export(args []string) {
+	flags.Usage = Usage
+
+	if len(args) == 0 {
+		Usage()
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		log.Fatal("missing -o")
+	}
+	if *bbox != "" && *polygons != "" {
+		log.Fatal("cannot use -bbox and -geojson option together")
+	}
+
+	e := &exporter{complete: *complete}
+	if *bbox != "" {
+		bounds, err := parseBbox(*bbox)
+		if err != nil {
+			log.Fatal(err)
+		}
+		e.inArea = func(nd *element.Node) bool {
+			return nd.Long >= bounds.MinX && nd.Long <= bounds.MaxX &&
+				nd.Lat >= bounds.MinY && nd.Lat <= bounds.MaxY
+		}
+	} else if *polygons != "" {
+		step := log.StartStep("Reading -geojson polygons")
+		limiter, err := limit.NewFromGeoJSON(*polygons, 0.0, 4326)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.StopStep(step)
+		g := geos.NewGeos()
+		defer g.Finish()
+		e.inArea = func(nd *element.Node) bool {
+			return limiter.IntersectsPoint(g, nd.Long, nd.Lat)
+		}
+	}
+
+	if *diffdir == "" {
+		*diffdir = *cachedir
+	}
+	state, err := diffstate.ParseLastState(*diffdir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warn("unable to read last.state.txt: ", err)
+	}
+
+	osmCache := cache.NewOSMCache(*cachedir)
+	if !osmCache.Exists() {
+		log.Fatal("no cache found in ", *cachedir)
+	}
+	if err := osmCache.Open(); err != nil {
+		log.Fatal("osm cache: ", err)
+	}
+	e.osmCache = osmCache
+
+	w, err := openWriter(*output, state)
+	if err != nil {
+		osmCache.Close()
+		log.Fatal(err)
+	}
+	e.w = w
+
+	if err := e.export(); err != nil {
+		osmCache.Close()
+		log.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		osmCache.Close()
+		log.Fatal(err)
+	}
+	// explicitly Close since os.Exit prevents defers
+	osmCache.Close()
+}
+
+type exporter struct {
+	osmCache *cache.OSMCache
+	w        osmWriter
+	// inArea returns true if the node is within the export area,
+	// nil for complete exports
+	inArea   func(nd *element.Node) bool
+	complete bool
+
+	// selected elements for exports with inArea
+	nodes     map[int64]struct{}
+	ways      map[int64]struct{}
+	relations map[int64]struct{}
+}
+
+func (e *exporter) export() error {
+	if e.inArea != nil {
+		e.nodes = make(map[int64]struct{})
+		e.ways = make(map[int64]struct{})
+		e.relations = make(map[int64]struct{})
+
+		step := log.StartStep("Selecting ways")
+		if err := e.selectWays(); err != nil {
+			return err
+		}
+		log.StopStep(step)
+
+		step = log.StartStep("Selecting relations")
+		if err := e.selectRelations(); err != nil {
+			return err
+		}
+		log.StopStep(step)
+	}
+
+	step := log.StartStep("Writing nodes")
+	n, err := e.writeNodes()
+	if err != nil {
+		return err
+	}
+	log.StopStep(step)
+	log.Printf("exported %d nodes", n)
+
+	step = log.StartStep("Writing ways")
+	n = 0
+	for w := range e.osmCache.Ways.Iter() {
+		if e.ways != nil {
+			if _, ok := e.ways[w.Id]; !ok {
+				continue
+			}
+		}
+		if err := e.w.WriteWay(w); err != nil {
+			return err
+		}
+		n += 1
+	}
+	log.StopStep(step)
+	log.Printf("exported %d ways", n)
+
+	step = log.StartStep("Writing relations")
+	n = 0
+	for r := range e.osmCache.Relations.Iter() {
+		if e.relations != nil {
+			if _, ok := e.relations[r.Id]; !ok {
+				continue
+			}
+		}
+		if err := e.w.WriteRelation(r); err != nil {
+			return err
+		}
+		n += 1
+	}
+	log.StopStep(step)
+	log.Printf("exported %d relations", n)
+	return nil
+}
+
+// selectWays selects all ways with at least one node within the area.
+func (e *exporter) selectWays() error {
+	for w := range e.osmCache.Ways.Iter() {
+		for _, ref := range w.Refs {
+			nd, err := e.osmCache.Coords.GetCoord(ref)
+			if err == cache.NotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if e.inArea(nd) {
+				e.addWay(w)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (e *exporter) addWay(w *element.Way) {
+	e.ways[w.Id] = struct{}{}
+	if e.complete {
+		for _, ref := range w.Refs {
+			e.nodes[ref] = struct{}{}
+		}
+	}
+}
+
+// selectRelations selects all relations with at least one selected way
+// or one node within the area. Missing member ways and nodes are added
+// for complete exports. Member relations are added without their members.
+func (e *exporter) selectRelations() error {
+	var missingWays []int64
+	for r := range e.osmCache.Relations.Iter() {
+		selected := false
+		for _, m := range r.Members {
+			if m.Type == element.WAY {
+				if _, ok := e.ways[m.Id]; ok {
+					selected = true
+					break
+				}
+			} else if m.Type == element.NODE {
+				nd, err := e.osmCache.Coords.GetCoord(m.Id)
+				if err == cache.NotFound {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if e.inArea(nd) {
+					selected = true
+					break
+				}
+			}
+		}
+		if !selected {
+			continue
+		}
+		e.relations[r.Id] = struct{}{}
+		if !e.complete {
+			continue
+		}
+		for _, m := range r.Members {
+			switch m.Type {
+			case element.WAY:
+				if _, ok := e.ways[m.Id]; !ok {
+					missingWays = append(missingWays, m.Id)
+				}
+			case element.NODE:
+				e.nodes[m.Id] = struct{}{}
+			case element.RELATION:
+				e.relations[m.Id] = struct{}{}
+			}
+		}
+	}
+
+	for _, id := range missingWays {
+		w, err := e.osmCache.Ways.GetWay(id)
+		if err == cache.NotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		e.addWay(w)
+	}
+	return nil
+}
+
+// writeNodes writes all selected coords. Tags are merged from the
+// nodes cache, both caches are iterated in the order of their keys.
+func (e *exporter) writeNodes() (int, error) {
+	tagged := e.osmCache.Nodes.Iter()
+	// drain to stop the iterator goroutine on early returns
+	defer func() {
+		for range tagged {
+		}
+	}()
+	next, ok := <-tagged
+
+	n := 0
+	for coords := range e.osmCache.Coords.Iter() {
+		for i := range coords {
+			nd := &coords[i]
+			for ok && uint64(next.Id) < uint64(nd.Id) {
+				next, ok = <-tagged
+			}
+			if ok && next.Id == nd.Id {
+				nd.Tags = next.Tags
+			}
+			if e.inArea != nil && !e.inArea(nd) {
+				if _, ok := e.nodes[nd.Id]; !ok {
+					continue
+				}
+			}
+			if err := e.w.WriteNode(nd); err != nil {
+				return n, err
+			}
+			n += 1
+		}
+	}
+	return n, nil
+}
@@ -131,6 +131,20 @@ func (c *OSMCache) Remove() error {
 	return nil
 }
 
+// Compact compacts the LevelDB files of all caches. Call it after
+// large deletes (e.g. cache prune) to free the disk space.
+func (c *OSMCache) Compact() error {
+	if err := c.Coords.Flush(); err != nil {
+		return err
+	}
+	c.Coords.compact()
+	c.Nodes.compact()
+	c.Ways.compact()
+	c.Relations.compact()
+	c.InsertedWays.compact()
+	return nil
+}
+
 // FirstMemberIsCached checks whether the first way or node member is cached.
 // Also returns true if there are no members of type WAY or NODE.
 func (c *OSMCache) FirstMemberIsCached(members []element.Member) (bool, error) {
@@ -158,6 +172,25 @@ func (c *OSMCache) FirstMemberIsCached(members []element.Member) (bool, error) {
 	return true, nil
 }
 
+// FirstWayMemberIsCached checks whether the first way member is cached.
+// Also returns true if there are no members of type WAY. Use this
+// instead of FirstMemberIsCached if the coords are not cached.
+func (c *OSMCache) FirstWayMemberIsCached(members []element.Member) (bool, error) {
+	for _, m := range members {
+		if m.Type == element.WAY {
+			_, err := c.Ways.GetWay(m.Id)
+			if err == NotFound {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+	return true, nil
+}
+
 type cache struct {
 	db      *levigo.DB
 	options *cacheOptions
@@ -196,6 +229,10 @@ func (c *cache) open(path string) error {
 	return nil
 }
 
+func (c *cache) compact() {
+	c.db.CompactRange(levigo.Range{})
+}
+
 func idToKeyBuf(id int64) []byte {
 	b := make([]byte, 8)
 	bin.BigEndian.PutUint64(b, uint64(id))
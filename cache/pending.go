@@ -0,0 +1,112 @@
+package cache
+
+import (
+	bin "encoding/binary"
+	"errors"
+
+	"github.com/jmhodges/levigo"
+	"github.com/omniscale/imposm3/element"
+)
+
+// PendingElement is a cached way or relation with references to
+// elements that are not cached.
+type PendingElement struct {
+	Type    element.MemberType
+	Id      int64
+	Missing []element.Member
+}
+
+// PendingCache stores ways and relations that reference elements that
+// are not cached (e.g. nodes outside of the -limitto area). These
+// elements need to be re-inserted when the missing elements are added
+// to the cache by a later diff.
+type PendingCache struct {
+	cache
+}
+
+func newPendingCache(path string) (*PendingCache, error) {
+	cache := PendingCache{}
+	cache.options = &globalCacheOptions.Pending
+	err := cache.open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &cache, err
+}
+
+// Put stores the missing references of an element. It removes the
+// element if missing is empty.
+func (p *PendingCache) Put(typ element.MemberType, id int64, missing []element.Member) error {
+	if len(missing) == 0 {
+		return p.Delete(typ, id)
+	}
+	return p.db.Put(p.wo, versionKeyBuf(typ, id), marshalMissing(missing))
+}
+
+// Get returns the missing references of an element.
+func (p *PendingCache) Get(typ element.MemberType, id int64) ([]element.Member, error) {
+	data, err := p.db.Get(p.ro, versionKeyBuf(typ, id))
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, NotFound
+	}
+	return unmarshalMissing(data)
+}
+
+func (p *PendingCache) Delete(typ element.MemberType, id int64) error {
+	return p.db.Delete(p.wo, versionKeyBuf(typ, id))
+}
+
+// Iter returns all pending elements.
+func (p *PendingCache) Iter() chan PendingElement {
+	elems := make(chan PendingElement)
+	go func() {
+		ro := levigo.NewReadOptions()
+		ro.SetFillCache(false)
+		it := p.db.NewIterator(ro)
+		// close iter before chan, see RelationsCache.Iter
+		defer close(elems)
+		defer it.Close()
+		it.SeekToFirst()
+		for ; it.Valid(); it.Next() {
+			key := it.Key()
+			missing, err := unmarshalMissing(it.Value())
+			if err != nil {
+				panic(err)
+			}
+			elems <- PendingElement{
+				Type:    element.MemberType(key[0]),
+				Id:      int64(bin.BigEndian.Uint64(key[1:])),
+				Missing: missing,
+			}
+		}
+	}()
+	return elems
+}
+
+func marshalMissing(missing []element.Member) []byte {
+	buf := make([]byte, len(missing)*(bin.MaxVarintLen64+1))
+	n := 0
+	for _, m := range missing {
+		buf[n] = byte(m.Type)
+		n += 1
+		n += bin.PutVarint(buf[n:], m.Id)
+	}
+	return buf[:n]
+}
+
+func unmarshalMissing(data []byte) ([]element.Member, error) {
+	var missing []element.Member
+	for len(data) > 0 {
+		typ := element.MemberType(data[0])
+		id, n := bin.Varint(data[1:])
+		if n <= 0 {
+			return nil, errors.New("invalid pending data")
+		}
+		missing = append(missing, element.Member{Type: typ, Id: id})
+		data = data[1+n:]
+	}
+	return missing, nil
+}
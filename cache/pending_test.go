@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+func TestPendingCache(t *testing.T) {
+	cache_dir, _ := ioutil.TempDir("", "imposm3_test")
+	defer os.RemoveAll(cache_dir)
+
+	cache, err := newPendingCache(cache_dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	missing := []element.Member{
+		{Type: element.NODE, Id: 1000},
+		{Type: element.WAY, Id: -42},
+	}
+	if err := cache.Put(element.RELATION, 10, missing); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Put(element.WAY, 20, []element.Member{{Type: element.NODE, Id: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cache.Get(element.RELATION, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, missing) {
+		t.Error("unexpected missing members", got)
+	}
+	if _, err := cache.Get(element.WAY, 10); err != NotFound {
+		t.Error("expected NotFound", err)
+	}
+
+	var elems []PendingElement
+	for elem := range cache.Iter() {
+		elems = append(elems, elem)
+	}
+	if len(elems) != 2 ||
+		elems[0].Type != element.WAY || elems[0].Id != 20 ||
+		elems[1].Type != element.RELATION || elems[1].Id != 10 {
+		t.Error("unexpected pending elements", elems)
+	}
+
+	// empty missing removes element
+	if err := cache.Put(element.WAY, 20, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Get(element.WAY, 20); err != NotFound {
+		t.Error("expected NotFound", err)
+	}
+}
@@ -0,0 +1,301 @@
+package prune
+
+import (
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/logging"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+var log = logging.NewLogger("prune")
+
+type pruner struct {
+	osmCache  *cache.OSMCache
+	diffCache *cache.DiffCache
+	limiter   *limit.Limiter
+	g         *geos.Geos
+}
+
+// Prune removes all elements from the cache that can not influence any
+// imported feature, neither now nor in future diff imports.
+//
+// Only elements outside of the -limitto cache buffer are removed from
+// the spatial caches. Coords inside the buffer are kept, even if they are
+// not referenced by any way, since new ways from future diffs can
+// reference them. Ways and relations are kept if at least one of their
+// members is kept. Additionally, nodes and relations that do not pass the
+// tag filters of the mapping are removed, as they are never inserted.
+func Prune() {
+	if config.BaseOptions.Quiet {
+		logging.SetQuiet(true)
+	}
+
+	var regions []limit.Region
+	for _, r := range config.BaseOptions.Regions() {
+		regions = append(regions, limit.Region{
+			Name:    r.Name,
+			LimitTo: r.LimitTo,
+			Bbox:    r.Bbox,
+			Buffer:  r.CacheBuffer,
+		})
+	}
+
+	var geometryLimiter *limit.Limiter
+	if len(regions) > 0 {
+		var err error
+		step := log.StartStep("Reading limitto geometries")
+		geometryLimiter, err = limit.New(regions, config.BaseOptions.Srid)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.StopStep(step)
+		if !geometryLimiter.HasBuffer() {
+			log.Warn("no -limittocachebuffer for -limitto, only pruning by tags")
+			geometryLimiter = nil
+		}
+	}
+
+	tagmapping, err := mapping.NewMapping(config.BaseOptions.MappingFile)
+	if err != nil {
+		log.Fatal("mapping: ", err)
+	}
+
+	osmCache := cache.NewOSMCache(config.BaseOptions.CacheDir)
+	if !osmCache.Exists() {
+		log.Fatal("no cache found in ", config.BaseOptions.CacheDir)
+	}
+	diffCache := cache.NewDiffCache(config.BaseOptions.CacheDir)
+	if !diffCache.Exists() {
+		log.Fatal("missing diff cache, prune requires a cache from an import with -diff")
+	}
+	if err := osmCache.Open(); err != nil {
+		log.Fatal("osm cache: ", err)
+	}
+	if err := diffCache.Open(); err != nil {
+		osmCache.Close()
+		log.Fatal("diff cache: ", err)
+	}
+
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	p := &pruner{
+		osmCache:  osmCache,
+		diffCache: diffCache,
+		limiter:   geometryLimiter,
+		g:         g,
+	}
+
+	if err := p.prune(tagmapping); err != nil {
+		osmCache.Close()
+		diffCache.Close()
+		log.Fatal(err)
+	}
+
+	step := log.StartStep("Compacting cache")
+	if err := osmCache.Compact(); err != nil {
+		log.Fatal(err)
+	}
+	diffCache.Compact()
+	log.StopStep(step)
+
+	// explicitly Close since os.Exit prevents defers
+	osmCache.Close()
+	diffCache.Close()
+}
+
+func (p *pruner) prune(tagmapping *mapping.Mapping) error {
+	if p.limiter != nil {
+		step := log.StartStep("Pruning ways")
+		n, err := p.pruneWays()
+		if err != nil {
+			return err
+		}
+		log.Printf("removed %d ways", n)
+		log.StopStep(step)
+	}
+
+	step := log.StartStep("Pruning relations")
+	n, err := p.pruneRelations(tagmapping.RelationTagFilter())
+	if err != nil {
+		return err
+	}
+	log.Printf("removed %d relations", n)
+	log.StopStep(step)
+
+	if p.limiter != nil {
+		step = log.StartStep("Pruning coords")
+		n, err = p.osmCache.Coords.Prune(p.keepCoord)
+		if err != nil {
+			return err
+		}
+		log.Printf("removed %d coords", n)
+		log.StopStep(step)
+	}
+
+	step = log.StartStep("Pruning nodes")
+	n, err = p.pruneNodes(tagmapping.NodeTagFilter())
+	if err != nil {
+		return err
+	}
+	log.Printf("removed %d nodes", n)
+	log.StopStep(step)
+	return nil
+}
+
+func (p *pruner) inBuffer(nd *element.Node) bool {
+	return p.limiter == nil || p.limiter.IntersectsBuffer(p.g, nd.Long, nd.Lat)
+}
+
+// pruneWays removes all ways without any coord in the limitto buffer.
+// Diff imports do not cache such ways either.
+func (p *pruner) pruneWays() (int, error) {
+	removed := 0
+	for w := range p.osmCache.Ways.Iter() {
+		keep := false
+		for _, ref := range w.Refs {
+			nd, err := p.osmCache.Coords.GetCoord(ref)
+			if err == cache.NotFound {
+				continue
+			}
+			if err != nil {
+				return removed, err
+			}
+			if p.inBuffer(nd) {
+				keep = true
+				break
+			}
+		}
+		if keep {
+			continue
+		}
+		if err := p.removeWay(w); err != nil {
+			return removed, err
+		}
+		removed += 1
+	}
+	return removed, nil
+}
+
+func (p *pruner) removeWay(w *element.Way) error {
+	if err := p.osmCache.Ways.DeleteWay(w.Id); err != nil {
+		return err
+	}
+	if err := p.osmCache.InsertedWays.DeleteMembers(
+		[]element.Member{{Id: w.Id, Type: element.WAY}},
+	); err != nil {
+		return err
+	}
+	// DeleteFromWay only requires the node IDs
+	w.Nodes = make([]element.Node, len(w.Refs))
+	for i, ref := range w.Refs {
+		w.Nodes[i].Id = ref
+	}
+	p.diffCache.Coords.DeleteFromWay(w)
+	return p.diffCache.Ways.Delete(w.Id)
+}
+
+// pruneRelations removes all relations that are never inserted, because
+// they do not pass the relation tag filter or because none of their
+// members are cached. Sub-relations of inserted relations are kept.
+func (p *pruner) pruneRelations(filter mapping.TagFilterer) (int, error) {
+	removed := 0
+	for r := range p.osmCache.Relations.Iter() {
+		if len(p.diffCache.Relations.Get(r.Id)) > 0 {
+			continue
+		}
+		if filter.Filter(&r.Tags) {
+			cached, err := p.anyMemberCached(r.Members)
+			if err != nil {
+				return removed, err
+			}
+			if cached {
+				continue
+			}
+		}
+		if err := p.osmCache.Relations.DeleteRelation(r.Id); err != nil {
+			return removed, err
+		}
+		for _, m := range r.Members {
+			var err error
+			if m.Type == element.WAY {
+				err = p.diffCache.Ways.DeleteRef(m.Id, r.Id)
+			} else if m.Type == element.NODE {
+				err = p.diffCache.CoordsRel.DeleteRef(m.Id, r.Id)
+			} else if m.Type == element.RELATION {
+				err = p.diffCache.Relations.DeleteRef(m.Id, r.Id)
+			}
+			if err != nil {
+				return removed, err
+			}
+		}
+		removed += 1
+	}
+	return removed, nil
+}
+
+func (p *pruner) anyMemberCached(members []element.Member) (bool, error) {
+	for _, m := range members {
+		var err error
+		if m.Type == element.WAY {
+			_, err = p.osmCache.Ways.GetWay(m.Id)
+		} else if m.Type == element.NODE {
+			_, err = p.osmCache.Coords.GetCoord(m.Id)
+		} else if m.Type == element.RELATION {
+			_, err = p.osmCache.Relations.GetRelation(m.Id)
+		} else {
+			continue
+		}
+		if err == nil {
+			return true, nil
+		}
+		if err != cache.NotFound {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// keepCoord returns true if the coord is inside the limitto buffer or if
+// it is referenced by any remaining way or relation.
+func (p *pruner) keepCoord(nd *element.Node) bool {
+	if p.inBuffer(nd) {
+		return true
+	}
+	return p.isReferenced(nd.Id)
+}
+
+func (p *pruner) isReferenced(id int64) bool {
+	for _, wayId := range p.diffCache.Coords.Get(id) {
+		if _, err := p.osmCache.Ways.GetWay(wayId); err != cache.NotFound {
+			// also keep coord on errors
+			return true
+		}
+	}
+	for _, relId := range p.diffCache.CoordsRel.Get(id) {
+		if _, err := p.osmCache.Relations.GetRelation(relId); err != cache.NotFound {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneNodes removes all nodes without any mapped tags and all nodes
+// that were removed from the coords cache.
+func (p *pruner) pruneNodes(filter mapping.TagFilterer) (int, error) {
+	removed := 0
+	for nd := range p.osmCache.Nodes.Iter() {
+		filter.Filter(&nd.Tags)
+		if len(nd.Tags) > 0 && (p.inBuffer(nd) || p.isReferenced(nd.Id)) {
+			continue
+		}
+		if err := p.osmCache.Nodes.DeleteNode(nd.Id); err != nil {
+			return removed, err
+		}
+		removed += 1
+	}
+	return removed, nil
+}
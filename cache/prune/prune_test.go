@@ -0,0 +1,198 @@
+package prune
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+const testMapping = `
+tables:
+  roads:
+    type: linestring
+    columns:
+    - name: osm_id
+      type: id
+    - name: geometry
+      type: geometry
+    mapping:
+      highway: [__any__]
+  buildings:
+    type: polygon
+    columns:
+    - name: osm_id
+      type: id
+    - name: geometry
+      type: geometry
+    mapping:
+      building: [__any__]
+  pois:
+    type: point
+    columns:
+    - name: osm_id
+      type: id
+    - name: geometry
+      type: geometry
+    mapping:
+      amenity: [__any__]
+`
+
+func testNode(id int64, long, lat float64, tags element.Tags) element.Node {
+	return element.Node{OSMElem: element.OSMElem{Id: id, Tags: tags}, Long: long, Lat: lat}
+}
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mappingFile := filepath.Join(dir, "mapping.yml")
+	if err := ioutil.WriteFile(mappingFile, []byte(testMapping), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tagmapping, err := mapping.NewMapping(mappingFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	osmCache := cache.NewOSMCache(filepath.Join(dir, "osm"))
+	if err := osmCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer osmCache.Close()
+	diffCache := cache.NewDiffCache(filepath.Join(dir, "diff"))
+	if err := diffCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer diffCache.Close()
+
+	limiter, err := limit.New([]limit.Region{{Bbox: []float64{10, 50, 11, 51}, Buffer: 0.1}}, 4326)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	// 1, 2 inside, 3 in buffer, 4-6 outside
+	nodes := []element.Node{
+		testNode(1, 10.5, 50.5, element.Tags{"amenity": "cafe"}),
+		testNode(2, 10.6, 50.5, element.Tags{"unmapped": "yes"}),
+		testNode(3, 11.05, 50.5, nil),
+		testNode(4, 12.0, 50.5, element.Tags{"amenity": "cafe"}),
+		testNode(5, 12.1, 50.5, nil),
+		testNode(6, 12.2, 50.5, element.Tags{"amenity": "cafe"}),
+	}
+	if err := osmCache.Coords.PutCoords(nodes); err != nil {
+		t.Fatal(err)
+	}
+	for i := range nodes {
+		if nodes[i].Tags != nil {
+			if err := osmCache.Nodes.PutNode(&nodes[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, w := range []*element.Way{
+		{OSMElem: element.OSMElem{Id: 10, Tags: element.Tags{"highway": "primary"}}, Refs: []int64{1, 2}},
+		// crosses the buffer
+		{OSMElem: element.OSMElem{Id: 11, Tags: element.Tags{"highway": "primary"}}, Refs: []int64{2, 3, 4}},
+		{OSMElem: element.OSMElem{Id: 12, Tags: element.Tags{"highway": "primary"}}, Refs: []int64{4, 5}},
+	} {
+		if err := osmCache.Ways.PutWay(w); err != nil {
+			t.Fatal(err)
+		}
+		for _, ref := range w.Refs {
+			w.Nodes = append(w.Nodes, element.Node{OSMElem: element.OSMElem{Id: ref}})
+		}
+		diffCache.Coords.AddFromWay(w)
+	}
+
+	building := element.Tags{"type": "multipolygon", "building": "yes"}
+	for _, r := range []*element.Relation{
+		// member is outside
+		{OSMElem: element.OSMElem{Id: 20, Tags: building}, Members: []element.Member{{Id: 12, Type: element.WAY}}},
+		{OSMElem: element.OSMElem{Id: 21, Tags: building}, Members: []element.Member{
+			{Id: 11, Type: element.WAY},
+			{Id: 24, Type: element.RELATION},
+		}},
+		// not mapped
+		{OSMElem: element.OSMElem{Id: 22, Tags: element.Tags{"type": "multipolygon", "unmapped": "yes"}}, Members: []element.Member{{Id: 10, Type: element.WAY}}},
+		// sub-relation of 21
+		{OSMElem: element.OSMElem{Id: 24, Tags: element.Tags{"type": "multipolygon"}}, Members: []element.Member{{Id: 5, Type: element.NODE}}},
+	} {
+		if err := osmCache.Relations.PutRelation(r); err != nil {
+			t.Fatal(err)
+		}
+		diffCache.Ways.AddFromMembers(r.Id, r.Members)
+		diffCache.CoordsRel.AddFromMembers(r.Id, r.Members)
+		diffCache.Relations.AddFromMembers(r.Id, r.Members)
+	}
+
+	p := &pruner{osmCache: osmCache, diffCache: diffCache, limiter: limiter, g: g}
+	if !p.keepCoord(&nodes[2]) {
+		t.Error("coord in buffer not kept")
+	}
+	if cached, err := p.anyMemberCached([]element.Member{{Id: 99, Type: element.WAY}, {Id: 5, Type: element.NODE}}); err != nil || !cached {
+		t.Error("expected cached member", err)
+	}
+
+	if err := p.prune(tagmapping); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		id   int64
+		kept bool
+	}{{10, true}, {11, true}, {12, false}} {
+		if _, err := osmCache.Ways.GetWay(tc.id); (err == nil) != tc.kept {
+			t.Errorf("unexpected way %d: %v", tc.id, err)
+		}
+	}
+	for _, tc := range []struct {
+		id   int64
+		kept bool
+	}{{20, false}, {21, true}, {22, false}, {24, true}} {
+		if _, err := osmCache.Relations.GetRelation(tc.id); (err == nil) != tc.kept {
+			t.Errorf("unexpected relation %d: %v", tc.id, err)
+		}
+	}
+	// coord 4 and 5 are referenced by remaining way 11 and relation 24
+	for _, tc := range []struct {
+		id   int64
+		kept bool
+	}{{1, true}, {2, true}, {3, true}, {4, true}, {5, true}, {6, false}} {
+		if _, err := osmCache.Coords.GetCoord(tc.id); (err == nil) != tc.kept {
+			t.Errorf("unexpected coord %d: %v", tc.id, err)
+		}
+	}
+	// node 2 has no mapped tags, node 6 is outside
+	for _, tc := range []struct {
+		id   int64
+		kept bool
+	}{{1, true}, {2, false}, {4, true}, {6, false}} {
+		if _, err := osmCache.Nodes.GetNode(tc.id); (err == nil) != tc.kept {
+			t.Errorf("unexpected node %d: %v", tc.id, err)
+		}
+	}
+
+	if refs := diffCache.Ways.Get(12); len(refs) != 0 {
+		t.Error("unexpected relations for removed way", refs)
+	}
+	if refs := diffCache.Coords.Get(5); len(refs) != 0 {
+		t.Error("unexpected ways for coord of removed way", refs)
+	}
+	if refs := diffCache.Ways.Get(11); len(refs) != 1 || refs[0] != 21 {
+		t.Error("unexpected relations for way 11", refs)
+	}
+}
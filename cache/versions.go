@@ -0,0 +1,94 @@
+package cache
+
+import (
+	bin "encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/jmhodges/levigo"
+	"github.com/omniscale/imposm3/element"
+)
+
+// ElementVersion is the version of an element and the index of the
+// input file it was read from.
+type ElementVersion struct {
+	Id      int64
+	Version int
+	File    int
+	// Replaced is true if the element replaced an element from a
+	// previous input file.
+	Replaced bool
+}
+
+// VersionsCache stores the versions of all elements during an import of
+// multiple files. It is used to deduplicate elements that are present
+// in more than one file. It is removed after the import.
+type VersionsCache struct {
+	cache
+	path string
+}
+
+// NewVersionsCache opens a VersionsCache in the directory of the OSMCache.
+func (c *OSMCache) NewVersionsCache() (*VersionsCache, error) {
+	versions := VersionsCache{path: filepath.Join(c.dir, "versions")}
+	versions.options = &globalCacheOptions.Versions
+	if err := versions.open(versions.path); err != nil {
+		return nil, err
+	}
+	return &versions, nil
+}
+
+func versionKeyBuf(typ element.MemberType, id int64) []byte {
+	b := make([]byte, 9)
+	b[0] = byte(typ)
+	bin.BigEndian.PutUint64(b[1:], uint64(id))
+	return b
+}
+
+func (p *VersionsCache) GetVersion(typ element.MemberType, id int64) (*ElementVersion, error) {
+	data, err := p.db.Get(p.ro, versionKeyBuf(typ, id))
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, NotFound
+	}
+	v := &ElementVersion{Id: id}
+	version, n := bin.Uvarint(data)
+	if n <= 0 {
+		return nil, errors.New("invalid version data")
+	}
+	file, m := bin.Uvarint(data[n:])
+	if m <= 0 || len(data) != n+m+1 {
+		return nil, errors.New("invalid version data")
+	}
+	v.Version = int(version)
+	v.File = int(file)
+	v.Replaced = data[n+m] == 1
+	return v, nil
+}
+
+func (p *VersionsCache) PutVersions(typ element.MemberType, versions []ElementVersion) error {
+	batch := levigo.NewWriteBatch()
+	defer batch.Close()
+
+	buf := make([]byte, 2*bin.MaxVarintLen64+1)
+	for _, v := range versions {
+		n := bin.PutUvarint(buf, uint64(v.Version))
+		n += bin.PutUvarint(buf[n:], uint64(v.File))
+		if v.Replaced {
+			buf[n] = 1
+		} else {
+			buf[n] = 0
+		}
+		batch.Put(versionKeyBuf(typ, v.Id), buf[:n+1])
+	}
+	return p.db.Write(p.wo, batch)
+}
+
+// Remove closes and removes the cache.
+func (p *VersionsCache) Remove() error {
+	p.Close()
+	return os.RemoveAll(p.path)
+}
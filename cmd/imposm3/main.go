@@ -7,10 +7,13 @@ import (
 	"runtime"
 
 	"github.com/omniscale/imposm3"
+	"github.com/omniscale/imposm3/cache/export"
+	"github.com/omniscale/imposm3/cache/prune"
 	"github.com/omniscale/imposm3/cache/query"
 	"github.com/omniscale/imposm3/config"
 	"github.com/omniscale/imposm3/import_"
 	"github.com/omniscale/imposm3/logging"
+	"github.com/omniscale/imposm3/replication"
 	"github.com/omniscale/imposm3/stats"
 	"github.com/omniscale/imposm3/update"
 )
@@ -23,7 +26,13 @@ func PrintCmds() {
 	fmt.Println("\timport")
 	fmt.Println("\tdiff")
 	fmt.Println("\trun")
+	fmt.Println("\trollback")
+	fmt.Println("\treconcile")
 	fmt.Println("\tquery-cache")
+	fmt.Println("\tcache prune")
+	fmt.Println("\tcache export")
+	fmt.Println("\tstate")
+	fmt.Println("\treplication cleanup")
 	fmt.Println("\tversion")
 }
 
@@ -60,8 +69,43 @@ func Main(usage func()) {
 			stats.StartHttpPProf(config.BaseOptions.Httpprofile)
 		}
 		update.Run()
+	case "rollback":
+		config.ParseRollback(os.Args[2:])
+		update.Rollback()
+	case "reconcile":
+		config.ParseReconcile(os.Args[2:])
+		update.Reconcile()
 	case "query-cache":
 		query.Query(os.Args[2:])
+	case "cache":
+		if len(os.Args) <= 2 {
+			usage()
+			log.Fatal("missing cache command")
+		}
+		switch os.Args[2] {
+		case "prune":
+			config.ParsePrune(os.Args[3:])
+			prune.Prune()
+		case "export":
+			export.Export(os.Args[3:])
+		default:
+			usage()
+			log.Fatalf("invalid cache command: '%s'", os.Args[2])
+		}
+	case "replication":
+		if len(os.Args) <= 2 {
+			usage()
+			log.Fatal("missing replication command")
+		}
+		switch os.Args[2] {
+		case "cleanup":
+			replication.CleanupCmd(os.Args[3:])
+		default:
+			usage()
+			log.Fatalf("invalid replication command: '%s'", os.Args[2])
+		}
+	case "state":
+		replication.StateCmd(os.Args[2:])
 	case "version":
 		fmt.Println(imposm3.Version)
 		os.Exit(0)
@@ -7,22 +7,105 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	CacheDir            string          `json:"cachedir"`
-	DiffDir             string          `json:"diffdir"`
-	Connection          string          `json:"connection"`
-	MappingFile         string          `json:"mapping"`
-	LimitTo             string          `json:"limitto"`
-	LimitToCacheBuffer  float64         `json:"limitto_cache_buffer"`
-	Srid                int             `json:"srid"`
-	Schemas             Schemas         `json:"schemas"`
-	ExpireTilesDir      string          `json:"expiretiles_dir"`
-	ExpireTilesZoom     int             `json:"expiretiles_zoom"`
-	ReplicationUrl      string          `json:"replication_url"`
-	ReplicationInterval MinutesInterval `json:"replication_interval"`
+	CacheDir            string            `json:"cachedir"`
+	DiffDir             string            `json:"diffdir"`
+	Connection          string            `json:"connection"`
+	MappingFile         string            `json:"mapping"`
+	LimitTo             string            `json:"limitto"`
+	LimitToCacheBuffer  float64           `json:"limitto_cache_buffer"`
+	Bbox                Bbox              `json:"bbox"`
+	LimitToRegions      []LimitToRegion   `json:"limitto_regions"`
+	Srid                int               `json:"srid"`
+	Schemas             Schemas           `json:"schemas"`
+	ExpireTilesDir      string            `json:"expiretiles_dir"`
+	ExpireTilesZoom     int               `json:"expiretiles_zoom"`
+	ReplicationUrl      string            `json:"replication_url"`
+	ReplicationInterval MinutesInterval   `json:"replication_interval"`
+	ReplicationFeeds    []ReplicationFeed `json:"replication_feeds"`
+	OverlayDir          string            `json:"overlay_dir"`
+}
+
+// ReplicationFeed is one of multiple related replication feeds with
+// different granularity (e.g. day, hour and minute).
+type ReplicationFeed struct {
+	Url      string          `json:"url"`
+	Interval MinutesInterval `json:"interval"`
+}
+
+// LimitToRegion is a named -limitto area. Elements are clipped to each
+// region and optionally written into a separate schema.
+type LimitToRegion struct {
+	Name        string  `json:"name"`
+	LimitTo     string  `json:"limitto"`
+	Bbox        Bbox    `json:"bbox"`
+	CacheBuffer float64 `json:"cache_buffer"`
+	// Schema for all tables of this region, used for import and
+	// production. Uses the default schemas if empty.
+	Schema string `json:"schema"`
+}
+
+// Bbox is a flag.Value for minx,miny,maxx,maxy in EPSG:4326. The JSON
+// value can be a string or a list of numbers.
+type Bbox []float64
+
+func (b *Bbox) String() string {
+	if b == nil || len(*b) == 0 {
+		return ""
+	}
+	parts := make([]string, len(*b))
+	for i, v := range *b {
+		parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (b *Bbox) Set(value string) error {
+	if value == "" {
+		*b = nil
+		return nil
+	}
+	parts := strings.Split(value, ",")
+	values := make([]float64, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return fmt.Errorf("invalid bbox '%s': %s", value, err)
+		}
+		values[i] = v
+	}
+	return b.set(values)
+}
+
+func (b *Bbox) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return b.Set(s)
+	}
+	var values []float64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	return b.set(values)
+}
+
+func (b *Bbox) set(values []float64) error {
+	if len(values) != 4 {
+		return errors.New("bbox requires four values: minx,miny,maxx,maxy")
+	}
+	if values[0] >= values[2] || values[1] >= values[3] {
+		return errors.New("invalid bbox, min values need to be smaller than max values")
+	}
+	*b = values
+	return nil
 }
 
 type Schemas struct {
@@ -40,6 +123,9 @@ const defaultSchemaBackup = "backup"
 var ImportFlags = flag.NewFlagSet("import", flag.ExitOnError)
 var DiffFlags = flag.NewFlagSet("diff", flag.ExitOnError)
 var RunFlags = flag.NewFlagSet("run", flag.ExitOnError)
+var PruneFlags = flag.NewFlagSet("cache prune", flag.ExitOnError)
+var RollbackFlags = flag.NewFlagSet("rollback", flag.ExitOnError)
+var ReconcileFlags = flag.NewFlagSet("reconcile", flag.ExitOnError)
 
 type _BaseOptions struct {
 	Connection          string
@@ -49,14 +135,19 @@ type _BaseOptions struct {
 	Srid                int
 	LimitTo             string
 	LimitToCacheBuffer  float64
+	Bbox                Bbox
+	LimitToRegions      []LimitToRegion
 	ConfigFile          string
 	Httpprofile         string
 	Quiet               bool
 	Schemas             Schemas
 	ExpireTilesDir      string
 	ExpireTilesZoom     int
+	UndoSeqs            int
 	ReplicationUrl      string
 	ReplicationInterval time.Duration
+	ReplicationFeeds    []ReplicationFeed
+	OverlayDir          string
 }
 
 func (o *_BaseOptions) updateFromConfig() error {
@@ -100,8 +191,11 @@ func (o *_BaseOptions) updateFromConfig() error {
 	if o.MappingFile == "" {
 		o.MappingFile = conf.MappingFile
 	}
-	if o.LimitTo == "" {
+	if o.LimitTo == "" && o.Bbox == nil {
+		// -limitto or -bbox overwrite all limits from the config
 		o.LimitTo = conf.LimitTo
+		o.Bbox = conf.Bbox
+		o.LimitToRegions = conf.LimitToRegions
 	}
 	if o.LimitTo == "NONE" {
 		// allow overwrite from cmd line
@@ -117,6 +211,9 @@ func (o *_BaseOptions) updateFromConfig() error {
 	if o.ExpireTilesDir == "" {
 		o.ExpireTilesDir = conf.ExpireTilesDir
 	}
+	if o.OverlayDir == "" {
+		o.OverlayDir = conf.OverlayDir
+	}
 	if o.ExpireTilesZoom == 0 {
 		o.ExpireTilesZoom = conf.ExpireTilesZoom
 	}
@@ -131,6 +228,7 @@ func (o *_BaseOptions) updateFromConfig() error {
 		o.ReplicationInterval = time.Minute
 	}
 	o.ReplicationUrl = conf.ReplicationUrl
+	o.ReplicationFeeds = conf.ReplicationFeeds
 
 	if o.DiffDir == "" {
 		if conf.DiffDir == "" {
@@ -151,13 +249,64 @@ func (o *_BaseOptions) check() []error {
 	if o.MappingFile == "" {
 		errs = append(errs, errors.New("missing mapping"))
 	}
+	if o.LimitTo != "" && o.Bbox != nil {
+		errs = append(errs, errors.New("-limitto and -bbox can not be combined"))
+	}
+	if len(o.LimitToRegions) > 0 && (o.LimitTo != "" || o.Bbox != nil) {
+		errs = append(errs, errors.New("limitto_regions can not be combined with -limitto or -bbox"))
+	}
+	names := make(map[string]bool)
+	for _, r := range o.LimitToRegions {
+		if r.Name == "" {
+			errs = append(errs, errors.New("missing name for limitto_regions"))
+			continue
+		}
+		if names[r.Name] {
+			errs = append(errs, fmt.Errorf("duplicate limitto_regions name %s", r.Name))
+		}
+		names[r.Name] = true
+		if (r.LimitTo == "") == (r.Bbox == nil) {
+			errs = append(errs, fmt.Errorf("limitto_regions %s requires either limitto or bbox", r.Name))
+		}
+	}
 	return errs
 }
 
+// Regions returns all limitto_regions, or a single unnamed region for
+// -limitto or -bbox. Returns nil if elements are not limited.
+func (o *_BaseOptions) Regions() []LimitToRegion {
+	if len(o.LimitToRegions) > 0 {
+		return o.LimitToRegions
+	}
+	if o.LimitTo == "" && o.Bbox == nil {
+		return nil
+	}
+	return []LimitToRegion{{
+		LimitTo:     o.LimitTo,
+		Bbox:        o.Bbox,
+		CacheBuffer: o.LimitToCacheBuffer,
+	}}
+}
+
+// RegionSchemas returns the schema for each region with a custom schema.
+func (o *_BaseOptions) RegionSchemas() map[string]string {
+	var schemas map[string]string
+	for _, r := range o.LimitToRegions {
+		if r.Schema == "" {
+			continue
+		}
+		if schemas == nil {
+			schemas = make(map[string]string)
+		}
+		schemas[r.Name] = r.Schema
+	}
+	return schemas
+}
+
 type _ImportOptions struct {
 	Overwritecache   bool
 	Appendcache      bool
-	Read             string
+	Read             FileList
 	Write            bool
 	Optimize         bool
 	Diff             bool
@@ -167,8 +316,101 @@ type _ImportOptions struct {
 	DiffStateBefore  time.Duration
 }
 
+// FileList is a flag.Value for one or more files. Files can be separated
+// by comma or the flag can be repeated.
+type FileList []string
+
+func (l *FileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *FileList) Set(value string) error {
+	if value == "" {
+		// -read= resets the list
+		*l = nil
+		return nil
+	}
+	for _, f := range strings.Split(value, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			*l = append(*l, f)
+		}
+	}
+	return nil
+}
+
+type _RunOptions struct {
+	FromDir   string
+	UntilSeq  int
+	UntilTime time.Time
+	Once      bool
+	CatchUp   int
+	KeepSeqs  int
+	KeepAge   time.Duration
+	// ReconcileInterval is the interval for re-checking pending
+	// elements, 0 disables the reconciliation.
+	ReconcileInterval time.Duration
+}
+
+// HasTarget returns true if run should stop after a specific diff.
+func (o *_RunOptions) HasTarget() bool {
+	return o.UntilSeq != 0 || !o.UntilTime.IsZero() || o.Once
+}
+
+// TargetReached returns whether the -until-seq or -until-time target is
+// reached after importing the diff seq with the state time t.
+func (o *_RunOptions) TargetReached(seq int, t time.Time) bool {
+	if o.UntilSeq != 0 && seq >= o.UntilSeq {
+		return true
+	}
+	if !o.UntilTime.IsZero() && !t.IsZero() && !t.Before(o.UntilTime) {
+		return true
+	}
+	return false
+}
+
+// TimeFlag is a flag.Value for a UTC timestamp (2006-01-02T15:04:05Z).
+// The time and timezone are optional.
+type TimeFlag struct {
+	*time.Time
+}
+
+var timeFlagFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func (f TimeFlag) String() string {
+	if f.Time == nil || f.Time.IsZero() {
+		return ""
+	}
+	return f.Time.Format(time.RFC3339)
+}
+
+func (f TimeFlag) Set(value string) error {
+	if value == "" {
+		*f.Time = time.Time{}
+		return nil
+	}
+	for _, format := range timeFlagFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			*f.Time = t.UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time '%s', expected 2006-01-02T15:04:05Z", value)
+}
+
 var BaseOptions = _BaseOptions{}
 var ImportOptions = _ImportOptions{}
+var RunOptions = _RunOptions{}
+
+type _RollbackOptions struct {
+	ToSeq int
+}
+
+var RollbackOptions = _RollbackOptions{}
 
 func addBaseFlags(flags *flag.FlagSet) {
 	flags.StringVar(&BaseOptions.Connection, "connection", "", "connection parameters")
@@ -178,6 +420,7 @@ func addBaseFlags(flags *flag.FlagSet) {
 	flags.IntVar(&BaseOptions.Srid, "srid", defaultSrid, "srs id")
 	flags.StringVar(&BaseOptions.LimitTo, "limitto", "", "limit to geometries")
 	flags.Float64Var(&BaseOptions.LimitToCacheBuffer, "limittocachebuffer", 0.0, "limit to buffer for cache")
+	flags.Var(&BaseOptions.Bbox, "bbox", "limit to minx,miny,maxx,maxy (EPSG:4326)")
 	flags.StringVar(&BaseOptions.ConfigFile, "config", "", "config (json)")
 	flags.StringVar(&BaseOptions.Httpprofile, "httpprofile", "", "bind address for profile server")
 	flags.BoolVar(&BaseOptions.Quiet, "quiet", false, "quiet log output")
@@ -199,8 +442,26 @@ func UsageDiff() {
 }
 
 func UsageRun() {
-	fmt.Fprintf(os.Stderr, "Usage: %s %s [args] [.osc.gz, ...]\n\n", os.Args[0], os.Args[1])
-	DiffFlags.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "Usage: %s %s [args]\n\n", os.Args[0], os.Args[1])
+	RunFlags.PrintDefaults()
+	os.Exit(2)
+}
+
+func UsagePrune() {
+	fmt.Fprintf(os.Stderr, "Usage: %s cache prune [args]\n\n", os.Args[0])
+	PruneFlags.PrintDefaults()
+	os.Exit(2)
+}
+
+func UsageRollback() {
+	fmt.Fprintf(os.Stderr, "Usage: %s %s [args]\n\n", os.Args[0], os.Args[1])
+	RollbackFlags.PrintDefaults()
+	os.Exit(2)
+}
+
+func UsageReconcile() {
+	fmt.Fprintf(os.Stderr, "Usage: %s %s [args]\n\n", os.Args[0], os.Args[1])
+	ReconcileFlags.PrintDefaults()
 	os.Exit(2)
 }
 
@@ -208,13 +469,19 @@ func init() {
 	ImportFlags.Usage = UsageImport
 	DiffFlags.Usage = UsageDiff
 	RunFlags.Usage = UsageRun
+	PruneFlags.Usage = UsagePrune
+	RollbackFlags.Usage = UsageRollback
+	ReconcileFlags.Usage = UsageReconcile
 
 	addBaseFlags(DiffFlags)
 	addBaseFlags(ImportFlags)
 	addBaseFlags(RunFlags)
+	addBaseFlags(PruneFlags)
+	addBaseFlags(RollbackFlags)
+	addBaseFlags(ReconcileFlags)
 	ImportFlags.BoolVar(&ImportOptions.Overwritecache, "overwritecache", false, "overwritecache")
 	ImportFlags.BoolVar(&ImportOptions.Appendcache, "appendcache", false, "append cache")
-	ImportFlags.StringVar(&ImportOptions.Read, "read", "", "read")
+	ImportFlags.Var(&ImportOptions.Read, "read", "read OSM file(s), separate multiple files with comma")
 	ImportFlags.BoolVar(&ImportOptions.Write, "write", false, "write")
 	ImportFlags.BoolVar(&ImportOptions.Optimize, "optimize", false, "optimize")
 	ImportFlags.BoolVar(&ImportOptions.Diff, "diff", false, "enable diff support")
@@ -225,16 +492,36 @@ func init() {
 
 	DiffFlags.StringVar(&BaseOptions.ExpireTilesDir, "expiretiles-dir", "", "write expire tiles into dir")
 	DiffFlags.IntVar(&BaseOptions.ExpireTilesZoom, "expiretiles-zoom", 14, "write expire tiles in this zoom level")
+	DiffFlags.IntVar(&BaseOptions.UndoSeqs, "undo-seqs", 0, "record undo logs and keep them for the last n sequences")
+	DiffFlags.StringVar(&BaseOptions.OverlayDir, "overlay-dir", "", "apply local .osc files from dir after each diff")
 
 	RunFlags.StringVar(&BaseOptions.ExpireTilesDir, "expiretiles-dir", "", "write expire tiles into dir")
 	RunFlags.IntVar(&BaseOptions.ExpireTilesZoom, "expiretiles-zoom", 14, "write expire tiles in this zoom level")
+	RunFlags.IntVar(&BaseOptions.UndoSeqs, "undo-seqs", 0, "record undo logs and keep them for the last n sequences")
+	RunFlags.StringVar(&BaseOptions.OverlayDir, "overlay-dir", "", "apply local .osc files from dir after each diff")
 	RunFlags.DurationVar(&BaseOptions.ReplicationInterval, "replication-interval", time.Minute, "replication interval as duration (1m, 1h, 24h)")
+	RollbackFlags.StringVar(&BaseOptions.ExpireTilesDir, "expiretiles-dir", "", "write expire tiles into dir")
+	RollbackFlags.IntVar(&BaseOptions.ExpireTilesZoom, "expiretiles-zoom", 14, "write expire tiles in this zoom level")
+	RollbackFlags.IntVar(&RollbackOptions.ToSeq, "to-seq", 0, "roll back all diffs after this sequence")
+	ReconcileFlags.StringVar(&BaseOptions.ExpireTilesDir, "expiretiles-dir", "", "write expire tiles into dir")
+	ReconcileFlags.IntVar(&BaseOptions.ExpireTilesZoom, "expiretiles-zoom", 14, "write expire tiles in this zoom level")
+
+	RunFlags.StringVar(&RunOptions.FromDir, "from-dir", "", "read diffs from local replication directory instead of downloading")
+	RunFlags.IntVar(&RunOptions.UntilSeq, "until-seq", 0, "stop after importing this sequence")
+	RunFlags.Var(TimeFlag{&RunOptions.UntilTime}, "until-time", "stop after importing the first diff at or after this time (UTC)")
+	RunFlags.BoolVar(&RunOptions.Once, "once", false, "stop after importing the next diff")
+	RunFlags.IntVar(&RunOptions.CatchUp, "catchup", 1, "merge up to n pending diffs into one transaction")
+	RunFlags.IntVar(&RunOptions.KeepSeqs, "keep-seqs", 0, "remove downloaded diffs, except for the last n sequences")
+	RunFlags.DurationVar(&RunOptions.KeepAge, "keep-age", 0, "remove downloaded diffs after this duration (e.g. 72h)")
+	RunFlags.DurationVar(&RunOptions.ReconcileInterval, "reconcile-interval", 0, "re-check pending elements after this duration (e.g. 1h)")
 }
 
 func ParseImport(args []string) {
 	if len(args) == 0 {
 		UsageImport()
 	}
+	// -read can be repeated, reset files from previous calls
+	ImportOptions.Read = nil
 	err := ImportFlags.Parse(args)
 	if err != nil {
 		log.Fatal(err)
@@ -286,12 +573,90 @@ func ParseRunImport(args []string) {
 	}
 
 	errs := BaseOptions.check()
+	if RunOptions.UntilSeq < 0 {
+		errs = append(errs, errors.New("-until-seq needs to be positive"))
+	}
+	if RunOptions.CatchUp < 1 {
+		errs = append(errs, errors.New("-catchup needs to be 1 or larger"))
+	}
+	for _, f := range BaseOptions.ReplicationFeeds {
+		if f.Url == "" || f.Interval.Duration < time.Minute {
+			errs = append(errs, errors.New("replication_feeds need an url and an interval of at least 1m"))
+			break
+		}
+	}
 	if len(errs) != 0 {
 		reportErrors(errs)
 		UsageRun()
 	}
 }
 
+func ParsePrune(args []string) {
+	if len(args) == 0 {
+		UsagePrune()
+	}
+	err := PruneFlags.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = BaseOptions.updateFromConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	errs := BaseOptions.check()
+	if len(errs) != 0 {
+		reportErrors(errs)
+		UsagePrune()
+	}
+}
+
+func ParseRollback(args []string) {
+	if len(args) == 0 {
+		UsageRollback()
+	}
+	err := RollbackFlags.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = BaseOptions.updateFromConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	errs := BaseOptions.check()
+	if RollbackOptions.ToSeq <= 0 {
+		errs = append(errs, errors.New("missing -to-seq"))
+	}
+	if len(errs) != 0 {
+		reportErrors(errs)
+		UsageRollback()
+	}
+}
+
+func ParseReconcile(args []string) {
+	if len(args) == 0 {
+		UsageReconcile()
+	}
+	err := ReconcileFlags.Parse(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = BaseOptions.updateFromConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	errs := BaseOptions.check()
+	if len(errs) != 0 {
+		reportErrors(errs)
+		UsageReconcile()
+	}
+}
+
 func reportErrors(errs []error) {
 	fmt.Println("errors in config/options:")
 	for _, err := range errs {
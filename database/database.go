@@ -3,6 +3,7 @@ package database
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/omniscale/imposm3/element"
 	"github.com/omniscale/imposm3/geom"
@@ -15,6 +16,10 @@ type Config struct {
 	ImportSchema     string
 	ProductionSchema string
 	BackupSchema     string
+	// RegionSchemas maps -limitto region names to a separate schema.
+	// Geometries of these regions are inserted into the tables of
+	// this schema, see geom.Geometry.Region.
+	RegionSchemas map[string]string
 }
 
 type DB interface {
@@ -63,6 +68,13 @@ type Deleter interface {
 	DeleteElem(element.OSMElem) error
 }
 
+// Historian is implemented by databases that support history tables.
+type Historian interface {
+	// SetHistoryTime sets the timestamp for valid_from/valid_to of
+	// all following inserts and deletes.
+	SetHistoryTime(time.Time)
+}
+
 type Optimizer interface {
 	Optimize() error
 }
@@ -90,6 +102,9 @@ func Open(conf Config, m *mapping.Mapping) (DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(conf.RegionSchemas) > 0 {
+		return openRegions(db, conf, m, newFunc)
+	}
 	return db, nil
 }
 
@@ -107,6 +122,8 @@ func (n *nullDb) InsertPolygon(element.OSMElem, geom.Geometry, []mapping.Match)
 func (n *nullDb) InsertRelationMember(element.Relation, element.Member, geom.Geometry, []mapping.Match) error {
 	return nil
 }
+func (n *nullDb) Delete(int64, interface{}) error  { return nil }
+func (n *nullDb) DeleteElem(element.OSMElem) error { return nil }
 
 func newNullDb(conf Config, m *mapping.Mapping) (DB, error) {
 	return &nullDb{}, nil
@@ -69,6 +69,13 @@ func (t *validatedGeometryType) GeneralizeSql(colSpec *ColumnSpec, spec *General
 	)
 }
 
+// derivedGeometryType is for additional geometry columns (e.g. centroid).
+// The column is created with the table and is not the main geometry
+// column of the table.
+type derivedGeometryType struct {
+	geometryType
+}
+
 var pgTypes map[string]ColumnType
 
 func init() {
@@ -82,5 +89,8 @@ func init() {
 		"hstore_string":      &simpleColumnType{"HSTORE"},
 		"geometry":           &geometryType{"GEOMETRY"},
 		"validated_geometry": &validatedGeometryType{geometryType{"GEOMETRY"}},
+		"point_geometry":     &derivedGeometryType{geometryType{"GEOMETRY(POINT)"}},
+		"polygon_geometry":   &derivedGeometryType{geometryType{"GEOMETRY(POLYGON)"}},
+		"text":               &simpleColumnType{"TEXT"},
 	}
 }
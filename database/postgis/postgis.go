@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	pq "github.com/lib/pq"
 	"github.com/omniscale/imposm3/database"
@@ -40,6 +41,13 @@ func createTable(tx *sql.Tx, spec TableSpec) error {
 	var sql string
 	var err error
 
+	for _, view := range spec.viewNames() {
+		err = dropViewIfExists(tx, spec.Schema, view)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = dropTableIfExists(tx, spec.Schema, spec.FullName)
 	if err != nil {
 		return err
@@ -55,6 +63,21 @@ func createTable(tx *sql.Tx, spec TableSpec) error {
 	if err != nil {
 		return err
 	}
+
+	if spec.History {
+		sql = spec.CreateCurrentViewSQL()
+		_, err = tx.Exec(sql)
+		if err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	if spec.Network {
+		sql = spec.CreateVerticesViewSQL()
+		_, err = tx.Exec(sql)
+		if err != nil {
+			return &SQLError{sql, err}
+		}
+	}
 	return nil
 }
 
@@ -298,9 +321,17 @@ func (pg *PostGIS) generalizeTable(table *GeneralizedTableSpec) error {
 	}
 	defer rollbackIfTx(&tx)
 
-	var where string
+	var conds []string
+	if table.SourceGeneralized == nil && table.Source.History {
+		// only generalize current rows
+		conds = append(conds, `"valid_to" IS NULL`)
+	}
 	if table.Where != "" {
-		where = " WHERE " + table.Where
+		conds = append(conds, "("+table.Where+")")
+	}
+	var where string
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
 	}
 	var cols []string
 
@@ -430,6 +461,8 @@ type PostGIS struct {
 
 	updateIdsMu sync.Mutex
 	updatedIds  map[string][]int64
+
+	historyTime time.Time
 }
 
 func (pg *PostGIS) Open() error {
@@ -575,6 +608,11 @@ func (pg *PostGIS) EnableGeneralizeUpdates() {
 	pg.updatedIds = make(map[string][]int64)
 }
 
+// SetHistoryTime sets the valid_from/valid_to timestamp for history tables.
+func (pg *PostGIS) SetHistoryTime(t time.Time) {
+	pg.historyTime = t
+}
+
 func (pg *PostGIS) Begin() error {
 	var err error
 	pg.txRouter, err = newTxRouter(pg, false)
@@ -22,6 +22,7 @@ func (pg *PostGIS) rotate(source, dest, backup string) error {
 	defer rollbackIfTx(&tx)
 
 	for _, tableName := range pg.tableNames() {
+		viewNames := pg.viewNames(tableName)
 		tableName = pg.Prefix + tableName
 
 		log.Printf("Rotating %s from %s -> %s -> %s", tableName, source, dest, backup)
@@ -47,11 +48,24 @@ func (pg *PostGIS) rotate(source, dest, backup string) error {
 		if destExists {
 			log.Printf("backup of %s, to %s", tableName, backup)
 			if backupExists {
+				for _, viewName := range viewNames {
+					err = dropViewIfExists(tx, backup, viewName)
+					if err != nil {
+						return err
+					}
+				}
 				err = dropTableIfExists(tx, backup, tableName)
 				if err != nil {
 					return err
 				}
 			}
+			for _, viewName := range viewNames {
+				sql := fmt.Sprintf(`ALTER VIEW IF EXISTS "%s"."%s" SET SCHEMA "%s"`, dest, viewName, backup)
+				_, err = tx.Exec(sql)
+				if err != nil {
+					return err
+				}
+			}
 			sql := fmt.Sprintf(`ALTER TABLE "%s"."%s" SET SCHEMA "%s"`, dest, tableName, backup)
 			_, err = tx.Exec(sql)
 			if err != nil {
@@ -59,6 +73,13 @@ func (pg *PostGIS) rotate(source, dest, backup string) error {
 			}
 		}
 
+		for _, viewName := range viewNames {
+			sql := fmt.Sprintf(`ALTER VIEW IF EXISTS "%s"."%s" SET SCHEMA "%s"`, source, viewName, dest)
+			_, err = tx.Exec(sql)
+			if err != nil {
+				return err
+			}
+		}
 		sql := fmt.Sprintf(`ALTER TABLE "%s"."%s" SET SCHEMA "%s"`, source, tableName, dest)
 		_, err = tx.Exec(sql)
 		if err != nil {
@@ -92,6 +113,7 @@ func (pg *PostGIS) RemoveBackup() error {
 	backup := pg.Config.BackupSchema
 
 	for _, tableName := range pg.tableNames() {
+		viewNames := pg.viewNames(tableName)
 		tableName = pg.Prefix + tableName
 
 		backupExists, err := tableExists(tx, backup, tableName)
@@ -100,6 +122,12 @@ func (pg *PostGIS) RemoveBackup() error {
 		}
 		if backupExists {
 			log.Printf("removing backup of %s from %s", tableName, backup)
+			for _, viewName := range viewNames {
+				err = dropViewIfExists(tx, backup, viewName)
+				if err != nil {
+					return err
+				}
+			}
 			err = dropTableIfExists(tx, backup, tableName)
 			if err != nil {
 				return err
@@ -127,3 +155,12 @@ func (pg *PostGIS) tableNames() []string {
 	}
 	return names
 }
+
+// viewNames returns the names of all views of the table (without prefix),
+// e.g. the view with the current rows of a history table.
+func (pg *PostGIS) viewNames(name string) []string {
+	if spec, ok := pg.Tables[name]; ok {
+		return spec.viewNames()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package postgis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+func isSpatialJoinColumn(col ColumnSpec) bool {
+	return col.FieldType.Name == "spatial_join" || col.FieldType.Name == "spatial_join_id"
+}
+
+// UpdateSpatialJoins recomputes the spatial_join columns of all rows that
+// intersect one of the bounds. The rows are updated within the current
+// transaction.
+func (pg *PostGIS) UpdateSpatialJoins(bounds []geos.Bounds) error {
+	if len(bounds) == 0 {
+		return nil
+	}
+	if pg.txRouter == nil || pg.txRouter.tx == nil {
+		return errors.New("spatial_join update requires a transaction")
+	}
+	defer log.StopStep(log.StartStep("Updating spatial_join columns"))
+	for _, spec := range pg.Tables {
+		if err := pg.updateSpatialJoins(spec, bounds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pg *PostGIS) updateSpatialJoins(spec *TableSpec, bounds []geos.Bounds) error {
+	var cols []ColumnSpec
+	geomCol := ""
+	for _, col := range spec.Columns {
+		if isSpatialJoinColumn(col) {
+			cols = append(cols, col)
+		} else if geomCol == "" && col.Type.Name() == "GEOMETRY" {
+			geomCol = col.Name
+		}
+	}
+	if len(cols) == 0 || geomCol == "" {
+		return nil
+	}
+	tx := pg.txRouter.tx
+
+	// collect all rows first, a row can intersect multiple bounds
+	wkbs := make(map[string][]byte)
+	for _, b := range bounds {
+		sql := fmt.Sprintf(`SELECT ctid::text, ST_AsBinary("%s") FROM "%s"."%s" WHERE "%s" && ST_MakeEnvelope($1, $2, $3, $4, %d)`,
+			geomCol, spec.Schema, spec.FullName, geomCol, spec.Srid)
+		if spec.History {
+			sql += ` AND "valid_to" IS NULL`
+		}
+		rows, err := tx.Query(sql, b.MinX, b.MinY, b.MaxX, b.MaxY)
+		if err != nil {
+			return &SQLError{sql, err}
+		}
+		for rows.Next() {
+			var ctid string
+			var wkb []byte
+			if err := rows.Scan(&ctid, &wkb); err != nil {
+				rows.Close()
+				return err
+			}
+			wkbs[ctid] = wkb
+		}
+		if err := rows.Err(); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	if len(wkbs) == 0 {
+		return nil
+	}
+
+	var sets []string
+	for i, col := range cols {
+		sets = append(sets, fmt.Sprintf(`"%s" = $%d`, col.Name, i+1))
+	}
+	sql := fmt.Sprintf(`UPDATE "%s"."%s" SET %s WHERE ctid = $%d::tid`,
+		spec.Schema, spec.FullName, strings.Join(sets, ", "), len(cols)+1)
+	stmt, err := tx.Prepare(sql)
+	if err != nil {
+		return &SQLError{sql, err}
+	}
+	defer stmt.Close()
+
+	g := geos.NewGeos()
+	defer g.Finish()
+	for ctid, wkb := range wkbs {
+		geosGeom := g.FromWkb(wkb)
+		if geosGeom == nil {
+			log.Warnf("unable to read geometry of %s for spatial_join update", spec.FullName)
+			continue
+		}
+		geometry := geom.Geometry{Geom: geosGeom}
+		values := make([]interface{}, 0, len(cols)+1)
+		for _, col := range cols {
+			values = append(values, col.FieldType.Func("", &element.OSMElem{}, &geometry, mapping.Match{}))
+		}
+		values = append(values, ctid)
+		g.Destroy(geosGeom)
+		if _, err := stmt.Exec(values...); err != nil {
+			return &SQLError{sql, err}
+		}
+	}
+	return nil
+}
@@ -20,6 +20,10 @@ type TableSpec struct {
 	GeometryType    string
 	Srid            int
 	Generalizations []*GeneralizedTableSpec
+	// History tables keep old rows with valid_from/valid_to timestamps.
+	History bool
+	// Network tables have an additional view with all vertices.
+	Network bool
 }
 
 type GeneralizedTableSpec struct {
@@ -60,6 +64,12 @@ func (spec *TableSpec) CreateTableSQL() string {
 		}
 		cols = append(cols, col.AsSQL())
 	}
+	if spec.History {
+		cols = append(cols,
+			`"valid_from" TIMESTAMP WITH TIME ZONE`,
+			`"valid_to" TIMESTAMP WITH TIME ZONE`,
+		)
+	}
 	columnSQL := strings.Join(cols, ",\n")
 	return fmt.Sprintf(`
         CREATE TABLE IF NOT EXISTS "%s"."%s" (
@@ -79,6 +89,11 @@ func (spec *TableSpec) InsertSQL() string {
 		vars = append(vars,
 			col.Type.PrepareInsertSql(len(vars)+1, spec))
 	}
+	if spec.History {
+		// the history time is appended to each row by syncTableTx
+		cols = append(cols, `"valid_from"`)
+		vars = append(vars, fmt.Sprintf("$%d", len(vars)+1))
+	}
 	columns := strings.Join(cols, ", ")
 	placeholders := strings.Join(vars, ", ")
 
@@ -117,6 +132,15 @@ func (spec *TableSpec) DeleteSQL() string {
 		panic("missing id column")
 	}
 
+	if spec.History {
+		// close current row instead of removing it
+		return fmt.Sprintf(`UPDATE "%s"."%s" SET "valid_to" = $2 WHERE "%s" = $1 AND "valid_to" IS NULL`,
+			spec.Schema,
+			spec.FullName,
+			idColumnName,
+		)
+	}
+
 	return fmt.Sprintf(`DELETE FROM "%s"."%s" WHERE "%s" = $1`,
 		spec.Schema,
 		spec.FullName,
@@ -127,8 +151,15 @@ func (spec *TableSpec) DeleteSQL() string {
 func NewTableSpec(pg *PostGIS, t *mapping.Table) *TableSpec {
 	var geomType string
 	switch t.Type {
-	case mapping.RelationMemberTable:
+	case mapping.RelationMemberTable, mapping.RestrictionTable:
 		geomType = "geometry"
+	case mapping.RelationTable:
+		geomType = string(t.Type)
+		if t.RelationGeometry != "" {
+			geomType = "geometry"
+		}
+	case mapping.NetworkTable:
+		geomType = string(mapping.LineStringTable)
 	default:
 		geomType = string(t.Type)
 	}
@@ -139,6 +170,8 @@ func NewTableSpec(pg *PostGIS, t *mapping.Table) *TableSpec {
 		Schema:       pg.Config.ImportSchema,
 		GeometryType: geomType,
 		Srid:         pg.Config.Srid,
+		History:      t.History,
+		Network:      t.Type == mapping.NetworkTable,
 	}
 	for _, field := range t.Fields {
 		fieldType := field.FieldType()
@@ -156,6 +189,76 @@ func NewTableSpec(pg *PostGIS, t *mapping.Table) *TableSpec {
 	return &spec
 }
 
+// CurrentViewName returns the name of the view with the current rows of
+// a history table.
+func (spec *TableSpec) CurrentViewName() string {
+	return spec.FullName + "_current"
+}
+
+// CreateCurrentViewSQL returns the SQL to create the view with all
+// current rows (rows without valid_to) of a history table.
+func (spec *TableSpec) CreateCurrentViewSQL() string {
+	return fmt.Sprintf(`CREATE VIEW "%s"."%s" AS SELECT * FROM "%s"."%s" WHERE "valid_to" IS NULL`,
+		spec.Schema,
+		spec.CurrentViewName(),
+		spec.Schema,
+		spec.FullName,
+	)
+}
+
+// VerticesViewName returns the name of the view with all vertices of a
+// network table.
+func (spec *TableSpec) VerticesViewName() string {
+	return spec.FullName + "_vertices"
+}
+
+// CreateVerticesViewSQL returns the SQL to create the view with the
+// node ID and point of all edge_source and edge_target nodes of a network
+// table.
+func (spec *TableSpec) CreateVerticesViewSQL() string {
+	var sourceCol, targetCol, geomCol string
+	for _, col := range spec.Columns {
+		switch col.FieldType.Name {
+		case "edge_source":
+			sourceCol = col.Name
+		case "edge_target":
+			targetCol = col.Name
+		}
+		if col.Type.Name() == "GEOMETRY" && geomCol == "" {
+			geomCol = col.Name
+		}
+	}
+	where := ""
+	if spec.History {
+		where = ` WHERE "valid_to" IS NULL`
+	}
+	return fmt.Sprintf(`CREATE VIEW "%[1]s"."%[2]s" AS SELECT DISTINCT ON (id) id, geometry FROM (
+            SELECT "%[4]s" AS id, ST_StartPoint("%[6]s") AS geometry FROM "%[1]s"."%[3]s"%[7]s
+            UNION ALL
+            SELECT "%[5]s" AS id, ST_EndPoint("%[6]s") AS geometry FROM "%[1]s"."%[3]s"%[7]s
+        ) AS vertices`,
+		spec.Schema,
+		spec.VerticesViewName(),
+		spec.FullName,
+		sourceCol,
+		targetCol,
+		geomCol,
+		where,
+	)
+}
+
+// viewNames returns the names of all views of the table.
+func (spec *TableSpec) viewNames() []string {
+	var names []string
+	if spec.History {
+		names = append(names, spec.CurrentViewName())
+	}
+	if spec.Network {
+		names = append(names, spec.VerticesViewName())
+	}
+	return names
+}
+
 func NewGeneralizedTableSpec(pg *PostGIS, t *mapping.GeneralizedTable) *GeneralizedTableSpec {
 	spec := GeneralizedTableSpec{
 		Name:       t.Name,
@@ -207,6 +310,10 @@ func (spec *GeneralizedTableSpec) InsertSQL() string {
 	}
 
 	where := fmt.Sprintf(` WHERE "%s" = $1`, idColumnName)
+	if spec.Source.History {
+		// only generalize current rows
+		where += ` AND "valid_to" IS NULL`
+	}
 	if spec.Where != "" {
 		where += " AND (" + spec.Where + ")"
 	}
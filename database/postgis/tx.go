@@ -119,6 +119,7 @@ type syncTableTx struct {
 	DeleteStmt *sql.Stmt
 	InsertSql  string
 	DeleteSql  string
+	history    bool
 }
 
 type tableSpec interface {
@@ -132,6 +133,9 @@ func NewSynchronousTableTx(pg *PostGIS, tableName string, spec tableSpec) TableT
 		Table: tableName,
 		Spec:  spec,
 	}
+	if ts, ok := spec.(*TableSpec); ok {
+		tt.history = ts.History
+	}
 	return tt
 }
 
@@ -164,6 +168,9 @@ func (tt *syncTableTx) Begin(tx *sql.Tx) error {
 }
 
 func (tt *syncTableTx) Insert(row []interface{}) error {
+	if tt.history {
+		row = append(row, tt.Pg.historyTime)
+	}
 	_, err := tt.InsertStmt.Exec(row...)
 	if err != nil {
 		return &SQLInsertError{SQLError{tt.InsertSql, err}, row}
@@ -172,7 +179,12 @@ func (tt *syncTableTx) Insert(row []interface{}) error {
 }
 
 func (tt *syncTableTx) Delete(id int64) error {
-	_, err := tt.DeleteStmt.Exec(id)
+	var err error
+	if tt.history {
+		_, err = tt.DeleteStmt.Exec(id, tt.Pg.historyTime)
+	} else {
+		_, err = tt.DeleteStmt.Exec(id)
+	}
 	if err != nil {
 		return &SQLInsertError{SQLError{tt.DeleteSql, err}, id}
 	}
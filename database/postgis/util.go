@@ -92,6 +92,15 @@ func dropTableIfExists(tx *sql.Tx, schema, table string) error {
 	return nil
 }
 
+func dropViewIfExists(tx *sql.Tx, schema, view string) error {
+	sql := fmt.Sprintf(`DROP VIEW IF EXISTS "%s"."%s"`, schema, view)
+	_, err := tx.Exec(sql)
+	if err != nil {
+		return &SQLError{sql, err}
+	}
+	return nil
+}
+
 // rollbackIfTx rollsback transaction if tx is not nil.
 func rollbackIfTx(tx **sql.Tx) {
 	if *tx != nil {
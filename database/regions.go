@@ -0,0 +1,220 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// regionDb routes inserts into a separate database for each region with a
+// custom schema (see Config.RegionSchemas). All other calls are passed to
+// each database, except for the Deployer methods, which only apply to the
+// default database.
+type regionDb struct {
+	DB
+	// databases by region name
+	regions map[string]DB
+	// all databases, the default database first
+	dbs []DB
+}
+
+// openRegions opens one database for each schema in conf.RegionSchemas.
+// The schema is used as ImportSchema and ProductionSchema.
+func openRegions(def DB, conf Config, m *mapping.Mapping, newFunc func(Config, *mapping.Mapping) (DB, error)) (DB, error) {
+	rdb := &regionDb{
+		DB:      def,
+		regions: make(map[string]DB),
+		dbs:     []DB{def},
+	}
+	bySchema := make(map[string]DB)
+	for region, schema := range conf.RegionSchemas {
+		if db, ok := bySchema[schema]; ok {
+			rdb.regions[region] = db
+			continue
+		}
+		regionConf := conf
+		regionConf.ImportSchema = schema
+		regionConf.ProductionSchema = schema
+		regionConf.RegionSchemas = nil
+		db, err := newFunc(regionConf, m)
+		if err != nil {
+			rdb.Close()
+			return nil, fmt.Errorf("region %s: %v", region, err)
+		}
+		bySchema[schema] = db
+		rdb.regions[region] = db
+		rdb.dbs = append(rdb.dbs, db)
+	}
+	return rdb, nil
+}
+
+func (r *regionDb) db(g geom.Geometry) DB {
+	if db, ok := r.regions[g.Region]; ok {
+		return db
+	}
+	return r.DB
+}
+
+func (r *regionDb) each(f func(DB) error) error {
+	for _, db := range r.dbs {
+		if err := f(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *regionDb) Begin() error { return r.each(func(db DB) error { return db.Begin() }) }
+func (r *regionDb) End() error   { return r.each(func(db DB) error { return db.End() }) }
+func (r *regionDb) Init() error  { return r.each(func(db DB) error { return db.Init() }) }
+
+func (r *regionDb) Abort() error {
+	var firstErr error
+	for _, db := range r.dbs {
+		if err := db.Abort(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *regionDb) Close() error {
+	var firstErr error
+	for _, db := range r.dbs {
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *regionDb) InsertPoint(elem element.OSMElem, g geom.Geometry, matches []mapping.Match) error {
+	return r.db(g).InsertPoint(elem, g, matches)
+}
+
+func (r *regionDb) InsertLineString(elem element.OSMElem, g geom.Geometry, matches []mapping.Match) error {
+	return r.db(g).InsertLineString(elem, g, matches)
+}
+
+func (r *regionDb) InsertPolygon(elem element.OSMElem, g geom.Geometry, matches []mapping.Match) error {
+	return r.db(g).InsertPolygon(elem, g, matches)
+}
+
+func (r *regionDb) InsertRelationMember(rel element.Relation, m element.Member, g geom.Geometry, matches []mapping.Match) error {
+	return r.db(g).InsertRelationMember(rel, m, g, matches)
+}
+
+func (r *regionDb) BeginBulk() error {
+	return r.each(func(db DB) error {
+		if db, ok := db.(BulkBeginner); ok {
+			return db.BeginBulk()
+		}
+		return db.Begin()
+	})
+}
+
+func (r *regionDb) Delete(id int64, matches interface{}) error {
+	return r.each(func(db DB) error {
+		if db, ok := db.(Deleter); ok {
+			return db.Delete(id, matches)
+		}
+		return fmt.Errorf("database %T does not support deletes", db)
+	})
+}
+
+func (r *regionDb) DeleteElem(elem element.OSMElem) error {
+	return r.each(func(db DB) error {
+		if db, ok := db.(Deleter); ok {
+			return db.DeleteElem(elem)
+		}
+		return fmt.Errorf("database %T does not support deletes", db)
+	})
+}
+
+func (r *regionDb) Generalize() error {
+	return r.each(func(db DB) error {
+		if db, ok := db.(Generalizer); ok {
+			return db.Generalize()
+		}
+		return nil
+	})
+}
+
+func (r *regionDb) EnableGeneralizeUpdates() {
+	r.each(func(db DB) error {
+		if db, ok := db.(Generalizer); ok {
+			db.EnableGeneralizeUpdates()
+		}
+		return nil
+	})
+}
+
+func (r *regionDb) GeneralizeUpdates() error {
+	return r.each(func(db DB) error {
+		if db, ok := db.(Generalizer); ok {
+			return db.GeneralizeUpdates()
+		}
+		return nil
+	})
+}
+
+func (r *regionDb) UpdateSpatialJoins(bounds []geos.Bounds) error {
+	return r.each(func(db DB) error {
+		if db, ok := db.(SpatialJoinUpdater); ok {
+			return db.UpdateSpatialJoins(bounds)
+		}
+		return nil
+	})
+}
+
+func (r *regionDb) Finish() error {
+	return r.each(func(db DB) error {
+		if db, ok := db.(Finisher); ok {
+			return db.Finish()
+		}
+		return nil
+	})
+}
+
+func (r *regionDb) Optimize() error {
+	return r.each(func(db DB) error {
+		if db, ok := db.(Optimizer); ok {
+			return db.Optimize()
+		}
+		return nil
+	})
+}
+
+func (r *regionDb) SetHistoryTime(t time.Time) {
+	r.each(func(db DB) error {
+		if db, ok := db.(Historian); ok {
+			db.SetHistoryTime(t)
+		}
+		return nil
+	})
+}
+
+func (r *regionDb) Deploy() error {
+	if db, ok := r.DB.(Deployer); ok {
+		return db.Deploy()
+	}
+	return nil
+}
+
+func (r *regionDb) RevertDeploy() error {
+	if db, ok := r.DB.(Deployer); ok {
+		return db.RevertDeploy()
+	}
+	return nil
+}
+
+func (r *regionDb) RemoveBackup() error {
+	if db, ok := r.DB.(Deployer); ok {
+		return db.RemoveBackup()
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+type recordingDb struct {
+	nullDb
+	schema  string
+	points  []string
+	begins  int
+	deploys int
+}
+
+func (r *recordingDb) Begin() error {
+	r.begins += 1
+	return nil
+}
+
+func (r *recordingDb) InsertPoint(elem element.OSMElem, g geom.Geometry, matches []mapping.Match) error {
+	r.points = append(r.points, g.Region)
+	return nil
+}
+
+func (r *recordingDb) Deploy() error {
+	r.deploys += 1
+	return nil
+}
+func (r *recordingDb) RevertDeploy() error { return nil }
+func (r *recordingDb) RemoveBackup() error { return nil }
+
+func TestRegionDb(t *testing.T) {
+	opened := map[string]*recordingDb{}
+	newFunc := func(conf Config, m *mapping.Mapping) (DB, error) {
+		db := &recordingDb{schema: conf.ImportSchema}
+		opened[conf.ImportSchema] = db
+		return db, nil
+	}
+
+	conf := Config{
+		ImportSchema: "import",
+		RegionSchemas: map[string]string{
+			"north": "osm_north",
+			"south": "osm_south",
+			"east":  "osm_south",
+		},
+	}
+	def, _ := newFunc(conf, nil)
+	db, err := openRegions(def, conf, nil, newFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opened) != 3 {
+		t.Fatal("expected one database per schema", opened)
+	}
+
+	if err := db.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, region := range []string{"north", "south", "east", "west", ""} {
+		if err := db.InsertPoint(element.OSMElem{}, geom.Geometry{Region: region}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.(Deployer).Deploy(); err != nil {
+		t.Fatal(err)
+	}
+
+	for schema, expected := range map[string][]string{
+		"import":    {"west", ""},
+		"osm_north": {"north"},
+		"osm_south": {"south", "east"},
+	} {
+		rdb := opened[schema]
+		if len(rdb.points) != len(expected) {
+			t.Fatal(schema, rdb.points)
+		}
+		for i := range expected {
+			if rdb.points[i] != expected[i] {
+				t.Error(schema, rdb.points)
+			}
+		}
+		if rdb.begins != 1 {
+			t.Error("expected Begin for", schema)
+		}
+	}
+	if opened["import"].deploys != 1 || opened["osm_north"].deploys != 0 {
+		t.Error("expected Deploy only for default database")
+	}
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/spatialjoin"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// SpatialJoinUpdater is implemented by databases that can recompute
+// spatial_join columns.
+type SpatialJoinUpdater interface {
+	// UpdateSpatialJoins recomputes the spatial_join columns of all rows
+	// that intersect one of the bounds.
+	UpdateSpatialJoins([]geos.Bounds) error
+}
+
+// spatialJoinIndexer updates the spatial join index with all polygons that
+// are inserted into or deleted from spatial join source tables. All calls
+// are passed to the wrapped Deleter.
+type spatialJoinIndexer struct {
+	Deleter
+	index *spatialjoin.Index
+}
+
+// NewSpatialJoinIndexer returns a Deleter that updates index before
+// passing all inserts and deletes to db. db can be nil to only update the
+// index. The changes are visible after index.Apply.
+func NewSpatialJoinIndexer(db Deleter, index *spatialjoin.Index) Deleter {
+	if db == nil {
+		db = &nullDb{}
+	}
+	return &spatialJoinIndexer{Deleter: db, index: index}
+}
+
+func (s *spatialJoinIndexer) InsertPolygon(elem element.OSMElem, g geom.Geometry, matches []mapping.Match) error {
+	if g.Geom != nil {
+		for _, m := range matches {
+			if s.index.HasTable(m.Table.Name) {
+				s.index.Add(m.Table.Name, elem.Id, elem.Tags, g.Geom)
+			}
+		}
+	}
+	return s.Deleter.InsertPolygon(elem, g, matches)
+}
+
+func (s *spatialJoinIndexer) Delete(id int64, matches interface{}) error {
+	if matches, ok := matches.([]mapping.Match); ok {
+		for _, m := range matches {
+			if s.index.HasTable(m.Table.Name) {
+				s.index.Remove(m.Table.Name, id)
+			}
+		}
+	}
+	return s.Deleter.Delete(id, matches)
+}
+
+func (s *spatialJoinIndexer) DeleteElem(elem element.OSMElem) error {
+	s.index.RemoveId(elem.Id)
+	return s.Deleter.DeleteElem(elem)
+}
@@ -64,6 +64,32 @@ func (t *validatedGeometryType) GeneralizeSql(colSpec *ColumnSpec, spec *General
 	)
 }
 
+// derivedGeometryType is for additional geometry columns (e.g. centroid).
+// They are created with the table, as they are not the main geometry
+// column of the table.
+type derivedGeometryType struct {
+	geometryType
+}
+
+// isMainGeometryColumn returns true for the main geometry column of the
+// table, which is added after the table was created.
+func isMainGeometryColumn(col ColumnSpec) bool {
+	switch col.Type.(type) {
+	case *geometryType, *validatedGeometryType:
+		return true
+	}
+	return false
+}
+
+// isGeometryColumn returns true for all columns with (E)WKB values.
+func isGeometryColumn(col ColumnSpec) bool {
+	switch col.FieldType.GoType {
+	case "geometry", "validated_geometry", "point_geometry", "polygon_geometry":
+		return true
+	}
+	return false
+}
+
 var mssqlTypes map[string]ColumnType
 
 func init() {
@@ -77,5 +103,8 @@ func init() {
 		"hstore_string":      &simpleColumnType{"NVARCHAR(max)"},
 		"geometry":           &geometryType{"GEOMETRY"},
 		"validated_geometry": &validatedGeometryType{geometryType{"GEOMETRY"}},
+		"point_geometry":     &derivedGeometryType{geometryType{"GEOMETRY"}},
+		"polygon_geometry":   &derivedGeometryType{geometryType{"GEOMETRY"}},
+		"text":               &simpleColumnType{"NVARCHAR(max)"},
 	}
 }
@@ -55,7 +55,7 @@ func (spec *TableSpec) CreateTableSQL() string {
 	}
 
 	for _, col := range spec.Columns {
-		if col.Type.Name() == "GEOMETRY" {
+		if isMainGeometryColumn(col) {
 			continue
 		}
 		cols = append(cols, col.AsSQL())
@@ -131,8 +131,15 @@ func (spec *TableSpec) DeleteSQL() string {
 func NewTableSpec(mssql *Mssql, t *mapping.Table) *TableSpec {
     var geomType string
 	switch t.Type {
-	case mapping.RelationMemberTable:
+	case mapping.RelationMemberTable, mapping.RestrictionTable:
 		geomType = "geometry"
+	case mapping.RelationTable:
+		geomType = string(t.Type)
+		if t.RelationGeometry != "" {
+			geomType = "geometry"
+		}
+	case mapping.NetworkTable:
+		geomType = string(mapping.LineStringTable)
 	default:
 		geomType = string(t.Type)
 	}
@@ -60,7 +60,7 @@ func createTable(tx *sql.Tx, spec TableSpec) error {
 func addGeometryColumn(tx *sql.Tx, tableName string, spec TableSpec) error {
 	colName := ""
 	for _, col := range spec.Columns {
-		if col.Type.Name() == "GEOMETRY" {
+		if isMainGeometryColumn(col) {
 			colName = col.Name
 			break
 		}
@@ -240,7 +240,7 @@ func createIndex(mssql *Mssql, tableName string, columns []ColumnSpec) error {
 				return err
 			}
 		}
-		if col.Type.Name() == "GEOMETRY" {
+		if isMainGeometryColumn(col) {
 			sql := fmt.Sprintf(`CREATE SPATIAL INDEX %s_geom ON %s.%s(%s) USING GEOMETRY_AUTO_GRID
 			WITH( BOUNDING_BOX  = ( xmin  = -20037508.34, ymin  = -20037508.34, xmax  = 20037508.34, ymax  = 20037508.34), CELLS_PER_OBJECT  = 16, STATISTICS_NORECOMPUTE = OFF, ALLOW_ROW_LOCKS = ON, ALLOW_PAGE_LOCKS = ON)`,
 				tableName, mssql.Config.ImportSchema, tableName, col.Name)
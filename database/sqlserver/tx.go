@@ -75,7 +75,7 @@ func (tt *bulkTableTx) Insert(row []interface{}) error {
 	for idx, col := range tt.Spec.Columns {
 
 		//geometryType
-		if col.FieldType.Name == "geometry" || col.FieldType.Name == "validated_geometry" {
+		if isGeometryColumn(col) && row[idx] != nil {
 			wkb, _ := hex.DecodeString(row[idx].(string))
 			udt, err := mssqlclrgeo.WkbToUdtGeo(wkb, false)
 			if err != nil {
@@ -216,7 +216,7 @@ func (tt *syncTableTx) Insert(row []interface{}) error {
 	if tt.Spec2 != nil {
 		for idx, col := range tt.Spec2.Columns {
 			//geometryType
-			if col.FieldType.Name == "geometry" || col.FieldType.Name == "validated_geometry" {
+			if isGeometryColumn(col) && row[idx] != nil {
 				wkb, _ := hex.DecodeString(row[idx].(string))
 				udt, err := mssqlclrgeo.WkbToUdtGeo(wkb, false)
 				if err != nil {
@@ -75,6 +75,7 @@ type Member struct {
 	Role string     `json:"role"`
 	Way  *Way       `json:"-"`
 	Node *Node      `json:"-"`
+	Rel  *Relation  `json:"-"`
 	Elem *OSMElem   `json:"-"`
 }
 
@@ -83,6 +84,20 @@ type Relation struct {
 	Members []Member `json:"members"`
 }
 
+// FlatMembers returns all members of the relation, including the members
+// of all resolved sub-relations (members with Rel). Members of a
+// sub-relation follow directly after the sub-relation member.
+func (r *Relation) FlatMembers() []Member {
+	var members []Member
+	for _, m := range r.Members {
+		members = append(members, m)
+		if m.Rel != nil {
+			members = append(members, m.Rel.FlatMembers()...)
+		}
+	}
+	return members
+}
+
 type Metadata struct {
 	UserId    int
 	UserName  string
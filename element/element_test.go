@@ -67,3 +67,27 @@ func TestIdRefs(t *testing.T) {
 	}
 
 }
+
+func TestFlatMembers(t *testing.T) {
+	sub := &Relation{Members: []Member{
+		{Id: 3, Type: WAY},
+		{Id: 4, Type: RELATION, Rel: &Relation{Members: []Member{{Id: 5, Type: NODE}}}},
+	}}
+	rel := Relation{Members: []Member{
+		{Id: 1, Type: WAY},
+		{Id: 2, Type: RELATION, Rel: sub},
+		{Id: 6, Type: RELATION}, // unresolved
+		{Id: 7, Type: NODE},
+	}}
+
+	members := rel.FlatMembers()
+	expected := []int64{1, 2, 3, 4, 5, 6, 7}
+	if len(members) != len(expected) {
+		t.Fatal(members)
+	}
+	for i, id := range expected {
+		if members[i].Id != id {
+			t.Error(i, members)
+		}
+	}
+}
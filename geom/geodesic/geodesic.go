@@ -0,0 +1,298 @@
+/*
+Package geodesic calculates areas and lengths of geometries in m and m² on
+the WGS84 ellipsoid. The results match ST_Area and ST_Length of the
+geography type of PostGIS.
+*/
+package geodesic
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/omniscale/imposm3/geom/wkb"
+	"github.com/omniscale/imposm3/proj"
+)
+
+// WGS84 ellipsoid
+const (
+	a = 6378137.0
+	f = 1 / 298.257223563
+	b = a * (1 - f)
+)
+
+var (
+	e2 = f * (2 - f)
+	e  = math.Sqrt(e2)
+	// second eccentricity squared
+	ep2 = e2 / (1 - e2)
+	// q of the pole
+	qp = q(1)
+	// radius of the sphere with the same surface as the ellipsoid
+	authalicRadius = a * math.Sqrt(qp/2)
+	// coefficients of the area integral, see i4
+	i4Coeffs = areaCoefficients(ep2)
+)
+
+// Area returns the area of all polygons of the EWKB geometry in m². The
+// geometry needs to be in EPSG:4326 or EPSG:3857.
+func Area(ewkb []byte) (float64, error) {
+	coords, err := decode(ewkb)
+	if err != nil {
+		return 0, err
+	}
+	area := 0.0
+	for _, polygon := range coords.Polygons {
+		for i, ring := range polygon {
+			if i == 0 {
+				area += RingArea(ring)
+			} else {
+				area -= RingArea(ring)
+			}
+		}
+	}
+	return area, nil
+}
+
+// Length returns the length of all linestrings and polygon rings of the
+// EWKB geometry in m. The geometry needs to be in EPSG:4326 or EPSG:3857.
+func Length(ewkb []byte) (float64, error) {
+	coords, err := decode(ewkb)
+	if err != nil {
+		return 0, err
+	}
+	length := 0.0
+	for _, line := range coords.Lines {
+		length += LineLength(line)
+	}
+	for _, polygon := range coords.Polygons {
+		for _, ring := range polygon {
+			length += LineLength(ring)
+		}
+	}
+	return length, nil
+}
+
+// decode returns the coordinates of ewkb in EPSG:4326.
+func decode(ewkb []byte) (*wkb.Coords, error) {
+	coords, err := wkb.DecodeCoords(ewkb)
+	if err != nil {
+		return nil, err
+	}
+	switch coords.Srid {
+	case 4326:
+	case 3857:
+		toWgs := func(line [][2]float64) {
+			for i, c := range line {
+				line[i][0], line[i][1] = proj.MercToWgs(c[0], c[1])
+			}
+		}
+		for _, line := range coords.Lines {
+			toWgs(line)
+		}
+		for _, polygon := range coords.Polygons {
+			for _, ring := range polygon {
+				toWgs(ring)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SRID %d for geodesic calculations", coords.Srid)
+	}
+	return coords, nil
+}
+
+// q is used to calculate the authalic latitude for sinLat.
+func q(sinLat float64) float64 {
+	esin := e * sinLat
+	return (1 - e2) * (sinLat/(1-esin*esin) - 1/(2*e)*math.Log((1-esin)/(1+esin)))
+}
+
+// RingArea returns the area of a ring of long/lat coordinates in m². The
+// edges of the ring are geodesics on the ellipsoid, see C. F. F. Karney,
+// Algorithms for geodesics, J. Geodesy 87, 43-55 (2013).
+func RingArea(ring [][2]float64) float64 {
+	if len(ring) < 3 {
+		return 0
+	}
+	area := 0.0
+	crossings := 0
+	edge := func(p1, p2 [2]float64) {
+		_, s12 := inverse(p1[0], p1[1], p2[0], p2[1])
+		area += s12
+		crossings += transit(p1[0], p2[0])
+	}
+	for i := 0; i < len(ring)-1; i++ {
+		edge(ring[i], ring[i+1])
+	}
+	if ring[0] != ring[len(ring)-1] {
+		edge(ring[len(ring)-1], ring[0])
+	}
+
+	// rings around a pole cross the prime meridian an odd number of times
+	total := 4 * math.Pi * authalicRadius * authalicRadius
+	if crossings%2 != 0 {
+		if area < 0 {
+			area += total / 2
+		} else {
+			area -= total / 2
+		}
+	}
+	return math.Abs(math.Remainder(area, total))
+}
+
+// transit returns 1 or -1 if the edge from long1 to long2 crosses the
+// prime meridian eastwards or westwards, 0 otherwise.
+func transit(long1, long2 float64) int {
+	long1 = math.Remainder(long1, 360)
+	long2 = math.Remainder(long2, 360)
+	dLong := math.Remainder(long2-long1, 360)
+	if long1 <= 0 && long2 > 0 && dLong > 0 {
+		return 1
+	}
+	if long2 <= 0 && long1 > 0 && dLong < 0 {
+		return -1
+	}
+	return 0
+}
+
+// areaCoefficients returns the coefficients d of the power series of
+// (t(ep2) - t(x)) / (ep2 - x) in x, with t(x) = x + sqrt(1/x + 1) *
+// asinh(sqrt(x)) (Karney, eq. 60-61).
+func areaCoefficients(ep2 float64) []float64 {
+	const n = 12
+	// power series of t(x) from the series of sqrt(1+x) and
+	// asinh(sqrt(x))/sqrt(x)
+	sqrtCoeffs := make([]float64, n)
+	asinhCoeffs := make([]float64, n)
+	sqrtCoeffs[0], asinhCoeffs[0] = 1, 1
+	c := 1.0
+	for i := 1; i < n; i++ {
+		sqrtCoeffs[i] = sqrtCoeffs[i-1] * (1.5 - float64(i)) / float64(i)
+		c *= -float64(2*i-1) / float64(2*i)
+		asinhCoeffs[i] = c / float64(2*i+1)
+	}
+	t := make([]float64, n)
+	for i := range t {
+		for j := 0; j <= i; j++ {
+			t[i] += sqrtCoeffs[j] * asinhCoeffs[i-j]
+		}
+	}
+	t[1] += 1
+
+	d := make([]float64, n-1)
+	for m := range d {
+		y := 1.0
+		for i := m + 1; i < n; i++ {
+			d[m] += t[i] * y
+			y *= ep2
+		}
+	}
+	return d
+}
+
+// i4 returns the integral I4 of the area of a geodesic (Karney, eq. 60)
+// at the arc length sigma from the equator, for k2 = ep2 * cos²(alpha0).
+func i4(k2, sigma float64) float64 {
+	u := math.Cos(sigma)
+	u2 := 1 - u*u
+	// p is the integral of (1-v²)^m from 0 to u
+	p := u
+	pow := 1.0
+	k2m := 1.0
+	sum := 0.0
+	for m, d := range i4Coeffs {
+		if m > 0 {
+			pow *= u2
+			k2m *= k2
+			p = (u*pow + 2*float64(m)*p) / float64(2*m+1)
+		}
+		sum += d * k2m * p
+	}
+	return sum / 2
+}
+
+// LineLength returns the length of a line of long/lat coordinates in m.
+func LineLength(line [][2]float64) float64 {
+	length := 0.0
+	for i := 1; i < len(line); i++ {
+		length += Distance(line[i-1][0], line[i-1][1], line[i][0], line[i][1])
+	}
+	return length
+}
+
+// Distance returns the geodesic distance between two long/lat
+// coordinates in m, calculated with the inverse formula of Vincenty.
+// Falls back to the distance on the authalic sphere for nearly antipodal
+// points, where the formula does not converge.
+func Distance(long1, lat1, long2, lat2 float64) float64 {
+	dist, _ := inverse(long1, lat1, long2, lat2)
+	return dist
+}
+
+// inverse returns the geodesic distance between two long/lat coordinates
+// in m and the signed area between the geodesic and the equator in m².
+// The area of nearly antipodal points is only approximated, see Distance.
+func inverse(long1, lat1, long2, lat2 float64) (dist, area float64) {
+	const rad = math.Pi / 180
+	l := math.Remainder(long2-long1, 360) * rad
+	u1 := math.Atan((1 - f) * math.Tan(lat1*rad))
+	u2 := math.Atan((1 - f) * math.Tan(lat2*rad))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	lambda := l
+	converged := false
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, sinAlpha, cos2Alpha, cos2SigmaM float64
+	for i := 0; i < 100; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		sinSigma = math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return 0, 0 // same points
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha = cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0.0 // on the equator
+		if cos2Alpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		c := f / 16 * cos2Alpha * (4 + f*(4-3*cos2Alpha))
+		prev := lambda
+		lambda = l + (1-c)*f*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+
+	if converged {
+		u2sq := cos2Alpha * (a*a - b*b) / (b * b)
+		bigA := 1 + u2sq/16384*(4096+u2sq*(-768+u2sq*(320-175*u2sq)))
+		bigB := u2sq / 1024 * (256 + u2sq*(-128+u2sq*(74-47*u2sq)))
+		deltaSigma := bigB * sinSigma * (cos2SigmaM + bigB/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			bigB/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		dist = b * bigA * (sigma - deltaSigma)
+	} else {
+		dist = sphericalDistance(long1, lat1, long2, lat2)
+	}
+
+	// area between the geodesic and the equator (Karney, eq. 58), from
+	// the azimuths at both points and the equatorial azimuth alpha0
+	alpha1 := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	alpha2 := math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)
+	cosAlpha0 := math.Sqrt(cos2Alpha)
+	sigma1 := math.Atan2(sinU1, cosU1*math.Cos(alpha1))
+	k2 := ep2 * cos2Alpha
+	area = authalicRadius*authalicRadius*math.Remainder(alpha2-alpha1, 2*math.Pi) +
+		e2*a*a*cosAlpha0*sinAlpha*(i4(k2, sigma1+sigma)-i4(k2, sigma1))
+	return dist, area
+}
+
+func sphericalDistance(long1, lat1, long2, lat2 float64) float64 {
+	const rad = math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLong := (long2 - long1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * authalicRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
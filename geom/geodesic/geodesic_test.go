@@ -0,0 +1,143 @@
+package geodesic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/omniscale/imposm3/proj"
+)
+
+func TestDistance(t *testing.T) {
+	for _, tc := range []struct {
+		long1, lat1, long2, lat2 float64
+		expected                 float64
+	}{
+		// Flinders Peak to Buninyong, from Vincenty's paper
+		{144.42486789, -37.95103342, 143.92649554, -37.65282114, 54972.271},
+		{0, 0, 1, 0, 111319.491},
+		{0, 0, 0, 1, 110574.389},
+		{179.5, 0, -179.5, 0, 111319.491},
+		{10, 53, 10, 53, 0},
+	} {
+		if d := Distance(tc.long1, tc.lat1, tc.long2, tc.lat2); math.Abs(d-tc.expected) > 0.001 {
+			t.Errorf("unexpected distance %f for %v", d, tc)
+		}
+	}
+	// nearly antipodal
+	if d := Distance(0, 0, 179.9, 0.1); d < 19950000 || d > 20010000 {
+		t.Error("unexpected distance for antipodal points", d)
+	}
+}
+
+func TestAuthalicRadius(t *testing.T) {
+	// surface of the WGS84 ellipsoid: 510065621.724 km²
+	if area := 4 * math.Pi * authalicRadius * authalicRadius; math.Abs(area-510065621724000) > 1e6 {
+		t.Error("unexpected surface", area)
+	}
+}
+
+// authalic returns the authalic latitude (in radians) of lat (in
+// degrees).
+func authalic(lat float64) float64 {
+	return math.Asin(q(math.Sin(lat*math.Pi/180)) / qp)
+}
+
+// latBandArea returns the area between two parallels on the ellipsoid.
+func latBandArea(lat1, lat2, dLong float64) float64 {
+	return authalicRadius * authalicRadius * dLong * math.Pi / 180 *
+		(math.Sin(authalic(lat2)) - math.Sin(authalic(lat1)))
+}
+
+func TestRingArea(t *testing.T) {
+	ring := [][2]float64{{10, 53}, {10.01, 53}, {10.01, 53.01}, {10, 53.01}, {10, 53}}
+	expected := latBandArea(53, 53.01, 0.01)
+	if area := RingArea(ring); math.Abs(area-expected)/expected > 1e-5 {
+		t.Error("unexpected area", area, expected)
+	}
+	// counter-clockwise and without closing coordinate
+	if area := RingArea([][2]float64{{10, 53}, {10, 53.01}, {10.01, 53.01}, {10.01, 53}}); math.Abs(area-expected)/expected > 1e-5 {
+		t.Error("unexpected area", area, expected)
+	}
+	// crossing the antimeridian
+	ring = [][2]float64{{179.995, -10}, {-179.995, -10}, {-179.995, -9.99}, {179.995, -9.99}, {179.995, -10}}
+	expected = latBandArea(-10, -9.99, 0.01)
+	if area := RingArea(ring); math.Abs(area-expected)/expected > 1e-5 {
+		t.Error("unexpected area", area, expected)
+	}
+}
+
+func TestRingAreaGeography(t *testing.T) {
+	for _, tc := range []struct {
+		ring     [][2]float64
+		expected float64
+	}{
+		// ST_Area('POLYGON((0 0,1 0,1 1,0 1,0 0))'::geography)
+		{[][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}, 12308778361.469},
+		{[][2]float64{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}}, 12308778361.469},
+		{[][2]float64{{179.5, 0}, {-179.5, 0}, {-179.5, 1}, {179.5, 1}, {179.5, 0}}, 12308778361.469},
+		// octant, 1/8 of the surface of the WGS84 ellipsoid
+		{[][2]float64{{0, 0}, {90, 0}, {0, 90}, {0, 0}}, 510065621724088.5 / 8},
+	} {
+		if area := RingArea(tc.ring); math.Abs(area-tc.expected) > 0.1 {
+			t.Errorf("unexpected area %f for %v, expected %f", area, tc.ring, tc.expected)
+		}
+	}
+
+	// around the north pole, the geodesics between the vertices are
+	// closer to the pole than the parallel
+	ring := [][2]float64{{0, 89}, {90, 89}, {180, 89}, {-90, 89}, {0, 89}}
+	if area, band := RingArea(ring), latBandArea(89, 90, 360); area >= band || area < band/2 {
+		t.Error("unexpected area around pole", area, band)
+	}
+}
+
+// ewkbPolygon returns the EWKB of a polygon with the rings.
+func ewkbPolygon(srid uint32, rings ...[][2]float64) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(1)
+	binary.Write(buf, binary.LittleEndian, uint32(3|0x20000000))
+	binary.Write(buf, binary.LittleEndian, srid)
+	binary.Write(buf, binary.LittleEndian, uint32(len(rings)))
+	for _, ring := range rings {
+		binary.Write(buf, binary.LittleEndian, uint32(len(ring)))
+		for _, c := range ring {
+			binary.Write(buf, binary.LittleEndian, c)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestAreaLength(t *testing.T) {
+	exterior := [][2]float64{{10, 53}, {10.02, 53}, {10.02, 53.02}, {10, 53.02}, {10, 53}}
+	interior := [][2]float64{{10.005, 53.005}, {10.015, 53.005}, {10.015, 53.015}, {10.005, 53.015}, {10.005, 53.005}}
+	expectedArea := RingArea(exterior) - RingArea(interior)
+	expectedLength := LineLength(exterior) + LineLength(interior)
+
+	area, err := Area(ewkbPolygon(4326, exterior, interior))
+	if err != nil || math.Abs(area-expectedArea) > 1e-6 {
+		t.Error("unexpected area", area, expectedArea, err)
+	}
+	length, err := Length(ewkbPolygon(4326, exterior, interior))
+	if err != nil || math.Abs(length-expectedLength) > 1e-6 {
+		t.Error("unexpected length", length, expectedLength, err)
+	}
+
+	toMerc := func(ring [][2]float64) [][2]float64 {
+		var result [][2]float64
+		for _, c := range ring {
+			x, y := proj.WgsToMerc(c[0], c[1])
+			result = append(result, [2]float64{x, y})
+		}
+		return result
+	}
+	area, err = Area(ewkbPolygon(3857, toMerc(exterior), toMerc(interior)))
+	if err != nil || math.Abs(area-expectedArea) > 1e-3 {
+		t.Error("unexpected area for EPSG:3857", area, expectedArea, err)
+	}
+
+	if _, err := Area(ewkbPolygon(25832, exterior)); err == nil {
+		t.Error("expected error for unsupported SRID")
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/omniscale/imposm3/logging"
+	"github.com/omniscale/imposm3/proj"
 )
 
 var log = logging.NewLogger("geojson")
@@ -17,6 +20,14 @@ type object struct {
 	Geometry    *object                `json:"geometry"`
 	Coordinates []interface{}          `json:"coordinates"`
 	Properties  map[string]interface{} `json:"properties"`
+	Crs         *crs                   `json:"crs"`
+}
+
+type crs struct {
+	Type       string `json:"type"`
+	Properties struct {
+		Name string `json:"name"`
+	} `json:"properties"`
 }
 
 type geometry struct {
@@ -44,10 +55,6 @@ func newPointFromCoords(coords []interface{}) (Point, error) {
 		return p, errors.New("invalid lat")
 	}
 
-	if p.Long > 180.0 || p.Long < -180.0 || p.Lat > 90.0 || p.Lat < -90.0 {
-		log.Warn("coordinates outside of world boundary. non-4326?: ", p)
-	}
-
 	return p, nil
 }
 
@@ -124,8 +131,9 @@ func newMultiPolygonFeaturesFromCoords(coords []interface{}) ([]Feature, error)
 	return features, nil
 }
 
-// ParseGeoJSON parses geojson from reader and returns []Feature in WGS84 and
-// another []Feature tranformed in targetSRID.
+// ParseGeoJSON parses geojson from reader and returns []Feature in WGS84.
+// Features in EPSG:3857 (named CRS of the root object) are transformed to
+// WGS84. Other CRS are not supported.
 func ParseGeoJSON(r io.Reader) ([]Feature, error) {
 	decoder := json.NewDecoder(r)
 
@@ -135,15 +143,75 @@ func ParseGeoJSON(r io.Reader) ([]Feature, error) {
 		return nil, err
 	}
 
+	srid, err := crsSrid(obj.Crs)
+	if err != nil {
+		return nil, err
+	}
+	toWgs, err := crsTransform(srid)
+	if err != nil {
+		return nil, err
+	}
+
 	polygons, err := constructPolygonFeatures(obj)
 
 	if err != nil {
 		return nil, err
 	}
 
+	for _, f := range polygons {
+		for _, ls := range f.Polygon {
+			for i, p := range ls {
+				if toWgs != nil {
+					ls[i].Long, ls[i].Lat = toWgs(p.Long, p.Lat)
+				} else if p.Long > 180.0 || p.Long < -180.0 || p.Lat > 90.0 || p.Lat < -90.0 {
+					log.Warn("coordinates outside of world boundary. non-4326?: ", p)
+				}
+			}
+		}
+	}
+
 	return polygons, nil
 }
 
+// crsSrid returns the EPSG code of a named CRS (e.g. EPSG:25832 or
+// urn:ogc:def:crs:EPSG::25832). Returns 4326 if c is nil, as this is the
+// default CRS of GeoJSON.
+func crsSrid(c *crs) (int, error) {
+	if c == nil {
+		return 4326, nil
+	}
+	if c.Type != "name" {
+		return 0, fmt.Errorf("unsupported crs type '%s', only named CRS are supported", c.Type)
+	}
+	name := c.Properties.Name
+	if name == "urn:ogc:def:crs:OGC:1.3:CRS84" || name == "urn:ogc:def:crs:OGC::CRS84" {
+		return 4326, nil
+	}
+	srid, err := strconv.Atoi(name[strings.LastIndex(name, ":")+1:])
+	if err != nil {
+		return 0, fmt.Errorf("unsupported crs '%s'", name)
+	}
+	return srid, nil
+}
+
+// crsTransform returns the transformation from srid to EPSG:4326, or nil
+// for geographic coordinates. The WGS84, ETRS89 and NAD83 datums are
+// treated as equal, their difference is irrelevant for -limitto.
+func crsTransform(srid int) (func(x, y float64) (long, lat float64), error) {
+	switch srid {
+	case 4326, 4258, 4269:
+		return nil, nil
+	case 3857, 900913, 102100, 102113:
+		return proj.MercToWgs, nil
+	}
+	if zone, south, ok := proj.UTMZone(srid); ok {
+		return func(x, y float64) (float64, float64) {
+			return proj.UTMToWgs(zone, south, x, y)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported crs EPSG:%d, only EPSG:4326, EPSG:3857 and UTM zones are supported", srid)
+}
+
 func constructPolygonFeatures(obj *object) ([]Feature, error) {
 	switch obj.Type {
 	case "Point":
@@ -2,6 +2,7 @@ package geojson
 
 import (
 	"bytes"
+	"math"
 	"testing"
 )
 
@@ -151,3 +152,46 @@ func TestParseProperties(t *testing.T) {
 		t.Fatal(features)
 	}
 }
+
+func TestParseCrs(t *testing.T) {
+	r := bytes.NewBufferString(`{"type": "FeatureCollection",
+		"crs": {"type": "name", "properties": {"name": "urn:ogc:def:crs:EPSG::3857"}},
+		"features": [{"type": "Feature", "properties": {},
+		"geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1113194.9, 0], [1113194.9, 1118889.9], [0, 0]]]}}]}`)
+	features, err := ParseGeoJSON(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := features[0].Polygon[0][2]; math.Abs(p.Long-10.0) > 1e-6 || math.Abs(p.Lat-10.0) > 1e-6 {
+		t.Error("coordinates not transformed to EPSG:4326", p)
+	}
+
+	r = bytes.NewBufferString(`{"type": "Polygon",
+		"crs": {"type": "name", "properties": {"name": "urn:ogc:def:crs:OGC:1.3:CRS84"}},
+		"coordinates": [[[8, 50], [11, 50], [11, 53], [8, 50]]]}`)
+	features, err = ParseGeoJSON(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := features[0].Polygon[0][1]; p.Long != 11 || p.Lat != 50 {
+		t.Error("unexpected coordinates", p)
+	}
+
+	r = bytes.NewBufferString(`{"type": "Polygon",
+		"crs": {"type": "name", "properties": {"name": "EPSG:25832"}},
+		"coordinates": [[[500000, 0], [466522.5129, 5883511.6115], [500000, 5883511.6115], [500000, 0]]]}`)
+	features, err = ParseGeoJSON(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := features[0].Polygon[0][1]; math.Abs(p.Long-8.5) > 1e-6 || math.Abs(p.Lat-53.1) > 1e-6 {
+		t.Error("coordinates not transformed to EPSG:4326", p)
+	}
+
+	r = bytes.NewBufferString(`{"type": "Polygon",
+		"crs": {"type": "name", "properties": {"name": "EPSG:31467"}},
+		"coordinates": [[[8, 50], [11, 50], [11, 53], [8, 50]]]}`)
+	if _, err := ParseGeoJSON(r); err == nil {
+		t.Error("expected error for unsupported crs")
+	}
+}
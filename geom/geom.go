@@ -16,6 +16,15 @@ type GeomError struct {
 type Geometry struct {
 	Geom *geos.Geom
 	Wkb  []byte
+	// Region of the -limitto area the geometry was clipped to.
+	Region string
+	// Source and Target are the node IDs of the first and last node of
+	// network edges.
+	Source, Target int64
+	// Restriction of restriction tables.
+	Restriction *Restriction
+	// ParentRelations of ways, for parent_relation_tags columns.
+	ParentRelations []*element.Relation
 }
 
 func (e *GeomError) Error() string {
@@ -147,3 +156,39 @@ func AsGeomElement(g *geos.Geos, geom *geos.Geom) (Geometry, error) {
 		Geom: geom,
 	}, nil
 }
+
+// RelationCollection builds a GeometryCollection from all way and node
+// members of rel, including the members of resolved sub-relations. Node
+// members need to be filled with Member.Node, way members with the nodes
+// of the way. Returns nil if rel has no such members.
+func RelationCollection(g *geos.Geos, rel *element.Relation) (*geos.Geom, error) {
+	var geoms []*geos.Geom
+	for _, m := range rel.FlatMembers() {
+		var geom *geos.Geom
+		var err error
+		if m.Type == element.NODE && m.Node != nil {
+			geom, err = Point(g, *m.Node)
+		} else if m.Type == element.WAY && m.Way != nil {
+			geom, err = LineString(g, m.Way.Nodes)
+		} else {
+			continue
+		}
+		if err != nil {
+			if err == ErrorOneNodeWay {
+				continue
+			}
+			return nil, err
+		}
+		// Point and LineString are destroyed later, but
+		// GeometryCollection takes ownership
+		geoms = append(geoms, g.Clone(geom))
+	}
+	if len(geoms) == 0 {
+		return nil, nil
+	}
+	geom := g.GeometryCollection(geoms)
+	if geom == nil {
+		return nil, errors.New("unable to create geometry collection")
+	}
+	return geom, nil
+}
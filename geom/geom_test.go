@@ -145,3 +145,31 @@ func TestUnduplicateNodes(t *testing.T) {
 	}
 
 }
+
+func TestRelationCollection(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	sub := &element.Relation{Members: []element.Member{
+		routeWay(2, "", 1, 0, 2, 0),
+		{Id: 11, Type: element.NODE, Node: &element.Node{Long: 5, Lat: 5}},
+	}}
+	rel := &element.Relation{Members: []element.Member{
+		routeWay(1, "", 0, 0, 1, 0),
+		{Id: 10, Type: element.RELATION, Rel: sub},
+		{Id: 12, Type: element.RELATION},
+	}}
+	geom, err := RelationCollection(g, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Destroy(geom)
+	if g.Type(geom) != "GeometryCollection" || g.NumGeoms(geom) != 3 {
+		t.Error("unexpected geometry", g.AsWkt(geom))
+	}
+
+	geom, err = RelationCollection(g, &element.Relation{Members: []element.Member{{Id: 12, Type: element.RELATION}}})
+	if err != nil || geom != nil {
+		t.Error("expected no geometry", geom, err)
+	}
+}
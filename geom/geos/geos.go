@@ -9,6 +9,7 @@ extern void goLogString(char *msg);
 extern void debug_wrap(const char *fmt, ...);
 extern GEOSContextHandle_t initGEOS_r_debug();
 extern void initGEOS_debug();
+extern GEOSGeometry *makeValid(GEOSContextHandle_t handle, const GEOSGeometry *g);
 */
 import "C"
 
@@ -106,6 +107,17 @@ func (this *Geos) SetHandleSrid(srid int) {
 	this.srid = srid
 }
 
+// Srid returns the SRID of geom, as set by AsEwkbHex.
+func (this *Geos) Srid(geom *Geom) int {
+	return int(C.GEOSGetSRID_r(this.v, geom.v))
+}
+
+// NumCoordinates returns the number of coordinates of all parts of geom,
+// or -1 on errors.
+func (this *Geos) NumCoordinates(geom *Geom) int {
+	return int(C.GEOSGetNumCoordinates_r(this.v, geom.v))
+}
+
 func (this *Geos) NumGeoms(geom *Geom) int32 {
 	count := int32(C.GEOSGetNumGeometries_r(this.v, geom.v))
 	return count
@@ -239,6 +251,36 @@ func (this *Geos) MultiLineString(lines []*Geom) *Geom {
 	return &Geom{geom}
 }
 
+func (this *Geos) MultiPoint(points []*Geom) *Geom {
+	if len(points) == 0 {
+		return nil
+	}
+	pointPtr := make([]*C.GEOSGeometry, len(points))
+	for i, geom := range points {
+		pointPtr[i] = geom.v
+	}
+	geom := C.GEOSGeom_createCollection_r(this.v, C.GEOS_MULTIPOINT, &pointPtr[0], C.uint(len(points)))
+	if geom == nil {
+		return nil
+	}
+	return &Geom{geom}
+}
+
+func (this *Geos) GeometryCollection(geoms []*Geom) *Geom {
+	if len(geoms) == 0 {
+		return nil
+	}
+	geomPtr := make([]*C.GEOSGeometry, len(geoms))
+	for i, geom := range geoms {
+		geomPtr[i] = geom.v
+	}
+	geom := C.GEOSGeom_createCollection_r(this.v, C.GEOS_GEOMETRYCOLLECTION, &geomPtr[0], C.uint(len(geoms)))
+	if geom == nil {
+		return nil
+	}
+	return &Geom{geom}
+}
+
 func (this *Geos) IsValid(geom *Geom) bool {
 	if C.GEOSisValid_r(this.v, geom.v) == 1 {
 		return true
@@ -246,6 +288,36 @@ func (this *Geos) IsValid(geom *Geom) bool {
 	return false
 }
 
+// ValidDetail returns the reason why geom is invalid and the location
+// of the problem (nil if unknown). Returns an empty reason for valid
+// geometries.
+func (this *Geos) ValidDetail(geom *Geom) (string, *Geom) {
+	var reason *C.char
+	var location *C.GEOSGeometry
+	switch C.GEOSisValidDetail_r(this.v, geom.v, 0, &reason, &location) {
+	case 1:
+		return "", nil
+	case 2:
+		// exception (already logged to console)
+		return "validation failed", nil
+	}
+	result := C.GoString(reason)
+	C.GEOSFree_r(this.v, unsafe.Pointer(reason))
+	if location == nil {
+		return result, nil
+	}
+	return result, &Geom{location}
+}
+
+// PointXY returns the coordinate of a point.
+func (this *Geos) PointXY(geom *Geom) (float64, float64, error) {
+	var x, y C.double
+	if C.GEOSGeomGetX_r(this.v, geom.v, &x) != 1 || C.GEOSGeomGetY_r(this.v, geom.v, &y) != 1 {
+		return 0, 0, errors.New("unable to get point coordinates")
+	}
+	return float64(x), float64(y), nil
+}
+
 func (this *Geos) IsSimple(geom *Geom) bool {
 	if C.GEOSisSimple_r(this.v, geom.v) == 1 {
 		return true
@@ -290,6 +362,18 @@ func (g *Geos) MakeValid(geom *Geom) (*Geom, error) {
 	return fixed, nil
 }
 
+// Repair returns a valid version of geom that keeps all vertices
+// (GEOSMakeValid). The result can contain parts of a lower dimension, e.g.
+// lines of collapsed polygons. Returns nil on errors and for GEOS
+// versions older than 3.8.
+func (this *Geos) Repair(geom *Geom) *Geom {
+	fixed := C.makeValid(this.v, geom.v)
+	if fixed == nil {
+		return nil
+	}
+	return &Geom{fixed}
+}
+
 func (this *Geom) Area() float64 {
 	var area C.double
 	if ret := C.GEOSArea(this.v, &area); ret == 1 {
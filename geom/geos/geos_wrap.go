@@ -32,6 +32,15 @@ void initGEOS_debug() {
     return initGEOS(devnull, debug_wrap);
 }
 
+// GEOSMakeValid_r is only available with GEOS >= 3.8
+GEOSGeometry *makeValid(GEOSContextHandle_t handle, const GEOSGeometry *g) {
+#if GEOS_VERSION_MAJOR > 3 || (GEOS_VERSION_MAJOR == 3 && GEOS_VERSION_MINOR >= 8)
+    return GEOSMakeValid_r(handle, g);
+#else
+    return NULL;
+#endif
+}
+
 typedef struct {
     uint32_t num;
     uint32_t *arr;
@@ -37,6 +37,17 @@ func (this *Geos) CreateIndex() *Index {
 	return &Index{tree, &sync.Mutex{}, []IndexGeom{}}
 }
 
+// IndexDestroy frees the index. The indexed geometries are not destroyed.
+func (this *Geos) IndexDestroy(index *Index) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if index.v != nil {
+		C.GEOSSTRtree_destroy_r(this.v, index.v)
+		index.v = nil
+	}
+	index.geoms = nil
+}
+
 // IndexQuery adds a geom to the index with the id.
 func (this *Geos) IndexAdd(index *Index, geom *Geom) {
 	index.mu.Lock()
@@ -42,6 +42,44 @@ func (this *Geos) Buffer(geom *Geom, size float64) *Geom {
 	return &Geom{buffered}
 }
 
+// PointOnSurface returns a point that is guaranteed to be inside of
+// geom.
+func (this *Geos) PointOnSurface(geom *Geom) *Geom {
+	point := C.GEOSPointOnSurface_r(this.v, geom.v)
+	if point == nil {
+		return nil
+	}
+	return &Geom{point}
+}
+
+// Centroid returns the center of mass of geom. The centroid is not
+// necessarily inside of geom.
+func (this *Geos) Centroid(geom *Geom) *Geom {
+	point := C.GEOSGetCentroid_r(this.v, geom.v)
+	if point == nil {
+		return nil
+	}
+	return &Geom{point}
+}
+
+// Boundary returns the boundary of geom, e.g. the rings of a polygon.
+func (this *Geos) Boundary(geom *Geom) *Geom {
+	boundary := C.GEOSBoundary_r(this.v, geom.v)
+	if boundary == nil {
+		return nil
+	}
+	return &Geom{boundary}
+}
+
+// Distance returns the minimum distance between a and b, or -1 on errors.
+func (this *Geos) Distance(a, b *Geom) float64 {
+	var dist C.double
+	if ret := C.GEOSDistance_r(this.v, a.v, b.v, &dist); ret != 1 {
+		return -1
+	}
+	return float64(dist)
+}
+
 func (this *Geos) SimplifyPreserveTopology(geom *Geom, tolerance float64) *Geom {
 	simplified := C.GEOSTopologyPreserveSimplify_r(this.v, geom.v, C.double(tolerance))
 	if simplified == nil {
@@ -0,0 +1,139 @@
+package geom
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+// maxLabelCells limits the number of cells that are checked for each
+// label point.
+const maxLabelCells = 2000
+
+// LabelPoint returns the pole of inaccessibility of a (multi)polygon, the
+// point inside of the polygon with the largest distance to the boundary.
+// It is calculated with a precision of 1% of the polygon size. Returns
+// the point on surface for all other geometry types.
+func LabelPoint(g *geos.Geos, geom *geos.Geom) (*geos.Geom, error) {
+	if t := g.Type(geom); t != "Polygon" && t != "MultiPolygon" {
+		return pointOnSurface(g, geom)
+	}
+	bounds := geom.Bounds()
+	size := math.Max(bounds.MaxX-bounds.MinX, bounds.MaxY-bounds.MinY)
+	if size == 0 || math.IsInf(size, 0) || math.IsNaN(size) {
+		return pointOnSurface(g, geom)
+	}
+
+	boundary := g.Boundary(geom)
+	if boundary == nil {
+		return nil, newGeomError("couldn't create boundary for label point", 1)
+	}
+	defer g.Destroy(boundary)
+	prep := g.Prepare(geom)
+	if prep == nil {
+		return nil, newGeomError("couldn't prepare geometry for label point", 1)
+	}
+	defer g.PreparedDestroy(prep)
+
+	dist := func(x, y float64) float64 {
+		p := g.Point(x, y)
+		if p == nil {
+			return math.Inf(-1)
+		}
+		defer g.Destroy(p)
+		d := g.Distance(p, boundary)
+		if !g.PreparedContains(prep, p) {
+			return -d
+		}
+		return d
+	}
+
+	x, y, d := polylabel(bounds, size/100, dist)
+	if d <= 0 {
+		// no cell inside the polygon, e.g. for very thin polygons
+		return pointOnSurface(g, geom)
+	}
+	point := g.Point(x, y)
+	if point == nil {
+		return nil, newGeomError("couldn't create label point", 1)
+	}
+	return point, nil
+}
+
+func pointOnSurface(g *geos.Geos, geom *geos.Geom) (*geos.Geom, error) {
+	point := g.PointOnSurface(geom)
+	if point == nil {
+		return nil, newGeomError("couldn't create point on surface", 1)
+	}
+	return point, nil
+}
+
+type labelCell struct {
+	x, y float64
+	// half of the cell size
+	h float64
+	// signed distance of the cell center to the polygon boundary
+	d float64
+	// max distance of any point within the cell
+	max float64
+}
+
+func newLabelCell(x, y, h float64, dist func(x, y float64) float64) *labelCell {
+	d := dist(x, y)
+	return &labelCell{x: x, y: y, h: h, d: d, max: d + h*math.Sqrt2}
+}
+
+// labelCells is a priority queue of cells by their max distance.
+type labelCells []*labelCell
+
+func (c labelCells) Len() int            { return len(c) }
+func (c labelCells) Less(i, j int) bool  { return c[i].max > c[j].max }
+func (c labelCells) Swap(i, j int)       { c[i], c[j] = c[j], c[i] }
+func (c *labelCells) Push(x interface{}) { *c = append(*c, x.(*labelCell)) }
+func (c *labelCells) Pop() interface{} {
+	old := *c
+	cell := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return cell
+}
+
+// polylabel searches the point with the largest signed distance within
+// bounds (see github.com/mapbox/polylabel). dist returns a negative
+// distance for points outside of the polygon. Returns the point and its
+// distance.
+func polylabel(bounds geos.Bounds, precision float64, dist func(x, y float64) float64) (float64, float64, float64) {
+	width := bounds.MaxX - bounds.MinX
+	height := bounds.MaxY - bounds.MinY
+	cellSize := math.Min(width, height)
+	if cellSize == 0 {
+		cellSize = math.Max(width, height)
+	}
+	h := cellSize / 2
+
+	cells := &labelCells{}
+	for x := bounds.MinX; x < bounds.MaxX; x += cellSize {
+		for y := bounds.MinY; y < bounds.MaxY; y += cellSize {
+			heap.Push(cells, newLabelCell(x+h, y+h, h, dist))
+		}
+	}
+
+	best := newLabelCell(bounds.MinX+width/2, bounds.MinY+height/2, 0, dist)
+	checked := cells.Len()
+	for cells.Len() > 0 {
+		cell := heap.Pop(cells).(*labelCell)
+		if cell.d > best.d {
+			best = cell
+		}
+		if cell.max-best.d <= precision || checked >= maxLabelCells {
+			continue
+		}
+		h := cell.h / 2
+		heap.Push(cells, newLabelCell(cell.x-h, cell.y-h, h, dist))
+		heap.Push(cells, newLabelCell(cell.x+h, cell.y-h, h, dist))
+		heap.Push(cells, newLabelCell(cell.x-h, cell.y+h, h, dist))
+		heap.Push(cells, newLabelCell(cell.x+h, cell.y+h, h, dist))
+		checked += 4
+	}
+	return best.x, best.y, best.d
+}
@@ -0,0 +1,72 @@
+package geom
+
+import (
+	"math"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestPolylabelCircle(t *testing.T) {
+	// signed distance to a circle at 3,4 with radius 2
+	dist := func(x, y float64) float64 {
+		return 2 - math.Hypot(x-3, y-4)
+	}
+	x, y, d := polylabel(geos.Bounds{MinX: 1, MinY: 2, MaxX: 5, MaxY: 6}, 0.04, dist)
+	if math.Abs(x-3) > 0.05 || math.Abs(y-4) > 0.05 || d < 1.95 {
+		t.Errorf("unexpected label point %f %f (%f)", x, y, d)
+	}
+}
+
+func TestLabelPoint(t *testing.T) {
+	// U-shaped polygon, centroid is outside
+	nodes := []element.Node{
+		{Long: 0, Lat: 0},
+		{Long: 30, Lat: 0},
+		{Long: 30, Lat: 30},
+		{Long: 20, Lat: 30},
+		{Long: 20, Lat: 10},
+		{Long: 10, Lat: 10},
+		{Long: 10, Lat: 30},
+		{Long: 0, Lat: 30},
+		{Long: 0, Lat: 0},
+	}
+	g := geos.NewGeos()
+	defer g.Finish()
+	geom, err := Polygon(g, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point, err := LabelPoint(g, geom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Destroy(point)
+	if !g.Contains(geom, point) {
+		t.Fatal("label point not inside polygon", g.AsWkt(point))
+	}
+	// 5 units from the boundary in the bottom part or the legs
+	boundary := g.Boundary(geom)
+	defer g.Destroy(boundary)
+	if d := g.Distance(point, boundary); d < 4.5 {
+		t.Error("label point too close to boundary", d, g.AsWkt(point))
+	}
+}
+
+func TestLabelPointLineString(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	geom, err := LineString(g, []element.Node{{Long: 0, Lat: 0}, {Long: 10, Lat: 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	point, err := LabelPoint(g, geom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Destroy(point)
+	if g.Type(point) != "Point" {
+		t.Fatal("not a point", g.AsWkt(point))
+	}
+}
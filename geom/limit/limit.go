@@ -2,8 +2,11 @@ package limit
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"math"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
@@ -110,7 +113,73 @@ func splitPolygonAtGrid(g *geos.Geos, geom *geos.Geom, gridWidth, currentGridWid
 	return result, nil
 }
 
-type Limiter struct {
+// Region is a named area for the Limiter.
+type Region struct {
+	// Name of the region, returned by ClipRegions.
+	Name string
+	// LimitTo is a .geojson, .json, .poly or .wkt file with the
+	// polygons of the region. GeoJSON in EPSG:3857 or in UTM zones is
+	// supported with a named CRS, WKT in EPSG:3857 with an SRID=3857;
+	// prefix. All other files need to be in EPSG:4326.
+	LimitTo string
+	// Bbox is used instead of LimitTo if it is set
+	// (minx, miny, maxx, maxy in EPSG:4326).
+	Bbox []float64
+	// Buffer for the cache (in EPSG:4326), see IntersectsBuffer.
+	Buffer float64
+	// Schema is the target schema of the region. Regions with the same
+	// schema are clipped together, so that each geometry is only
+	// returned once for all of these regions.
+	Schema string
+}
+
+// loadPolygons returns the polygons of the region in EPSG:4326.
+func (r *Region) loadPolygons() ([]geojson.Polygon, error) {
+	if len(r.Bbox) > 0 {
+		if len(r.Bbox) != 4 {
+			return nil, errors.New("bbox requires minx,miny,maxx,maxy")
+		}
+		minx, miny, maxx, maxy := r.Bbox[0], r.Bbox[1], r.Bbox[2], r.Bbox[3]
+		return []geojson.Polygon{{geojson.LineString{
+			{Long: minx, Lat: miny},
+			{Long: maxx, Lat: miny},
+			{Long: maxx, Lat: maxy},
+			{Long: minx, Lat: maxy},
+			{Long: minx, Lat: miny},
+		}}}, nil
+	}
+
+	f, err := os.Open(r.LimitTo)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(r.LimitTo)) {
+	case ".poly":
+		return parsePoly(f)
+	case ".wkt":
+		wkt, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return parseWkt(string(wkt))
+	default:
+		features, err := geojson.ParseGeoJSON(f)
+		if err != nil {
+			return nil, err
+		}
+		polygons := make([]geojson.Polygon, len(features))
+		for i := range features {
+			polygons[i] = features[i].Polygon
+		}
+		return polygons, nil
+	}
+}
+
+// region is a prepared Region.
+type region struct {
+	name string
 	// for quick intersections of small geometries
 	index *geos.Index
 	// for direct intersections of large geometries
@@ -130,106 +199,201 @@ type Limiter struct {
 	bufferedPrepMu *sync.Mutex
 }
 
+type Limiter struct {
+	regions   []*region
+	groups    []*regionGroup
+	hasBuffer bool
+}
+
+// regionGroup are all regions with the same schema.
+type regionGroup struct {
+	schema string
+	// union of all regions, or the region itself for single regions
+	clip    *region
+	regions []*region
+}
+
+// Part is a part of a clipped geometry within a region.
+type Part struct {
+	Geom   *geos.Geom
+	Region string
+}
+
+// NewFromGeoJSON returns a Limiter for a single unnamed region. See Region
+// for all supported files.
 func NewFromGeoJSON(source string, buffer float64, targetSRID int) (*Limiter, error) {
-	f, err := os.Open(source)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
+	return New([]Region{{LimitTo: source, Buffer: buffer}}, targetSRID)
+}
 
-	features, err := geojson.ParseGeoJSON(f)
-	if err != nil {
-		return nil, err
+// New returns a Limiter for all regions. Regions can overlap, but
+// geometries are only returned for multiple regions if the regions have
+// different schemas.
+func New(regions []Region, targetSRID int) (*Limiter, error) {
+	l := &Limiter{}
+	for i := range regions {
+		if regions[i].Buffer != 0.0 {
+			l.hasBuffer = true
+		}
 	}
 
 	g := geos.NewGeos()
 	defer g.Finish()
 
-	index := g.CreateIndex()
+	for i := range regions {
+		r, err := newRegion(g, &regions[i], l.hasBuffer, targetSRID)
+		if err != nil {
+			if regions[i].Name != "" {
+				return nil, fmt.Errorf("region %s: %v", regions[i].Name, err)
+			}
+			return nil, err
+		}
+		l.regions = append(l.regions, r)
+
+		var group *regionGroup
+		for _, rg := range l.groups {
+			if rg.schema == regions[i].Schema {
+				group = rg
+			}
+		}
+		if group == nil {
+			group = &regionGroup{schema: regions[i].Schema, clip: r}
+			l.groups = append(l.groups, group)
+		}
+		group.regions = append(group.regions, r)
+	}
 
-	var bufferedPolygons []*geos.Geom
-	var polygons []*geos.Geom
+	for _, group := range l.groups {
+		if len(group.regions) == 1 {
+			continue
+		}
+		var polygons []geojson.Polygon
+		for i := range regions {
+			if regions[i].Schema != group.schema {
+				continue
+			}
+			p, err := regions[i].loadPolygons()
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, p...)
+		}
+		r, err := newRegionFromPolygons(g, group.schema, polygons, 0, false, targetSRID)
+		if err != nil {
+			return nil, err
+		}
+		group.clip = r
+	}
+	return l, nil
+}
 
-	withBuffer := false
-	if buffer != 0.0 {
-		withBuffer = true
+// newRegion prepares the region geometries. The buffered geometry is
+// created if withBuffer is true, even if the buffer of this region is 0.
+func newRegion(g *geos.Geos, reg *Region, withBuffer bool, targetSRID int) (*region, error) {
+	polygons, err := reg.loadPolygons()
+	if err != nil {
+		return nil, err
 	}
+	return newRegionFromPolygons(g, reg.Name, polygons, reg.Buffer, withBuffer, targetSRID)
+}
+
+func newRegionFromPolygons(g *geos.Geos, name string, polygons []geojson.Polygon, buffer float64, withBuffer bool, targetSRID int) (*region, error) {
+	r := &region{
+		name:           name,
+		index:          g.CreateIndex(),
+		geomPrepMu:     &sync.Mutex{},
+		bufferedPrepMu: &sync.Mutex{},
+	}
+
+	// simplify with a fraction of the buffer, so that the simplified
+	// buffered geometry still contains the original geometry
+	tolerance := math.Abs(buffer) / 4
 
 	if withBuffer {
-		for _, feature := range features {
-			geom, err := geosPolygon(g, feature.Polygon)
+		var bufferedPolygons []*geos.Geom
+		for _, polygon := range polygons {
+			geom, err := geosPolygon(g, polygon)
 			if err != nil {
 				return nil, err
 			}
-			simplified := g.SimplifyPreserveTopology(geom, 0.01)
-			if simplified == nil {
-				return nil, errors.New("couldn't simplify limitto")
+			if tolerance > 0 {
+				simplified := g.SimplifyPreserveTopology(geom, tolerance)
+				g.Destroy(geom)
+				if simplified == nil {
+					return nil, errors.New("couldn't simplify limitto")
+				}
+				geom = simplified
 			}
-			buffered := g.Buffer(simplified, buffer)
-			g.Destroy(simplified)
+			buffered := g.Buffer(geom, buffer)
+			g.Destroy(geom)
 			if buffered == nil {
 				return nil, errors.New("couldn't buffer limitto")
 			}
 			// buffered gets destroyed in UnionPolygons
 			bufferedPolygons = append(bufferedPolygons, buffered)
 		}
+		union := g.UnionPolygons(bufferedPolygons)
+		if union == nil {
+			return nil, errors.New("unable to union limitto polygons")
+		}
+		if tolerance > 0 {
+			simplified := g.SimplifyPreserveTopology(union, tolerance)
+			g.Destroy(union)
+			if simplified == nil {
+				return nil, errors.New("unable to simplify limitto polygons")
+			}
+			union = simplified
+		}
+		r.bufferedBbox = union.Bounds()
+		// keep union around for prepared geometry
+		r.bufferedPrep = g.Prepare(union)
+		if r.bufferedPrep == nil {
+			return nil, errors.New("unable to prepare limitto polygons")
+		}
 	}
-	for _, feature := range features {
+
+	var geoms []*geos.Geom
+	for _, polygon := range polygons {
 		if targetSRID != 4326 {
 			// transforms polygon in-place
-			transformPolygon(feature.Polygon, targetSRID)
+			transformPolygon(polygon, targetSRID)
 		}
-		geom, err := geosPolygon(g, feature.Polygon)
+		geom, err := geosPolygon(g, polygon)
 		if err != nil {
 			return nil, err
 		}
 
-		polygons = append(polygons, geom)
+		geoms = append(geoms, geom)
+	}
 
-		minGridWidth := 50000.0
-		if targetSRID == 4326 {
-			minGridWidth = 0.5
-		}
-		parts, err := splitPolygonAtAutoGrid(g, geom, minGridWidth)
+	r.geom = g.UnionPolygons(geoms)
+	if r.geom == nil {
+		return nil, errors.New("unable to union limitto polygons")
+	}
 
+	// index the parts of the union, overlapping polygons would result in
+	// duplicate intersections
+	minGridWidth := 50000.0
+	if targetSRID == 4326 {
+		minGridWidth = 0.5
+	}
+	polygonGeoms := []*geos.Geom{r.geom}
+	if g.Type(r.geom) == "MultiPolygon" {
+		polygonGeoms = g.Geoms(r.geom)
+	}
+	for _, geom := range polygonGeoms {
+		parts, err := splitPolygonAtAutoGrid(g, geom, minGridWidth)
 		if err != nil {
 			return nil, err
 		}
 		for _, part := range parts {
-			g.IndexAdd(index, part)
-		}
-	}
-
-	var bufferedBbox geos.Bounds
-	var bufferedPrep *geos.PreparedGeom
-	if len(bufferedPolygons) > 0 {
-		union := g.UnionPolygons(bufferedPolygons)
-		if union == nil {
-			return nil, errors.New("unable to union limitto polygons")
-		}
-		simplified := g.SimplifyPreserveTopology(union, 0.05)
-		if simplified == nil {
-			return nil, errors.New("unable to simplify limitto polygons")
-		}
-		g.Destroy(union)
-		bufferedBbox = simplified.Bounds()
-		bufferedPrep = g.Prepare(simplified)
-		// keep simplified around for prepared geometry
-		if bufferedPrep == nil {
-			return nil, errors.New("unable to prepare limitto polygons")
+			g.IndexAdd(r.index, part)
 		}
 	}
-	var geomPrep *geos.PreparedGeom
-	union := g.UnionPolygons(polygons)
-	if union == nil {
-		return nil, errors.New("unable to union limitto polygons")
-	}
-	geomPrep = g.Prepare(union)
-	if geomPrep == nil {
+	r.geomPrep = g.Prepare(r.geom)
+	if r.geomPrep == nil {
 		return nil, errors.New("unable to prepare limitto polygons")
 	}
-
-	return &Limiter{index, union, geomPrep, &sync.Mutex{}, bufferedBbox, bufferedPrep, &sync.Mutex{}}, nil
+	return r, nil
 }
 
 func filterGeometryByType(g *geos.Geos, geom *geos.Geom, targetType string) []*geos.Geom {
@@ -274,27 +438,101 @@ func filterGeometryByType(g *geos.Geos, geom *geos.Geom, targetType string) []*g
 // Returns nil if geom is outside of the LimitTo geometry.
 // Returns only similar geometry types (e.g. clipped Polygon will return
 // one or more Polygons, but no LineString or Point, etc.)
+// Returns the parts of all regions, see ClipRegions.
 func (l *Limiter) Clip(geom *geos.Geom) ([]*geos.Geom, error) {
+	parts, err := l.ClipRegions(geom)
+	if err != nil {
+		return nil, err
+	}
+	var geoms []*geos.Geom
+	for _, p := range parts {
+		geoms = append(geoms, p.Geom)
+	}
+	return geoms, nil
+}
+
+// ClipRegions returns geom (in targetSRID) clipped to the regions, see
+// Clip. Regions with the same schema are clipped together and each part
+// gets the name of the first of these regions that contains the part.
+// Geometries within overlapping regions with different schemas are
+// returned for each schema.
+func (l *Limiter) ClipRegions(geom *geos.Geom) ([]Part, error) {
+	var parts []Part
+	returned := false
+	for _, group := range l.groups {
+		geoms, err := group.clip.clip(geom)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range geoms {
+			if p == geom {
+				// geom is completely within the region, return copies
+				// for all other schemas
+				if returned {
+					p = cloneLater(p)
+				}
+				returned = true
+			}
+			parts = append(parts, Part{Geom: p, Region: group.regionName(p)})
+		}
+	}
+	return parts, nil
+}
+
+func cloneLater(geom *geos.Geom) *geos.Geom {
+	g := geos.NewGeos()
+	defer g.Finish()
+	clone := g.Clone(geom)
+	g.DestroyLater(clone)
+	return clone
+}
+
+// regionName returns the name of the first region that contains a point
+// on the surface of geom.
+func (rg *regionGroup) regionName(geom *geos.Geom) string {
+	if len(rg.regions) == 1 {
+		return rg.regions[0].name
+	}
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	p := g.PointOnSurface(geom)
+	if p == nil {
+		return rg.regions[0].name
+	}
+	defer g.Destroy(p)
+	for _, r := range rg.regions {
+		r.geomPrepMu.Lock()
+		intersects := g.PreparedIntersects(r.geomPrep, p)
+		r.geomPrepMu.Unlock()
+		if intersects {
+			return r.name
+		}
+	}
+	return rg.regions[0].name
+}
+
+func (r *region) clip(geom *geos.Geom) ([]*geos.Geom, error) {
 	g := geos.NewGeos()
 	defer g.Finish()
 
 	// check if geom is completely contained
-	l.geomPrepMu.Lock()
-	if g.PreparedContains(l.geomPrep, geom) {
-		l.geomPrepMu.Unlock()
+	r.geomPrepMu.Lock()
+	if g.PreparedContains(r.geomPrep, geom) {
+		r.geomPrepMu.Unlock()
 		return []*geos.Geom{geom}, nil
 	}
-	l.geomPrepMu.Unlock()
+	r.geomPrepMu.Unlock()
 
 	// we have intersections, query index to get intersecting parts
-	hits := g.IndexQueryGeoms(l.index, geom)
+	hits := g.IndexQueryGeoms(r.index, geom)
 
 	geomType := g.Type(geom)
 
 	// too many intersecting parts, it probably faster to
 	// intersect with the original geometry
 	if len(hits) > 50 {
-		newPart := g.Intersection(l.geom, geom)
+		newPart := g.Intersection(r.geom, geom)
 		if newPart == nil {
 			return nil, nil
 		}
@@ -318,27 +556,62 @@ func (l *Limiter) Clip(geom *geos.Geom) ([]*geos.Geom, error) {
 	return mergeGeometries(g, intersections, geomType), nil
 }
 
-// IntersectsBuffer returns true if the point (EPSG:4326) intersects the buffered
-// LimitTo geometry.
-func (c *Limiter) IntersectsBuffer(g *geos.Geos, x, y float64) bool {
-	if c.bufferedPrep == nil {
-		return true
-	}
-	if x < c.bufferedBbox.MinX ||
-		y < c.bufferedBbox.MinY ||
-		x > c.bufferedBbox.MaxX ||
-		y > c.bufferedBbox.MaxY {
-		return false
-	}
+// IntersectsPoint returns true if the point (in the SRID of the Limiter)
+// intersects the LimitTo geometry of any region.
+func (l *Limiter) IntersectsPoint(g *geos.Geos, x, y float64) bool {
 	p := g.Point(x, y)
 	if p == nil {
 		return false
 	}
 	defer g.Destroy(p)
 
-	c.bufferedPrepMu.Lock()
-	defer c.bufferedPrepMu.Unlock()
-	return g.PreparedIntersects(c.bufferedPrep, p)
+	for _, r := range l.regions {
+		r.geomPrepMu.Lock()
+		intersects := g.PreparedIntersects(r.geomPrep, p)
+		r.geomPrepMu.Unlock()
+		if intersects {
+			return true
+		}
+	}
+	return false
+}
+
+// HasBuffer returns true if the Limiter was created with a buffer
+// for the cache (see IntersectsBuffer).
+func (c *Limiter) HasBuffer() bool {
+	return c.hasBuffer
+}
+
+// IntersectsBuffer returns true if the point (EPSG:4326) intersects the buffered
+// LimitTo geometry of any region. Always returns true if no region has
+// a buffer.
+func (c *Limiter) IntersectsBuffer(g *geos.Geos, x, y float64) bool {
+	if !c.hasBuffer {
+		return true
+	}
+	var p *geos.Geom
+	for _, r := range c.regions {
+		if x < r.bufferedBbox.MinX ||
+			y < r.bufferedBbox.MinY ||
+			x > r.bufferedBbox.MaxX ||
+			y > r.bufferedBbox.MaxY {
+			continue
+		}
+		if p == nil {
+			p = g.Point(x, y)
+			if p == nil {
+				return false
+			}
+			defer g.Destroy(p)
+		}
+		r.bufferedPrepMu.Lock()
+		intersects := g.PreparedIntersects(r.bufferedPrep, p)
+		r.bufferedPrepMu.Unlock()
+		if intersects {
+			return true
+		}
+	}
+	return false
 }
 
 func flattenPolygons(g *geos.Geos, geoms []*geos.Geom) []*geos.Geom {
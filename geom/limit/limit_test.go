@@ -1,6 +1,7 @@
 package limit
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/omniscale/imposm3/geom/geos"
@@ -319,6 +320,177 @@ func TestClipperWithBuffer(t *testing.T) {
 
 }
 
+func TestClipRegions(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	limiter, err := New([]Region{
+		{Name: "west", Bbox: []float64{0, 0, 10, 10}, Schema: "west"},
+		{Name: "east", Bbox: []float64{10, 0, 20, 10}, Buffer: 0.5, Schema: "east"},
+	}, 4326)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts, err := limiter.ClipRegions(g.FromWkt("LINESTRING(5 5, 15 5)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].Region != "west" || parts[1].Region != "east" {
+		t.Fatal(parts)
+	}
+	if l := parts[0].Geom.Length(); l < 4.99 || l > 5.01 {
+		t.Error("unexpected length", l)
+	}
+
+	parts, err = limiter.ClipRegions(g.FromWkt("POINT(15 5)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 || parts[0].Region != "east" {
+		t.Fatal(parts)
+	}
+
+	if !limiter.HasBuffer() {
+		t.Fatal("expected buffer")
+	}
+	// buffer of east region
+	if !limiter.IntersectsBuffer(g, 20.2, 5) {
+		t.Error("point in buffer of east region")
+	}
+	// west region without buffer
+	if limiter.IntersectsBuffer(g, -0.2, 5) {
+		t.Error("point outside of west region")
+	}
+	if !limiter.IntersectsPoint(g, 5, 5) || limiter.IntersectsPoint(g, 25, 5) {
+		t.Error("unexpected IntersectsPoint result")
+	}
+}
+
+func TestClipOverlappingRegions(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	limiter, err := New([]Region{
+		{Name: "west", Bbox: []float64{0, 0, 12, 10}},
+		{Name: "east", Bbox: []float64{8, 0, 20, 10}},
+		{Name: "extra", Bbox: []float64{8, 0, 20, 10}, Schema: "extra"},
+	}, 4326)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// crosses the overlap of west and east
+	parts, err := limiter.ClipRegions(g.FromWkt("LINESTRING(5 5, 15 5)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].Region != "west" || parts[1].Region != "extra" {
+		t.Fatal(parts)
+	}
+	if l := parts[0].Geom.Length(); l < 9.99 || l > 10.01 {
+		t.Error("unexpected length", l)
+	}
+	if l := parts[1].Geom.Length(); l < 6.99 || l > 7.01 {
+		t.Error("unexpected length", l)
+	}
+
+	// within the overlap of west and east
+	parts, err = limiter.ClipRegions(g.FromWkt("POLYGON((9 1, 11 1, 11 2, 9 2, 9 1))"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].Region != "west" || parts[1].Region != "extra" {
+		t.Fatal(parts)
+	}
+	if parts[0].Geom.Area() != 2 {
+		t.Error("unexpected area", parts[0].Geom.Area())
+	}
+
+	// only in east
+	parts, err = limiter.ClipRegions(g.FromWkt("POINT(15 5)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 || parts[0].Region != "east" || parts[1].Region != "extra" {
+		t.Fatal(parts)
+	}
+}
+
+func TestParsePoly(t *testing.T) {
+	polygons, err := parsePoly(strings.NewReader(`test
+1
+   8.0 50.0
+   11.0 50.0
+   11.0 53.0
+   8.0 53.0
+END
+!hole
+   9 51
+   10 51
+   10 52
+END
+2
+   20 20
+   21 20
+   21 21
+END
+END
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(polygons) != 2 {
+		t.Fatal(polygons)
+	}
+	// closed shell with hole
+	if len(polygons[0]) != 2 || len(polygons[0][0]) != 5 || len(polygons[0][1]) != 4 {
+		t.Error(polygons[0])
+	}
+	if len(polygons[1]) != 1 {
+		t.Error(polygons[1])
+	}
+
+	if _, err := parsePoly(strings.NewReader("test\n1\n 1 1\n")); err == nil {
+		t.Error("expected error for incomplete file")
+	}
+}
+
+func TestParseWkt(t *testing.T) {
+	polygons, err := parseWkt("POLYGON((8 50, 11 50, 11 53, 8 50), (9 51, 10 51, 10 52, 9 51))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(polygons) != 1 || len(polygons[0]) != 2 || polygons[0][0][1].Long != 11 {
+		t.Error(polygons)
+	}
+
+	polygons, err = parseWkt(" multipolygon (((8 50,11 50,11 53,8 50)),((1 1,2 1,2 2,1 1)))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(polygons) != 2 {
+		t.Error(polygons)
+	}
+
+	polygons, err = parseWkt("SRID=3857;POLYGON((0 0, 1113194.9 0, 1113194.9 1118889.9, 0 0))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := polygons[0][0][2]; p.Long < 9.99 || p.Long > 10.01 || p.Lat < 9.99 || p.Lat > 10.01 {
+		t.Error("not transformed to EPSG:4326", p)
+	}
+
+	for _, wkt := range []string{
+		"POINT(1 1)",
+		"POLYGON((1 1, 2 2)",
+		"POLYGON((1 1, 2 2)) foo",
+		"SRID=25832;POLYGON((1 1, 2 2, 3 3, 1 1))",
+	} {
+		if _, err := parseWkt(wkt); err == nil {
+			t.Error("expected error for", wkt)
+		}
+	}
+}
+
 func TestSplitParams(t *testing.T) {
 	var gridWidth, startWidth float64
 
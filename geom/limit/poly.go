@@ -0,0 +1,115 @@
+package limit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/omniscale/imposm3/geom/geojson"
+)
+
+// parsePoly parses polygons from an Osmosis .poly file
+// (http://wiki.openstreetmap.org/wiki/Osmosis/Polygon_Filter_File_Format).
+// Each section is a ring, sections with a name starting with ! are holes.
+// Holes are added to the first polygon that contains them.
+func parsePoly(r io.Reader) ([]geojson.Polygon, error) {
+	scanner := bufio.NewScanner(r)
+
+	var shells, holes []geojson.LineString
+	var ring geojson.LineString
+	inRing := false
+	isHole := false
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if line == 1 {
+			// name of the file
+			continue
+		}
+		if !inRing {
+			if text == "END" {
+				return assignHoles(shells, holes)
+			}
+			inRing = true
+			isHole = strings.HasPrefix(text, "!")
+			ring = nil
+			continue
+		}
+		if text == "END" {
+			if len(ring) < 3 {
+				return nil, fmt.Errorf("ring with less than 3 points in line %d", line)
+			}
+			if ring[0] != ring[len(ring)-1] {
+				ring = append(ring, ring[0])
+			}
+			if isHole {
+				holes = append(holes, ring)
+			} else {
+				shells = append(shells, ring)
+			}
+			inRing = false
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid coordinate in line %d: %s", line, text)
+		}
+		long, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coordinate in line %d: %s", line, text)
+		}
+		lat, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coordinate in line %d: %s", line, text)
+		}
+		ring = append(ring, geojson.Point{Long: long, Lat: lat})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("missing END in .poly file")
+}
+
+// assignHoles creates a polygon for each shell and adds each hole to the
+// first shell that contains the first point of the hole.
+func assignHoles(shells, holes []geojson.LineString) ([]geojson.Polygon, error) {
+	if len(shells) == 0 {
+		return nil, errors.New("no polygon in .poly file")
+	}
+	polygons := make([]geojson.Polygon, len(shells))
+	for i, shell := range shells {
+		polygons[i] = geojson.Polygon{shell}
+	}
+NextHole:
+	for _, hole := range holes {
+		for i, shell := range shells {
+			if ringContains(shell, hole[0]) {
+				polygons[i] = append(polygons[i], hole)
+				continue NextHole
+			}
+		}
+		return nil, fmt.Errorf("hole at %v outside of all polygons", hole[0])
+	}
+	return polygons, nil
+}
+
+// ringContains returns whether p is inside of the closed ring
+// (ray casting).
+func ringContains(ring geojson.LineString, p geojson.Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Long < (b.Long-a.Long)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Long {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,172 @@
+package limit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/omniscale/imposm3/geom/geojson"
+	"github.com/omniscale/imposm3/proj"
+)
+
+// parseWkt parses a POLYGON or MULTIPOLYGON from WKT. Coordinates are in
+// EPSG:4326, or in EPSG:3857 with an EWKT SRID=3857; prefix.
+func parseWkt(wkt string) ([]geojson.Polygon, error) {
+	wkt = strings.TrimSpace(wkt)
+	srid := 4326
+	if strings.HasPrefix(strings.ToUpper(wkt), "SRID=") {
+		parts := strings.SplitN(wkt[5:], ";", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("invalid EWKT SRID")
+		}
+		var err error
+		srid, err = strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid EWKT SRID: %s", parts[0])
+		}
+		if srid != 4326 && srid != 3857 {
+			return nil, fmt.Errorf("unsupported SRID %d, only 4326 and 3857 are supported", srid)
+		}
+		wkt = strings.TrimSpace(parts[1])
+	}
+
+	idx := strings.IndexRune(wkt, '(')
+	if idx < 0 {
+		return nil, errors.New("invalid WKT, missing (")
+	}
+	geomType := strings.ToUpper(strings.TrimSpace(wkt[:idx]))
+	p := &wktParser{s: wkt[idx:]}
+
+	var polygons []geojson.Polygon
+	switch geomType {
+	case "POLYGON":
+		poly, err := p.polygon()
+		if err != nil {
+			return nil, err
+		}
+		polygons = append(polygons, poly)
+	case "MULTIPOLYGON":
+		if err := p.expect('('); err != nil {
+			return nil, err
+		}
+		for {
+			poly, err := p.polygon()
+			if err != nil {
+				return nil, err
+			}
+			polygons = append(polygons, poly)
+			if !p.next(',') {
+				break
+			}
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported WKT type '%s', only POLYGON and MULTIPOLYGON are supported", geomType)
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("unexpected '%s' at end of WKT", p.s[p.pos:])
+	}
+
+	if srid == 3857 {
+		for _, poly := range polygons {
+			for _, ls := range poly {
+				for i := range ls {
+					ls[i].Long, ls[i].Lat = proj.MercToWgs(ls[i].Long, ls[i].Lat)
+				}
+			}
+		}
+	}
+	return polygons, nil
+}
+
+type wktParser struct {
+	s   string
+	pos int
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// next consumes c and returns true if c is the next non-space character.
+func (p *wktParser) next(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *wktParser) expect(c byte) error {
+	if !p.next(c) {
+		return fmt.Errorf("invalid WKT, expected '%c' at position %d", c, p.pos)
+	}
+	return nil
+}
+
+func (p *wktParser) number() (float64, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("+-.0123456789eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	f, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WKT, expected number at position %d", start)
+	}
+	return f, nil
+}
+
+func (p *wktParser) ring() (geojson.LineString, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var ls geojson.LineString
+	for {
+		long, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		lat, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		ls = append(ls, geojson.Point{Long: long, Lat: lat})
+		if !p.next(',') {
+			break
+		}
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return ls, nil
+}
+
+func (p *wktParser) polygon() (geojson.Polygon, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var poly geojson.Polygon
+	for {
+		ls, err := p.ring()
+		if err != nil {
+			return nil, err
+		}
+		poly = append(poly, ls)
+		if !p.next(',') {
+			break
+		}
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return poly, nil
+}
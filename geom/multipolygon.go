@@ -8,24 +8,78 @@ import (
 	"github.com/omniscale/imposm3/geom/geos"
 )
 
+// Repair strategies for invalid multipolygons.
+const (
+	// RepairBuffer0 fixes invalid geometries with buffer(0). Parts of
+	// self-intersecting rings can get lost.
+	RepairBuffer0 = "buffer0"
+	// RepairMakeValid fixes invalid geometries with GEOS MakeValid, which
+	// keeps all vertices. Falls back to buffer0 for GEOS < 3.8.
+	RepairMakeValid = "make_valid"
+	// RepairDrop skips invalid geometries.
+	RepairDrop = "drop"
+)
+
+// MultiPolygonOptions configure how multipolygons are build from relations.
+type MultiPolygonOptions struct {
+	// MaxRingGap is the max distance between the endpoints of a ring
+	// that is still closed.
+	MaxRingGap float64
+	// Repair strategy for invalid geometries, RepairBuffer0 if empty.
+	Repair string
+	// IgnoreWayTags disables old-style multipolygons. Relations without
+	// tags do not get the tags of their outer way.
+	IgnoreWayTags bool
+}
+
+// Actions for Issues.
+const (
+	IssueSkipped  = "skipped"
+	IssueRepaired = "repaired"
+	IssueDropped  = "dropped"
+	IssueFailed   = "failed"
+)
+
+// Issue is a problem found while building a multipolygon.
+type Issue struct {
+	Reason string
+	// Action is IssueSkipped for rings that are not part of the
+	// multipolygon, IssueRepaired, IssueDropped or IssueFailed.
+	Action string
+	// Locations of the problem, e.g. the endpoints of an unclosed ring.
+	Locations []element.Node
+}
+
+// ErrorInvalid is returned by Build for invalid multipolygons with
+// RepairDrop.
+var ErrorInvalid = newGeomError("multipolygon is invalid", 0)
+
 type PreparedRelation struct {
 	rings []*ring
 	rel   *element.Relation
 	srid  int
+	opts  MultiPolygonOptions
+	// Issues of the relation, collected by PrepareRelation and Build.
+	Issues []Issue
 }
 
 // PrepareRelation is the first step in building a (multi-)polygon of a Relation.
 // It builds rings from all ways and returns an error if there are unclosed rings.
 // It also merges the Relation.Tags with the Tags of the outer way.
-func PrepareRelation(rel *element.Relation, srid int, maxRingGap float64) (PreparedRelation, error) {
-	rings, err := buildRings(rel, maxRingGap)
+// The returned PreparedRelation contains the Issues, even on errors.
+func PrepareRelation(rel *element.Relation, srid int, opts MultiPolygonOptions) (PreparedRelation, error) {
+	rings, issues, err := buildRings(rel, opts.MaxRingGap)
 	if err != nil {
-		return PreparedRelation{}, err
+		return PreparedRelation{Issues: issues}, err
 	}
 
-	rel.Tags = relationTags(rel.Tags, rings[0].ways[0].Tags)
+	if opts.IgnoreWayTags {
+		rel.Tags = relationTags(rel.Tags, nil)
+	} else {
+		rel.Tags = relationTags(rel.Tags, rings[0].ways[0].Tags)
+	}
 
-	return PreparedRelation{rings, rel, srid}, nil
+	return PreparedRelation{rings, rel, srid, opts, issues}, nil
 }
 
 // Build creates the (multi)polygon Geometry of the Relation.
@@ -34,7 +88,10 @@ func (prep *PreparedRelation) Build() (Geometry, error) {
 	g.SetHandleSrid(prep.srid)
 	defer g.Finish()
 
-	geom, err := buildRelGeometry(g, prep.rel, prep.rings)
+	geom, issue, err := buildRelGeometry(g, prep.rel, prep.rings, prep.opts.Repair)
+	if issue != nil {
+		prep.Issues = append(prep.Issues, *issue)
+	}
 	if err != nil {
 		return Geometry{}, err
 	}
@@ -55,8 +112,9 @@ func destroyRings(g *geos.Geos, rings []*ring) {
 	}
 }
 
-func buildRings(rel *element.Relation, maxRingGap float64) ([]*ring, error) {
+func buildRings(rel *element.Relation, maxRingGap float64) ([]*ring, []Issue, error) {
 	var rings []*ring
+	var issues []Issue
 	var incompleteRings []*ring
 	var completeRings []*ring
 	var mergedRings []*ring
@@ -84,7 +142,7 @@ func buildRings(rel *element.Relation, maxRingGap float64) ([]*ring, error) {
 		if r.isClosed() {
 			r.geom, err = Polygon(g, r.nodes)
 			if err != nil {
-				return nil, err
+				return nil, issues, err
 			}
 			completeRings = append(completeRings, r)
 		} else {
@@ -97,18 +155,23 @@ func buildRings(rel *element.Relation, maxRingGap float64) ([]*ring, error) {
 	// create geometries for merged rings
 	for _, ring := range mergedRings {
 		if !ring.isClosed() && !ring.tryClose(maxRingGap) {
+			issues = append(issues, Issue{
+				Reason:    "unclosed ring",
+				Action:    IssueSkipped,
+				Locations: ringEndpoints(ring),
+			})
 			continue
 		}
 		ring.geom, err = Polygon(g, ring.nodes)
 		if err != nil {
-			return nil, err
+			return nil, issues, err
 		}
 		completeRings = append(completeRings, ring)
 	}
 
 	if len(completeRings) == 0 {
 		err = ErrorNoRing // for defer
-		return nil, err
+		return nil, issues, err
 	}
 
 	// sort by area (large to small)
@@ -117,7 +180,7 @@ func buildRings(rel *element.Relation, maxRingGap float64) ([]*ring, error) {
 	}
 	sort.Sort(sortableRingsDesc(completeRings))
 
-	return completeRings, nil
+	return completeRings, issues, nil
 }
 
 type sortableRingsDesc []*ring
@@ -128,13 +191,15 @@ func (r sortableRingsDesc) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
 
 // buildRelGeometry builds the geometry of rel by creating a multipolygon of all rings.
 // rings need to be sorted by area (large to small).
-func buildRelGeometry(g *geos.Geos, rel *element.Relation, rings []*ring) (*geos.Geom, error) {
+// Invalid geometries are repaired (see Repair constants) and the returned
+// Issue describes the problem.
+func buildRelGeometry(g *geos.Geos, rel *element.Relation, rings []*ring, repair string) (*geos.Geom, *Issue, error) {
 	totalRings := len(rings)
 	shells := map[*ring]bool{rings[0]: true}
 	for i := 0; i < totalRings; i++ {
 		testGeom := g.Prepare(rings[i].geom)
 		if testGeom == nil {
-			return nil, errors.New("Error while preparing geometry")
+			return nil, nil, errors.New("Error while preparing geometry")
 		}
 		for j := i + 1; j < totalRings; j++ {
 			if g.PreparedContains(testGeom, rings[j].geom) {
@@ -171,18 +236,18 @@ func buildRelGeometry(g *geos.Geos, rel *element.Relation, rings []*ring) (*geos
 			ring := g.Clone(g.ExteriorRing(hole.geom))
 			g.Destroy(hole.geom)
 			if ring == nil {
-				return nil, errors.New("Error while getting exterior ring.")
+				return nil, nil, errors.New("Error while getting exterior ring.")
 			}
 			interiors = append(interiors, ring)
 		}
 		exterior := g.Clone(g.ExteriorRing(shell.geom))
 		g.Destroy(shell.geom)
 		if exterior == nil {
-			return nil, errors.New("Error while getting exterior ring.")
+			return nil, nil, errors.New("Error while getting exterior ring.")
 		}
 		polygon := g.Polygon(exterior, interiors)
 		if polygon == nil {
-			return nil, errors.New("Error while building polygon.")
+			return nil, nil, errors.New("Error while building polygon.")
 		}
 		polygons = append(polygons, polygon)
 	}
@@ -193,13 +258,12 @@ func buildRelGeometry(g *geos.Geos, rel *element.Relation, rings []*ring) (*geos
 	} else {
 		result = g.MultiPolygon(polygons)
 		if result == nil {
-			return nil, errors.New("Error while building multi-polygon.")
+			return nil, nil, errors.New("Error while building multi-polygon.")
 		}
 	}
-	var err error
-	result, err = g.MakeValid(result)
+	result, issue, err := repairGeometry(g, result, repair)
 	if err != nil {
-		return nil, err
+		return nil, issue, err
 	}
 
 	g.DestroyLater(result)
@@ -221,7 +285,63 @@ func buildRelGeometry(g *geos.Geos, rel *element.Relation, rings []*ring) (*geos
 		}
 	}
 
-	return result, nil
+	return result, issue, nil
+}
+
+// repairGeometry returns geom if it is valid, or a repaired geometry. The
+// Issue describes why geom is invalid. Destroys geom if it is not
+// returned.
+func repairGeometry(g *geos.Geos, geom *geos.Geom, repair string) (*geos.Geom, *Issue, error) {
+	reason, location := g.ValidDetail(geom)
+	if reason == "" {
+		return geom, nil, nil
+	}
+	issue := &Issue{Reason: reason, Action: IssueRepaired}
+	if location != nil {
+		if x, y, err := g.PointXY(location); err == nil {
+			issue.Locations = []element.Node{{Long: x, Lat: y}}
+		}
+		g.Destroy(location)
+	}
+
+	if repair == RepairDrop {
+		g.Destroy(geom)
+		issue.Action = IssueDropped
+		return nil, issue, ErrorInvalid
+	}
+
+	if repair == RepairMakeValid {
+		// only keep polygons, MakeValid returns lines for collapsed rings
+		if parts := singleParts(g, g.Repair(geom), "Polygon"); len(parts) == 1 {
+			g.Destroy(geom)
+			return parts[0], issue, nil
+		} else if len(parts) > 1 {
+			if fixed := g.MultiPolygon(parts); fixed != nil {
+				g.Destroy(geom)
+				return fixed, issue, nil
+			}
+			for _, p := range parts {
+				g.Destroy(p)
+			}
+		}
+		// GEOS < 3.8 or nothing left, try buffer(0)
+	}
+
+	fixed, err := g.MakeValid(geom)
+	if err != nil {
+		g.Destroy(geom)
+		issue.Action = IssueFailed
+		return nil, issue, err
+	}
+	return fixed, issue, nil
+}
+
+// ringEndpoints returns the first and last node of an unclosed ring.
+func ringEndpoints(r *ring) []element.Node {
+	if len(r.nodes) == 0 {
+		return nil
+	}
+	return []element.Node{r.nodes[0], r.nodes[len(r.nodes)-1]}
 }
 
 func relationTags(relTags, wayTags element.Tags) element.Tags {
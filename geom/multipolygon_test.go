@@ -27,7 +27,7 @@ func makeWay(id int64, tags element.Tags, coords []coord) element.Way {
 }
 
 func buildRelation(rel *element.Relation, srid int) (Geometry, error) {
-	prep, err := PrepareRelation(rel, srid, 0.1)
+	prep, err := PrepareRelation(rel, srid, MultiPolygonOptions{MaxRingGap: 0.1})
 	if err != nil {
 		return Geometry{}, err
 	}
@@ -638,7 +638,7 @@ func TestClosedAndOpenRing(t *testing.T) {
 		{Id: 2, Type: element.WAY, Role: "outer", Way: &w2},
 	}
 
-	prep, err := PrepareRelation(&rel, 3857, 0.1)
+	prep, err := PrepareRelation(&rel, 3857, MultiPolygonOptions{MaxRingGap: 0.1})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -658,3 +658,141 @@ func TestClosedAndOpenRing(t *testing.T) {
 		t.Fatal("geometry not valid", g.AsWkt(geom.Geom))
 	}
 }
+
+func TestOpenRingIssues(t *testing.T) {
+	w1 := makeWay(1, element.Tags{}, []coord{
+		{1, 0, 0},
+		{2, 10, 0},
+		{3, 10, 10},
+		{4, 0, 10},
+	})
+
+	rel := element.Relation{
+		OSMElem: element.OSMElem{Id: 1, Tags: element.Tags{}}}
+	rel.Members = []element.Member{
+		{Id: 1, Type: element.WAY, Role: "outer", Way: &w1},
+	}
+
+	prep, err := PrepareRelation(&rel, 3857, MultiPolygonOptions{MaxRingGap: 0.1})
+	if err != ErrorNoRing {
+		t.Fatal("expected ErrorNoRing", err)
+	}
+	if len(prep.Issues) != 1 {
+		t.Fatal("expected single issue", prep.Issues)
+	}
+	issue := prep.Issues[0]
+	if issue.Action != IssueSkipped || len(issue.Locations) != 2 {
+		t.Fatal("unexpected issue", issue)
+	}
+	if l := issue.Locations[1]; l.Long != 0 || l.Lat != 10 {
+		t.Fatal("unexpected location", l)
+	}
+
+	// closed with larger ring gap
+	prep, err = PrepareRelation(&rel, 3857, MultiPolygonOptions{MaxRingGap: 11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prep.Issues) != 0 {
+		t.Fatal("unexpected issues", prep.Issues)
+	}
+}
+
+func TestIgnoreWayTags(t *testing.T) {
+	for _, ignore := range []bool{false, true} {
+		w1 := makeWay(1, element.Tags{"landusage": "forest"}, []coord{
+			{1, 0, 0},
+			{2, 10, 0},
+			{3, 10, 10},
+			{1, 0, 0},
+		})
+		rel := element.Relation{
+			OSMElem: element.OSMElem{Id: 1, Tags: element.Tags{"type": "multipolygon"}}}
+		rel.Members = []element.Member{
+			{Id: 1, Type: element.WAY, Role: "outer", Way: &w1},
+		}
+		_, err := PrepareRelation(&rel, 3857, MultiPolygonOptions{MaxRingGap: 0.1, IgnoreWayTags: ignore})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ignore && len(rel.Tags) != 0 {
+			t.Error("expected no tags", rel.Tags)
+		}
+		if !ignore && rel.Tags["landusage"] != "forest" {
+			t.Error("expected way tags", rel.Tags)
+		}
+	}
+}
+
+func TestRepairInvalidMultiPolygon(t *testing.T) {
+	//  4   3
+	//  # X #
+	//  1   2
+	for _, tc := range []struct {
+		repair string
+		area   float64
+		err    error
+		action string
+	}{
+		{"", 25, nil, IssueRepaired},
+		{RepairBuffer0, 25, nil, IssueRepaired},
+		{RepairMakeValid, 50, nil, IssueRepaired},
+		{RepairDrop, 0, ErrorInvalid, IssueDropped},
+	} {
+		t.Run(tc.repair, func(t *testing.T) {
+			if tc.repair == RepairMakeValid && !hasRepair() {
+				t.Skip("make_valid requires GEOS 3.8")
+			}
+			w1 := makeWay(1, element.Tags{}, []coord{
+				{1, 0, 0},
+				{3, 10, 10},
+				{2, 10, 0},
+				{4, 0, 10},
+				{1, 0, 0},
+			})
+			rel := element.Relation{
+				OSMElem: element.OSMElem{Id: 1, Tags: element.Tags{}}}
+			rel.Members = []element.Member{
+				{Id: 1, Type: element.WAY, Role: "outer", Way: &w1},
+			}
+
+			prep, err := PrepareRelation(&rel, 3857, MultiPolygonOptions{MaxRingGap: 0.1, Repair: tc.repair})
+			if err != nil {
+				t.Fatal(err)
+			}
+			geom, err := prep.Build()
+			if err != tc.err {
+				t.Fatal("unexpected error", err)
+			}
+			if len(prep.Issues) != 1 {
+				t.Fatal("expected single issue", prep.Issues)
+			}
+			issue := prep.Issues[0]
+			if issue.Action != tc.action || issue.Reason == "" {
+				t.Error("unexpected issue", issue)
+			}
+			if len(issue.Locations) != 1 || issue.Locations[0].Long != 5 || issue.Locations[0].Lat != 5 {
+				t.Error("unexpected location", issue.Locations)
+			}
+			if tc.err != nil {
+				return
+			}
+			if area := geom.Geom.Area(); math.Abs(area-tc.area) > 1e-9 {
+				t.Error("unexpected area", area)
+			}
+		})
+	}
+}
+
+func hasRepair() bool {
+	g := geos.NewGeos()
+	defer g.Finish()
+	p := g.Point(0, 0)
+	defer g.Destroy(p)
+	fixed := g.Repair(p)
+	if fixed == nil {
+		return false
+	}
+	g.Destroy(fixed)
+	return true
+}
@@ -0,0 +1,32 @@
+package geom
+
+import "github.com/omniscale/imposm3/element"
+
+// SplitEdges splits the nodes of a way into the edges of a routable
+// network. Edges end at each inner node where junction returns true.
+// Nodes that occur multiple times in the way are always junctions, to
+// split loops.
+func SplitEdges(nodes []element.Node, junction func(id int64) bool) [][]element.Node {
+	if len(nodes) < 2 {
+		return nil
+	}
+	seen := make(map[int64]int, len(nodes))
+	for _, nd := range nodes {
+		seen[nd.Id] += 1
+	}
+	closed := nodes[0].Id == nodes[len(nodes)-1].Id
+
+	var edges [][]element.Node
+	start := 0
+	for i := 1; i < len(nodes)-1; i++ {
+		count := seen[nodes[i].Id]
+		if closed && nodes[i].Id == nodes[0].Id {
+			count -= 1
+		}
+		if count > 1 || junction(nodes[i].Id) {
+			edges = append(edges, nodes[start:i+1])
+			start = i
+		}
+	}
+	return append(edges, nodes[start:])
+}
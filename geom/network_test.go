@@ -0,0 +1,50 @@
+package geom
+
+import (
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+func TestSplitEdges(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		refs      []int64
+		junctions []int64
+		expected  [][]int64
+	}{
+		{"no junctions", []int64{1, 2, 3}, nil, [][]int64{{1, 2, 3}}},
+		{"end nodes are no inner junctions", []int64{1, 2, 3}, []int64{1, 3}, [][]int64{{1, 2, 3}}},
+		{"junction", []int64{1, 2, 3, 4}, []int64{2}, [][]int64{{1, 2}, {2, 3, 4}}},
+		{"junctions", []int64{1, 2, 3, 4}, []int64{2, 3}, [][]int64{{1, 2}, {2, 3}, {3, 4}}},
+		{"loop", []int64{1, 2, 3, 4, 2, 5}, nil, [][]int64{{1, 2}, {2, 3, 4, 2}, {2, 5}}},
+		{"closed way", []int64{1, 2, 3, 1}, nil, [][]int64{{1, 2, 3, 1}}},
+		{"closed way with junction", []int64{1, 2, 3, 1}, []int64{3}, [][]int64{{1, 2, 3}, {3, 1}}},
+	} {
+		var nodes []element.Node
+		for _, id := range tc.refs {
+			nodes = append(nodes, element.Node{OSMElem: element.OSMElem{Id: id}})
+		}
+		junctions := make(map[int64]bool)
+		for _, id := range tc.junctions {
+			junctions[id] = true
+		}
+		edges := SplitEdges(nodes, func(id int64) bool { return junctions[id] })
+		if len(edges) != len(tc.expected) {
+			t.Errorf("%s: unexpected edges %v", tc.name, edges)
+			continue
+		}
+		for i := range edges {
+			if len(edges[i]) != len(tc.expected[i]) {
+				t.Errorf("%s: unexpected edge %v", tc.name, edges[i])
+				continue
+			}
+			for j := range edges[i] {
+				if edges[i][j].Id != tc.expected[i][j] {
+					t.Errorf("%s: unexpected edge %v", tc.name, edges[i])
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package geom
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+// Restriction is a turn restriction from a way, over a node or ways, to a
+// way.
+type Restriction struct {
+	// Type is the restriction value, e.g. no_left_turn.
+	Type string
+	// Vehicle is the suffix of restriction:<vehicle> tags. Empty for all
+	// vehicles.
+	Vehicle string
+	// Condition is the condition of restriction:conditional tags.
+	Condition string
+	From      int64
+	// ViaNode is the via node or 0 if the restriction is via ways.
+	ViaNode int64
+	ViaWays []int64
+	To      int64
+	// Error describes why the restriction relation is invalid.
+	Error string
+}
+
+// RestrictionTypes returns a Restriction (without members) for each
+// restriction, restriction:<vehicle>, restriction:conditional and
+// restriction:<vehicle>:conditional tag.
+func RestrictionTypes(tags element.Tags) []Restriction {
+	var keys []string
+	for k := range tags {
+		if k == "restriction" || strings.HasPrefix(k, "restriction:") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var result []Restriction
+	for _, k := range keys {
+		vehicle := strings.TrimPrefix(strings.TrimPrefix(k, "restriction"), ":")
+		if vehicle != "conditional" && !strings.HasSuffix(vehicle, ":conditional") {
+			result = append(result, Restriction{Type: tags[k], Vehicle: vehicle})
+			continue
+		}
+		vehicle = strings.TrimSuffix(strings.TrimSuffix(vehicle, "conditional"), ":")
+		for _, cond := range splitConditions(tags[k]) {
+			parts := strings.SplitN(cond, "@", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			result = append(result, Restriction{
+				Type:      strings.TrimSpace(parts[0]),
+				Vehicle:   vehicle,
+				Condition: strings.Trim(strings.TrimSpace(parts[1]), "()"),
+			})
+		}
+	}
+	return result
+}
+
+// splitConditions splits conditional values at all semicolons outside
+// of parentheses.
+func splitConditions(val string) []string {
+	var result []string
+	depth := 0
+	start := 0
+	for i, c := range val {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ';':
+			if depth == 0 {
+				result = append(result, val[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(result, val[start:])
+}
+
+// Restrictions validates the members of a restriction relation and
+// returns one Restriction for each restriction type and from/to pair.
+// Only no_entry restrictions can have multiple from ways and only no_exit
+// restrictions can have multiple to ways. From and to ways need to start
+// or end at the via node, or at the first/last of the connected via ways.
+// The way members need to be filled.
+func Restrictions(rel *element.Relation) ([]Restriction, error) {
+	types := RestrictionTypes(rel.Tags)
+	if len(types) == 0 {
+		return nil, errors.New("missing restriction tag")
+	}
+
+	var from, via, to []element.Member
+	for _, m := range rel.Members {
+		switch m.Role {
+		case "from":
+			from = append(from, m)
+		case "via":
+			via = append(via, m)
+		case "to":
+			to = append(to, m)
+		default:
+			continue
+		}
+		if m.Type == element.WAY && m.Way == nil {
+			return nil, fmt.Errorf("missing %s way %d", m.Role, m.Id)
+		}
+		if m.Type != element.WAY && (m.Role != "via" || m.Type != element.NODE) {
+			return nil, fmt.Errorf("%s member %d is not a way", m.Role, m.Id)
+		}
+	}
+
+	if len(from) == 0 {
+		return nil, errors.New("missing from way")
+	}
+	if len(to) == 0 {
+		return nil, errors.New("missing to way")
+	}
+	if len(via) == 0 {
+		return nil, errors.New("missing via member")
+	}
+	for _, t := range types {
+		if len(from) > 1 && t.Type != "no_entry" {
+			return nil, fmt.Errorf("multiple from ways for %s", t.Type)
+		}
+		if len(to) > 1 && t.Type != "no_exit" {
+			return nil, fmt.Errorf("multiple to ways for %s", t.Type)
+		}
+	}
+
+	var viaNode int64
+	var viaWays []int64
+	// nodes that from/to ways need to connect to
+	var fromNodes, toNodes []int64
+	if via[0].Type == element.NODE {
+		if len(via) > 1 {
+			return nil, errors.New("multiple via nodes")
+		}
+		viaNode = via[0].Id
+		fromNodes = []int64{viaNode}
+		toNodes = fromNodes
+	} else {
+		for i, m := range via {
+			if m.Type != element.WAY {
+				return nil, errors.New("mixed via nodes and ways")
+			}
+			if i > 0 && !waysConnected(via[i-1].Way, m.Way) {
+				return nil, fmt.Errorf("via way %d not connected to via way %d", m.Id, via[i-1].Id)
+			}
+			viaWays = append(viaWays, m.Id)
+		}
+		fromNodes = endNodes(via[0].Way)
+		toNodes = endNodes(via[len(via)-1].Way)
+	}
+
+	for _, m := range from {
+		if !endsAtAny(m.Way, fromNodes) {
+			return nil, fmt.Errorf("from way %d not connected to via", m.Id)
+		}
+	}
+	for _, m := range to {
+		if !endsAtAny(m.Way, toNodes) {
+			return nil, fmt.Errorf("to way %d not connected to via", m.Id)
+		}
+	}
+
+	var result []Restriction
+	for _, t := range types {
+		for _, f := range from {
+			for _, tw := range to {
+				r := t
+				r.From = f.Id
+				r.ViaNode = viaNode
+				r.ViaWays = viaWays
+				r.To = tw.Id
+				result = append(result, r)
+			}
+		}
+	}
+	return result, nil
+}
+
+func endNodes(w *element.Way) []int64 {
+	if len(w.Refs) == 0 {
+		return nil
+	}
+	return []int64{w.Refs[0], w.Refs[len(w.Refs)-1]}
+}
+
+func endsAtAny(w *element.Way, nodes []int64) bool {
+	for _, end := range endNodes(w) {
+		for _, n := range nodes {
+			if end == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func waysConnected(a, b *element.Way) bool {
+	return endsAtAny(a, endNodes(b))
+}
+
+// RestrictionGeometry builds a GeometryCollection with the LineStrings of
+// the from way, the via ways or the point of the via node, and the to
+// way of r. The way members of rel need to be filled with nodes.
+func RestrictionGeometry(g *geos.Geos, rel *element.Relation, r Restriction) (*geos.Geom, error) {
+	ways := make(map[int64]*element.Way)
+	for _, m := range rel.Members {
+		if m.Way != nil {
+			ways[m.Id] = m.Way
+		}
+	}
+
+	var geoms []*geos.Geom
+	addLine := func(id int64) error {
+		line, err := LineString(g, ways[id].Nodes)
+		if err != nil {
+			return err
+		}
+		// LineString is destroyed later, but GeometryCollection takes
+		// ownership
+		geoms = append(geoms, g.Clone(line))
+		return nil
+	}
+
+	if err := addLine(r.From); err != nil {
+		return nil, err
+	}
+	if r.ViaNode != 0 {
+		from := ways[r.From]
+		for i, ref := range from.Refs {
+			if ref != r.ViaNode || i >= len(from.Nodes) {
+				continue
+			}
+			point, err := Point(g, from.Nodes[i])
+			if err != nil {
+				return nil, err
+			}
+			geoms = append(geoms, g.Clone(point))
+			break
+		}
+	}
+	for _, id := range r.ViaWays {
+		if err := addLine(id); err != nil {
+			return nil, err
+		}
+	}
+	if err := addLine(r.To); err != nil {
+		return nil, err
+	}
+
+	geom := g.GeometryCollection(geoms)
+	if geom == nil {
+		return nil, errors.New("unable to create restriction geometry")
+	}
+	return geom, nil
+}
@@ -0,0 +1,154 @@
+package geom
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+func restrictionWay(id int64, role string, refs ...int64) element.Member {
+	way := &element.Way{OSMElem: element.OSMElem{Id: id}, Refs: refs}
+	return element.Member{Id: id, Type: element.WAY, Role: role, Way: way}
+}
+
+func TestRestrictionTypes(t *testing.T) {
+	tags := element.Tags{
+		"type":                        "restriction",
+		"restriction":                 "no_left_turn",
+		"restriction:hgv":             "no_right_turn",
+		"restriction:conditional":     "no_u_turn @ (Mo-Fr 07:00-09:00; Sa 10:00-12:00); only_straight_on @ wet",
+		"restriction:bus:conditional": "no_left_turn @ (22:00-06:00)",
+		"except":                      "bicycle",
+	}
+	expected := []Restriction{
+		{Type: "no_left_turn"},
+		{Type: "no_left_turn", Vehicle: "bus", Condition: "22:00-06:00"},
+		{Type: "no_u_turn", Condition: "Mo-Fr 07:00-09:00; Sa 10:00-12:00"},
+		{Type: "only_straight_on", Condition: "wet"},
+		{Type: "no_right_turn", Vehicle: "hgv"},
+	}
+	if got := RestrictionTypes(tags); !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected restriction types %#v", got)
+	}
+}
+
+func TestRestrictions(t *testing.T) {
+	viaNode := element.Member{Id: 10, Type: element.NODE, Role: "via"}
+	for _, tc := range []struct {
+		name     string
+		tags     element.Tags
+		members  []element.Member
+		expected []Restriction
+		err      string
+	}{
+		{
+			name: "via node",
+			tags: element.Tags{"restriction": "no_left_turn"},
+			members: []element.Member{
+				restrictionWay(1, "from", 1, 10),
+				viaNode,
+				restrictionWay(2, "to", 10, 2),
+			},
+			expected: []Restriction{{Type: "no_left_turn", From: 1, ViaNode: 10, To: 2}},
+		},
+		{
+			name: "via ways",
+			tags: element.Tags{"restriction": "no_u_turn"},
+			members: []element.Member{
+				restrictionWay(1, "from", 1, 10),
+				restrictionWay(3, "via", 11, 10),
+				restrictionWay(4, "via", 11, 12),
+				restrictionWay(2, "to", 2, 12),
+			},
+			expected: []Restriction{{Type: "no_u_turn", From: 1, ViaWays: []int64{3, 4}, To: 2}},
+		},
+		{
+			name: "no_entry with multiple from",
+			tags: element.Tags{"restriction": "no_entry"},
+			members: []element.Member{
+				restrictionWay(1, "from", 1, 10),
+				restrictionWay(3, "from", 3, 10),
+				viaNode,
+				restrictionWay(2, "to", 10, 2),
+			},
+			expected: []Restriction{
+				{Type: "no_entry", From: 1, ViaNode: 10, To: 2},
+				{Type: "no_entry", From: 3, ViaNode: 10, To: 2},
+			},
+		},
+		{
+			name: "multiple from",
+			tags: element.Tags{"restriction": "no_left_turn"},
+			members: []element.Member{
+				restrictionWay(1, "from", 1, 10),
+				restrictionWay(3, "from", 3, 10),
+				viaNode,
+				restrictionWay(2, "to", 10, 2),
+			},
+			err: "multiple from ways for no_left_turn",
+		},
+		{
+			name: "missing tag",
+			tags: element.Tags{"type": "restriction"},
+			err:  "missing restriction tag",
+		},
+		{
+			name: "missing via",
+			tags: element.Tags{"restriction": "no_left_turn"},
+			members: []element.Member{
+				restrictionWay(1, "from", 1, 10),
+				restrictionWay(2, "to", 10, 2),
+			},
+			err: "missing via member",
+		},
+		{
+			name: "via node in the middle",
+			tags: element.Tags{"restriction": "no_left_turn"},
+			members: []element.Member{
+				restrictionWay(1, "from", 1, 10, 3),
+				viaNode,
+				restrictionWay(2, "to", 10, 2),
+			},
+			err: "from way 1 not connected to via",
+		},
+		{
+			name: "disconnected via ways",
+			tags: element.Tags{"restriction": "no_u_turn"},
+			members: []element.Member{
+				restrictionWay(1, "from", 1, 10),
+				restrictionWay(3, "via", 11, 10),
+				restrictionWay(4, "via", 13, 12),
+				restrictionWay(2, "to", 2, 12),
+			},
+			err: "via way 4 not connected to via way 3",
+		},
+		{
+			name: "via node as to",
+			tags: element.Tags{"restriction": "no_left_turn"},
+			members: []element.Member{
+				restrictionWay(1, "from", 1, 10),
+				viaNode,
+				{Id: 10, Type: element.NODE, Role: "to"},
+			},
+			err: "to member 10 is not a way",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rel := &element.Relation{OSMElem: element.OSMElem{Tags: tc.tags}, Members: tc.members}
+			got, err := Restrictions(rel)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("unexpected restrictions %#v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package geom
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+// IsRouteStop returns true for stop and platform roles of route
+// relations (e.g. stop, stop_exit_only or platform_entry_only).
+func IsRouteStop(role string) bool {
+	return strings.HasPrefix(role, "stop") || strings.HasPrefix(role, "platform")
+}
+
+// Route builds a MultiLineString from all way members of a route
+// relation. Ways are joined in the order of the members. Ways with a
+// forward role keep their direction, ways with a backward role are
+// reversed and all other ways are reversed if required to connect to the
+// previous way. Members of resolved sub-relations (e.g. routes of a
+// superroute) are included. Stop and platform members are ignored, see
+// RouteStops. Parts without forward or backward ways are merged with
+// LineMerge afterwards, parts with these roles keep their direction.
+// Returns the geometry and the number of gaps between the remaining
+// parts. The way members need to be filled with nodes.
+func Route(g *geos.Geos, rel *element.Relation) (*geos.Geom, int, error) {
+	parts := routeLines(rel.FlatMembers())
+	var lines, merge []*geos.Geom
+	// merged lines are inserted at the position of the first mergeable
+	// part
+	mergePos := -1
+	for _, part := range parts {
+		line, err := LineString(g, part.nodes)
+		if err != nil {
+			if err == ErrorOneNodeWay {
+				continue
+			}
+			return nil, 0, err
+		}
+		// LineString is destroyed later, but LineMerge and
+		// MultiLineString take ownership
+		line = g.Clone(line)
+		if part.fixed {
+			lines = append(lines, line)
+			continue
+		}
+		if mergePos == -1 {
+			mergePos = len(lines)
+		}
+		merge = append(merge, line)
+	}
+	if len(merge) > 0 {
+		merged := g.LineMerge(merge)
+		if len(merged) == 0 {
+			return nil, 0, errors.New("unable to merge route lines")
+		}
+		lines = append(lines[:mergePos], append(merged, lines[mergePos:]...)...)
+	}
+	if len(lines) == 0 {
+		return nil, 0, newGeomError("route relation without ways", 0)
+	}
+	geom := g.MultiLineString(lines)
+	if geom == nil {
+		return nil, 0, errors.New("unable to create route geometry")
+	}
+	return geom, len(lines) - 1, nil
+}
+
+// routeLine is a continuous line of one or more way members. fixed is
+// true if the line contains ways with a forward or backward role.
+type routeLine struct {
+	nodes []element.Node
+	fixed bool
+}
+
+// routeLines joins the nodes of all way members into continuous lines.
+func routeLines(members []element.Member) []routeLine {
+	var lines []routeLine
+	var current []element.Node
+	// current line is a single way without role, which we can reverse
+	reversible := false
+	// current line contains ways with forward or backward role
+	currentFixed := false
+
+	for _, m := range members {
+		if m.Type != element.WAY || m.Way == nil || IsRouteStop(m.Role) {
+			continue
+		}
+		nodes := m.Way.Nodes
+		if len(nodes) < 2 {
+			continue
+		}
+		forward := m.Role == "forward"
+		backward := m.Role == "backward"
+		if backward {
+			nodes = reversedNodes(nodes)
+		}
+		fixed := forward || backward
+
+		if current != nil {
+			first, last := nodes[0], nodes[len(nodes)-1]
+			end := current[len(current)-1]
+			start := current[0]
+			joined := true
+			switch {
+			case nodesEqual(end, first):
+				current = append(current, nodes[1:]...)
+			case !fixed && nodesEqual(end, last):
+				current = append(current, reversedNodes(nodes)[1:]...)
+			case reversible && nodesEqual(start, first):
+				current = append(reversedNodes(current), nodes[1:]...)
+			case reversible && !fixed && nodesEqual(start, last):
+				current = append(reversedNodes(current), reversedNodes(nodes)[1:]...)
+			default:
+				joined = false
+			}
+			if joined {
+				reversible = false
+				currentFixed = currentFixed || fixed
+				continue
+			}
+			// gap
+			lines = append(lines, routeLine{current, currentFixed})
+		}
+		current = append([]element.Node(nil), nodes...)
+		reversible = !fixed
+		currentFixed = fixed
+	}
+	if current != nil {
+		lines = append(lines, routeLine{current, currentFixed})
+	}
+	return lines
+}
+
+func reversedNodes(nodes []element.Node) []element.Node {
+	result := make([]element.Node, len(nodes))
+	for i, nd := range nodes {
+		result[len(nodes)-1-i] = nd
+	}
+	return result
+}
+
+// RouteStops builds a MultiPoint from all stop and platform members of a
+// route relation, including stops of resolved sub-relations. Node
+// members need to be filled with Member.Node, way members with the nodes
+// of the way. Ways are added as a point on the surface of the way.
+func RouteStops(g *geos.Geos, rel *element.Relation) (*geos.Geom, error) {
+	var points []*geos.Geom
+	for _, m := range rel.FlatMembers() {
+		if !IsRouteStop(m.Role) {
+			continue
+		}
+		var point *geos.Geom
+		if m.Type == element.NODE && m.Node != nil {
+			point = g.Point(m.Node.Long, m.Node.Lat)
+		} else if m.Type == element.WAY && m.Way != nil {
+			var geom *geos.Geom
+			var err error
+			if m.Way.IsClosed() {
+				geom, err = Polygon(g, m.Way.Nodes)
+			} else {
+				geom, err = LineString(g, m.Way.Nodes)
+			}
+			if err != nil {
+				continue
+			}
+			point = g.PointOnSurface(geom)
+		}
+		if point != nil {
+			points = append(points, point)
+		}
+	}
+	if len(points) == 0 {
+		return nil, newGeomError("route relation without stops", 0)
+	}
+	geom := g.MultiPoint(points)
+	if geom == nil {
+		return nil, errors.New("unable to create route stops geometry")
+	}
+	return geom, nil
+}
@@ -0,0 +1,230 @@
+package geom
+
+import (
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func routeWay(id int64, role string, coords ...float64) element.Member {
+	way := &element.Way{OSMElem: element.OSMElem{Id: id}}
+	for i := 0; i < len(coords); i += 2 {
+		way.Nodes = append(way.Nodes, element.Node{Long: coords[i], Lat: coords[i+1]})
+	}
+	return element.Member{Id: id, Type: element.WAY, Role: role, Way: way}
+}
+
+func lineCoords(nodes []element.Node) []float64 {
+	var coords []float64
+	for _, nd := range nodes {
+		coords = append(coords, nd.Long, nd.Lat)
+	}
+	return coords
+}
+
+func TestRouteLines(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		members  []element.Member
+		expected [][]float64
+		fixed    []bool
+	}{
+		{
+			name: "ordered",
+			members: []element.Member{
+				routeWay(1, "", 0, 0, 1, 0),
+				routeWay(2, "", 1, 0, 2, 0),
+			},
+			expected: [][]float64{{0, 0, 1, 0, 2, 0}},
+		},
+		{
+			name: "reversed ways",
+			members: []element.Member{
+				routeWay(1, "", 1, 0, 0, 0),
+				routeWay(2, "", 2, 0, 1, 0),
+				routeWay(3, "", 2, 0, 3, 0),
+			},
+			expected: [][]float64{{0, 0, 1, 0, 2, 0, 3, 0}},
+		},
+		{
+			name: "backward role",
+			members: []element.Member{
+				routeWay(1, "forward", 0, 0, 1, 0),
+				routeWay(2, "backward", 2, 0, 1, 0),
+			},
+			expected: [][]float64{{0, 0, 1, 0, 2, 0}},
+		},
+		{
+			name: "forward role does not connect in reverse",
+			members: []element.Member{
+				routeWay(1, "forward", 0, 0, 1, 0),
+				routeWay(2, "forward", 2, 0, 1, 0),
+			},
+			expected: [][]float64{{0, 0, 1, 0}, {2, 0, 1, 0}},
+		},
+		{
+			name: "stops and platforms",
+			members: []element.Member{
+				{Id: 10, Type: element.NODE, Role: "stop"},
+				routeWay(1, "", 0, 0, 1, 0),
+				routeWay(3, "platform", 5, 5, 6, 6),
+				routeWay(2, "", 1, 0, 2, 0),
+			},
+			expected: [][]float64{{0, 0, 1, 0, 2, 0}},
+		},
+		{
+			name: "gap",
+			members: []element.Member{
+				routeWay(1, "", 0, 0, 1, 0),
+				routeWay(2, "", 5, 0, 6, 0),
+				routeWay(3, "", 7, 0, 6, 0),
+			},
+			expected: [][]float64{{0, 0, 1, 0}, {5, 0, 6, 0, 7, 0}},
+		},
+		{
+			name: "gap and backward role",
+			members: []element.Member{
+				routeWay(1, "", 0, 0, 1, 0),
+				routeWay(2, "", 5, 0, 6, 0),
+				routeWay(3, "backward", 1, 0, 2, 0),
+			},
+			expected: [][]float64{{0, 0, 1, 0}, {5, 0, 6, 0}, {2, 0, 1, 0}},
+			fixed:    []bool{false, false, true},
+		},
+	} {
+		lines := routeLines(tc.members)
+		if len(lines) != len(tc.expected) {
+			t.Errorf("%s: unexpected lines %v", tc.name, lines)
+			continue
+		}
+		for i := range lines {
+			if tc.fixed != nil && lines[i].fixed != tc.fixed[i] {
+				t.Errorf("%s: unexpected fixed line %d", tc.name, i)
+			}
+			coords := lineCoords(lines[i].nodes)
+			if len(coords) != len(tc.expected[i]) {
+				t.Errorf("%s: unexpected line %v", tc.name, coords)
+				continue
+			}
+			for j := range coords {
+				if coords[j] != tc.expected[i][j] {
+					t.Errorf("%s: unexpected line %v", tc.name, coords)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestRoute(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	rel := &element.Relation{Members: []element.Member{
+		routeWay(1, "", 0, 0, 1, 0),
+		routeWay(2, "", 5, 0, 6, 0),
+		// connects the second part with the first part
+		routeWay(3, "", 1, 0, 5, 0),
+		routeWay(4, "", 20, 0, 21, 0),
+	}}
+	geom, gaps, err := Route(g, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Destroy(geom)
+	if gaps != 1 {
+		t.Error("unexpected gaps", gaps)
+	}
+	if g.Type(geom) != "MultiLineString" || g.NumGeoms(geom) != 2 {
+		t.Error("unexpected geometry", g.AsWkt(geom))
+	}
+	if geom.Length() != 7 {
+		t.Error("unexpected length", geom.Length())
+	}
+}
+
+func TestRouteBackwardGap(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	rel := &element.Relation{Members: []element.Member{
+		routeWay(1, "", 0, 0, 1, 0),
+		routeWay(2, "", 5, 0, 6, 0),
+		// touches the first part, but only in the reversed direction
+		routeWay(3, "backward", 1, 0, 2, 0),
+		routeWay(4, "", 20, 0, 21, 0),
+	}}
+	geom, gaps, err := Route(g, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Destroy(geom)
+	if gaps != 3 {
+		t.Error("unexpected gaps", gaps)
+	}
+	if g.Type(geom) != "MultiLineString" || g.NumGeoms(geom) != 4 {
+		t.Fatal("unexpected geometry", g.AsWkt(geom))
+	}
+	if geom.Length() != 4 {
+		t.Error("unexpected length", geom.Length())
+	}
+	// backward part is not merged and keeps its direction
+	if b := g.Geoms(geom)[3].Bounds(); b.MinX != 1 || b.MaxX != 2 {
+		t.Error("unexpected backward part", g.AsWkt(geom))
+	}
+}
+
+func TestRouteSubRelations(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	// superroute with two connected routes
+	rel := &element.Relation{Members: []element.Member{
+		{Id: 10, Type: element.RELATION, Rel: &element.Relation{Members: []element.Member{
+			routeWay(1, "", 0, 0, 1, 0),
+			{Id: 20, Type: element.NODE, Role: "stop", Node: &element.Node{Long: 0, Lat: 0}},
+		}}},
+		{Id: 11, Type: element.RELATION, Rel: &element.Relation{Members: []element.Member{
+			routeWay(2, "", 2, 0, 1, 0),
+			{Id: 21, Type: element.NODE, Role: "stop", Node: &element.Node{Long: 2, Lat: 0}},
+		}}},
+	}}
+	geom, gaps, err := Route(g, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Destroy(geom)
+	if gaps != 0 || geom.Length() != 2 {
+		t.Error("unexpected geometry", gaps, g.AsWkt(geom))
+	}
+
+	stops, err := RouteStops(g, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Destroy(stops)
+	if g.NumGeoms(stops) != 2 {
+		t.Error("unexpected stops", g.AsWkt(stops))
+	}
+}
+
+func TestRouteStops(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	rel := &element.Relation{Members: []element.Member{
+		{Id: 10, Type: element.NODE, Role: "stop", Node: &element.Node{Long: 1, Lat: 1}},
+		{Id: 11, Type: element.NODE, Role: "", Node: &element.Node{Long: 2, Lat: 2}},
+		routeWay(1, "", 0, 0, 1, 0),
+		routeWay(2, "platform_entry_only", 0, 0, 2, 0, 2, 2, 0, 2, 0, 0),
+	}}
+	geom, err := RouteStops(g, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Destroy(geom)
+	if g.Type(geom) != "MultiPoint" || g.NumGeoms(geom) != 2 {
+		t.Error("unexpected geometry", g.AsWkt(geom))
+	}
+}
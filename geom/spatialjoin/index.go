@@ -0,0 +1,313 @@
+/*
+Package spatialjoin provides an in-memory index of polygons for
+spatial_join columns.
+*/
+package spatialjoin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+// IndexFileName is the name of the saved index in the cache dir. It is
+// written by imposm import -diff and updated by each diff import.
+const IndexFileName = "spatial_join.json"
+
+type feature struct {
+	table  string
+	id     int64
+	tags   element.Tags
+	geom   *geos.Geom
+	area   float64
+	bounds geos.Bounds
+	// for quick contains checks
+	prep   *geos.PreparedGeom
+	prepMu *sync.Mutex
+}
+
+type op struct {
+	remove bool
+	// table of the removed features, all tables if empty
+	table string
+	id    int64
+	f     *feature
+}
+
+// Index contains the polygons of all spatial join source tables.
+// Added and removed polygons are only visible after Apply, so that the
+// index does not change while other geometries are inserted. Index is safe
+// for concurrent use.
+type Index struct {
+	mu     sync.RWMutex
+	tables map[string]struct{}
+	// features by ID, for all tables
+	features map[int64][]*feature
+	tree     *geos.Index
+	// features in order of the tree
+	treeFeatures []*feature
+
+	pendingMu sync.Mutex
+	pending   []op
+}
+
+func NewIndex() *Index {
+	return &Index{
+		tables:   make(map[string]struct{}),
+		features: make(map[int64][]*feature),
+	}
+}
+
+// AddTable registers a spatial join source table.
+func (idx *Index) AddTable(table string) {
+	idx.mu.Lock()
+	idx.tables[table] = struct{}{}
+	idx.mu.Unlock()
+}
+
+// HasTable returns true if table is a spatial join source table.
+func (idx *Index) HasTable(table string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	_, ok := idx.tables[table]
+	return ok
+}
+
+// Add adds a copy of the polygon geom with the ID and tags of an element
+// of table.
+func (idx *Index) Add(table string, id int64, tags element.Tags, geom *geos.Geom) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	clone := g.Clone(geom)
+	if clone == nil {
+		return
+	}
+	tagsCopy := make(element.Tags, len(tags))
+	for k, v := range tags {
+		tagsCopy[k] = v
+	}
+	f := &feature{
+		table:  table,
+		id:     id,
+		tags:   tagsCopy,
+		geom:   clone,
+		area:   clone.Area(),
+		bounds: clone.Bounds(),
+	}
+	idx.pendingMu.Lock()
+	idx.pending = append(idx.pending, op{f: f})
+	idx.pendingMu.Unlock()
+}
+
+// Remove removes all polygons with the ID from table.
+func (idx *Index) Remove(table string, id int64) {
+	idx.pendingMu.Lock()
+	idx.pending = append(idx.pending, op{remove: true, table: table, id: id})
+	idx.pendingMu.Unlock()
+}
+
+// RemoveId removes all polygons with the ID from all tables.
+func (idx *Index) RemoveId(id int64) {
+	idx.Remove("", id)
+}
+
+// Apply adds and removes all pending polygons and rebuilds the index.
+// Returns the bounds of all added and removed polygons.
+func (idx *Index) Apply() []geos.Bounds {
+	idx.pendingMu.Lock()
+	pending := idx.pending
+	idx.pending = nil
+	idx.pendingMu.Unlock()
+
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if len(pending) == 0 && idx.tree != nil {
+		return nil
+	}
+
+	// the tree references the envelopes of the geometries
+	if idx.tree != nil {
+		g.IndexDestroy(idx.tree)
+	}
+
+	var changed []geos.Bounds
+	for _, o := range pending {
+		if !o.remove {
+			idx.features[o.f.id] = append(idx.features[o.f.id], o.f)
+			changed = append(changed, o.f.bounds)
+			continue
+		}
+		var keep []*feature
+		for _, f := range idx.features[o.id] {
+			if o.table != "" && f.table != o.table {
+				keep = append(keep, f)
+				continue
+			}
+			changed = append(changed, f.bounds)
+			if f.prep != nil {
+				g.PreparedDestroy(f.prep)
+			}
+			g.Destroy(f.geom)
+		}
+		if keep == nil {
+			delete(idx.features, o.id)
+		} else {
+			idx.features[o.id] = keep
+		}
+	}
+
+	idx.tree = g.CreateIndex()
+	idx.treeFeatures = idx.treeFeatures[:0]
+	for _, features := range idx.features {
+		for _, f := range features {
+			if f.prep == nil {
+				f.prep = g.Prepare(f.geom)
+				f.prepMu = &sync.Mutex{}
+			}
+			g.IndexAdd(idx.tree, f.geom)
+			idx.treeFeatures = append(idx.treeFeatures, f)
+		}
+	}
+	return changed
+}
+
+// Lookup returns the ID and tags of the smallest polygon of table that
+// contains a point on the surface of geom. Only polygons with all tags of
+// filter are considered. Returns false if no polygon contains geom.
+func (idx *Index) Lookup(table string, filter element.Tags, geom *geos.Geom) (int64, element.Tags, bool) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	point := g.PointOnSurface(geom)
+	if point == nil {
+		return 0, nil, false
+	}
+	defer g.Destroy(point)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.tree == nil {
+		return 0, nil, false
+	}
+
+	var match *feature
+	for _, i := range g.IndexQuery(idx.tree, point) {
+		f := idx.treeFeatures[i]
+		if f.table != table || !matchTags(f.tags, filter) {
+			continue
+		}
+		if match != nil && match.area <= f.area {
+			continue
+		}
+		f.prepMu.Lock()
+		contains := g.PreparedContains(f.prep, point)
+		f.prepMu.Unlock()
+		if contains {
+			match = f
+		}
+	}
+	if match == nil {
+		return 0, nil, false
+	}
+	return match.id, match.tags, true
+}
+
+func matchTags(tags, filter element.Tags) bool {
+	for k, v := range filter {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+type jsonFeature struct {
+	Table string
+	Id    int64
+	Tags  element.Tags
+	Wkb   []byte
+}
+
+// Save writes all applied polygons to fname. The index is written to a
+// temporary file first and renamed afterwards.
+func (idx *Index) Save(fname string) error {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	tmpname := fname + ".tmp"
+	f, err := os.Create(tmpname)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+
+	idx.mu.RLock()
+	for _, features := range idx.features {
+		for _, feat := range features {
+			wkb := g.AsWkb(feat.geom)
+			if wkb == nil {
+				idx.mu.RUnlock()
+				f.Close()
+				return errors.New("unable to create WKB for spatial join index")
+			}
+			if err := enc.Encode(jsonFeature{feat.table, feat.id, feat.tags, wkb}); err != nil {
+				idx.mu.RUnlock()
+				f.Close()
+				return err
+			}
+		}
+	}
+	idx.mu.RUnlock()
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpname, fname)
+}
+
+// Load adds all polygons from fname, as written by Save, and applies
+// them.
+func (idx *Index) Load(fname string) error {
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var feat jsonFeature
+		if err := dec.Decode(&feat); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		geom := g.FromWkb(feat.Wkb)
+		if geom == nil {
+			return errors.New("unable to read WKB from spatial join index")
+		}
+		idx.Add(feat.Table, feat.Id, feat.Tags, geom)
+		g.Destroy(geom)
+	}
+	idx.Apply()
+	return nil
+}
@@ -0,0 +1,89 @@
+package spatialjoin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestIndexLookup(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	idx := NewIndex()
+	idx.AddTable("admin")
+	idx.Add("admin", 1, element.Tags{"name": "country", "admin_level": "2"}, g.FromWkt("POLYGON((0 0, 100 0, 100 100, 0 100, 0 0))"))
+	idx.Add("admin", 2, element.Tags{"name": "state", "admin_level": "4"}, g.FromWkt("POLYGON((0 0, 50 0, 50 50, 0 50, 0 0))"))
+	idx.Add("other", 3, element.Tags{"name": "other"}, g.FromWkt("POLYGON((0 0, 10 0, 10 10, 0 10, 0 0))"))
+
+	// not visible before Apply
+	if _, _, ok := idx.Lookup("admin", nil, g.FromWkt("POINT(5 5)")); ok {
+		t.Error("unexpected match before Apply")
+	}
+	if changed := idx.Apply(); len(changed) != 3 {
+		t.Error("unexpected changed bounds", changed)
+	}
+
+	for _, tc := range []struct {
+		wkt    string
+		filter element.Tags
+		id     int64
+		name   string
+	}{
+		{"POINT(5 5)", nil, 2, "state"},
+		{"POINT(5 5)", element.Tags{"admin_level": "2"}, 1, "country"},
+		{"POINT(75 75)", nil, 1, "country"},
+		{"POINT(75 75)", element.Tags{"admin_level": "4"}, 0, ""},
+		{"POINT(200 200)", nil, 0, ""},
+		// line is joined by a point on the line, not by its centroid
+		{"LINESTRING(10 10, 10 20, 90 90)", nil, 2, "state"},
+	} {
+		id, tags, ok := idx.Lookup("admin", tc.filter, g.FromWkt(tc.wkt))
+		if ok != (tc.id != 0) || id != tc.id || tags["name"] != tc.name {
+			t.Errorf("unexpected lookup for %s %v: %d %v %v", tc.wkt, tc.filter, id, tags, ok)
+		}
+	}
+
+	idx.Remove("admin", 2)
+	if changed := idx.Apply(); len(changed) != 1 {
+		t.Error("unexpected changed bounds", changed)
+	}
+	if id, _, _ := idx.Lookup("admin", nil, g.FromWkt("POINT(5 5)")); id != 1 {
+		t.Error("unexpected lookup after remove", id)
+	}
+	if changed := idx.Apply(); changed != nil {
+		t.Error("unexpected changed bounds", changed)
+	}
+}
+
+func TestIndexSaveLoad(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	dir, err := ioutil.TempDir("", "imposm3test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "index.json")
+
+	idx := NewIndex()
+	idx.Add("admin", 1, element.Tags{"name": "country"}, g.FromWkt("POLYGON((0 0, 100 0, 100 100, 0 100, 0 0))"))
+	idx.Apply()
+	if err := idx.Save(fname); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewIndex()
+	if err := loaded.Load(fname); err != nil {
+		t.Fatal(err)
+	}
+	id, tags, ok := loaded.Lookup("admin", nil, g.FromWkt("POINT(5 5)"))
+	if !ok || id != 1 || tags["name"] != "country" {
+		t.Error("unexpected lookup", id, tags, ok)
+	}
+}
@@ -0,0 +1,102 @@
+package geom
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+// maxSubdivideDepth stops the subdivision of geometries that can't be
+// split any further, e.g. with many identical coordinates.
+const maxSubdivideDepth = 50
+
+// Subdivide splits (multi)polygons and (multi)linestrings into parts with
+// at most maxVertices coordinates, similar to ST_Subdivide of PostGIS.
+// Geometries are split recursively at the center of the longer side of
+// their bounds. All parts are single polygons or linestrings. Returns geom
+// itself if it does not need to be split. All other parts need to be
+// destroyed by the caller.
+func Subdivide(g *geos.Geos, geom *geos.Geom, maxVertices int) ([]*geos.Geom, error) {
+	var suffix string
+	switch t := g.Type(geom); {
+	case strings.HasSuffix(t, "Polygon"):
+		suffix = "Polygon"
+	case strings.HasSuffix(t, "LineString"):
+		suffix = "LineString"
+	default:
+		return []*geos.Geom{geom}, nil
+	}
+	if g.NumCoordinates(geom) <= maxVertices {
+		if g.Type(geom) == suffix {
+			return []*geos.Geom{geom}, nil
+		}
+		return singleParts(g, g.Clone(geom), suffix), nil
+	}
+	return subdivide(g, geom, maxVertices, suffix, 0)
+}
+
+func subdivide(g *geos.Geos, geom *geos.Geom, maxVertices int, suffix string, depth int) ([]*geos.Geom, error) {
+	bounds := geom.Bounds()
+	if bounds == geos.NilBounds {
+		return nil, errors.New("couldn't create bounds for geom")
+	}
+	halves := []geos.Bounds{bounds, bounds}
+	if bounds.MaxX-bounds.MinX >= bounds.MaxY-bounds.MinY {
+		mid := bounds.MinX + (bounds.MaxX-bounds.MinX)/2
+		halves[0].MaxX, halves[1].MinX = mid, mid
+	} else {
+		mid := bounds.MinY + (bounds.MaxY-bounds.MinY)/2
+		halves[0].MaxY, halves[1].MinY = mid, mid
+	}
+
+	var result []*geos.Geom
+	for _, b := range halves {
+		clipGeom := g.BoundsPolygon(b)
+		if clipGeom == nil {
+			return nil, errors.New("couldn't create bounds polygon")
+		}
+		part := g.Intersection(geom, clipGeom)
+		g.Destroy(clipGeom)
+		if part == nil {
+			return nil, errors.New("couldn't create intersection")
+		}
+		for _, p := range singleParts(g, part, suffix) {
+			if g.NumCoordinates(p) <= maxVertices || depth >= maxSubdivideDepth {
+				result = append(result, p)
+				continue
+			}
+			moreParts, err := subdivide(g, p, maxVertices, suffix, depth+1)
+			g.Destroy(p)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, moreParts...)
+		}
+	}
+	return result, nil
+}
+
+// singleParts returns all non-empty polygons or linestrings (depending on
+// suffix) of geom. Destroys geom, if it is not returned itself.
+func singleParts(g *geos.Geos, geom *geos.Geom, suffix string) []*geos.Geom {
+	if geom == nil {
+		return nil
+	}
+	if g.IsEmpty(geom) {
+		g.Destroy(geom)
+		return nil
+	}
+	if g.Type(geom) == suffix {
+		return []*geos.Geom{geom}
+	}
+	var result []*geos.Geom
+	for _, member := range g.Geoms(geom) {
+		if strings.HasSuffix(g.Type(member), suffix) {
+			// members of members, for collections with multi geometries
+			result = append(result, singleParts(g, g.Clone(member), suffix)...)
+		}
+	}
+	g.Destroy(geom)
+	return result
+}
@@ -0,0 +1,71 @@
+package geom
+
+import (
+	"math"
+	"testing"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestSubdivide(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	// 11 coordinates
+	geom := g.FromWkt("POLYGON((0 0, 10 0, 20 0, 30 0, 40 0, 40 10, 30 10, 20 10, 10 10, 0 10, 0 0))")
+	defer g.Destroy(geom)
+
+	parts, err := Subdivide(g, geom, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 {
+		t.Fatal("expected two parts", len(parts))
+	}
+	area := 0.0
+	for _, p := range parts {
+		if g.Type(p) != "Polygon" {
+			t.Error("unexpected type", g.Type(p))
+		}
+		if n := g.NumCoordinates(p); n > 8 {
+			t.Error("too many coordinates", n)
+		}
+		area += p.Area()
+		g.Destroy(p)
+	}
+	if math.Abs(area-geom.Area()) > 1e-9 {
+		t.Error("unexpected area", area)
+	}
+
+	parts, err = Subdivide(g, geom, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 || parts[0] != geom {
+		t.Error("expected unchanged geometry", parts)
+	}
+}
+
+func TestSubdivideLineString(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+
+	geom := g.FromWkt("LINESTRING(0 0, 1 0, 2 0, 3 0, 4 0, 5 0, 6 0, 7 0, 8 0, 9 0)")
+	defer g.Destroy(geom)
+
+	parts, err := Subdivide(g, geom, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	length := 0.0
+	for _, p := range parts {
+		if g.Type(p) != "LineString" {
+			t.Error("unexpected type", g.Type(p))
+		}
+		length += p.Length()
+		g.Destroy(p)
+	}
+	if len(parts) != 2 || length != 9 {
+		t.Error("unexpected parts", len(parts), length)
+	}
+}
@@ -0,0 +1,355 @@
+/*
+Package wkb converts WKB geometries to GeoJSON and decodes their
+coordinates.
+*/
+package wkb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/omniscale/imposm3/proj"
+)
+
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+
+	// EWKB flags
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSrid = 0x20000000
+)
+
+var ErrInvalid = errors.New("invalid WKB")
+
+type geometry struct {
+	typ int
+	// coordinates of points, linestrings and polygon rings
+	coords [][][2]float64
+	// members of multi geometries and collections
+	geoms []geometry
+}
+
+type reader struct {
+	buf []byte
+	pos int
+	// SRID of the first EWKB geometry with SRID
+	srid uint32
+}
+
+func (r *reader) read(n int) ([]byte, error) {
+	if r.pos+n > len(r.buf) {
+		return nil, ErrInvalid
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) uint32(order binary.ByteOrder) (uint32, error) {
+	b, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return order.Uint32(b), nil
+}
+
+func (r *reader) float64(order binary.ByteOrder) (float64, error) {
+	b, err := r.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(order.Uint64(b)), nil
+}
+
+func (r *reader) coords(order binary.ByteOrder, dims int) ([][2]float64, error) {
+	n, err := r.uint32(order)
+	if err != nil {
+		return nil, err
+	}
+	if int(n)*dims*8 > len(r.buf)-r.pos {
+		return nil, ErrInvalid
+	}
+	coords := make([][2]float64, n)
+	for i := range coords {
+		for d := 0; d < dims; d++ {
+			v, err := r.float64(order)
+			if err != nil {
+				return nil, err
+			}
+			if d < 2 {
+				coords[i][d] = v
+			}
+		}
+	}
+	return coords, nil
+}
+
+func (r *reader) geometry() (geometry, error) {
+	var g geometry
+	b, err := r.read(1)
+	if err != nil {
+		return g, err
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if b[0] == 1 {
+		order = binary.LittleEndian
+	}
+	typ, err := r.uint32(order)
+	if err != nil {
+		return g, err
+	}
+	dims := 2
+	if typ&ewkbZ != 0 {
+		dims++
+	}
+	if typ&ewkbM != 0 {
+		dims++
+	}
+	if typ&ewkbSrid != 0 {
+		srid, err := r.uint32(order)
+		if err != nil {
+			return g, err
+		}
+		if r.srid == 0 {
+			r.srid = srid
+		}
+	}
+	typ &^= ewkbZ | ewkbM | ewkbSrid
+	// ISO WKB with Z/M types (1001, 2001, 3001)
+	switch typ / 1000 {
+	case 1, 2:
+		dims++
+	case 3:
+		dims += 2
+	}
+	g.typ = int(typ % 1000)
+
+	switch g.typ {
+	case wkbPoint:
+		coords := make([][2]float64, 1)
+		for d := 0; d < dims; d++ {
+			v, err := r.float64(order)
+			if err != nil {
+				return g, err
+			}
+			if d < 2 {
+				coords[0][d] = v
+			}
+		}
+		if math.IsNaN(coords[0][0]) {
+			// empty point
+			coords = nil
+		}
+		g.coords = [][][2]float64{coords}
+	case wkbLineString:
+		coords, err := r.coords(order, dims)
+		if err != nil {
+			return g, err
+		}
+		g.coords = [][][2]float64{coords}
+	case wkbPolygon:
+		n, err := r.uint32(order)
+		if err != nil {
+			return g, err
+		}
+		for i := uint32(0); i < n; i++ {
+			coords, err := r.coords(order, dims)
+			if err != nil {
+				return g, err
+			}
+			g.coords = append(g.coords, coords)
+		}
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+		n, err := r.uint32(order)
+		if err != nil {
+			return g, err
+		}
+		for i := uint32(0); i < n; i++ {
+			member, err := r.geometry()
+			if err != nil {
+				return g, err
+			}
+			g.geoms = append(g.geoms, member)
+		}
+	default:
+		return g, fmt.Errorf("unsupported WKB geometry type %d", g.typ)
+	}
+	return g, nil
+}
+
+func parse(wkb []byte) (geometry, error) {
+	g, _, err := parseSrid(wkb)
+	return g, err
+}
+
+func parseSrid(wkb []byte) (geometry, int, error) {
+	r := reader{buf: wkb}
+	g, err := r.geometry()
+	if err != nil {
+		return g, 0, err
+	}
+	if r.pos != len(wkb) {
+		return g, 0, ErrInvalid
+	}
+	return g, int(r.srid), nil
+}
+
+// Coords are the coordinates of all linestrings and polygons of a
+// geometry.
+type Coords struct {
+	// Srid of EWKB geometries, 0 for WKB
+	Srid int
+	// Lines are the coordinates of all linestrings.
+	Lines [][][2]float64
+	// Polygons are the rings of all polygons, the exterior ring first.
+	Polygons [][][][2]float64
+}
+
+// DecodeCoords returns the coordinates of all linestrings and polygons of
+// the WKB or EWKB geometry, including all members of multi geometries and
+// collections. Points are ignored.
+func DecodeCoords(wkb []byte) (*Coords, error) {
+	g, srid, err := parseSrid(wkb)
+	if err != nil {
+		return nil, err
+	}
+	coords := &Coords{Srid: srid}
+	coords.add(g)
+	return coords, nil
+}
+
+func (c *Coords) add(g geometry) {
+	switch g.typ {
+	case wkbLineString:
+		c.Lines = append(c.Lines, g.coords[0])
+	case wkbPolygon:
+		if len(g.coords) > 0 {
+			c.Polygons = append(c.Polygons, g.coords)
+		}
+	}
+	for _, member := range g.geoms {
+		c.add(member)
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+var geojsonTypes = map[int]string{
+	wkbPoint:              "Point",
+	wkbLineString:         "LineString",
+	wkbPolygon:            "Polygon",
+	wkbMultiPoint:         "MultiPoint",
+	wkbMultiLineString:    "MultiLineString",
+	wkbMultiPolygon:       "MultiPolygon",
+	wkbGeometryCollection: "GeometryCollection",
+}
+
+// GeoJSON returns the GeoJSON geometry object of the WKB or EWKB geometry.
+// EWKB geometries in EPSG:3857 are transformed to EPSG:4326, as GeoJSON
+// coordinates are always long/lat.
+func GeoJSON(wkb []byte) (string, error) {
+	g, srid, err := parseSrid(wkb)
+	if err != nil {
+		return "", err
+	}
+	if srid == 3857 {
+		mercToWgs(g)
+	}
+	buf := &bytes.Buffer{}
+	writeGeoJSON(buf, g)
+	return buf.String(), nil
+}
+
+// mercToWgs transforms all coordinates of g from EPSG:3857 to EPSG:4326.
+func mercToWgs(g geometry) {
+	for _, coords := range g.coords {
+		for i, c := range coords {
+			coords[i][0], coords[i][1] = proj.MercToWgs(c[0], c[1])
+		}
+	}
+	for _, member := range g.geoms {
+		mercToWgs(member)
+	}
+}
+
+func writeGeoJSON(buf *bytes.Buffer, g geometry) {
+	buf.WriteString(`{"type":"`)
+	buf.WriteString(geojsonTypes[g.typ])
+	if g.typ == wkbGeometryCollection {
+		buf.WriteString(`","geometries":[`)
+		for i, member := range g.geoms {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeGeoJSON(buf, member)
+		}
+		buf.WriteString("]}")
+		return
+	}
+	buf.WriteString(`","coordinates":`)
+	writeGeoJSONCoords(buf, g)
+	buf.WriteByte('}')
+}
+
+func writeGeoJSONCoords(buf *bytes.Buffer, g geometry) {
+	switch g.typ {
+	case wkbPoint:
+		if len(g.coords[0]) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		writeGeoJSONPoint(buf, g.coords[0][0])
+	case wkbLineString:
+		writeGeoJSONLine(buf, g.coords[0])
+	case wkbPolygon:
+		buf.WriteByte('[')
+		for i, ring := range g.coords {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeGeoJSONLine(buf, ring)
+		}
+		buf.WriteByte(']')
+	default:
+		buf.WriteByte('[')
+		for i, member := range g.geoms {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeGeoJSONCoords(buf, member)
+		}
+		buf.WriteByte(']')
+	}
+}
+
+func writeGeoJSONPoint(buf *bytes.Buffer, c [2]float64) {
+	buf.WriteByte('[')
+	buf.WriteString(formatFloat(c[0]))
+	buf.WriteByte(',')
+	buf.WriteString(formatFloat(c[1]))
+	buf.WriteByte(']')
+}
+
+func writeGeoJSONLine(buf *bytes.Buffer, coords [][2]float64) {
+	buf.WriteByte('[')
+	for i, c := range coords {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeGeoJSONPoint(buf, c)
+	}
+	buf.WriteByte(']')
+}
@@ -0,0 +1,90 @@
+package wkb
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+const (
+	le0   = "0000000000000000"
+	le05  = "000000000000E03F"
+	le1   = "000000000000F03F"
+	le2   = "0000000000000040"
+	point = "0101000000" + le1 + le2
+)
+
+func TestConvert(t *testing.T) {
+	for _, tc := range []struct {
+		wkb     string
+		geojson string
+	}{
+		{point, `{"type":"Point","coordinates":[1,2]}`},
+		// EWKB with SRID 4326
+		{"0101000020E6100000" + le05 + le2, `{"type":"Point","coordinates":[0.5,2]}`},
+		// EWKB with SRID 3857, GeoJSON is transformed to EPSG:4326
+		{"0102000020110F000002000000" + "93107C45F81B7341" + le0 + "93107C45F81B63C1" + le0,
+			`{"type":"LineString","coordinates":[[180,0],[-90,0]]}`},
+		// big endian
+		{"00000000013FF00000000000004000000000000000", `{"type":"Point","coordinates":[1,2]}`},
+		{"010200000002000000" + le0 + le0 + le1 + le1,
+			`{"type":"LineString","coordinates":[[0,0],[1,1]]}`},
+		{"01030000000100000004000000" + le0 + le0 + le1 + le0 + le1 + le1 + le0 + le0,
+			`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`},
+		{"010400000002000000" + point + point,
+			`{"type":"MultiPoint","coordinates":[[1,2],[1,2]]}`},
+		{"010700000001000000" + point,
+			`{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]}]}`},
+		{"010700000000000000", `{"type":"GeometryCollection","geometries":[]}`},
+	} {
+		wkb, err := hex.DecodeString(tc.wkb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if geojson, err := GeoJSON(wkb); err != nil || geojson != tc.geojson {
+			t.Errorf("unexpected GeoJSON for %s: %s %v", tc.wkb, geojson, err)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"0101000000" + le1,
+		point + "00",
+		"010200000064000000" + le0 + le0,
+		"0108000000",
+	} {
+		wkb, _ := hex.DecodeString(s)
+		if _, err := GeoJSON(wkb); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
+
+func TestDecodeCoords(t *testing.T) {
+	line := "010200000002000000" + le0 + le0 + le1 + le1
+	polygon := "01030000000100000004000000" + le0 + le0 + le1 + le0 + le1 + le1 + le0 + le0
+	// EWKB GeometryCollection with SRID 4326
+	wkb, err := hex.DecodeString("0107000020E610000003000000" + point + line + polygon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coords, err := DecodeCoords(wkb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coords.Srid != 4326 {
+		t.Error("unexpected srid", coords.Srid)
+	}
+	if len(coords.Lines) != 1 || len(coords.Lines[0]) != 2 || coords.Lines[0][1] != [2]float64{1, 1} {
+		t.Error("unexpected lines", coords.Lines)
+	}
+	if len(coords.Polygons) != 1 || len(coords.Polygons[0]) != 1 || len(coords.Polygons[0][0]) != 4 {
+		t.Error("unexpected polygons", coords.Polygons)
+	}
+
+	wkb, _ = hex.DecodeString(point)
+	if coords, err := DecodeCoords(wkb); err != nil || coords.Srid != 0 || coords.Lines != nil || coords.Polygons != nil {
+		t.Error("unexpected coords for point", coords, err)
+	}
+}
@@ -5,6 +5,7 @@ package import_
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/omniscale/imposm3/cache"
 	"github.com/omniscale/imposm3/config"
@@ -12,9 +13,11 @@ import (
 	_ "github.com/omniscale/imposm3/database/postgis"
 	_ "github.com/omniscale/imposm3/database/sqlserver"
 	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/geom/spatialjoin"
 	"github.com/omniscale/imposm3/logging"
 	"github.com/omniscale/imposm3/mapping"
 	"github.com/omniscale/imposm3/reader"
+	"github.com/omniscale/imposm3/replication"
 	"github.com/omniscale/imposm3/stats"
 	"github.com/omniscale/imposm3/update/state"
 	"github.com/omniscale/imposm3/writer"
@@ -27,7 +30,7 @@ func Import() {
 		logging.SetQuiet(true)
 	}
 
-	if (config.ImportOptions.Write || config.ImportOptions.Read != "") && (config.ImportOptions.RevertDeploy || config.ImportOptions.RemoveBackup) {
+	if (config.ImportOptions.Write || len(config.ImportOptions.Read) > 0) && (config.ImportOptions.RevertDeploy || config.ImportOptions.RemoveBackup) {
 		log.Fatal("-revertdeploy and -removebackup not compatible with -read/-write")
 	}
 
@@ -36,14 +39,10 @@ func Import() {
 	}
 
 	var geometryLimiter *limit.Limiter
-	if (config.ImportOptions.Write || config.ImportOptions.Read != "") && config.BaseOptions.LimitTo != "" {
+	if regions := limitRegions(); (config.ImportOptions.Write || len(config.ImportOptions.Read) > 0) && len(regions) > 0 {
 		var err error
 		step := log.StartStep("Reading limitto geometries")
-		geometryLimiter, err = limit.NewFromGeoJSON(
-			config.BaseOptions.LimitTo,
-			config.BaseOptions.LimitToCacheBuffer,
-			config.BaseOptions.Srid,
-		)
+		geometryLimiter, err = limit.New(regions, config.BaseOptions.Srid)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -54,6 +53,13 @@ func Import() {
 	if err != nil {
 		log.Fatal("mapping file: ", err)
 	}
+	if config.ImportOptions.Write && tagmapping.HasNetworkTables() && !config.ImportOptions.Diff {
+		log.Fatal("network tables require -diff")
+	}
+	parentRelations := tagmapping.ParentRelations()
+	if config.ImportOptions.Write && parentRelations != nil && !config.ImportOptions.Diff {
+		log.Fatal("parent_relation_tags columns require -diff")
+	}
 
 	var db database.DB
 
@@ -67,6 +73,7 @@ func Import() {
 			ImportSchema:     config.BaseOptions.Schemas.Import,
 			ProductionSchema: config.BaseOptions.Schemas.Production,
 			BackupSchema:     config.BaseOptions.Schemas.Backup,
+			RegionSchemas:    config.BaseOptions.RegionSchemas(),
 		}
 		db, err = database.Open(conf, tagmapping)
 		if err != nil {
@@ -77,7 +84,7 @@ func Import() {
 
 	osmCache := cache.NewOSMCache(config.BaseOptions.CacheDir)
 
-	if config.ImportOptions.Read != "" && osmCache.Exists() {
+	if len(config.ImportOptions.Read) > 0 && osmCache.Exists() {
 		if config.ImportOptions.Overwritecache {
 			log.Printf("removing existing cache %s", config.BaseOptions.CacheDir)
 			err := osmCache.Remove()
@@ -93,7 +100,7 @@ func Import() {
 
 	var elementCounts *stats.ElementCounts
 
-	if config.ImportOptions.Read != "" {
+	if len(config.ImportOptions.Read) > 0 {
 		step := log.StartStep("Reading OSM data")
 		err = osmCache.Open()
 		if err != nil {
@@ -111,11 +118,14 @@ func Import() {
 			readLimiter = nil
 		}
 
-		err := reader.ReadPbf(config.ImportOptions.Read,
+		err := reader.ReadFiles(config.ImportOptions.Read,
 			osmCache,
 			progress,
 			tagmapping,
 			readLimiter,
+			// coords of all nodes are required for diff updates
+			// and for ways in existing caches
+			config.ImportOptions.Diff || config.ImportOptions.Appendcache,
 		)
 		if err != nil {
 			log.Fatal(err)
@@ -126,10 +136,10 @@ func Import() {
 		osmCache.Close()
 		log.StopStep(step)
 		if config.ImportOptions.Diff {
-			diffstate, err := state.FromPbf(config.ImportOptions.Read, config.ImportOptions.DiffStateBefore)
+			diffstate, err := initialDiffState()
 			if err != nil {
-				log.Print("error parsing diff state form PBF", err)
-			} else if diffstate != nil {
+				log.Print("error determining diff state for import file: ", err)
+			} else {
 				os.MkdirAll(config.BaseOptions.DiffDir, 0755)
 				err := state.WriteLastState(config.BaseOptions.DiffDir, diffstate)
 				if err != nil {
@@ -177,9 +187,22 @@ func Import() {
 		if diffCache != nil {
 			diffCache.Coords.SetLinearImport(true)
 			diffCache.Ways.SetLinearImport(true)
+			diffCache.Relations.SetLinearImport(true)
 		}
 		osmCache.Coords.SetReadOnly(true)
 
+		if tagmapping.SpatialJoin != nil {
+			buildSpatialJoinIndex(osmCache, tagmapping, geometryLimiter, config.BaseOptions.Srid)
+		}
+
+		var mpErrors *writer.MultiPolygonErrors
+		if tagmapping.MultiPolygons.ErrorsFile != "" {
+			mpErrors, err = writer.NewMultiPolygonErrors(tagmapping.MultiPolygons.ErrorsFile, config.BaseOptions.Srid, true)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
 		relations := osmCache.Relations.Iter()
 		relWriter := writer.NewRelationWriter(osmCache, diffCache,
 			tagmapping.SingleIdSpace,
@@ -188,12 +211,26 @@ func Import() {
 			tagmapping.PolygonMatcher(),
 			tagmapping.RelationMatcher(),
 			tagmapping.RelationMemberMatcher(),
+			tagmapping.RestrictionMatcher(),
 			config.BaseOptions.Srid)
 		relWriter.SetLimiter(geometryLimiter)
+		relWriter.SetParentRelations(parentRelations)
+		relWriter.SetMultiPolygonOptions(tagmapping.MultiPolygonOptions(config.BaseOptions.Srid))
+		relWriter.SetMultiPolygonErrors(mpErrors)
 		relWriter.EnableConcurrent()
 		relWriter.Start()
 		relWriter.Wait() // blocks till the Relations.Iter() finishes
-		osmCache.Relations.Close()
+		if mpErrors != nil {
+			if err := mpErrors.Close(); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if parentRelations != nil {
+			// ways query their parent relations, which requires the
+			// complete ways index from the relation import
+			diffCache.Ways.SetLinearImport(false)
+		}
 
 		ways := osmCache.Ways.Iter()
 		wayWriter := writer.NewWayWriter(osmCache, diffCache,
@@ -203,9 +240,29 @@ func Import() {
 			tagmapping.PolygonMatcher(), tagmapping.LineStringMatcher(),
 			config.BaseOptions.Srid)
 		wayWriter.SetLimiter(geometryLimiter)
+		wayWriter.SetParentRelations(parentRelations)
 		wayWriter.EnableConcurrent()
 		wayWriter.Start()
 		wayWriter.Wait() // blocks till the Ways.Iter() finishes
+		osmCache.Relations.Close()
+
+		if tagmapping.HasNetworkTables() {
+			// network ways are split at shared nodes, which requires
+			// the complete coords index from the way import
+			diffCache.Coords.SetLinearImport(false)
+			step := log.StartStep("Writing network edges")
+			ways := osmCache.Ways.Iter()
+			networkWriter := writer.NewNetworkWriter(osmCache, diffCache,
+				tagmapping.SingleIdSpace,
+				ways, db,
+				tagmapping.NetworkMatcher(),
+				config.BaseOptions.Srid)
+			networkWriter.SetLimiter(geometryLimiter)
+			networkWriter.EnableConcurrent()
+			networkWriter.Start()
+			networkWriter.Wait() // blocks till the Ways.Iter() finishes
+			log.StopStep(step)
+		}
 		osmCache.Ways.Close()
 
 		nodes := osmCache.Nodes.Iter()
@@ -228,6 +285,12 @@ func Import() {
 
 		if config.ImportOptions.Diff {
 			diffCache.Close()
+			if tagmapping.SpatialJoin != nil {
+				fname := filepath.Join(config.BaseOptions.CacheDir, spatialjoin.IndexFileName)
+				if err := tagmapping.SpatialJoin.Save(fname); err != nil {
+					log.Fatal(err)
+				}
+			}
 		}
 
 		log.StopStep(stepWrite)
@@ -293,3 +356,79 @@ func Import() {
 	log.StopStep(step)
 
 }
+
+// initialDiffState returns the state of the last diff before the
+// timestamp of the import files, minus -diff-state-before.
+func initialDiffState() (*state.DiffState, error) {
+	timestamp, err := state.FilesTimestamp(config.ImportOptions.Read)
+	if err != nil {
+		return nil, err
+	}
+	url := config.BaseOptions.ReplicationUrl
+	if url == "" && len(config.BaseOptions.ReplicationFeeds) > 0 {
+		// run switches to the finest feed
+		feeds := config.BaseOptions.ReplicationFeeds
+		url = feeds[len(feeds)-1].Url
+	}
+	if url == "" {
+		url = "http://planet.openstreetmap.org/replication/minute/"
+	}
+	return replication.StateBefore(url, timestamp.Add(-config.ImportOptions.DiffStateBefore))
+}
+
+// limitRegions returns the regions for -limitto, -bbox or the
+// limitto_regions.
+func limitRegions() []limit.Region {
+	var regions []limit.Region
+	for _, r := range config.BaseOptions.Regions() {
+		regions = append(regions, limit.Region{
+			Name:    r.Name,
+			LimitTo: r.LimitTo,
+			Bbox:    r.Bbox,
+			Buffer:  r.CacheBuffer,
+			Schema:  r.Schema,
+		})
+	}
+	return regions
+}
+
+// buildSpatialJoinIndex loads all polygons of the spatial_join source
+// tables into the SpatialJoin index of tagmapping, before the spatial_join
+// columns of the other tables are written.
+func buildSpatialJoinIndex(osmCache *cache.OSMCache, tagmapping *mapping.Mapping, limiter *limit.Limiter, srid int) {
+	step := log.StartStep("Building spatial join index")
+	defer log.StopStep(step)
+
+	sub := tagmapping.SpatialJoinMapping()
+	indexer := database.NewSpatialJoinIndexer(nil, tagmapping.SpatialJoin)
+	progress := stats.NewStatsReporter()
+
+	relWriter := writer.NewRelationWriter(osmCache, nil,
+		sub.SingleIdSpace,
+		osmCache.Relations.Iter(),
+		indexer, progress,
+		sub.PolygonMatcher(),
+		sub.RelationMatcher(),
+		sub.RelationMemberMatcher(),
+		sub.RestrictionMatcher(),
+		srid)
+	relWriter.SetLimiter(limiter)
+	relWriter.SetMultiPolygonOptions(sub.MultiPolygonOptions(srid))
+	relWriter.EnableConcurrent()
+	relWriter.Start()
+	relWriter.Wait()
+
+	wayWriter := writer.NewWayWriter(osmCache, nil,
+		sub.SingleIdSpace,
+		osmCache.Ways.Iter(), indexer,
+		progress,
+		sub.PolygonMatcher(), sub.LineStringMatcher(),
+		srid)
+	wayWriter.SetLimiter(limiter)
+	wayWriter.EnableConcurrent()
+	wayWriter.Start()
+	wayWriter.Wait()
+
+	progress.Stop()
+	tagmapping.SpatialJoin.Apply()
+}
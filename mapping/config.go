@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 
 	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom/spatialjoin"
 
 	"gopkg.in/yaml.v2"
 )
@@ -17,6 +18,9 @@ type Field struct {
 	Type       string                 `yaml:"type"`
 	Args       map[string]interface{} `yaml:"args"`
 	FromMember bool                   `yaml:"from_member"`
+
+	spatialJoin    *spatialJoin
+	parentRelation *parentRelation
 }
 
 type Table struct {
@@ -28,8 +32,32 @@ type Table struct {
 	Fields       []*Field              `yaml:"columns"` // TODO rename Fields internaly to Columns
 	OldFields    []*Field              `yaml:"fields"`
 	Filters      *Filters              `yaml:"filters"`
+	// History keeps updated and deleted rows with valid_from/valid_to
+	// timestamps instead of removing them.
+	History bool `yaml:"history"`
+	// RelationGeometry builds the geometry of relation tables.
+	RelationGeometry RelationGeometry `yaml:"relation_geometry"`
+	// InvalidRestrictions inserts all invalid restrictions into a
+	// restriction table, instead of the valid ones.
+	InvalidRestrictions bool `yaml:"invalid_restrictions"`
+	// GeometryTransform transforms the geometries of ways and relations
+	// before they are inserted, e.g. "point_on_surface" or "buffer 10".
+	GeometryTransform string `yaml:"geometry_transform"`
+
+	transform *Transform
 }
 
+// RelationGeometry is the geometry of relation tables.
+type RelationGeometry string
+
+const (
+	// RouteGeometry is a MultiLineString of all ways of a route.
+	RouteGeometry RelationGeometry = "route"
+	// RouteStopsGeometry is a MultiPoint of all stops and platforms of
+	// a route.
+	RouteStopsGeometry RelationGeometry = "route_stops"
+)
+
 type GeneralizedTable struct {
 	Name            string
 	SourceTableName string  `yaml:"source"`
@@ -50,9 +78,13 @@ type Mapping struct {
 	GeneralizedTables GeneralizedTables `yaml:"generalized_tables"`
 	Tags              Tags              `yaml:"tags"`
 	Areas             Areas             `yaml:"areas"`
+	MultiPolygons     MultiPolygons     `yaml:"multipolygons"`
 	// SingleIdSpace mangles the overlapping node/way/relation IDs
 	// to be unique (nodes positive, ways negative, relations negative -1e17)
 	SingleIdSpace bool `yaml:"use_single_id_space"`
+	// SpatialJoin contains the polygons of all spatial_join source tables.
+	// nil if there are no spatial_join columns.
+	SpatialJoin *spatialjoin.Index `yaml:"-"`
 }
 
 type Areas struct {
@@ -145,6 +177,10 @@ func (tt *TableType) UnmarshalJSON(data []byte) error {
 		*tt = RelationTable
 	case `"relation_member"`:
 		*tt = RelationMemberTable
+	case `"network"`:
+		*tt = NetworkTable
+	case `"restriction"`:
+		*tt = RestrictionTable
 	default:
 		return errors.New("unknown type " + string(data))
 	}
@@ -158,8 +194,33 @@ const (
 	GeometryTable       TableType = "geometry"
 	RelationTable       TableType = "relation"
 	RelationMemberTable TableType = "relation_member"
+	// NetworkTable contains the ways split into edges at shared nodes.
+	NetworkTable TableType = "network"
+	// RestrictionTable contains the resolved from/via/to members of turn
+	// restriction relations.
+	RestrictionTable TableType = "restriction"
 )
 
+// withGeometryTables returns true if tables of this type also include
+// all geometry tables.
+func (tt TableType) withGeometryTables() bool {
+	return tt != NetworkTable && tt != RestrictionTable
+}
+
+// matchesType returns true if the table receives elements of tables of
+// this type. Geometry tables receive elements of all types, point tables
+// with a point geometry_transform receive polygons as well.
+func (t *Table) matchesType(tableType TableType) bool {
+	if t.Type == tableType {
+		return true
+	}
+	if t.Type == GeometryTable && tableType.withGeometryTables() {
+		return true
+	}
+	return tableType == PolygonTable && t.Type == PointTable &&
+		t.transform != nil && t.transform.toPoint()
+}
+
 func NewMapping(filename string) (*Mapping, error) {
 	f, err := ioutil.ReadFile(filename)
 	if err != nil {
@@ -192,6 +253,15 @@ func (t *Table) ExtraTags() map[Key]bool {
 	return tags
 }
 
+func (t *Table) hasField(fieldType string) bool {
+	for _, f := range t.Fields {
+		if f.Type == fieldType {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Mapping) prepare() error {
 	for name, t := range m.Tables {
 		t.Name = name
@@ -199,6 +269,44 @@ func (m *Mapping) prepare() error {
 			// todo deprecate 'fields'
 			t.Fields = t.OldFields
 		}
+		switch t.RelationGeometry {
+		case "":
+		case RouteGeometry, RouteStopsGeometry:
+			if t.Type != RelationTable {
+				return fmt.Errorf("relation_geometry requires a relation table (%s)", name)
+			}
+		default:
+			return fmt.Errorf("unknown relation_geometry %s (%s)", t.RelationGeometry, name)
+		}
+		if t.Type == NetworkTable && (!t.hasField("edge_source") || !t.hasField("edge_target")) {
+			return fmt.Errorf("network table requires edge_source and edge_target columns (%s)", name)
+		}
+		if t.InvalidRestrictions && t.Type != RestrictionTable {
+			return fmt.Errorf("invalid_restrictions requires a restriction table (%s)", name)
+		}
+		if t.GeometryTransform != "" {
+			transform, err := parseTransform(t.GeometryTransform)
+			if err != nil {
+				return fmt.Errorf("%v (%s)", err, name)
+			}
+			if err := transform.checkTableType(t.Type); err != nil {
+				return fmt.Errorf("%v (%s)", err, name)
+			}
+			if t.Type == PointTable && transform.toPoint() && !m.SingleIdSpace {
+				return fmt.Errorf("geometry_transform %s for point tables requires use_single_id_space (%s)", transform.Type, name)
+			}
+			t.transform = transform
+		}
+	}
+
+	if err := m.prepareSpatialJoins(); err != nil {
+		return err
+	}
+	if err := m.prepareParentRelations(); err != nil {
+		return err
+	}
+	if err := m.prepareMultiPolygons(); err != nil {
+		return err
 	}
 
 	for name, t := range m.GeneralizedTables {
@@ -224,7 +332,7 @@ func (tt TagTables) addFromMapping(mapping KeyValues, table DestTable) {
 
 func (m *Mapping) mappings(tableType TableType, mappings TagTables) {
 	for name, t := range m.Tables {
-		if t.Type != GeometryTable && t.Type != tableType {
+		if !t.matchesType(tableType) {
 			continue
 		}
 		mappings.addFromMapping(t.Mapping, DestTable{Name: name})
@@ -247,7 +355,7 @@ func (m *Mapping) mappings(tableType TableType, mappings TagTables) {
 func (m *Mapping) tables(tableType TableType) map[string]*TableFields {
 	result := make(map[string]*TableFields)
 	for name, t := range m.Tables {
-		if t.Type == tableType || t.Type == "geometry" {
+		if t.matchesType(tableType) {
 			result[name] = t.TableFields()
 		}
 	}
@@ -256,7 +364,7 @@ func (m *Mapping) tables(tableType TableType) map[string]*TableFields {
 
 func (m *Mapping) extraTags(tableType TableType, tags map[Key]bool) {
 	for _, t := range m.Tables {
-		if t.Type != tableType && t.Type != "geometry" {
+		if !t.matchesType(tableType) && t.Type != "geometry" {
 			continue
 		}
 		for key, _ := range t.ExtraTags() {
@@ -268,6 +376,7 @@ func (m *Mapping) extraTags(tableType TableType, tags map[Key]bool) {
 			}
 		}
 	}
+	m.spatialJoinTags(tableType, tags)
 	for _, k := range m.Tags.Include {
 		tags[k] = true
 	}
@@ -276,6 +385,20 @@ func (m *Mapping) extraTags(tableType TableType, tags map[Key]bool) {
 	tags["area"] = true
 }
 
+// HasNetworkTables returns true if the mapping contains a network table.
+func (m *Mapping) HasNetworkTables() bool {
+	return m.hasTables(NetworkTable)
+}
+
+func (m *Mapping) hasTables(tableType TableType) bool {
+	for _, t := range m.Tables {
+		if t.Type == tableType {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Mapping) ElementFilters() map[string][]ElementFilter {
 	result := make(map[string][]ElementFilter)
 
@@ -295,7 +418,7 @@ func (m *Mapping) ElementFilters() map[string][]ElementFilter {
 	}
 
 	for name, t := range m.Tables {
-		if t.Type == LineStringTable && areaTags != nil {
+		if (t.Type == LineStringTable || t.Type == NetworkTable) && areaTags != nil {
 			f := func(tags element.Tags, key Key, closed bool) bool {
 				if closed {
 					if tags["area"] == "yes" {
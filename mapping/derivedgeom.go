@@ -0,0 +1,85 @@
+package mapping
+
+import (
+	"encoding/hex"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/wkb"
+)
+
+// derivedGeometry returns the EWKB (hex) of the geometry created by
+// derive, in the same SRID as the source geometry.
+func derivedGeometry(geometry *geom.Geometry, derive func(*geos.Geos, *geos.Geom) (*geos.Geom, error)) interface{} {
+	if geometry.Geom == nil {
+		return nil
+	}
+	g := geos.NewGeos()
+	defer g.Finish()
+	g.SetHandleSrid(g.Srid(geometry.Geom))
+
+	result, err := derive(g, geometry.Geom)
+	if err != nil {
+		log.Warn(err)
+		return nil
+	}
+	if result == nil {
+		return nil
+	}
+	defer g.Destroy(result)
+	return string(g.AsEwkbHex(result))
+}
+
+func Centroid(val string, elem *element.OSMElem, geometry *geom.Geometry, match Match) interface{} {
+	return derivedGeometry(geometry, func(g *geos.Geos, geom *geos.Geom) (*geos.Geom, error) {
+		return g.Centroid(geom), nil
+	})
+}
+
+func PointOnSurface(val string, elem *element.OSMElem, geometry *geom.Geometry, match Match) interface{} {
+	return derivedGeometry(geometry, func(g *geos.Geos, geom *geos.Geom) (*geos.Geom, error) {
+		return g.PointOnSurface(geom), nil
+	})
+}
+
+func LabelPoint(val string, elem *element.OSMElem, geometry *geom.Geometry, match Match) interface{} {
+	return derivedGeometry(geometry, geom.LabelPoint)
+}
+
+func BBox(val string, elem *element.OSMElem, geometry *geom.Geometry, match Match) interface{} {
+	return derivedGeometry(geometry, func(g *geos.Geos, geom *geos.Geom) (*geos.Geom, error) {
+		return g.BoundsPolygon(geom.Bounds()), nil
+	})
+}
+
+// GeoJSON returns the geometry as GeoJSON in EPSG:4326.
+func GeoJSON(val string, elem *element.OSMElem, geometry *geom.Geometry, match Match) interface{} {
+	if len(geometry.Wkb) == 0 {
+		return nil
+	}
+	ewkb, err := hex.DecodeString(string(geometry.Wkb))
+	if err != nil {
+		log.Warn(err)
+		return nil
+	}
+	result, err := wkb.GeoJSON(ewkb)
+	if err != nil {
+		log.Warn(err)
+		return nil
+	}
+	return result
+}
+
+func WKT(val string, elem *element.OSMElem, geometry *geom.Geometry, match Match) interface{} {
+	if geometry.Geom == nil {
+		return nil
+	}
+	g := geos.NewGeos()
+	defer g.Finish()
+	result := g.AsWkt(geometry.Geom)
+	if result == "" {
+		return nil
+	}
+	return result
+}
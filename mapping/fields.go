@@ -1,6 +1,7 @@
 package mapping
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/omniscale/imposm3/element"
 	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geodesic"
 	"github.com/omniscale/imposm3/logging"
 )
 
@@ -38,6 +40,28 @@ func init() {
 		"pseudoarea":           {"pseudoarea", "float32", nil, MakePseudoArea, nil, false},
 		"area":                 {"area", "float32", Area, nil, nil, false},
 		"webmerc_area":         {"webmerc_area", "float32", WebmercArea, nil, nil, false},
+		"geodesic_area":        {"geodesic_area", "float32", GeodesicArea, nil, nil, false},
+		"region":               {"region", "string", Region, nil, nil, false},
+		"geodesic_length":      {"geodesic_length", "float32", GeodesicLength, nil, nil, false},
+		"edge_source":          {"edge_source", "int64", EdgeSource, nil, nil, false},
+		"edge_target":          {"edge_target", "int64", EdgeTarget, nil, nil, false},
+		"restriction_type":     {"restriction_type", "string", RestrictionType, nil, nil, false},
+		"restriction_vehicle":  {"restriction_vehicle", "string", RestrictionVehicle, nil, nil, false},
+		"restriction_cond":     {"restriction_cond", "string", RestrictionCondition, nil, nil, false},
+		"restriction_from":     {"restriction_from", "int64", RestrictionFrom, nil, nil, false},
+		"restriction_via_node": {"restriction_via_node", "int64", RestrictionViaNode, nil, nil, false},
+		"restriction_via_ways": {"restriction_via_ways", "string", RestrictionViaWays, nil, nil, false},
+		"restriction_to":       {"restriction_to", "int64", RestrictionTo, nil, nil, false},
+		"restriction_error":    {"restriction_error", "string", RestrictionError, nil, nil, false},
+		"spatial_join":         {"spatial_join", "string", nil, MakeSpatialJoin, nil, false},
+		"spatial_join_id":      {"spatial_join_id", "int64", nil, MakeSpatialJoin, nil, false},
+		"parent_relation_tags": {"parent_relation_tags", "string", nil, MakeParentRelationTags, nil, false},
+		"centroid":             {"centroid", "point_geometry", Centroid, nil, nil, false},
+		"point_on_surface":     {"point_on_surface", "point_geometry", PointOnSurface, nil, nil, false},
+		"label_point":          {"label_point", "point_geometry", LabelPoint, nil, nil, false},
+		"bbox":                 {"bbox", "polygon_geometry", BBox, nil, nil, false},
+		"geojson":              {"geojson", "text", GeoJSON, nil, nil, false},
+		"wkt":                  {"wkt", "text", WKT, nil, nil, false},
 		"zorder":               {"zorder", "int32", nil, MakeZOrder, nil, false},
 		"enumerate":            {"enumerate", "int32", nil, MakeEnumerate, nil, false},
 		"string_suffixreplace": {"string_suffixreplace", "string", nil, MakeSuffixReplace, nil, false},
@@ -81,7 +105,10 @@ func (f *FieldSpec) MemberValue(rel *element.Relation, member *element.Member, g
 }
 
 type TableFields struct {
-	fields []FieldSpec
+	fields              []FieldSpec
+	relationGeometry    RelationGeometry
+	invalidRestrictions bool
+	transform           *Transform
 }
 
 func (t *TableFields) MakeRow(elem *element.OSMElem, geom *geom.Geometry, match Match) []interface{} {
@@ -117,7 +144,11 @@ func (field *Field) FieldType() *FieldType {
 }
 
 func (t *Table) TableFields() *TableFields {
-	result := TableFields{}
+	result := TableFields{
+		relationGeometry:    t.RelationGeometry,
+		invalidRestrictions: t.InvalidRestrictions,
+		transform:           t.transform,
+	}
 
 	for _, mappingField := range t.Fields {
 		field := FieldSpec{}
@@ -216,8 +247,84 @@ func Geometry(val string, elem *element.OSMElem, geom *geom.Geometry, match Matc
 	return string(geom.Wkb)
 }
 
+func Region(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	return geom.Region
+}
+
+func EdgeSource(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	return geom.Source
+}
+
+func EdgeTarget(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	return geom.Target
+}
+
+func RestrictionType(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if geom.Restriction == nil || geom.Restriction.Type == "" {
+		return nil
+	}
+	return geom.Restriction.Type
+}
+
+func RestrictionVehicle(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if geom.Restriction == nil || geom.Restriction.Vehicle == "" {
+		return nil
+	}
+	return geom.Restriction.Vehicle
+}
+
+func RestrictionCondition(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if geom.Restriction == nil || geom.Restriction.Condition == "" {
+		return nil
+	}
+	return geom.Restriction.Condition
+}
+
+func RestrictionFrom(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if geom.Restriction == nil || geom.Restriction.From == 0 {
+		return nil
+	}
+	return geom.Restriction.From
+}
+
+func RestrictionViaNode(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if geom.Restriction == nil || geom.Restriction.ViaNode == 0 {
+		return nil
+	}
+	return geom.Restriction.ViaNode
+}
+
+// RestrictionViaWays returns the comma separated IDs of all via ways.
+func RestrictionViaWays(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if geom.Restriction == nil || len(geom.Restriction.ViaWays) == 0 {
+		return nil
+	}
+	ids := make([]string, len(geom.Restriction.ViaWays))
+	for i, id := range geom.Restriction.ViaWays {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(ids, ",")
+}
+
+func RestrictionTo(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if geom.Restriction == nil || geom.Restriction.To == 0 {
+		return nil
+	}
+	return geom.Restriction.To
+}
+
+func RestrictionError(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	if geom.Restriction == nil || geom.Restriction.Error == "" {
+		return nil
+	}
+	return geom.Restriction.Error
+}
+
 func MakePseudoArea(fieldName string, fieldType FieldType, field Field) (MakeValue, error) {
 	log.Print("warn: pseudoarea type is deprecated and will be removed. See area and webmercarea type.")
+	if geodesic, _ := field.Args["geodesic"].(bool); geodesic {
+		return GeodesicArea, nil
+	}
 	return Area, nil
 }
 
@@ -246,6 +353,40 @@ func WebmercArea(val string, elem *element.OSMElem, geom *geom.Geometry, match M
 	return float32(area)
 }
 
+// geodesicValue returns the value of calc for the EWKB of geom, or nil if
+// it is 0.
+func geodesicValue(geom *geom.Geometry, calc func([]byte) (float64, error)) interface{} {
+	if len(geom.Wkb) == 0 {
+		return nil
+	}
+	ewkb, err := hex.DecodeString(string(geom.Wkb))
+	if err != nil {
+		log.Warn(err)
+		return nil
+	}
+	v, err := calc(ewkb)
+	if err != nil {
+		log.Warn(err)
+		return nil
+	}
+	if v == 0.0 {
+		return nil
+	}
+	return float32(v)
+}
+
+// GeodesicArea returns the area of polygon geometries in m² on the
+// WGS84 ellipsoid.
+func GeodesicArea(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	return geodesicValue(geom, geodesic.Area)
+}
+
+// GeodesicLength returns the length of linestring geometries in m on the
+// WGS84 ellipsoid.
+func GeodesicLength(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+	return geodesicValue(geom, geodesic.Length)
+}
+
 var hstoreReplacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"")
 
 func MakeHStoreString(fieldName string, fieldType FieldType, field Field) (MakeValue, error) {
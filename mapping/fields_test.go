@@ -1,6 +1,8 @@
 package mapping
 
 import (
+	"encoding/hex"
+	"math"
 	"testing"
 
 	"github.com/omniscale/imposm3/element"
@@ -297,6 +299,68 @@ func TestMakeSuffixReplace(t *testing.T) {
 	}
 }
 
+func TestParentRelationTags(t *testing.T) {
+	rels := []*element.Relation{
+		{OSMElem: element.OSMElem{Id: 3, Tags: element.Tags{"type": "route", "route": "bus", "ref": "12", "network": "B"}}},
+		{OSMElem: element.OSMElem{Id: 1, Tags: element.Tags{"type": "route", "route": "bus", "ref": "5", "network": "A"}}},
+		{OSMElem: element.OSMElem{Id: 2, Tags: element.Tags{"type": "route", "route": "road", "ref": "A 1"}}},
+		{OSMElem: element.OSMElem{Id: 4, Tags: element.Tags{"type": "route", "route": "bus", "ref": "12"}}},
+		{OSMElem: element.OSMElem{Id: 5, Tags: element.Tags{"type": "boundary", "ref": "99"}}},
+	}
+	for _, tc := range []struct {
+		args     map[string]interface{}
+		expected interface{}
+	}{
+		{map[string]interface{}{"type": "route", "key": "ref"}, "5;A 1;12"},
+		{map[string]interface{}{"type": []interface{}{"route", "boundary"}, "key": "ref"}, "5;A 1;12;99"},
+		{map[string]interface{}{"type": "route", "key": "ref", "tags": map[interface{}]interface{}{"route": "bus"}}, "5;12"},
+		{map[string]interface{}{"type": "route", "key": "network"}, "A;B"},
+		{map[string]interface{}{"type": "route", "key": "ref", "aggregate": "first"}, "5"},
+		// numeric values are compared as numbers
+		{map[string]interface{}{"type": "route", "key": "ref", "aggregate": "max", "tags": map[interface{}]interface{}{"route": "bus"}}, "12"},
+		{map[string]interface{}{"type": "route", "key": "ref", "aggregate": "max", "by": "network"}, "12"},
+		{map[string]interface{}{"type": "route", "key": "colour"}, nil},
+		{map[string]interface{}{"type": "public_transport", "key": "ref"}, nil},
+	} {
+		m := Mapping{Tables: Tables{"roads": &Table{
+			Type:   LineStringTable,
+			Fields: []*Field{{Name: "refs", Type: "parent_relation_tags", Args: tc.args}},
+		}}}
+		if err := m.prepare(); err != nil {
+			t.Fatal(err)
+		}
+		fieldType := m.Tables["roads"].Fields[0].FieldType()
+		if fieldType == nil {
+			t.Fatal("missing field type")
+		}
+		if v := fieldType.Func("", &element.OSMElem{}, &geom.Geometry{ParentRelations: rels}, Match{}); v != tc.expected {
+			t.Errorf("unexpected value %v for %v", v, tc.args)
+		}
+		if v := fieldType.Func("", &element.OSMElem{}, &geom.Geometry{}, Match{}); v != nil {
+			t.Errorf("unexpected value %v without parent relations", v)
+		}
+	}
+
+	for _, tc := range []struct {
+		tableType TableType
+		args      map[string]interface{}
+		err       string
+	}{
+		{LineStringTable, map[string]interface{}{"key": "ref"}, "missing type in args for parent_relation_tags (roads)"},
+		{LineStringTable, map[string]interface{}{"type": "route"}, "missing key in args for parent_relation_tags (roads)"},
+		{LineStringTable, map[string]interface{}{"type": "route", "key": "ref", "aggregate": "min"}, "unknown aggregate min in args for parent_relation_tags (roads)"},
+		{PointTable, map[string]interface{}{"type": "route", "key": "ref"}, "parent_relation_tags requires a linestring or polygon table (roads)"},
+	} {
+		m := Mapping{Tables: Tables{"roads": &Table{
+			Type:   tc.tableType,
+			Fields: []*Field{{Name: "refs", Type: "parent_relation_tags", Args: tc.args}},
+		}}}
+		if err := m.prepare(); err == nil || err.Error() != tc.err {
+			t.Errorf("expected error %q, got %v", tc.err, err)
+		}
+	}
+}
+
 func TestHstoreString(t *testing.T) {
 	field := Field{
 		Name: "tags",
@@ -344,3 +408,87 @@ func TestHstoreString(t *testing.T) {
 	}
 
 }
+
+func TestDerivedGeometries(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	g.SetHandleSrid(3857)
+	ggeom := g.FromWkt("POLYGON((0 0, 10 0, 10 2, 0 2, 0 0))")
+	if ggeom == nil {
+		t.Fatal("unable to create test geometry")
+	}
+	geometry, err := geom.AsGeomElement(g, ggeom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elem := &element.OSMElem{}
+
+	tests := []struct {
+		fieldFunc MakeValue
+		expected  string
+	}{
+		{Centroid, "POINT (5 1)"},
+		{PointOnSurface, "POINT (5 1)"},
+		{LabelPoint, "POINT (5 1)"},
+		{BBox, "POLYGON ((0 0,10 0,10 2,0 2,0 0))"},
+	}
+	for _, test := range tests {
+		v, ok := test.fieldFunc("", elem, &geometry, Match{}).(string)
+		if !ok {
+			t.Fatal("no geometry for", test.expected)
+		}
+		ewkb, err := hex.DecodeString(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := g.FromWkb(ewkb); result == nil || !g.Equals(result, g.FromWkt(test.expected)) {
+			t.Errorf("%s != %s", v, test.expected)
+		}
+	}
+
+	// number of decimals depends on the GEOS version
+	v, _ := WKT("", elem, &geometry, Match{}).(string)
+	if result := g.FromWkt(v); result == nil || !g.Equals(result, ggeom) {
+		t.Error(v)
+	}
+	if v := GeoJSON("", elem, &geometry, Match{}); v != `{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,2],[0,2],[0,0]]]}` {
+		t.Error(v)
+	}
+}
+
+func TestGeodesicFields(t *testing.T) {
+	g := geos.NewGeos()
+	defer g.Finish()
+	g.SetHandleSrid(4326)
+	elem := &element.OSMElem{}
+
+	for _, test := range []struct {
+		wkt       string
+		expected  float32
+		fieldFunc MakeValue
+	}{
+		// 1°x1° at the equator
+		{"POLYGON((0 0, 1 0, 1 1, 0 1, 0 0))", 12308778361, GeodesicArea},
+		{"LINESTRING(0 0, 1 0)", 111319.491, GeodesicLength},
+		{"LINESTRING(0 0, 1 0)", 0, GeodesicArea},
+	} {
+		ggeom := g.FromWkt(test.wkt)
+		if ggeom == nil {
+			t.Fatalf("unable to create test geometry from %v", test.wkt)
+		}
+		geometry, err := geom.AsGeomElement(g, ggeom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := test.fieldFunc("", elem, &geometry, Match{})
+		if test.expected == 0 {
+			if v != nil {
+				t.Errorf("%v %v != nil", test.wkt, v)
+			}
+			continue
+		}
+		if v, ok := v.(float32); !ok || math.Abs(float64(v-test.expected))/float64(test.expected) > 1e-4 {
+			t.Errorf("%v %v != %f", test.wkt, v, test.expected)
+		}
+	}
+}
@@ -25,9 +25,11 @@ func (m *Mapping) WayTagFilter() TagFilterer {
 	mappings := make(map[Key]map[Value][]OrderedDestTable)
 	m.mappings("linestring", mappings)
 	m.mappings("polygon", mappings)
+	m.mappings("network", mappings)
 	tags := make(map[Key]bool)
 	m.extraTags("linestring", tags)
 	m.extraTags("polygon", tags)
+	m.extraTags("network", tags)
 	return &TagFilter{mappings, tags}
 }
 
@@ -47,7 +49,13 @@ func (m *Mapping) RelationTagFilter() TagFilterer {
 		"boundary":     []OrderedDestTable{},
 		"land_area":    []OrderedDestTable{},
 	}
-	return &RelationTagFilter{TagFilter{mappings, tags}}
+	restrictions := m.hasTables(RestrictionTable)
+	if restrictions {
+		m.extraTags(RestrictionTable, tags)
+		mappings["type"]["restriction"] = []OrderedDestTable{}
+	}
+	parentRelations := m.parentRelationTags(tags)
+	return &RelationTagFilter{TagFilter{mappings, tags}, restrictions, parentRelations}
 }
 
 type TagFilter struct {
@@ -57,6 +65,11 @@ type TagFilter struct {
 
 type RelationTagFilter struct {
 	TagFilter
+	// restrictions keeps restriction relations for restriction tables
+	restrictions bool
+	// parentRelations keeps relations of these types for
+	// parent_relation_tags columns
+	parentRelations map[string]struct{}
 }
 
 type ExcludeFilter struct {
@@ -133,7 +146,15 @@ func (f *RelationTagFilter) Filter(tags *element.Tags) bool {
 		return false
 	}
 	if t, ok := (*tags)["type"]; ok {
+		if t == "restriction" && f.restrictions {
+			f.filterRestriction(tags)
+			return true
+		}
 		if t != "multipolygon" && t != "boundary" && t != "land_area" {
+			if _, ok := f.parentRelations[t]; ok {
+				f.filterParentRelation(tags)
+				return true
+			}
 			*tags = nil
 			return false
 		}
@@ -172,3 +193,29 @@ func (f *RelationTagFilter) Filter(tags *element.Tags) bool {
 	// always return true here since we found a matching type
 	return true
 }
+
+// filterRestriction removes all tags from restriction relations, except
+// the type, restriction, restriction:* and except tags and the extra tags.
+func (f *RelationTagFilter) filterRestriction(tags *element.Tags) {
+	for k := range *tags {
+		if k == "type" || k == "restriction" || k == "except" || strings.HasPrefix(k, "restriction:") {
+			continue
+		}
+		if _, ok := f.extraTags[Key(k)]; !ok {
+			delete(*tags, k)
+		}
+	}
+}
+
+// filterParentRelation removes all tags from parent relations, except the
+// type and the extra tags.
+func (f *RelationTagFilter) filterParentRelation(tags *element.Tags) {
+	for k := range *tags {
+		if k == "type" {
+			continue
+		}
+		if _, ok := f.extraTags[Key(k)]; !ok {
+			delete(*tags, k)
+		}
+	}
+}
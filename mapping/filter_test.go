@@ -250,6 +250,24 @@ func TestTagFilterRelations(t *testing.T) {
 	}
 	stringMapEquals(t, element.Tags{"name": "foo", "boundary": "administrative", "type": "boundary"}, tags)
 
+	// restriction relations keep all restriction tags
+	tags = element.Tags{"name": "foo", "unknown": "baz", "note": "bar", "restriction": "no_left_turn", "restriction:hgv": "no_u_turn", "except": "bicycle", "type": "restriction"}
+	if relations.Filter(&tags) != true {
+		t.Fatal("unexpected filter response for", tags)
+	}
+	stringMapEquals(t, element.Tags{"name": "foo", "note": "bar", "restriction": "no_left_turn", "restriction:hgv": "no_u_turn", "except": "bicycle", "type": "restriction"}, tags)
+
+	// route relations keep the tags for parent_relation_tags columns
+	tags = element.Tags{"name": "foo", "unknown": "baz", "ref": "42", "type": "route"}
+	if relations.Filter(&tags) != true {
+		t.Fatal("unexpected filter response for", tags)
+	}
+	stringMapEquals(t, element.Tags{"name": "foo", "ref": "42", "type": "route"}, tags)
+
+	tags = element.Tags{"name": "foo", "ref": "42", "type": "route_master"}
+	if relations.Filter(&tags) != false {
+		t.Fatal("unexpected filter response for", tags)
+	}
 }
 
 func TestPointMatcher(t *testing.T) {
@@ -322,6 +340,186 @@ func TestLineStringMatcher(t *testing.T) {
 	matchesEqual(t, []Match{{"highway", "footway", DestTable{Name: "roads", SubMapping: "roads"}, nil}}, ls.MatchWay(&elem))
 }
 
+func TestNetworkMatcher(t *testing.T) {
+	elem := element.Way{}
+	elem.Refs = []int64{1, 2, 3}
+	nw := mapping.NetworkMatcher()
+
+	elem.Tags = element.Tags{"highway": "pedestrian"}
+	matchesEqual(t, []Match{}, nw.MatchWay(&elem))
+
+	elem.Tags = element.Tags{"highway": "secondary", "railway": "tram"}
+	matchesEqual(t, []Match{{"highway", "secondary", DestTable{Name: "road_network"}, nil}}, nw.MatchWay(&elem))
+
+	// network ways are not matched as linestrings
+	ls := mapping.LineStringMatcher()
+	for _, m := range ls.MatchWay(&elem) {
+		if m.Table.Name == "road_network" {
+			t.Error("unexpected network match", m)
+		}
+	}
+}
+
+func TestNetworkTableColumns(t *testing.T) {
+	m := Mapping{Tables: Tables{"network": &Table{
+		Type:   NetworkTable,
+		Fields: []*Field{{Name: "source", Type: "edge_source"}},
+	}}}
+	if err := m.prepare(); err == nil {
+		t.Error("expected error for missing edge_target")
+	}
+	m.Tables["network"].Fields = append(m.Tables["network"].Fields, &Field{Name: "target", Type: "edge_target"})
+	if err := m.prepare(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestrictionMatcher(t *testing.T) {
+	elem := element.Relation{}
+	rm := mapping.RestrictionMatcher()
+
+	elem.Tags = element.Tags{"type": "multipolygon", "landuse": "park"}
+	matchesEqual(t, []Match{}, rm.MatchRelation(&elem))
+
+	elem.Tags = element.Tags{"type": "restriction", "restriction": "no_left_turn"}
+	matchesEqual(t, []Match{{"type", "restriction", DestTable{Name: "turn_restrictions"}, nil}}, rm.MatchRelation(&elem))
+}
+
+func TestInvalidRestrictionsTable(t *testing.T) {
+	m := Mapping{Tables: Tables{"invalid": &Table{
+		Type:                RelationTable,
+		InvalidRestrictions: true,
+	}}}
+	if err := m.prepare(); err == nil {
+		t.Error("expected error for invalid_restrictions in relation table")
+	}
+	m.Tables["invalid"].Type = RestrictionTable
+	if err := m.prepare(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSpatialJoinColumns(t *testing.T) {
+	newMapping := func(args map[string]interface{}, sourceType TableType) *Mapping {
+		return &Mapping{Tables: Tables{
+			"admin": &Table{Type: sourceType},
+			"pois": &Table{
+				Type:   PointTable,
+				Fields: []*Field{{Name: "country", Type: "spatial_join", Args: args}},
+			},
+		}}
+	}
+
+	for _, tc := range []struct {
+		args       map[string]interface{}
+		sourceType TableType
+		err        string
+	}{
+		{map[string]interface{}{"key": "name"}, PolygonTable, "missing table in args for spatial_join (pois)"},
+		{map[string]interface{}{"table": "admin"}, PolygonTable, "missing key in args for spatial_join (pois)"},
+		{map[string]interface{}{"table": "unknown", "key": "name"}, PolygonTable, "unknown spatial_join table unknown (pois)"},
+		{map[string]interface{}{"table": "admin", "key": "name"}, LineStringTable, "spatial_join table admin is not a polygon table (pois)"},
+		{map[string]interface{}{"table": "admin", "key": "name", "tags": "2"}, PolygonTable, "tags in args for spatial_join not a dict (pois)"},
+	} {
+		m := newMapping(tc.args, tc.sourceType)
+		if err := m.prepare(); err == nil || err.Error() != tc.err {
+			t.Errorf("expected error %q for %v, got %v", tc.err, tc.args, err)
+		}
+	}
+
+	m := newMapping(map[string]interface{}{
+		"table": "admin",
+		"key":   "name",
+		"tags":  map[interface{}]interface{}{"admin_level": 2},
+	}, PolygonTable)
+	if err := m.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if m.SpatialJoin == nil || !m.SpatialJoin.HasTable("admin") || m.SpatialJoin.HasTable("pois") {
+		t.Error("unexpected spatial join index", m.SpatialJoin)
+	}
+	sj := m.Tables["pois"].Fields[0].spatialJoin
+	if sj.table != "admin" || sj.key != "name" || sj.tags["admin_level"] != "2" {
+		t.Errorf("unexpected spatial join %#v", sj)
+	}
+	tags := make(map[Key]bool)
+	m.extraTags(PolygonTable, tags)
+	if !tags["name"] || !tags["admin_level"] {
+		t.Error("missing spatial join tags", tags)
+	}
+	if sub := m.SpatialJoinMapping(); len(sub.Tables) != 1 || sub.Tables["admin"] == nil {
+		t.Error("unexpected spatial join mapping", sub.Tables)
+	}
+}
+
+func TestGeometryTransform(t *testing.T) {
+	for _, tc := range []struct {
+		transform string
+		tableType TableType
+		err       string
+	}{
+		{"centroid", PolygonTable, "geometry_transform centroid requires a point or geometry table (shops)"},
+		{"buffer", PolygonTable, "geometry_transform buffer requires a value (shops)"},
+		{"buffer 0", PolygonTable, "geometry_transform buffer requires a non-zero size (shops)"},
+		{"buffer 10", LineStringTable, "geometry_transform buffer requires a polygon or geometry table (shops)"},
+		{"simplify ten", PolygonTable, "invalid value for geometry_transform simplify: ten (shops)"},
+		{"subdivide 4", PolygonTable, "geometry_transform subdivide requires at least 8 vertices (shops)"},
+		{"subdivide 256", PointTable, "geometry_transform subdivide requires a linestring, polygon or geometry table (shops)"},
+		{"rotate 90", PolygonTable, "unknown geometry_transform rotate (shops)"},
+	} {
+		m := Mapping{Tables: Tables{"shops": &Table{Type: tc.tableType, GeometryTransform: tc.transform}}, SingleIdSpace: true}
+		if err := m.prepare(); err == nil || err.Error() != tc.err {
+			t.Errorf("expected error %q for %s, got %v", tc.err, tc.transform, err)
+		}
+	}
+
+	// node and way IDs overlap in point tables
+	m := Mapping{Tables: Tables{"shops": &Table{Type: PointTable, GeometryTransform: "centroid"}}}
+	if err := m.prepare(); err == nil || err.Error() != "geometry_transform centroid for point tables requires use_single_id_space (shops)" {
+		t.Error("expected error for point table without use_single_id_space, got", err)
+	}
+
+	m = Mapping{SingleIdSpace: true, Tables: Tables{
+		"shops": &Table{
+			Type:              PointTable,
+			GeometryTransform: "point_on_surface",
+			Mapping:           KeyValues{"shop": []orderedValue{{value: "__any__"}}},
+			Fields:            []*Field{{Name: "name", Key: "name", Type: "string"}},
+		},
+		"buildings": &Table{
+			Type:              PolygonTable,
+			GeometryTransform: "subdivide 256",
+			Mapping:           KeyValues{"building": []orderedValue{{value: "__any__"}}},
+		},
+	}}
+	if err := m.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	elem := element.Way{}
+	elem.Refs = []int64{1, 2, 3, 1}
+	elem.Tags = element.Tags{"shop": "bakery", "building": "yes"}
+	matches := m.PolygonMatcher().MatchWay(&elem)
+	matchesEqual(t, []Match{
+		{"shop", "bakery", DestTable{Name: "shops"}, nil},
+		{"building", "yes", DestTable{Name: "buildings"}, nil},
+	}, matches)
+	for _, match := range matches {
+		if tr := match.Transform(); tr == nil ||
+			(match.Table.Name == "buildings" && (tr.Type != SubdivideTransform || tr.Value != 256)) {
+			t.Errorf("unexpected transform %v for %s", tr, match.Table.Name)
+		}
+	}
+	// shops are not matched as linestrings
+	elem.Refs = []int64{1, 2, 3}
+	matchesEqual(t, []Match{}, m.LineStringMatcher().MatchWay(&elem))
+
+	tags := make(map[Key]bool)
+	m.extraTags(PolygonTable, tags)
+	if !tags["name"] {
+		t.Error("missing tags of point table", tags)
+	}
+}
+
 func TestPolygonMatcher(t *testing.T) {
 	elem := element.Relation{}
 	polys := mapping.PolygonMatcher()
@@ -503,3 +701,29 @@ func BenchmarkFilterNodes(b *testing.B) {
 		}
 	}
 }
+
+func TestMultiPolygonOptions(t *testing.T) {
+	m := Mapping{MultiPolygons: MultiPolygons{Repair: "fix"}}
+	if err := m.prepare(); err == nil || err.Error() != "unknown multipolygons repair fix" {
+		t.Error("expected error for unknown repair, got", err)
+	}
+
+	opts := m.MultiPolygonOptions(3857)
+	if opts.MaxRingGap != 1e-1 || opts.IgnoreWayTags {
+		t.Error("unexpected defaults", opts)
+	}
+	if opts := m.MultiPolygonOptions(4326); opts.MaxRingGap != 1e-6 {
+		t.Error("unexpected default ring gap for 4326", opts)
+	}
+
+	gap := 2.5
+	oldStyle := false
+	m = Mapping{MultiPolygons: MultiPolygons{Repair: "make_valid", RingGap: &gap, OldStyleTags: &oldStyle}}
+	if err := m.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	opts = m.MultiPolygonOptions(4326)
+	if opts.MaxRingGap != 2.5 || opts.Repair != "make_valid" || !opts.IgnoreWayTags {
+		t.Error("unexpected options", opts)
+	}
+}
@@ -29,6 +29,18 @@ func (m *Mapping) LineStringMatcher() WayMatcher {
 	}
 }
 
+func (m *Mapping) NetworkMatcher() WayMatcher {
+	mappings := make(TagTables)
+	m.mappings(NetworkTable, mappings)
+	filters := m.ElementFilters()
+	return &tagMatcher{
+		mappings:   mappings,
+		tables:     m.tables(NetworkTable),
+		filters:    filters,
+		matchAreas: false,
+	}
+}
+
 func (m *Mapping) PolygonMatcher() RelWayMatcher {
 	mappings := make(TagTables)
 	m.mappings(PolygonTable, mappings)
@@ -65,6 +77,18 @@ func (m *Mapping) RelationMemberMatcher() RelationMatcher {
 	}
 }
 
+func (m *Mapping) RestrictionMatcher() RelationMatcher {
+	mappings := make(TagTables)
+	m.mappings(RestrictionTable, mappings)
+	filters := m.ElementFilters()
+	return &tagMatcher{
+		mappings:   mappings,
+		tables:     m.tables(RestrictionTable),
+		filters:    filters,
+		matchAreas: true,
+	}
+}
+
 type Match struct {
 	Key         string
 	Value       string
@@ -104,6 +128,22 @@ func (m *Match) MemberRow(rel *element.Relation, member *element.Member, geom *g
 	return m.tableFields.MakeMemberRow(rel, member, geom, *m)
 }
 
+// RelationGeometry returns the relation_geometry of the matched table.
+func (m *Match) RelationGeometry() RelationGeometry {
+	return m.tableFields.relationGeometry
+}
+
+// InvalidRestrictions returns true if the matched table contains invalid
+// restrictions.
+func (m *Match) InvalidRestrictions() bool {
+	return m.tableFields.invalidRestrictions
+}
+
+// Transform returns the geometry_transform of the matched table, or nil.
+func (m *Match) Transform() *Transform {
+	return m.tableFields.transform
+}
+
 func (tm *tagMatcher) MatchNode(node *element.Node) []Match {
 	return tm.match(node.Tags, false)
 }
@@ -0,0 +1,55 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/omniscale/imposm3/geom"
+)
+
+// MultiPolygons configures how multipolygons are build from relations.
+type MultiPolygons struct {
+	// RingGap is the max distance between the endpoints of a ring that
+	// is still closed, in the units of the projection.
+	RingGap *float64 `yaml:"ring_gap"`
+	// Repair is the strategy for invalid geometries: buffer0 (default),
+	// make_valid or drop.
+	Repair string `yaml:"repair"`
+	// OldStyleTags uses the tags of the outer way for relations without
+	// tags. Enabled by default.
+	OldStyleTags *bool `yaml:"old_style_tags"`
+	// ErrorsFile is a file where all failed or repaired multipolygons
+	// are appended as GeoJSON features.
+	ErrorsFile string `yaml:"errors_file"`
+}
+
+func (m *Mapping) prepareMultiPolygons() error {
+	mp := m.MultiPolygons
+	switch mp.Repair {
+	case "", geom.RepairBuffer0, geom.RepairMakeValid, geom.RepairDrop:
+	default:
+		return fmt.Errorf("unknown multipolygons repair %s", mp.Repair)
+	}
+	if mp.RingGap != nil && *mp.RingGap < 0 {
+		return errors.New("multipolygons ring_gap needs to be positive")
+	}
+	return nil
+}
+
+// MultiPolygonOptions returns the options for all multipolygons. The
+// default ring gap is ~0.1m.
+func (m *Mapping) MultiPolygonOptions(srid int) geom.MultiPolygonOptions {
+	mp := m.MultiPolygons
+	opts := geom.MultiPolygonOptions{
+		MaxRingGap:    1e-1, // 0.1m
+		Repair:        mp.Repair,
+		IgnoreWayTags: mp.OldStyleTags != nil && !*mp.OldStyleTags,
+	}
+	if srid == 4326 {
+		opts.MaxRingGap = 1e-6 // ~0.1m
+	}
+	if mp.RingGap != nil {
+		opts.MaxRingGap = *mp.RingGap
+	}
+	return opts
+}
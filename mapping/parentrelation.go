@@ -0,0 +1,235 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+)
+
+// parentRelation is the configuration of a parent_relation_tags column.
+type parentRelation struct {
+	// relation types, e.g. route
+	types map[string]struct{}
+	// only relations with these tags
+	tags element.Tags
+	// tag of the relations
+	key string
+	// list, first or max
+	aggregate string
+	// tag to compare for max
+	by string
+}
+
+func parseParentRelation(field *Field) (*parentRelation, error) {
+	pr := &parentRelation{
+		types:     make(map[string]struct{}),
+		tags:      element.Tags{},
+		aggregate: "list",
+	}
+	switch types := field.Args["type"].(type) {
+	case string:
+		pr.types[types] = struct{}{}
+	case []interface{}:
+		for _, t := range types {
+			pr.types[fmt.Sprint(t)] = struct{}{}
+		}
+	}
+	if len(pr.types) == 0 {
+		return nil, errors.New("missing type in args for parent_relation_tags")
+	}
+	pr.key, _ = field.Args["key"].(string)
+	if pr.key == "" {
+		return nil, errors.New("missing key in args for parent_relation_tags")
+	}
+	if _tags, ok := field.Args["tags"]; ok {
+		tags, ok := _tags.(map[interface{}]interface{})
+		if !ok {
+			return nil, errors.New("tags in args for parent_relation_tags not a dict")
+		}
+		for k, v := range tags {
+			pr.tags[fmt.Sprint(k)] = fmt.Sprint(v)
+		}
+	}
+	if aggregate, ok := field.Args["aggregate"].(string); ok {
+		pr.aggregate = aggregate
+	}
+	switch pr.aggregate {
+	case "list", "first":
+	case "max":
+		pr.by, _ = field.Args["by"].(string)
+		if pr.by == "" {
+			pr.by = pr.key
+		}
+	default:
+		return nil, fmt.Errorf("unknown aggregate %s in args for parent_relation_tags", pr.aggregate)
+	}
+	return pr, nil
+}
+
+func (pr *parentRelation) match(tags element.Tags) bool {
+	if _, ok := pr.types[tags["type"]]; !ok {
+		return false
+	}
+	for k, v := range pr.tags {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareParentRelations parses all parent_relation_tags columns.
+func (m *Mapping) prepareParentRelations() error {
+	for name, t := range m.Tables {
+		for _, field := range t.Fields {
+			if field.Type != "parent_relation_tags" {
+				continue
+			}
+			if t.Type != LineStringTable && t.Type != PolygonTable {
+				return fmt.Errorf("parent_relation_tags requires a linestring or polygon table (%s)", name)
+			}
+			pr, err := parseParentRelation(field)
+			if err != nil {
+				return fmt.Errorf("%v (%s)", err, name)
+			}
+			field.parentRelation = pr
+		}
+	}
+	return nil
+}
+
+func (m *Mapping) parentRelationFields() []*parentRelation {
+	var result []*parentRelation
+	for _, t := range m.Tables {
+		for _, field := range t.Fields {
+			if field.parentRelation != nil {
+				result = append(result, field.parentRelation)
+			}
+		}
+	}
+	return result
+}
+
+// parentRelationTags adds the tags of all parent_relation_tags columns.
+// Returns all relation types.
+func (m *Mapping) parentRelationTags(tags map[Key]bool) map[string]struct{} {
+	types := make(map[string]struct{})
+	for _, pr := range m.parentRelationFields() {
+		for t := range pr.types {
+			types[t] = struct{}{}
+		}
+		tags[Key(pr.key)] = true
+		if pr.by != "" {
+			tags[Key(pr.by)] = true
+		}
+		for k := range pr.tags {
+			tags[Key(k)] = true
+		}
+	}
+	return types
+}
+
+// ParentRelations selects the relations that are required for
+// parent_relation_tags columns.
+type ParentRelations struct {
+	fields []*parentRelation
+}
+
+// ParentRelations returns nil if there are no parent_relation_tags columns.
+func (m *Mapping) ParentRelations() *ParentRelations {
+	fields := m.parentRelationFields()
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ParentRelations{fields: fields}
+}
+
+// Match returns true if any parent_relation_tags column requires the
+// relation.
+func (p *ParentRelations) Match(tags element.Tags) bool {
+	for _, pr := range p.fields {
+		if pr.match(tags) {
+			return true
+		}
+	}
+	return false
+}
+
+type relationsById []*element.Relation
+
+func (r relationsById) Len() int           { return len(r) }
+func (r relationsById) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r relationsById) Less(i, j int) bool { return r[i].Id < r[j].Id }
+
+// compareValues compares a and b numerically if both are numbers.
+func compareValues(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+func MakeParentRelationTags(fieldName string, fieldType FieldType, field Field) (MakeValue, error) {
+	pr := field.parentRelation
+	if pr == nil {
+		return nil, fmt.Errorf("parent_relation_tags column %s not prepared", fieldName)
+	}
+	parentRelationTags := func(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+		var rels []*element.Relation
+		for _, r := range geom.ParentRelations {
+			if _, ok := r.Tags[pr.key]; ok && pr.match(r.Tags) {
+				rels = append(rels, r)
+			}
+		}
+		if len(rels) == 0 {
+			return nil
+		}
+		sort.Sort(relationsById(rels))
+
+		switch pr.aggregate {
+		case "first":
+			return rels[0].Tags[pr.key]
+		case "max":
+			var max *element.Relation
+			for _, r := range rels {
+				by, ok := r.Tags[pr.by]
+				if !ok {
+					continue
+				}
+				if max == nil || compareValues(by, max.Tags[pr.by]) > 0 {
+					max = r
+				}
+			}
+			if max == nil {
+				return nil
+			}
+			return max.Tags[pr.key]
+		}
+
+		var values []string
+		seen := make(map[string]struct{})
+		for _, r := range rels {
+			v := r.Tags[pr.key]
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = struct{}{}
+			values = append(values, v)
+		}
+		return strings.Join(values, ";")
+	}
+	return parentRelationTags, nil
+}
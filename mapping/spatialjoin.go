@@ -0,0 +1,141 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/spatialjoin"
+)
+
+// spatialJoin is the configuration of a spatial_join or spatial_join_id
+// column.
+type spatialJoin struct {
+	index *spatialjoin.Index
+	// source polygon table
+	table string
+	// tag of the polygon, only for spatial_join
+	key string
+	// only join polygons with these tags
+	tags element.Tags
+}
+
+func parseSpatialJoin(field *Field) (*spatialJoin, error) {
+	table, _ := field.Args["table"].(string)
+	if table == "" {
+		return nil, fmt.Errorf("missing table in args for %s", field.Type)
+	}
+	sj := &spatialJoin{table: table, tags: element.Tags{}}
+	if field.Type == "spatial_join" {
+		sj.key, _ = field.Args["key"].(string)
+		if sj.key == "" {
+			return nil, errors.New("missing key in args for spatial_join")
+		}
+	}
+	if _tags, ok := field.Args["tags"]; ok {
+		tags, ok := _tags.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("tags in args for %s not a dict", field.Type)
+		}
+		for k, v := range tags {
+			// allow unquoted numbers, e.g. admin_level: 2
+			sj.tags[fmt.Sprint(k)] = fmt.Sprint(v)
+		}
+	}
+	return sj, nil
+}
+
+func isSpatialJoin(field *Field) bool {
+	return field.Type == "spatial_join" || field.Type == "spatial_join_id"
+}
+
+// prepareSpatialJoins parses all spatial_join columns and registers their
+// source tables in the SpatialJoin index.
+func (m *Mapping) prepareSpatialJoins() error {
+	for name, t := range m.Tables {
+		for _, field := range t.Fields {
+			if !isSpatialJoin(field) {
+				continue
+			}
+			sj, err := parseSpatialJoin(field)
+			if err != nil {
+				return fmt.Errorf("%v (%s)", err, name)
+			}
+			source, ok := m.Tables[sj.table]
+			if !ok {
+				return fmt.Errorf("unknown spatial_join table %s (%s)", sj.table, name)
+			}
+			if source.Type != PolygonTable {
+				return fmt.Errorf("spatial_join table %s is not a polygon table (%s)", sj.table, name)
+			}
+			if m.SpatialJoin == nil {
+				m.SpatialJoin = spatialjoin.NewIndex()
+			}
+			m.SpatialJoin.AddTable(sj.table)
+			sj.index = m.SpatialJoin
+			field.spatialJoin = sj
+		}
+	}
+	return nil
+}
+
+// spatialJoinTags adds the key and tags of all spatial_join columns with a
+// source table of tableType.
+func (m *Mapping) spatialJoinTags(tableType TableType, tags map[Key]bool) {
+	for _, t := range m.Tables {
+		for _, field := range t.Fields {
+			sj := field.spatialJoin
+			if sj == nil || m.Tables[sj.table].Type != tableType {
+				continue
+			}
+			if sj.key != "" {
+				tags[Key(sj.key)] = true
+			}
+			for k := range sj.tags {
+				tags[Key(k)] = true
+			}
+		}
+	}
+}
+
+// SpatialJoinMapping returns a mapping with all spatial_join source
+// tables. Returns nil if there are no spatial_join columns.
+func (m *Mapping) SpatialJoinMapping() *Mapping {
+	if m.SpatialJoin == nil {
+		return nil
+	}
+	sm := *m
+	sm.Tables = make(Tables)
+	sm.GeneralizedTables = nil
+	for name, t := range m.Tables {
+		if m.SpatialJoin.HasTable(name) {
+			sm.Tables[name] = t
+		}
+	}
+	return &sm
+}
+
+func MakeSpatialJoin(fieldName string, fieldType FieldType, field Field) (MakeValue, error) {
+	sj := field.spatialJoin
+	if sj == nil {
+		return nil, fmt.Errorf("%s column %s not prepared", field.Type, fieldName)
+	}
+	spatialJoin := func(val string, elem *element.OSMElem, geom *geom.Geometry, match Match) interface{} {
+		if geom.Geom == nil {
+			return nil
+		}
+		id, tags, ok := sj.index.Lookup(sj.table, sj.tags, geom.Geom)
+		if !ok {
+			return nil
+		}
+		if sj.key == "" {
+			return id
+		}
+		if v, ok := tags[sj.key]; ok {
+			return v
+		}
+		return nil
+	}
+	return spatialJoin, nil
+}
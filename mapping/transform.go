@@ -0,0 +1,131 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+// TransformType is the operation of a geometry_transform.
+type TransformType string
+
+const (
+	CentroidTransform       TransformType = "centroid"
+	PointOnSurfaceTransform TransformType = "point_on_surface"
+	BufferTransform         TransformType = "buffer"
+	SimplifyTransform       TransformType = "simplify"
+	SubdivideTransform      TransformType = "subdivide"
+)
+
+// Transform is the parsed geometry_transform of a table, e.g.
+// "point_on_surface" or "buffer 10".
+type Transform struct {
+	Type TransformType
+	// buffer size, simplify tolerance or max vertices of subdivide
+	Value float64
+}
+
+func parseTransform(transform string) (*Transform, error) {
+	parts := strings.Fields(transform)
+	if len(parts) == 0 {
+		return nil, errors.New("empty geometry_transform")
+	}
+	t := &Transform{Type: TransformType(parts[0])}
+	switch t.Type {
+	case CentroidTransform, PointOnSurfaceTransform:
+		if len(parts) != 1 {
+			return nil, fmt.Errorf("geometry_transform %s takes no value", t.Type)
+		}
+		return t, nil
+	case BufferTransform, SimplifyTransform, SubdivideTransform:
+	default:
+		return nil, fmt.Errorf("unknown geometry_transform %s", t.Type)
+	}
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("geometry_transform %s requires a value", t.Type)
+	}
+	var err error
+	t.Value, err = strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for geometry_transform %s: %s", t.Type, parts[1])
+	}
+	switch {
+	case t.Type == BufferTransform && t.Value == 0:
+		return nil, errors.New("geometry_transform buffer requires a non-zero size")
+	case t.Type == SimplifyTransform && t.Value <= 0:
+		return nil, errors.New("geometry_transform simplify requires a positive tolerance")
+	case t.Type == SubdivideTransform && (t.Value < 8 || t.Value != float64(int(t.Value))):
+		return nil, errors.New("geometry_transform subdivide requires at least 8 vertices")
+	}
+	return t, nil
+}
+
+// toPoint returns true if the transform creates points from all
+// geometries.
+func (t *Transform) toPoint() bool {
+	return t.Type == CentroidTransform || t.Type == PointOnSurfaceTransform
+}
+
+// checkTableType returns an error if the transform does not create
+// geometries for tables of this type.
+func (t *Transform) checkTableType(tableType TableType) error {
+	if tableType == GeometryTable {
+		return nil
+	}
+	switch t.Type {
+	case CentroidTransform, PointOnSurfaceTransform:
+		if tableType == PointTable {
+			return nil
+		}
+		return fmt.Errorf("geometry_transform %s requires a point or geometry table", t.Type)
+	case BufferTransform:
+		if tableType == PolygonTable {
+			return nil
+		}
+		return fmt.Errorf("geometry_transform %s requires a polygon or geometry table", t.Type)
+	}
+	if tableType == LineStringTable || tableType == PolygonTable {
+		return nil
+	}
+	return fmt.Errorf("geometry_transform %s requires a linestring, polygon or geometry table", t.Type)
+}
+
+// Apply returns the transformed geometries of geom. Returns no geometry
+// if the result is empty, e.g. for a negative buffer of a small polygon.
+// The results are destroyed by the garbage collector.
+func (t *Transform) Apply(g *geos.Geos, geometry *geos.Geom) ([]*geos.Geom, error) {
+	var result *geos.Geom
+	switch t.Type {
+	case CentroidTransform:
+		result = g.Centroid(geometry)
+	case PointOnSurfaceTransform:
+		result = g.PointOnSurface(geometry)
+	case BufferTransform:
+		result = g.Buffer(geometry, t.Value)
+	case SimplifyTransform:
+		result = g.SimplifyPreserveTopology(geometry, t.Value)
+	case SubdivideTransform:
+		parts, err := geom.Subdivide(g, geometry, int(t.Value))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parts {
+			if p != geometry {
+				g.DestroyLater(p)
+			}
+		}
+		return parts, nil
+	}
+	if result == nil {
+		return nil, fmt.Errorf("couldn't create geometry for geometry_transform %s", t.Type)
+	}
+	g.DestroyLater(result)
+	if g.IsEmpty(result) {
+		return nil, nil
+	}
+	return []*geos.Geom{result}, nil
+}
@@ -0,0 +1,137 @@
+package diff
+
+import (
+	"io"
+	"sort"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+// MergeParser merges multiple .osc(.gz) files into a single change set.
+// Each element is returned only once with the latest change. The latest
+// change is the change with the highest version, or the change from the
+// later file if the versions are equal.
+//
+// All files are read into memory with the first call of Next. Elements are
+// returned in the same order as in .osc files from the OSM replication:
+// created/modified nodes, ways and relations, followed by deleted
+// relations, ways and nodes.
+type MergeParser struct {
+	fnames []string
+	elems  []Element
+	merged bool
+}
+
+// NewMergeParser returns a parser for the .osc or .osc.gz files. The files need
+// to be ordered from oldest to newest.
+func NewMergeParser(fnames []string) *MergeParser {
+	return &MergeParser{fnames: fnames}
+}
+
+// Next returns the next merged Element.
+// Returns io.EOF and an empty Element after the last element.
+func (p *MergeParser) Next() (Element, error) {
+	if !p.merged {
+		p.merged = true
+		elems, err := mergeFiles(p.fnames)
+		if err != nil {
+			return Element{}, err
+		}
+		p.elems = elems
+	}
+	if len(p.elems) == 0 {
+		return Element{}, io.EOF
+	}
+	e := p.elems[0]
+	p.elems[0] = Element{} // allow GC
+	p.elems = p.elems[1:]
+	return e, nil
+}
+
+type elemKey struct {
+	typ element.MemberType
+	id  int64
+}
+
+func (e *Element) key() elemKey {
+	if e.Node != nil {
+		return elemKey{element.NODE, e.Node.Id}
+	} else if e.Way != nil {
+		return elemKey{element.WAY, e.Way.Id}
+	}
+	return elemKey{element.RELATION, e.Rel.Id}
+}
+
+func (e *Element) version() int {
+	var md *element.Metadata
+	if e.Node != nil {
+		md = e.Node.Metadata
+	} else if e.Way != nil {
+		md = e.Way.Metadata
+	} else if e.Rel != nil {
+		md = e.Rel.Metadata
+	}
+	if md == nil {
+		return 0
+	}
+	return md.Version
+}
+
+func mergeFiles(fnames []string) ([]Element, error) {
+	latest := make(map[elemKey]Element)
+	for _, fname := range fnames {
+		parser, err := NewOscFileParser(fname)
+		if err != nil {
+			return nil, err
+		}
+		parser.SetWithMetadata(true)
+		for {
+			elem, err := parser.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if elem.Node == nil && elem.Way == nil && elem.Rel == nil {
+				continue
+			}
+			k := elem.key()
+			if prev, ok := latest[k]; ok {
+				v := elem.version()
+				if v != 0 && v < prev.version() {
+					continue
+				}
+			}
+			latest[k] = elem
+		}
+	}
+
+	elems := make([]Element, 0, len(latest))
+	for _, elem := range latest {
+		elems = append(elems, elem)
+	}
+	sort.Sort(byChangeOrder(elems))
+	return elems, nil
+}
+
+// byChangeOrder sorts elements in the order of .osc files from the
+// OSM replication.
+type byChangeOrder []Element
+
+func (s byChangeOrder) Len() int      { return len(s) }
+func (s byChangeOrder) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byChangeOrder) Less(i, j int) bool {
+	if s[i].Del != s[j].Del {
+		return !s[i].Del
+	}
+	ki, kj := s[i].key(), s[j].key()
+	if ki.typ != kj.typ {
+		if s[i].Del {
+			// delete relations before ways before nodes
+			return ki.typ > kj.typ
+		}
+		return ki.typ < kj.typ
+	}
+	return ki.id < kj.id
+}
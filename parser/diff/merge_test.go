@@ -0,0 +1,127 @@
+package diff
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeOscGz(t *testing.T, fname, osc string) {
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := gzip.NewWriter(f)
+	if _, err := w.Write([]byte(osc)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeParser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, "001.osc.gz")
+	second := filepath.Join(dir, "002.osc.gz")
+	writeOscGz(t, first, `<osmChange version="0.6">
+<create>
+  <node id="1" version="1" lat="1" lon="1"/>
+  <node id="2" version="1" lat="2" lon="2"/>
+  <way id="10" version="1"><nd ref="1"/><nd ref="2"/><tag k="highway" v="track"/></way>
+</create>
+<modify>
+  <node id="3" version="5" lat="3" lon="3"/>
+  <relation id="20" version="3"><member type="way" ref="10" role=""/></relation>
+</modify>
+</osmChange>`)
+	writeOscGz(t, second, `<osmChange version="0.6">
+<modify>
+  <node id="1" version="2" lat="1.5" lon="1.5"/>
+  <node id="3" version="4" lat="30" lon="30"/>
+  <way id="10" version="2"><nd ref="1"/><nd ref="2"/><tag k="highway" v="path"/></way>
+</modify>
+<delete>
+  <relation id="20" version="4"/>
+  <node id="2" version="2"/>
+</delete>
+</osmChange>`)
+
+	p := NewMergeParser([]string{first, second})
+	var elems []Element
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		elems = append(elems, e)
+	}
+
+	if len(elems) != 5 {
+		t.Fatal(elems)
+	}
+	if e := elems[0]; e.Node == nil || e.Node.Id != 1 || !e.Mod || e.Node.Lat != 1.5 {
+		t.Error("unexpected node", e)
+	}
+	// lower version from later file is ignored
+	if e := elems[1]; e.Node == nil || e.Node.Id != 3 || e.Node.Lat != 3 {
+		t.Error("unexpected node", e)
+	}
+	if e := elems[2]; e.Way == nil || e.Way.Id != 10 || e.Way.Tags["highway"] != "path" {
+		t.Error("unexpected way", e)
+	}
+	if e := elems[3]; e.Rel == nil || e.Rel.Id != 20 || !e.Del {
+		t.Error("unexpected relation", e)
+	}
+	if e := elems[4]; e.Node == nil || e.Node.Id != 2 || !e.Del {
+		t.Error("unexpected node", e)
+	}
+}
+
+func TestMergeParserOsc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, "001.osc.gz")
+	second := filepath.Join(dir, "002.osc")
+	writeOscGz(t, first, `<osmChange version="0.6">
+<create>
+  <node id="-1" lat="1" lon="1"><tag k="amenity" v="cafe"/></node>
+</create>
+</osmChange>`)
+	if err := ioutil.WriteFile(second, []byte(`<osmChange version="0.6">
+<modify>
+  <node id="-1" lat="2" lon="2"><tag k="amenity" v="bar"/></node>
+</modify>
+</osmChange>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewMergeParser([]string{first, second})
+	e, err := p.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// elements without version from later file win
+	if e.Node == nil || e.Node.Id != -1 || e.Node.Tags["amenity"] != "bar" {
+		t.Error("unexpected node", e)
+	}
+	if _, err := p.Next(); err != io.EOF {
+		t.Error("expected EOF", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/omniscale/imposm3/element"
@@ -98,6 +99,21 @@ func NewOscGzParser(fname string) (*Parser, error) {
 	return &Parser{reader: reader, elems: elems, errc: errc, onClose: file.Close}, nil
 }
 
+// NewOscFileParser returns a parser from a .osc or .osc.gz file
+func NewOscFileParser(fname string) (*Parser, error) {
+	if strings.HasSuffix(fname, ".gz") {
+		return NewOscGzParser(fname)
+	}
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	elems := make(chan Element)
+	errc := make(chan error)
+	return &Parser{reader: file, elems: elems, errc: errc, onClose: file.Close}, nil
+}
+
 func parse(reader io.Reader, elems chan Element, errc chan error, metadata bool) {
 	defer close(elems)
 	defer close(errc)
@@ -0,0 +1,161 @@
+/*
+Package batch sends parsed elements in batches to the channels of the
+streaming parsers (XML, o5m).
+*/
+package batch
+
+import "github.com/omniscale/imposm3/element"
+
+// Size is the number of elements per batch, similar to the number of
+// elements in a PBF block.
+const Size = 8000
+
+// Batcher collects parsed elements and sends them in batches to the
+// coords, nodes, ways and relations channels. Channels can be nil.
+// It calls the first way/relation callbacks after all previous elements
+// were sent and before the first way/relation is sent.
+type Batcher struct {
+	coords    chan []element.Node
+	nodes     chan []element.Node
+	ways      chan []element.Way
+	relations chan []element.Relation
+
+	firstWayCb      func()
+	firstRelationCb func()
+	waySeen         bool
+	relationSeen    bool
+
+	coordsBuf    []element.Node
+	nodesBuf     []element.Node
+	waysBuf      []element.Way
+	relationsBuf []element.Relation
+}
+
+func New(
+	coords chan []element.Node,
+	nodes chan []element.Node,
+	ways chan []element.Way,
+	relations chan []element.Relation,
+	firstWayCb func(),
+	firstRelationCb func(),
+) *Batcher {
+	return &Batcher{
+		coords:          coords,
+		nodes:           nodes,
+		ways:            ways,
+		relations:       relations,
+		firstWayCb:      firstWayCb,
+		firstRelationCb: firstRelationCb,
+	}
+}
+
+func (b *Batcher) AddNode(nd element.Node) {
+	if b.coords != nil {
+		coord := nd
+		coord.Tags = nil
+		b.coordsBuf = append(b.coordsBuf, coord)
+		if len(b.coordsBuf) >= Size {
+			b.flushCoords()
+		}
+	}
+	if b.nodes != nil && len(nd.Tags) > 0 {
+		if _, ok := nd.Tags["created_by"]; ok && len(nd.Tags) == 1 {
+			// don't add nodes with only created_by tag to nodes cache
+			return
+		}
+		b.nodesBuf = append(b.nodesBuf, nd)
+		if len(b.nodesBuf) >= Size {
+			b.flushNodes()
+		}
+	}
+}
+
+func (b *Batcher) AddWay(w element.Way) {
+	if b.ways == nil {
+		return
+	}
+	b.firstWay()
+	b.waysBuf = append(b.waysBuf, w)
+	if len(b.waysBuf) >= Size {
+		b.flushWays()
+	}
+}
+
+func (b *Batcher) AddRelation(r element.Relation) {
+	if b.relations == nil {
+		return
+	}
+	b.firstWay()
+	if !b.relationSeen {
+		b.relationSeen = true
+		b.flushWays()
+		if b.firstRelationCb != nil {
+			b.firstRelationCb()
+		}
+	}
+	b.relationsBuf = append(b.relationsBuf, r)
+	if len(b.relationsBuf) >= Size {
+		b.flushRelations()
+	}
+}
+
+func (b *Batcher) firstWay() {
+	if b.waySeen {
+		return
+	}
+	b.waySeen = true
+	b.flushCoords()
+	b.flushNodes()
+	if b.firstWayCb != nil {
+		b.firstWayCb()
+	}
+}
+
+// Flush sends all pending elements. The callbacks are called if they
+// were not called before, as the pbf.Parser does after the last block.
+func (b *Batcher) Flush() {
+	b.flushCoords()
+	b.flushNodes()
+	b.flushWays()
+	b.flushRelations()
+	if !b.waySeen {
+		b.waySeen = true
+		if b.firstWayCb != nil {
+			b.firstWayCb()
+		}
+	}
+	if !b.relationSeen {
+		b.relationSeen = true
+		if b.firstRelationCb != nil {
+			b.firstRelationCb()
+		}
+	}
+}
+
+func (b *Batcher) flushCoords() {
+	if len(b.coordsBuf) > 0 {
+		b.coords <- b.coordsBuf
+		b.coordsBuf = nil
+	}
+}
+
+func (b *Batcher) flushNodes() {
+	if len(b.nodesBuf) > 0 {
+		b.nodes <- b.nodesBuf
+		b.nodesBuf = nil
+	}
+}
+
+func (b *Batcher) flushWays() {
+	if len(b.waysBuf) > 0 {
+		b.ways <- b.waysBuf
+		b.waysBuf = nil
+	}
+}
+
+func (b *Batcher) flushRelations() {
+	if len(b.relationsBuf) > 0 {
+		b.relations <- b.relationsBuf
+		b.relationsBuf = nil
+	}
+}
@@ -0,0 +1,6 @@
+/*
+Package o5m provides a streaming parser for o5m files.
+
+See http://wiki.openstreetmap.org/wiki/O5m for the format specification.
+*/
+package o5m
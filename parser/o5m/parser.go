@@ -0,0 +1,502 @@
+package o5m
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/parser/internal/batch"
+)
+
+const (
+	dsNode      = 0x10
+	dsWay       = 0x11
+	dsRelation  = 0x12
+	dsBbox      = 0xdb
+	dsTimestamp = 0xdc
+	dsHeader    = 0xe0
+	dsEOF       = 0xfe
+	dsReset     = 0xff
+)
+
+const (
+	stringTableSize = 15000
+	// only strings (pairs) up to this length are stored in the string table
+	maxStoredString = 250
+)
+
+var errUnexpectedEnd = errors.New("o5m: unexpected end of dataset")
+
+type Header struct {
+	Time time.Time
+}
+
+// Parser is a stream based parser for o5m files.
+type Parser struct {
+	r               *bufio.Reader
+	header          Header
+	firstWayCb      func()
+	firstRelationCb func()
+	withMetadata    bool
+
+	// first object dataset, already read by NewParser
+	pending     byte
+	pendingData []byte
+
+	strings   [stringTableSize][2]string
+	stringPos int
+
+	lastId        int64
+	lastTimestamp int64
+	lastChangeset int64
+	lastLon       int64
+	lastLat       int64
+	lastRef       int64
+	lastMemberIds [3]int64
+}
+
+// NewParser returns a parser for r. It reads the file header and the
+// file timestamp (if present) and returns an error if r is not an o5m file.
+func NewParser(r io.Reader) (*Parser, error) {
+	p := &Parser{r: bufio.NewReader(r)}
+	first, err := p.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if first != dsReset {
+		return nil, errors.New("o5m: missing reset at start of file")
+	}
+	typ, data, err := p.readDataset()
+	if err != nil {
+		return nil, err
+	}
+	if typ != dsHeader || (string(data) != "o5m2" && string(data) != "o5c2") {
+		return nil, errors.New("o5m: missing o5m2 header")
+	}
+
+	for {
+		typ, data, err := p.readDataset()
+		if err == io.EOF {
+			return p, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case dsReset:
+			p.reset()
+		case dsTimestamp:
+			ts, n := binary.Varint(data)
+			if n <= 0 {
+				return nil, errUnexpectedEnd
+			}
+			p.header.Time = time.Unix(ts, 0)
+		case dsBbox, dsHeader:
+			// ignore
+		default:
+			p.pending, p.pendingData = typ, data
+			return p, nil
+		}
+	}
+}
+
+func (p *Parser) Header() Header {
+	return p.header
+}
+
+// SetWithMetadata enables parsing of metadata (version, timestamp, etc.).
+func (p *Parser) SetWithMetadata(metadata bool) {
+	p.withMetadata = metadata
+}
+
+// RegisterFirstWayCallback registers a callback that gets called when the
+// the first way is parsed. The callback should block until it is
+// safe to send ways to the way channel.
+func (p *Parser) RegisterFirstWayCallback(cb func()) {
+	p.firstWayCb = cb
+}
+
+// RegisterFirstRelationCallback registers a callback that gets called when the
+// the first relation is parsed. The callback should block until it is
+// safe to send relations to the relation channel.
+func (p *Parser) RegisterFirstRelationCallback(cb func()) {
+	p.firstRelationCb = cb
+}
+
+// Parse parses all elements and sends them to the channels.
+// Channels can be nil. Parse does not close the channels.
+func (p *Parser) Parse(
+	coords chan []element.Node,
+	nodes chan []element.Node,
+	ways chan []element.Way,
+	relations chan []element.Relation,
+) error {
+	b := batch.New(coords, nodes, ways, relations, p.firstWayCb, p.firstRelationCb)
+
+	typ, data := p.pending, p.pendingData
+	p.pendingData = nil
+	var err error
+	if typ == 0 {
+		typ, data, err = p.readDataset()
+	}
+	for {
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case dsReset:
+			p.reset()
+		case dsEOF:
+			b.Flush()
+			return nil
+		case dsNode:
+			nd, ok, err := p.parseNode(data)
+			if err != nil {
+				return err
+			}
+			if ok {
+				b.AddNode(nd)
+			}
+		case dsWay:
+			w, ok, err := p.parseWay(data)
+			if err != nil {
+				return err
+			}
+			if ok {
+				b.AddWay(w)
+			}
+		case dsRelation:
+			r, ok, err := p.parseRelation(data)
+			if err != nil {
+				return err
+			}
+			if ok {
+				b.AddRelation(r)
+			}
+		}
+		typ, data, err = p.readDataset()
+	}
+	b.Flush()
+	return nil
+}
+
+// readDataset reads the next dataset. Datasets 0xf0-0xff have no
+// length and no data.
+func (p *Parser) readDataset() (byte, []byte, error) {
+	typ, err := p.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	if typ >= 0xf0 {
+		return typ, nil, nil
+	}
+	length, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	return typ, data, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (p *Parser) reset() {
+	p.stringPos = 0
+	for i := range p.strings {
+		p.strings[i] = [2]string{}
+	}
+	p.lastId = 0
+	p.lastTimestamp = 0
+	p.lastChangeset = 0
+	p.lastLon = 0
+	p.lastLat = 0
+	p.lastRef = 0
+	p.lastMemberIds = [3]int64{}
+}
+
+// dataset is a cursor within the data of a single dataset.
+type dataset struct {
+	data []byte
+	pos  int
+}
+
+func (d *dataset) eof() bool {
+	return d.pos >= len(d.data)
+}
+
+func (d *dataset) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errUnexpectedEnd
+	}
+	d.pos += n
+	return v, nil
+}
+
+// varint reads a signed (zigzag encoded) varint.
+func (d *dataset) varint() (int64, error) {
+	v, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errUnexpectedEnd
+	}
+	d.pos += n
+	return v, nil
+}
+
+// delta reads a signed varint and adds it to last.
+func (d *dataset) delta(last *int64) (int64, error) {
+	v, err := d.varint()
+	if err != nil {
+		return 0, err
+	}
+	*last += v
+	return *last, nil
+}
+
+// cstring reads a zero terminated string.
+func (d *dataset) cstring() (string, error) {
+	end := bytes.IndexByte(d.data[d.pos:], 0)
+	if end < 0 {
+		return "", errUnexpectedEnd
+	}
+	s := string(d.data[d.pos : d.pos+end])
+	d.pos += end + 1
+	return s, nil
+}
+
+// stringPair reads an inline string pair or a reference to a previous
+// string pair. Inline pairs are added to the string table.
+func (p *Parser) stringPair(d *dataset) (string, string, error) {
+	if d.eof() {
+		return "", "", errUnexpectedEnd
+	}
+	if d.data[d.pos] != 0 {
+		return p.stringRef(d)
+	}
+	d.pos += 1
+	a, err := d.cstring()
+	if err != nil {
+		return "", "", err
+	}
+	b, err := d.cstring()
+	if err != nil {
+		return "", "", err
+	}
+	if len(a)+len(b) <= maxStoredString {
+		p.addString(a, b)
+	}
+	return a, b, nil
+}
+
+// singleString reads an inline string or a reference to a previous string.
+// Inline strings are added to the string table.
+func (p *Parser) singleString(d *dataset) (string, error) {
+	if d.eof() {
+		return "", errUnexpectedEnd
+	}
+	if d.data[d.pos] != 0 {
+		s, _, err := p.stringRef(d)
+		return s, err
+	}
+	d.pos += 1
+	s, err := d.cstring()
+	if err != nil {
+		return "", err
+	}
+	if len(s) <= maxStoredString {
+		p.addString(s, "")
+	}
+	return s, nil
+}
+
+func (p *Parser) stringRef(d *dataset) (string, string, error) {
+	ref, err := d.uvarint()
+	if err != nil {
+		return "", "", err
+	}
+	if ref < 1 || ref > stringTableSize {
+		return "", "", errors.New("o5m: invalid string reference " + strconv.FormatUint(ref, 10))
+	}
+	idx := (p.stringPos - int(ref) + stringTableSize) % stringTableSize
+	s := p.strings[idx]
+	return s[0], s[1], nil
+}
+
+func (p *Parser) addString(a, b string) {
+	p.strings[p.stringPos] = [2]string{a, b}
+	p.stringPos = (p.stringPos + 1) % stringTableSize
+}
+
+// objectHeader reads the ID and the version section of an object.
+// The metadata is only returned if withMetadata is enabled and if the
+// object has a version.
+func (p *Parser) objectHeader(d *dataset) (int64, *element.Metadata, error) {
+	id, err := d.delta(&p.lastId)
+	if err != nil {
+		return 0, nil, err
+	}
+	version, err := d.uvarint()
+	if err != nil {
+		return 0, nil, err
+	}
+	if version == 0 {
+		return id, nil, nil
+	}
+	var md *element.Metadata
+	if p.withMetadata {
+		md = &element.Metadata{Version: int(version)}
+	}
+	ts, err := d.delta(&p.lastTimestamp)
+	if err != nil {
+		return 0, nil, err
+	}
+	if ts == 0 {
+		return id, md, nil
+	}
+	changeset, err := d.delta(&p.lastChangeset)
+	if err != nil {
+		return 0, nil, err
+	}
+	uid, user, err := p.stringPair(d)
+	if err != nil {
+		return 0, nil, err
+	}
+	if md != nil {
+		md.Timestamp = time.Unix(ts, 0).UTC()
+		md.Changeset = int(changeset)
+		userId, _ := binary.Uvarint([]byte(uid))
+		md.UserId = int(userId)
+		md.UserName = user
+	}
+	return id, md, nil
+}
+
+func (p *Parser) tags(d *dataset) (element.Tags, error) {
+	var tags element.Tags
+	for !d.eof() {
+		k, v, err := p.stringPair(d)
+		if err != nil {
+			return nil, err
+		}
+		if tags == nil {
+			tags = make(element.Tags)
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// parseNode parses a node dataset. ok is false for deleted nodes.
+func (p *Parser) parseNode(data []byte) (nd element.Node, ok bool, err error) {
+	d := &dataset{data: data}
+	if nd.Id, nd.Metadata, err = p.objectHeader(d); err != nil {
+		return nd, false, err
+	}
+	if d.eof() {
+		return nd, false, nil
+	}
+	lon, err := d.delta(&p.lastLon)
+	if err != nil {
+		return nd, false, err
+	}
+	lat, err := d.delta(&p.lastLat)
+	if err != nil {
+		return nd, false, err
+	}
+	nd.Long = float64(lon) / 1e7
+	nd.Lat = float64(lat) / 1e7
+	if nd.Tags, err = p.tags(d); err != nil {
+		return nd, false, err
+	}
+	return nd, true, nil
+}
+
+// parseWay parses a way dataset. ok is false for deleted ways.
+func (p *Parser) parseWay(data []byte) (w element.Way, ok bool, err error) {
+	d := &dataset{data: data}
+	if w.Id, w.Metadata, err = p.objectHeader(d); err != nil {
+		return w, false, err
+	}
+	if d.eof() {
+		return w, false, nil
+	}
+	length, err := d.uvarint()
+	if err != nil {
+		return w, false, err
+	}
+	end := d.pos + int(length)
+	if end > len(d.data) {
+		return w, false, errUnexpectedEnd
+	}
+	for d.pos < end {
+		ref, err := d.delta(&p.lastRef)
+		if err != nil {
+			return w, false, err
+		}
+		w.Refs = append(w.Refs, ref)
+	}
+	if w.Tags, err = p.tags(d); err != nil {
+		return w, false, err
+	}
+	return w, true, nil
+}
+
+// parseRelation parses a relation dataset. ok is false for deleted relations.
+func (p *Parser) parseRelation(data []byte) (r element.Relation, ok bool, err error) {
+	d := &dataset{data: data}
+	if r.Id, r.Metadata, err = p.objectHeader(d); err != nil {
+		return r, false, err
+	}
+	if d.eof() {
+		return r, false, nil
+	}
+	length, err := d.uvarint()
+	if err != nil {
+		return r, false, err
+	}
+	end := d.pos + int(length)
+	if end > len(d.data) {
+		return r, false, errUnexpectedEnd
+	}
+	for d.pos < end {
+		delta, err := d.varint()
+		if err != nil {
+			return r, false, err
+		}
+		// member type (0, 1 or 2) and role are stored as a single string
+		typeRole, err := p.singleString(d)
+		if err != nil {
+			return r, false, err
+		}
+		if len(typeRole) == 0 || typeRole[0] < '0' || typeRole[0] > '2' {
+			return r, false, errors.New("o5m: invalid member type in relation " + strconv.FormatInt(r.Id, 10))
+		}
+		typ := typeRole[0] - '0'
+		p.lastMemberIds[typ] += delta
+		r.Members = append(r.Members, element.Member{
+			Id:   p.lastMemberIds[typ],
+			Type: element.MemberType(typ),
+			Role: typeRole[1:],
+		})
+	}
+	if r.Tags, err = p.tags(d); err != nil {
+		return r, false, err
+	}
+	return r, true, nil
+}
@@ -0,0 +1,184 @@
+package o5m
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+type o5mBuilder struct {
+	buf bytes.Buffer
+}
+
+func (b *o5mBuilder) dataset(typ byte, data []byte) {
+	b.buf.WriteByte(typ)
+	b.buf.Write(uvarint(uint64(len(data))))
+	b.buf.Write(data)
+}
+
+func uvarint(v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, v)]
+}
+
+func varint(v int64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutVarint(buf, v)]
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func pair(k, v string) []byte {
+	return []byte("\x00" + k + "\x00" + v + "\x00")
+}
+
+func testFile() []byte {
+	b := &o5mBuilder{}
+	b.buf.WriteByte(dsReset)
+	b.dataset(dsHeader, []byte("o5m2"))
+	b.dataset(dsTimestamp, varint(1480454580))
+
+	// untagged node 1, no version
+	b.dataset(dsNode, join(varint(1), uvarint(0), varint(85000000), varint(531000000)))
+	// tagged node 2 with metadata
+	b.dataset(dsNode, join(
+		varint(1), uvarint(3), varint(1480454000), varint(42), pair("\x07", "user"),
+		varint(-10000000), varint(-100),
+		pair("amenity", "pub"), pair("name", "Foo"),
+	))
+	// node 3 with only created_by
+	b.dataset(dsNode, join(varint(1), uvarint(0), varint(1), varint(1), pair("created_by", "JOSM")))
+	// node 4 references the amenity=pub pair (third most recent)
+	b.dataset(dsNode, join(varint(1), uvarint(0), varint(1), varint(1), uvarint(3)))
+	// deleted node
+	b.dataset(dsNode, join(varint(1), uvarint(0)))
+
+	b.buf.WriteByte(dsReset)
+	refs := join(varint(3), varint(-2), varint(1))
+	b.dataset(dsWay, join(varint(100), uvarint(0), uvarint(uint64(len(refs))), refs, pair("highway", "primary")))
+	refs = join(varint(-1))
+	b.dataset(dsWay, join(varint(1), uvarint(0), uvarint(uint64(len(refs))), refs))
+
+	b.buf.WriteByte(dsReset)
+	members := join(
+		varint(100), []byte("\x001outer\x00"),
+		varint(1), uvarint(1), // reference to 1outer
+		varint(2), []byte("\x000\x00"),
+	)
+	b.dataset(dsRelation, join(varint(1000), uvarint(0), uvarint(uint64(len(members))), members, pair("type", "multipolygon")))
+	b.buf.WriteByte(dsEOF)
+	return b.buf.Bytes()
+}
+
+func TestParser(t *testing.T) {
+	p, err := NewParser(bytes.NewReader(testFile()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Header().Time.Equal(time.Unix(1480454580, 0)) {
+		t.Errorf("unexpected header %v", p.Header())
+	}
+	p.SetWithMetadata(true)
+
+	coordsC := make(chan []element.Node, 10)
+	nodesC := make(chan []element.Node, 10)
+	waysC := make(chan []element.Way, 10)
+	relsC := make(chan []element.Relation, 10)
+	var calls []string
+	p.RegisterFirstWayCallback(func() {
+		calls = append(calls, "way")
+		if len(coordsC) != 1 || len(nodesC) != 1 || len(waysC) != 0 {
+			t.Error("nodes not sent before first way callback")
+		}
+	})
+	p.RegisterFirstRelationCallback(func() {
+		calls = append(calls, "relation")
+		if len(waysC) != 1 || len(relsC) != 0 {
+			t.Error("ways not sent before first relation callback")
+		}
+	})
+	if err := p.Parse(coordsC, nodesC, waysC, relsC); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []string{"way", "relation"}) {
+		t.Errorf("unexpected callbacks %v", calls)
+	}
+
+	coords := <-coordsC
+	expectedCoords := []element.Node{
+		{OSMElem: element.OSMElem{Id: 1}, Long: 8.5, Lat: 53.1},
+		{OSMElem: element.OSMElem{Id: 2}, Long: 7.5, Lat: 53.09999},
+		{OSMElem: element.OSMElem{Id: 3}, Long: 7.5000001, Lat: 53.0999901},
+		{OSMElem: element.OSMElem{Id: 4}, Long: 7.5000002, Lat: 53.0999902},
+	}
+	if len(coords) != len(expectedCoords) {
+		t.Fatalf("unexpected coords %v", coords)
+	}
+	for i := range coords {
+		if coords[i].Id != expectedCoords[i].Id ||
+			!almostEqual(coords[i].Long, expectedCoords[i].Long) ||
+			!almostEqual(coords[i].Lat, expectedCoords[i].Lat) ||
+			coords[i].Tags != nil {
+			t.Errorf("unexpected coord %v != %v", coords[i], expectedCoords[i])
+		}
+	}
+
+	nodes := <-nodesC
+	if len(nodes) != 2 || nodes[0].Id != 2 || nodes[1].Id != 4 {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	if !reflect.DeepEqual(nodes[0].Tags, element.Tags{"amenity": "pub", "name": "Foo"}) {
+		t.Errorf("unexpected tags %v", nodes[0].Tags)
+	}
+	expectedMetadata := &element.Metadata{
+		Version:   3,
+		Timestamp: time.Unix(1480454000, 0).UTC(),
+		Changeset: 42,
+		UserId:    7,
+		UserName:  "user",
+	}
+	if !reflect.DeepEqual(nodes[0].Metadata, expectedMetadata) {
+		t.Errorf("unexpected metadata %v", nodes[0].Metadata)
+	}
+	if !reflect.DeepEqual(nodes[1].Tags, element.Tags{"amenity": "pub"}) {
+		t.Errorf("unexpected tags %v", nodes[1].Tags)
+	}
+
+	ways := <-waysC
+	if len(ways) != 2 ||
+		ways[0].Id != 100 || !reflect.DeepEqual(ways[0].Refs, []int64{3, 1, 2}) ||
+		!reflect.DeepEqual(ways[0].Tags, element.Tags{"highway": "primary"}) ||
+		ways[1].Id != 101 || !reflect.DeepEqual(ways[1].Refs, []int64{1}) || ways[1].Tags != nil {
+		t.Errorf("unexpected ways %v", ways)
+	}
+
+	rels := <-relsC
+	if len(rels) != 1 || rels[0].Id != 1000 {
+		t.Fatalf("unexpected relations %v", rels)
+	}
+	expectedMembers := []element.Member{
+		{Id: 100, Type: element.WAY, Role: "outer"},
+		{Id: 101, Type: element.WAY, Role: "outer"},
+		{Id: 2, Type: element.NODE, Role: ""},
+	}
+	if !reflect.DeepEqual(rels[0].Members, expectedMembers) {
+		t.Errorf("unexpected members %v", rels[0].Members)
+	}
+}
+
+func TestParserInvalidHeader(t *testing.T) {
+	if _, err := NewParser(bytes.NewReader([]byte("<osm>"))); err == nil {
+		t.Error("expected error for non o5m file")
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
@@ -0,0 +1,5 @@
+/*
+Package osmxml provides a streaming parser and a writer for OSM XML (.osm)
+files. The writer also supports OSM change (.osc) files.
+*/
+package osmxml
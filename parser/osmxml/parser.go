@@ -0,0 +1,248 @@
+package osmxml
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/parser/internal/batch"
+)
+
+type Header struct {
+	Time time.Time
+}
+
+// Parser is a stream based parser for OSM XML files.
+// Elements should be ordered by type (nodes, ways, relations) for the
+// first way/relation callbacks.
+type Parser struct {
+	decoder         *xml.Decoder
+	header          Header
+	firstWayCb      func()
+	firstRelationCb func()
+	withMetadata    bool
+}
+
+// NewParser returns a parser for r. It reads the <osm> root element and
+// returns an error if r is not an OSM XML file.
+func NewParser(r io.Reader) (*Parser, error) {
+	p := &Parser{decoder: xml.NewDecoder(r)}
+	for {
+		token, err := p.decoder.Token()
+		if err == io.EOF {
+			return nil, errors.New("missing <osm> root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tok, ok := token.(xml.StartElement); ok {
+			if tok.Name.Local != "osm" {
+				return nil, errors.New("expected <osm> root element, got <" + tok.Name.Local + ">")
+			}
+			for _, attr := range tok.Attr {
+				// timestamp is set by osmconvert
+				if attr.Name.Local == "timestamp" {
+					p.header.Time, _ = time.Parse(time.RFC3339, attr.Value)
+				}
+			}
+			return p, nil
+		}
+	}
+}
+
+func (p *Parser) Header() Header {
+	return p.header
+}
+
+// SetWithMetadata enables parsing of metadata (version, timestamp, etc.).
+func (p *Parser) SetWithMetadata(metadata bool) {
+	p.withMetadata = metadata
+}
+
+// RegisterFirstWayCallback registers a callback that gets called when the
+// the first way is parsed. The callback should block until it is
+// safe to send ways to the way channel.
+func (p *Parser) RegisterFirstWayCallback(cb func()) {
+	p.firstWayCb = cb
+}
+
+// RegisterFirstRelationCallback registers a callback that gets called when the
+// the first relation is parsed. The callback should block until it is
+// safe to send relations to the relation channel.
+func (p *Parser) RegisterFirstRelationCallback(cb func()) {
+	p.firstRelationCb = cb
+}
+
+// Parse parses all elements and sends them to the channels.
+// Channels can be nil. Parse does not close the channels.
+func (p *Parser) Parse(
+	coords chan []element.Node,
+	nodes chan []element.Node,
+	ways chan []element.Way,
+	relations chan []element.Relation,
+) error {
+	b := batch.New(coords, nodes, ways, relations, p.firstWayCb, p.firstRelationCb)
+
+	var tags element.Tags
+	var node element.Node
+	var way element.Way
+	var rel element.Relation
+
+NextToken:
+	for {
+		token, err := p.decoder.Token()
+		if err == io.EOF {
+			b.Flush()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "node":
+				node = element.Node{}
+				for _, attr := range tok.Attr {
+					switch attr.Name.Local {
+					case "id":
+						node.Id, err = strconv.ParseInt(attr.Value, 10, 64)
+					case "lat":
+						node.Lat, err = strconv.ParseFloat(attr.Value, 64)
+					case "lon":
+						node.Long, err = strconv.ParseFloat(attr.Value, 64)
+					}
+					if err != nil {
+						return err
+					}
+				}
+				if p.withMetadata {
+					node.Metadata = parseMetadata(tok.Attr)
+				}
+			case "way":
+				way = element.Way{}
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "id" {
+						if way.Id, err = strconv.ParseInt(attr.Value, 10, 64); err != nil {
+							return err
+						}
+					}
+				}
+				if p.withMetadata {
+					way.Metadata = parseMetadata(tok.Attr)
+				}
+			case "relation":
+				rel = element.Relation{}
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "id" {
+						if rel.Id, err = strconv.ParseInt(attr.Value, 10, 64); err != nil {
+							return err
+						}
+					}
+				}
+				if p.withMetadata {
+					rel.Metadata = parseMetadata(tok.Attr)
+				}
+			case "nd":
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "ref" {
+						ref, err := strconv.ParseInt(attr.Value, 10, 64)
+						if err != nil {
+							return err
+						}
+						way.Refs = append(way.Refs, ref)
+					}
+				}
+			case "member":
+				member := element.Member{}
+				for _, attr := range tok.Attr {
+					switch attr.Name.Local {
+					case "type":
+						var ok bool
+						member.Type, ok = element.MemberTypeValues[attr.Value]
+						if !ok {
+							// ignore unknown member types
+							continue NextToken
+						}
+					case "role":
+						member.Role = attr.Value
+					case "ref":
+						member.Id, err = strconv.ParseInt(attr.Value, 10, 64)
+						if err != nil {
+							return err
+						}
+					}
+				}
+				rel.Members = append(rel.Members, member)
+			case "tag":
+				var k, v string
+				for _, attr := range tok.Attr {
+					if attr.Name.Local == "k" {
+						k = attr.Value
+					} else if attr.Name.Local == "v" {
+						v = attr.Value
+					}
+				}
+				if tags == nil {
+					tags = make(element.Tags)
+				}
+				tags[k] = v
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "node":
+				node.Tags = tags
+				tags = nil
+				b.AddNode(node)
+			case "way":
+				way.Tags = tags
+				tags = nil
+				b.AddWay(way)
+			case "relation":
+				rel.Tags = tags
+				tags = nil
+				b.AddRelation(rel)
+			case "osm":
+				b.Flush()
+				return nil
+			}
+		}
+	}
+}
+
+// parseMetadata returns the metadata of an element, or nil if the element
+// has no version attribute.
+func parseMetadata(attrs []xml.Attr) *element.Metadata {
+	var md *element.Metadata
+	for _, attr := range attrs {
+		if attr.Name.Local == "version" {
+			md = &element.Metadata{}
+			break
+		}
+	}
+	if md == nil {
+		return nil
+	}
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "version":
+			v, _ := strconv.ParseInt(attr.Value, 10, 64)
+			md.Version = int(v)
+		case "uid":
+			v, _ := strconv.ParseInt(attr.Value, 10, 64)
+			md.UserId = int(v)
+		case "user":
+			md.UserName = attr.Value
+		case "changeset":
+			v, _ := strconv.ParseInt(attr.Value, 10, 64)
+			md.Changeset = int(v)
+		case "timestamp":
+			md.Timestamp, _ = time.Parse(time.RFC3339, attr.Value)
+		}
+	}
+	return md
+}
@@ -0,0 +1,103 @@
+package osmxml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+const testOsm = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="test" timestamp="2016-11-29T21:23:00Z">
+ <bounds minlat="53" minlon="8" maxlat="54" maxlon="9"/>
+ <node id="1" lat="53.1" lon="8.5"/>
+ <node id="2" lat="53.2" lon="8.6" version="1">
+  <tag k="amenity" v="pub"/>
+ </node>
+ <node id="3" lat="53.3" lon="8.7">
+  <tag k="created_by" v="JOSM"/>
+ </node>
+ <way id="10">
+  <nd ref="1"/>
+  <nd ref="2"/>
+  <tag k="highway" v="primary"/>
+ </way>
+ <relation id="100">
+  <member type="way" ref="10" role="outer"/>
+  <member type="node" ref="3" role=""/>
+  <tag k="type" v="multipolygon"/>
+ </relation>
+</osm>
+`
+
+func TestParser(t *testing.T) {
+	p, err := NewParser(strings.NewReader(testOsm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Header().Time.Equal(time.Date(2016, 11, 29, 21, 23, 0, 0, time.UTC)) {
+		t.Errorf("unexpected header %v", p.Header())
+	}
+	p.SetWithMetadata(true)
+
+	coordsC := make(chan []element.Node, 10)
+	nodesC := make(chan []element.Node, 10)
+	waysC := make(chan []element.Way, 10)
+	relsC := make(chan []element.Relation, 10)
+	var calls []string
+	p.RegisterFirstWayCallback(func() {
+		calls = append(calls, "way")
+		if len(coordsC) != 1 || len(nodesC) != 1 || len(waysC) != 0 {
+			t.Error("nodes not sent before first way callback")
+		}
+	})
+	p.RegisterFirstRelationCallback(func() {
+		calls = append(calls, "relation")
+		if len(waysC) != 1 || len(relsC) != 0 {
+			t.Error("ways not sent before first relation callback")
+		}
+	})
+	if err := p.Parse(coordsC, nodesC, waysC, relsC); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(calls, []string{"way", "relation"}) {
+		t.Errorf("unexpected callbacks %v", calls)
+	}
+
+	coords := <-coordsC
+	if len(coords) != 3 || coords[2].Id != 3 || coords[2].Long != 8.7 || coords[2].Lat != 53.3 || coords[2].Tags != nil {
+		t.Errorf("unexpected coords %v", coords)
+	}
+	nodes := <-nodesC
+	if len(nodes) != 1 || nodes[0].Id != 2 || !reflect.DeepEqual(nodes[0].Tags, element.Tags{"amenity": "pub"}) {
+		t.Errorf("unexpected nodes %v", nodes)
+	}
+	if nodes[0].Metadata == nil || nodes[0].Metadata.Version != 1 {
+		t.Errorf("unexpected metadata %v", nodes[0].Metadata)
+	}
+	if coords[0].Metadata != nil {
+		t.Errorf("unexpected metadata %v", coords[0].Metadata)
+	}
+	ways := <-waysC
+	if len(ways) != 1 || ways[0].Id != 10 ||
+		!reflect.DeepEqual(ways[0].Refs, []int64{1, 2}) ||
+		!reflect.DeepEqual(ways[0].Tags, element.Tags{"highway": "primary"}) {
+		t.Errorf("unexpected ways %v", ways)
+	}
+	rels := <-relsC
+	expectedMembers := []element.Member{
+		{Id: 10, Type: element.WAY, Role: "outer"},
+		{Id: 3, Type: element.NODE, Role: ""},
+	}
+	if len(rels) != 1 || rels[0].Id != 100 || !reflect.DeepEqual(rels[0].Members, expectedMembers) {
+		t.Errorf("unexpected relations %v", rels)
+	}
+}
+
+func TestParserNoOsm(t *testing.T) {
+	if _, err := NewParser(strings.NewReader(`<osmChange version="0.6"/>`)); err == nil {
+		t.Error("expected error for osmChange file")
+	}
+}
@@ -0,0 +1,160 @@
+package osmxml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+const generator = "imposm3"
+
+// Writer writes OSM XML files. Elements of OSM change files are written
+// inside a <create> block, unless another action is set with SetAction.
+// Elements should be written ordered by type (nodes, ways, relations) and ID.
+type Writer struct {
+	w      *bufio.Writer
+	osc    bool
+	action string
+	closed bool
+}
+
+// NewWriter creates a new Writer for .osm files, or for .osc files
+// if osc is true.
+func NewWriter(w io.Writer, osc bool) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	if osc {
+		bw.WriteString(`<osmChange version="0.6" generator="` + generator + `">` + "\n<create>\n")
+	} else {
+		bw.WriteString(`<osm version="0.6" generator="` + generator + `">` + "\n")
+	}
+	return &Writer{w: bw, osc: osc, action: "create"}, nil
+}
+
+// SetAction sets the action (create, modify or delete) of the following
+// elements of OSM change files.
+func (w *Writer) SetAction(action string) {
+	if !w.osc || action == w.action {
+		return
+	}
+	w.w.WriteString("</" + w.action + ">\n<" + action + ">\n")
+	w.action = action
+}
+
+func (w *Writer) WriteNode(nd *element.Node) error {
+	w.w.WriteString(` <node id="`)
+	w.w.WriteString(strconv.FormatInt(nd.Id, 10))
+	w.w.WriteString(`" lat="`)
+	w.w.WriteString(strconv.FormatFloat(nd.Lat, 'f', 7, 64))
+	w.w.WriteString(`" lon="`)
+	w.w.WriteString(strconv.FormatFloat(nd.Long, 'f', 7, 64))
+	w.w.WriteString(`"`)
+	w.writeMetadata(nd.Metadata)
+	if len(nd.Tags) == 0 {
+		_, err := w.w.WriteString("/>\n")
+		return err
+	}
+	w.w.WriteString(">\n")
+	w.writeTags(nd.Tags)
+	_, err := w.w.WriteString(" </node>\n")
+	return err
+}
+
+func (w *Writer) WriteWay(way *element.Way) error {
+	w.w.WriteString(` <way id="`)
+	w.w.WriteString(strconv.FormatInt(way.Id, 10))
+	w.w.WriteString(`"`)
+	w.writeMetadata(way.Metadata)
+	w.w.WriteString(">\n")
+	for _, ref := range way.Refs {
+		w.w.WriteString(`  <nd ref="`)
+		w.w.WriteString(strconv.FormatInt(ref, 10))
+		w.w.WriteString("\"/>\n")
+	}
+	w.writeTags(way.Tags)
+	_, err := w.w.WriteString(" </way>\n")
+	return err
+}
+
+var memberTypes = map[element.MemberType]string{
+	element.NODE:     "node",
+	element.WAY:      "way",
+	element.RELATION: "relation",
+}
+
+func (w *Writer) WriteRelation(rel *element.Relation) error {
+	w.w.WriteString(` <relation id="`)
+	w.w.WriteString(strconv.FormatInt(rel.Id, 10))
+	w.w.WriteString(`"`)
+	w.writeMetadata(rel.Metadata)
+	w.w.WriteString(">\n")
+	for _, m := range rel.Members {
+		w.w.WriteString(`  <member type="`)
+		w.w.WriteString(memberTypes[m.Type])
+		w.w.WriteString(`" ref="`)
+		w.w.WriteString(strconv.FormatInt(m.Id, 10))
+		w.w.WriteString(`" role="`)
+		w.escape(m.Role)
+		w.w.WriteString("\"/>\n")
+	}
+	w.writeTags(rel.Tags)
+	_, err := w.w.WriteString(" </relation>\n")
+	return err
+}
+
+// Close writes the closing tags and flushes all pending data. It does
+// not close the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.osc {
+		w.w.WriteString("</" + w.action + ">\n</osmChange>\n")
+	} else {
+		w.w.WriteString("</osm>\n")
+	}
+	return w.w.Flush()
+}
+
+func (w *Writer) writeMetadata(md *element.Metadata) {
+	if md == nil {
+		return
+	}
+	w.w.WriteString(` version="`)
+	w.w.WriteString(strconv.FormatInt(int64(md.Version), 10))
+	w.w.WriteString(`" timestamp="`)
+	w.w.WriteString(md.Timestamp.UTC().Format(time.RFC3339))
+	w.w.WriteString(`" changeset="`)
+	w.w.WriteString(strconv.FormatInt(int64(md.Changeset), 10))
+	w.w.WriteString(`" uid="`)
+	w.w.WriteString(strconv.FormatInt(int64(md.UserId), 10))
+	w.w.WriteString(`" user="`)
+	w.escape(md.UserName)
+	w.w.WriteString(`"`)
+}
+
+func (w *Writer) writeTags(tags element.Tags) {
+	// sort keys for reproducible output
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.w.WriteString(`  <tag k="`)
+		w.escape(k)
+		w.w.WriteString(`" v="`)
+		w.escape(tags[k])
+		w.w.WriteString("\"/>\n")
+	}
+}
+
+func (w *Writer) escape(s string) {
+	xml.EscapeText(w.w, []byte(s))
+}
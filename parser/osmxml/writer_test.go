@@ -0,0 +1,130 @@
+package osmxml
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/parser/diff"
+)
+
+func TestWriteOsm(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteNode(&element.Node{OSMElem: element.OSMElem{Id: 1}, Long: 8.5, Lat: 53})
+	w.WriteWay(&element.Way{OSMElem: element.OSMElem{Id: 2, Tags: element.Tags{"name": `"A" & <B>`}}, Refs: []int64{1, 3}})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="imposm3">
+ <node id="1" lat="53.0000000" lon="8.5000000"/>
+ <way id="2">
+  <nd ref="1"/>
+  <nd ref="3"/>
+  <tag k="name" v="&#34;A&#34; &amp; &lt;B&gt;"/>
+ </way>
+</osm>
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestWriteOscRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := element.Node{OSMElem: element.OSMElem{Id: 1, Tags: element.Tags{"amenity": "pub"}}, Long: 8.5, Lat: 53.1234567}
+	way := element.Way{OSMElem: element.OSMElem{Id: 2, Tags: element.Tags{"highway": "primary"}}, Refs: []int64{1, 3}}
+	rel := element.Relation{
+		OSMElem: element.OSMElem{Id: 3, Tags: element.Tags{"type": "multipolygon"}},
+		Members: []element.Member{{Id: 2, Type: element.WAY, Role: "outer"}},
+	}
+	w.WriteNode(&node)
+	w.WriteWay(&way)
+	w.WriteRelation(&rel)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<create>") {
+		t.Fatal("missing <create> in", buf.String())
+	}
+
+	p := diff.NewParser(buf)
+	var elems []diff.Element
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		elems = append(elems, e)
+	}
+	if len(elems) != 3 {
+		t.Fatalf("unexpected elements %v", elems)
+	}
+	for _, e := range elems {
+		if !e.Add {
+			t.Errorf("expected create for %v", e)
+		}
+	}
+	if !reflect.DeepEqual(*elems[0].Node, node) {
+		t.Errorf("unexpected node %v", elems[0].Node)
+	}
+	if !reflect.DeepEqual(*elems[1].Way, way) {
+		t.Errorf("unexpected way %v", elems[1].Way)
+	}
+	if !reflect.DeepEqual(*elems[2].Rel, rel) {
+		t.Errorf("unexpected relation %v", elems[2].Rel)
+	}
+}
+
+func TestWriteOscActions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetAction("delete")
+	w.WriteNode(&element.Node{OSMElem: element.OSMElem{Id: 1}})
+	w.SetAction("modify")
+	w.WriteWay(&element.Way{OSMElem: element.OSMElem{Id: 2}, Refs: []int64{1, 3}})
+	w.SetAction("modify")
+	w.WriteWay(&element.Way{OSMElem: element.OSMElem{Id: 3}, Refs: []int64{1, 3}})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	p := diff.NewParser(buf)
+	var elems []diff.Element
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		elems = append(elems, e)
+	}
+	if len(elems) != 3 {
+		t.Fatalf("unexpected elements %v", elems)
+	}
+	if !elems[0].Del || elems[0].Node == nil {
+		t.Errorf("expected deleted node %v", elems[0])
+	}
+	if !elems[1].Mod || !elems[2].Mod {
+		t.Errorf("expected modified ways %v", elems[1:])
+	}
+}
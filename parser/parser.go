@@ -1 +1,239 @@
+/*
+Package parser opens OSM files in all supported formats (PBF, XML and o5m).
+
+XML and o5m files can be compressed with gzip or bzip2.
+*/
 package parser
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/parser/o5m"
+	"github.com/omniscale/imposm3/parser/osmxml"
+	"github.com/omniscale/imposm3/parser/pbf"
+)
+
+type Header struct {
+	Time     time.Time
+	Sequence int64
+	Filename string
+	// LocationsOnWays is true if the ways include the coordinates
+	// of their nodes (only supported by PBF files).
+	LocationsOnWays bool
+}
+
+// Parser sends all elements of an OSM file to the coords, nodes, ways and
+// relations channels. See pbf.Parser.
+type Parser interface {
+	Header() Header
+	// Parse sends all elements to the channels. Channels can be nil.
+	// Parse does not close the channels.
+	Parse(
+		coords chan []element.Node,
+		nodes chan []element.Node,
+		ways chan []element.Way,
+		relations chan []element.Relation,
+	) error
+	// SetWithMetadata enables parsing of metadata (version, timestamp, etc.).
+	SetWithMetadata(metadata bool)
+	// RegisterFirstWayCallback registers a callback that gets called
+	// after all nodes were sent and before the first way is sent.
+	RegisterFirstWayCallback(cb func())
+	// RegisterFirstRelationCallback registers a callback that gets called
+	// after all ways were sent and before the first relation is sent.
+	RegisterFirstRelationCallback(cb func())
+	// Close closes the file. Parse closes the file on its own, Close is
+	// only required if Parse is not called (e.g. to read the header).
+	Close() error
+}
+
+type format int
+
+const (
+	unknownFormat format = iota
+	pbfFormat
+	xmlFormat
+	o5mFormat
+)
+
+// NewParser returns a Parser for filename. The format is detected by the
+// file extension (.pbf, .osm, .o5m, optionally with .gz or .bz2) or by
+// the content of the file.
+func NewParser(filename string) (Parser, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(3)
+	compressed := true
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		r = gz
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		r = bzip2.NewReader(br)
+	default:
+		r = br
+		compressed = false
+	}
+	if compressed {
+		br = bufio.NewReader(r)
+		r = br
+	}
+
+	ft := formatFromFilename(filename)
+	if ft == unknownFormat {
+		ft = formatFromContent(br)
+	}
+
+	switch ft {
+	case pbfFormat:
+		f.Close()
+		if compressed {
+			return nil, errors.New("compressed PBF files are not supported: " + filename)
+		}
+		p, err := pbf.NewParser(filename)
+		if err != nil {
+			return nil, err
+		}
+		return &pbfParser{p}, nil
+	case xmlFormat:
+		p, err := osmxml.NewParser(r)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &streamParser{
+			streamParserImpl: p,
+			header:           Header{Time: p.Header().Time, Filename: filename},
+			f:                f,
+		}, nil
+	case o5mFormat:
+		p, err := o5m.NewParser(r)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &streamParser{
+			streamParserImpl: p,
+			header:           Header{Time: p.Header().Time, Filename: filename},
+			f:                f,
+		}, nil
+	}
+	f.Close()
+	return nil, errors.New("unknown file format: " + filename)
+}
+
+func formatFromFilename(filename string) format {
+	name := strings.ToLower(filename)
+	name = strings.TrimSuffix(name, ".gz")
+	name = strings.TrimSuffix(name, ".bz2")
+	switch {
+	case strings.HasSuffix(name, ".pbf"):
+		return pbfFormat
+	case strings.HasSuffix(name, ".osm"):
+		return xmlFormat
+	case strings.HasSuffix(name, ".o5m"):
+		return o5mFormat
+	}
+	return unknownFormat
+}
+
+func formatFromContent(r *bufio.Reader) format {
+	buf, _ := r.Peek(64)
+	switch {
+	case bytes.HasPrefix(buf, []byte{0xff, 0xe0}):
+		return o5mFormat
+	case bytes.Contains(buf, []byte("OSMHeader")):
+		return pbfFormat
+	}
+	// skip UTF-8 BOM and whitespace
+	buf = bytes.TrimPrefix(buf, []byte{0xef, 0xbb, 0xbf})
+	buf = bytes.TrimLeft(buf, " \t\r\n")
+	if bytes.HasPrefix(buf, []byte("<")) {
+		return xmlFormat
+	}
+	return unknownFormat
+}
+
+type pbfParser struct {
+	*pbf.Parser
+}
+
+func (p *pbfParser) Header() Header {
+	h := p.Parser.Header()
+	return Header{
+		Time:            h.Time,
+		Sequence:        h.Sequence,
+		Filename:        h.Filename,
+		LocationsOnWays: h.LocationsOnWays,
+	}
+}
+
+func (p *pbfParser) Parse(
+	coords chan []element.Node,
+	nodes chan []element.Node,
+	ways chan []element.Way,
+	relations chan []element.Relation,
+) error {
+	p.Parser.Parse(coords, nodes, ways, relations)
+	return nil
+}
+
+func (p *pbfParser) Close() error {
+	return p.Parser.Close()
+}
+
+// streamParserImpl is implemented by osmxml.Parser and o5m.Parser.
+type streamParserImpl interface {
+	Parse(
+		coords chan []element.Node,
+		nodes chan []element.Node,
+		ways chan []element.Way,
+		relations chan []element.Relation,
+	) error
+	SetWithMetadata(metadata bool)
+	RegisterFirstWayCallback(cb func())
+	RegisterFirstRelationCallback(cb func())
+}
+
+// streamParser closes the file after Parse.
+type streamParser struct {
+	streamParserImpl
+	header Header
+	f      *os.File
+}
+
+func (p *streamParser) Header() Header {
+	return p.header
+}
+
+func (p *streamParser) Parse(
+	coords chan []element.Node,
+	nodes chan []element.Node,
+	ways chan []element.Way,
+	relations chan []element.Relation,
+) error {
+	defer p.f.Close()
+	return p.streamParserImpl.Parse(coords, nodes, ways, relations)
+}
+
+func (p *streamParser) Close() error {
+	return p.f.Close()
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+func TestNewParserDetectFormat(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "imposm3-parser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	// gzip compressed XML without known file extension
+	fname := filepath.Join(tmpdir, "extract.dat")
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(`<?xml version="1.0"?><osm version="0.6"><node id="1" lat="1" lon="2"/></osm>`))
+	gz.Close()
+	f.Close()
+
+	p, err := NewParser(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(*streamParser); !ok {
+		t.Fatalf("unexpected parser %T", p)
+	}
+	coords := make(chan []element.Node, 1)
+	if err := p.Parse(coords, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	nds := <-coords
+	if len(nds) != 1 || nds[0].Id != 1 || nds[0].Long != 2 {
+		t.Errorf("unexpected coords %v", nds)
+	}
+
+	p, err = NewParser("pbf/monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(*pbfParser); !ok {
+		t.Errorf("unexpected parser %T", p)
+	}
+
+	unknown := filepath.Join(tmpdir, "unknown")
+	ioutil.WriteFile(unknown, []byte("foo"), 0644)
+	if _, err := NewParser(unknown); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -579,11 +579,19 @@ func (m *DenseNodes) GetKeysVals() []int32 {
 type Way struct {
 	Id *int64 `protobuf:"varint,1,req,name=id" json:"id,omitempty"`
 	// Parallel arrays.
-	Keys             []uint32 `protobuf:"varint,2,rep,packed,name=keys" json:"keys,omitempty"`
-	Vals             []uint32 `protobuf:"varint,3,rep,packed,name=vals" json:"vals,omitempty"`
-	Info             *Info    `protobuf:"bytes,4,opt,name=info" json:"info,omitempty"`
-	Refs             []int64  `protobuf:"zigzag64,8,rep,packed,name=refs" json:"refs,omitempty"`
-	XXX_unrecognized []byte   `json:"-"`
+	Keys []uint32 `protobuf:"varint,2,rep,packed,name=keys" json:"keys,omitempty"`
+	Vals []uint32 `protobuf:"varint,3,rep,packed,name=vals" json:"vals,omitempty"`
+	Info *Info    `protobuf:"bytes,4,opt,name=info" json:"info,omitempty"`
+	Refs []int64  `protobuf:"zigzag64,8,rep,packed,name=refs" json:"refs,omitempty"`
+	// The following two fields are optional. They are only used in a special
+	// format where node locations are also added to the ways. This makes the
+	// files larger, but allows creating way geometries directly.
+	//
+	// If this is used, you MUST set the optional_features tag "LocationsOnWays"
+	// and the number of values in refs, lat, and lon MUST be the same.
+	Lat              []int64 `protobuf:"zigzag64,9,rep,packed,name=lat" json:"lat,omitempty"`
+	Lon              []int64 `protobuf:"zigzag64,10,rep,packed,name=lon" json:"lon,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *Way) Reset()         { *m = Way{} }
@@ -625,6 +633,20 @@ func (m *Way) GetRefs() []int64 {
 	return nil
 }
 
+func (m *Way) GetLat() []int64 {
+	if m != nil {
+		return m.Lat
+	}
+	return nil
+}
+
+func (m *Way) GetLon() []int64 {
+	if m != nil {
+		return m.Lon
+	}
+	return nil
+}
+
 type Relation struct {
 	Id *int64 `protobuf:"varint,1,req,name=id" json:"id,omitempty"`
 	// Parallel arrays.
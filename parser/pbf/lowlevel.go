@@ -108,6 +108,11 @@ func readAndParseHeaderBlock(pos block) (*Header, error) {
 	result.Sequence = header.GetOsmosisReplicationSequenceNumber()
 	result.RequiredFeatures = header.RequiredFeatures
 	result.OptionalFeatures = header.OptionalFeatures
+	for _, feature := range header.OptionalFeatures {
+		if feature == "LocationsOnWays" {
+			result.LocationsOnWays = true
+		}
+	}
 	return result, nil
 }
 
@@ -125,6 +130,9 @@ type Header struct {
 
 	RequiredFeatures []string
 	OptionalFeatures []string
+	// LocationsOnWays is true if the ways include the coordinates
+	// of their nodes.
+	LocationsOnWays bool
 }
 
 func open(filename string) (f *pbf, err error) {
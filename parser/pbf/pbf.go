@@ -1,6 +1,8 @@
 package pbf
 
 import (
+	"time"
+
 	"github.com/omniscale/imposm3/element"
 	"github.com/omniscale/imposm3/parser/pbf/internal/osmpbf"
 )
@@ -18,7 +20,8 @@ func intToCoord(coord uint32) float64 {
 func readDenseNodes(
 	dense *osmpbf.DenseNodes,
 	block *osmpbf.PrimitiveBlock,
-	stringtable stringTable) (coords []element.Node, nodes []element.Node) {
+	stringtable stringTable,
+	withMetadata bool) (coords []element.Node, nodes []element.Node) {
 
 	var lastId int64
 	var lastLon, lastLat int64
@@ -30,6 +33,16 @@ func readDenseNodes(
 	coordScale := 0.000000001
 	lastKeyValPos := 0
 
+	info := dense.GetDenseinfo()
+	if !withMetadata || info == nil || len(info.Version) != len(dense.Id) ||
+		len(info.Timestamp) != len(dense.Id) || len(info.Changeset) != len(dense.Id) ||
+		len(info.Uid) != len(dense.Id) || len(info.UserSid) != len(dense.Id) {
+		info = nil
+	}
+	var lastTimestamp, lastChangeset int64
+	var lastUid, lastUserSid int32
+	dateGranularity := int64(block.GetDateGranularity())
+
 	for i := range coords {
 		lastId += dense.Id[i]
 		lastLon += dense.Lon[i]
@@ -37,6 +50,19 @@ func readDenseNodes(
 		coords[i].Id = lastId
 		coords[i].Long = (coordScale * float64(lonOffset+(granularity*lastLon)))
 		coords[i].Lat = (coordScale * float64(latOffset+(granularity*lastLat)))
+		if info != nil {
+			lastTimestamp += info.Timestamp[i]
+			lastChangeset += info.Changeset[i]
+			lastUid += info.Uid[i]
+			lastUserSid += info.UserSid[i]
+			coords[i].Metadata = &element.Metadata{
+				Version:   int(info.Version[i]),
+				Timestamp: time.Unix(0, lastTimestamp*dateGranularity*int64(time.Millisecond)).UTC(),
+				Changeset: int(lastChangeset),
+				UserId:    int(lastUid),
+				UserName:  stringtable[lastUserSid],
+			}
+		}
 		if stringtable != nil && len(dense.KeysVals) > 0 {
 			if dense.KeysVals[lastKeyValPos] != 0 {
 				tags := parseDenseNodeTags(stringtable, &dense.KeysVals, &lastKeyValPos)
@@ -92,10 +118,27 @@ func parseTags(stringtable stringTable, keys []uint32, vals []uint32) map[string
 	return tags
 }
 
+// parseInfo returns the metadata of a non-dense element, or nil if the
+// element has no metadata.
+func parseInfo(info *osmpbf.Info, block *osmpbf.PrimitiveBlock, stringtable stringTable) *element.Metadata {
+	if info == nil {
+		return nil
+	}
+	dateGranularity := int64(block.GetDateGranularity())
+	return &element.Metadata{
+		Version:   int(info.GetVersion()),
+		Timestamp: time.Unix(0, info.GetTimestamp()*dateGranularity*int64(time.Millisecond)).UTC(),
+		Changeset: int(info.GetChangeset()),
+		UserId:    int(info.GetUid()),
+		UserName:  stringtable[info.GetUserSid()],
+	}
+}
+
 func readNodes(
 	nodes []*osmpbf.Node,
 	block *osmpbf.PrimitiveBlock,
-	stringtable stringTable) ([]element.Node, []element.Node) {
+	stringtable stringTable,
+	withMetadata bool) ([]element.Node, []element.Node) {
 
 	coords := make([]element.Node, len(nodes))
 	nds := make([]element.Node, 0, len(nodes)/8)
@@ -111,6 +154,9 @@ func readNodes(
 		coords[i].Id = id
 		coords[i].Long = (coordScale * float64(lonOffset+(granularity*lon)))
 		coords[i].Lat = (coordScale * float64(latOffset+(granularity*lat)))
+		if withMetadata {
+			coords[i].Metadata = parseInfo(nodes[i].Info, block, stringtable)
+		}
 		if stringtable != nil {
 			tags := parseTags(stringtable, nodes[i].Keys, nodes[i].Vals)
 			if tags != nil {
@@ -141,15 +187,36 @@ func parseDeltaRefs(refs []int64) []int64 {
 func readWays(
 	ways []*osmpbf.Way,
 	block *osmpbf.PrimitiveBlock,
-	stringtable stringTable) []element.Way {
+	stringtable stringTable,
+	withMetadata bool) []element.Way {
 
 	result := make([]element.Way, len(ways))
+	granularity := int64(block.GetGranularity())
+	latOffset := block.GetLatOffset()
+	lonOffset := block.GetLonOffset()
+	coordScale := 0.000000001
 
 	for i := range ways {
 		id := *ways[i].Id
 		result[i].Id = id
 		result[i].Tags = parseTags(stringtable, ways[i].Keys, ways[i].Vals)
 		result[i].Refs = parseDeltaRefs(ways[i].Refs)
+		if len(ways[i].Lat) > 0 && len(ways[i].Lat) == len(ways[i].Refs) && len(ways[i].Lon) == len(ways[i].Refs) {
+			// LocationsOnWays
+			nodes := make([]element.Node, len(ways[i].Refs))
+			var lastLon, lastLat int64
+			for j := range nodes {
+				lastLon += ways[i].Lon[j]
+				lastLat += ways[i].Lat[j]
+				nodes[j].Id = result[i].Refs[j]
+				nodes[j].Long = (coordScale * float64(lonOffset+(granularity*lastLon)))
+				nodes[j].Lat = (coordScale * float64(latOffset+(granularity*lastLat)))
+			}
+			result[i].Nodes = nodes
+		}
+		if withMetadata {
+			result[i].Metadata = parseInfo(ways[i].Info, block, stringtable)
+		}
 	}
 	return result
 }
@@ -170,7 +237,8 @@ func parseRelationMembers(rel *osmpbf.Relation, stringtable stringTable) []eleme
 func readRelations(
 	relations []*osmpbf.Relation,
 	block *osmpbf.PrimitiveBlock,
-	stringtable stringTable) []element.Relation {
+	stringtable stringTable,
+	withMetadata bool) []element.Relation {
 
 	result := make([]element.Relation, len(relations))
 
@@ -179,6 +247,9 @@ func readRelations(
 		result[i].Id = id
 		result[i].Tags = parseTags(stringtable, relations[i].Keys, relations[i].Vals)
 		result[i].Members = parseRelationMembers(relations[i], stringtable)
+		if withMetadata {
+			result[i].Metadata = parseInfo(relations[i].Info, block, stringtable)
+		}
 	}
 	return result
 }
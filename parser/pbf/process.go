@@ -18,6 +18,8 @@ type Parser struct {
 	wg        sync.WaitGroup
 	waySync   *barrier
 	relSync   *barrier
+
+	withMetadata bool
 }
 
 func NewParser(
@@ -38,6 +40,11 @@ func (p *Parser) Header() Header {
 	return *p.pbf.header
 }
 
+// Close closes the file. Only required if Parse is not called.
+func (p *Parser) Close() error {
+	return p.pbf.close()
+}
+
 func (p *Parser) Parse(
 	coords chan []element.Node,
 	nodes chan []element.Node,
@@ -67,6 +74,11 @@ func (p *Parser) Parse(
 	p.wg.Wait()
 }
 
+// SetWithMetadata enables parsing of metadata (version, timestamp, etc.).
+func (p *Parser) SetWithMetadata(metadata bool) {
+	p.withMetadata = metadata
+}
+
 // RegisterFirstWayCallback registers a callback that gets called when the
 // the first way is parsed. The callback should block until it is
 // safe to send ways to the way channel.
@@ -93,7 +105,7 @@ func (p *Parser) parseBlock(pos block) {
 		if p.coords != nil || p.nodes != nil {
 			dense := group.GetDense()
 			if dense != nil {
-				parsedCoords, parsedNodes := readDenseNodes(dense, block, stringtable)
+				parsedCoords, parsedNodes := readDenseNodes(dense, block, stringtable, p.withMetadata)
 				if len(parsedCoords) > 0 && p.coords != nil {
 					p.coords <- parsedCoords
 				}
@@ -102,7 +114,7 @@ func (p *Parser) parseBlock(pos block) {
 				}
 			}
 			if len(group.Nodes) > 0 {
-				parsedCoords, parsedNodes := readNodes(group.Nodes, block, stringtable)
+				parsedCoords, parsedNodes := readNodes(group.Nodes, block, stringtable, p.withMetadata)
 				if len(parsedCoords) > 0 && p.coords != nil {
 					p.coords <- parsedCoords
 				}
@@ -112,7 +124,7 @@ func (p *Parser) parseBlock(pos block) {
 			}
 		}
 		if len(group.Ways) > 0 && p.ways != nil {
-			parsedWays := readWays(group.Ways, block, stringtable)
+			parsedWays := readWays(group.Ways, block, stringtable, p.withMetadata)
 			if len(parsedWays) > 0 {
 				if p.waySync != nil {
 					p.waySync.doneWait()
@@ -121,7 +133,7 @@ func (p *Parser) parseBlock(pos block) {
 			}
 		}
 		if len(group.Relations) > 0 && p.relations != nil {
-			parsedRelations := readRelations(group.Relations, block, stringtable)
+			parsedRelations := readRelations(group.Relations, block, stringtable, p.withMetadata)
 			if len(parsedRelations) > 0 {
 				if p.waySync != nil {
 					p.waySync.doneWait()
@@ -103,6 +103,38 @@ func TestParseCoords(t *testing.T) {
 	}
 }
 
+func TestParseMetadata(t *testing.T) {
+	ways := make(chan []element.Way)
+
+	p, err := NewParser("monaco-20150428.osm.pbf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetWithMetadata(true)
+
+	wg := sync.WaitGroup{}
+	var withoutMetadata int
+	wg.Add(1)
+	go func() {
+		for ws := range ways {
+			for _, w := range ws {
+				if w.Metadata == nil || w.Metadata.Version < 1 || w.Metadata.Timestamp.Year() < 2005 {
+					withoutMetadata += 1
+				}
+			}
+		}
+		wg.Done()
+	}()
+
+	p.Parse(nil, nil, ways, nil)
+	close(ways)
+	wg.Wait()
+
+	if withoutMetadata != 0 {
+		t.Error("ways without metadata:", withoutMetadata)
+	}
+}
+
 func TestParserNotify(t *testing.T) {
 	nodes := make(chan []element.Node)
 	coords := make(chan []element.Node)
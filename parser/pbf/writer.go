@@ -0,0 +1,330 @@
+package pbf
+
+import (
+	"bytes"
+	"compress/zlib"
+	structs "encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/parser/pbf/internal/osmpbf"
+)
+
+// maxBlockElements is the number of elements per PrimitiveBlock.
+// The spec recommends 8000 elements per block.
+const maxBlockElements = 8000
+
+const writingProgram = "imposm3"
+
+var errWrongOrder = errors.New("pbf writer: elements need to be ordered by type (nodes, ways, relations)")
+
+// Writer writes OSM PBF files with DenseNodes.
+// Elements need to be written ordered by type: all nodes first, then
+// all ways and all relations last. Elements of each type should be
+// sorted by ID. Metadata is not written.
+type Writer struct {
+	w               io.Writer
+	locationsOnWays bool
+	nodes           []element.Node
+	ways            []element.Way
+	relations       []element.Relation
+	// 0 nodes, 1 ways, 2 relations
+	current int
+	closed  bool
+}
+
+// NewWriter creates a Writer and writes the OSMHeader block to w.
+// The replication timestamp and sequence of header are included if they
+// are set. The coordinates of way nodes are included if
+// header.LocationsOnWays is true. Ways need to include all Nodes in this case.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	hb := &osmpbf.HeaderBlock{
+		RequiredFeatures: []string{"OsmSchema-V0.6", "DenseNodes"},
+		OptionalFeatures: header.OptionalFeatures,
+		Writingprogram:   proto.String(writingProgram),
+	}
+	if header.LocationsOnWays {
+		hasFeature := false
+		for _, f := range hb.OptionalFeatures {
+			if f == "LocationsOnWays" {
+				hasFeature = true
+			}
+		}
+		if !hasFeature {
+			hb.OptionalFeatures = append(hb.OptionalFeatures, "LocationsOnWays")
+		}
+	}
+	if !header.Time.IsZero() {
+		hb.OsmosisReplicationTimestamp = proto.Int64(header.Time.Unix())
+	}
+	if header.Sequence != 0 {
+		hb.OsmosisReplicationSequenceNumber = proto.Int64(header.Sequence)
+	}
+	data, err := proto.Marshal(hb)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBlob(w, "OSMHeader", data); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, locationsOnWays: header.LocationsOnWays}, nil
+}
+
+func (w *Writer) WriteNode(nd *element.Node) error {
+	if w.current > 0 {
+		return errWrongOrder
+	}
+	w.nodes = append(w.nodes, *nd)
+	if len(w.nodes) >= maxBlockElements {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *Writer) WriteWay(way *element.Way) error {
+	if w.current > 1 {
+		return errWrongOrder
+	}
+	if w.current < 1 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+		w.current = 1
+	}
+	w.ways = append(w.ways, *way)
+	if len(w.ways) >= maxBlockElements {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *Writer) WriteRelation(rel *element.Relation) error {
+	if w.current < 2 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+		w.current = 2
+	}
+	w.relations = append(w.relations, *rel)
+	if len(w.relations) >= maxBlockElements {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes all pending elements. It does not close the underlying
+// io.Writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	if len(w.nodes) == 0 && len(w.ways) == 0 && len(w.relations) == 0 {
+		return nil
+	}
+	st := newStringTableBuilder()
+	group := &osmpbf.PrimitiveGroup{}
+	if len(w.nodes) > 0 {
+		group.Dense = denseNodes(w.nodes, st)
+		w.nodes = w.nodes[:0]
+	}
+	if len(w.ways) > 0 {
+		group.Ways = pbfWays(w.ways, st, w.locationsOnWays)
+		w.ways = w.ways[:0]
+	}
+	if len(w.relations) > 0 {
+		group.Relations = pbfRelations(w.relations, st)
+		w.relations = w.relations[:0]
+	}
+	block := &osmpbf.PrimitiveBlock{
+		Stringtable:    &osmpbf.StringTable{S: st.strings},
+		Primitivegroup: []*osmpbf.PrimitiveGroup{group},
+	}
+	data, err := proto.Marshal(block)
+	if err != nil {
+		return err
+	}
+	return writeBlob(w.w, "OSMData", data)
+}
+
+func writeBlob(w io.Writer, blobType string, raw []byte) error {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	blob, err := proto.Marshal(&osmpbf.Blob{
+		RawSize:  proto.Int32(int32(len(raw))),
+		ZlibData: buf.Bytes(),
+	})
+	if err != nil {
+		return err
+	}
+	header, err := proto.Marshal(&osmpbf.BlobHeader{
+		Type:     proto.String(blobType),
+		Datasize: proto.Int32(int32(len(blob))),
+	})
+	if err != nil {
+		return err
+	}
+	if err := structs.Write(w, structs.BigEndian, int32(len(header))); err != nil {
+		return err
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err = w.Write(blob)
+	return err
+}
+
+type stringTableBuilder struct {
+	strings [][]byte
+	index   map[string]uint32
+}
+
+func newStringTableBuilder() *stringTableBuilder {
+	// index 0 is reserved as delimiter
+	return &stringTableBuilder{
+		strings: [][]byte{[]byte{}},
+		index:   make(map[string]uint32),
+	}
+}
+
+func (st *stringTableBuilder) id(s string) uint32 {
+	if id, ok := st.index[s]; ok {
+		return id
+	}
+	id := uint32(len(st.strings))
+	st.strings = append(st.strings, []byte(s))
+	st.index[s] = id
+	return id
+}
+
+func (st *stringTableBuilder) tags(tags element.Tags) (keys []uint32, vals []uint32) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	// sort keys for reproducible output
+	sortedKeys := make([]string, 0, len(tags))
+	for k := range tags {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Strings(sortedKeys)
+	keys = make([]uint32, len(tags))
+	vals = make([]uint32, len(tags))
+	for i, k := range sortedKeys {
+		keys[i] = st.id(k)
+		vals[i] = st.id(tags[k])
+	}
+	return keys, vals
+}
+
+// coordToPbf converts coord to nanodegrees / default granularity (100)
+func coordToPbf(coord float64) int64 {
+	return int64(math.Floor(coord*1e7 + 0.5))
+}
+
+func denseNodes(nodes []element.Node, st *stringTableBuilder) *osmpbf.DenseNodes {
+	dense := &osmpbf.DenseNodes{
+		Id:  make([]int64, len(nodes)),
+		Lat: make([]int64, len(nodes)),
+		Lon: make([]int64, len(nodes)),
+	}
+	withTags := false
+	for i := range nodes {
+		if len(nodes[i].Tags) > 0 {
+			withTags = true
+			break
+		}
+	}
+
+	var lastId, lastLat, lastLon int64
+	for i, nd := range nodes {
+		lat := coordToPbf(nd.Lat)
+		lon := coordToPbf(nd.Long)
+		dense.Id[i] = nd.Id - lastId
+		dense.Lat[i] = lat - lastLat
+		dense.Lon[i] = lon - lastLon
+		lastId, lastLat, lastLon = nd.Id, lat, lon
+
+		if withTags {
+			keys, vals := st.tags(nd.Tags)
+			for j := range keys {
+				dense.KeysVals = append(dense.KeysVals, int32(keys[j]), int32(vals[j]))
+			}
+			dense.KeysVals = append(dense.KeysVals, 0)
+		}
+	}
+	return dense
+}
+
+func deltaRefs(refs []int64) []int64 {
+	result := make([]int64, len(refs))
+	var lastRef int64
+	for i, ref := range refs {
+		result[i] = ref - lastRef
+		lastRef = ref
+	}
+	return result
+}
+
+func pbfWays(ways []element.Way, st *stringTableBuilder, locationsOnWays bool) []*osmpbf.Way {
+	result := make([]*osmpbf.Way, len(ways))
+	for i, way := range ways {
+		keys, vals := st.tags(way.Tags)
+		result[i] = &osmpbf.Way{
+			Id:   proto.Int64(way.Id),
+			Keys: keys,
+			Vals: vals,
+			Refs: deltaRefs(way.Refs),
+		}
+		if locationsOnWays && len(way.Nodes) == len(way.Refs) {
+			result[i].Lat = make([]int64, len(way.Nodes))
+			result[i].Lon = make([]int64, len(way.Nodes))
+			var lastLat, lastLon int64
+			for j, nd := range way.Nodes {
+				lat := coordToPbf(nd.Lat)
+				lon := coordToPbf(nd.Long)
+				result[i].Lat[j] = lat - lastLat
+				result[i].Lon[j] = lon - lastLon
+				lastLat, lastLon = lat, lon
+			}
+		}
+	}
+	return result
+}
+
+func pbfRelations(rels []element.Relation, st *stringTableBuilder) []*osmpbf.Relation {
+	result := make([]*osmpbf.Relation, len(rels))
+	for i, rel := range rels {
+		keys, vals := st.tags(rel.Tags)
+		r := &osmpbf.Relation{
+			Id:       proto.Int64(rel.Id),
+			Keys:     keys,
+			Vals:     vals,
+			RolesSid: make([]int32, len(rel.Members)),
+			Memids:   make([]int64, len(rel.Members)),
+			Types:    make([]osmpbf.Relation_MemberType, len(rel.Members)),
+		}
+		var lastId int64
+		for j, m := range rel.Members {
+			r.RolesSid[j] = int32(st.id(m.Role))
+			r.Memids[j] = m.Id - lastId
+			r.Types[j] = osmpbf.Relation_MemberType(m.Type)
+			lastId = m.Id
+		}
+		result[i] = r
+	}
+	return result
+}
@@ -0,0 +1,184 @@
+package pbf
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/element"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	f, err := ioutil.TempFile("", "imposm3-pbf-writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	ts := time.Date(2016, 11, 29, 21, 23, 0, 0, time.UTC)
+	w, err := NewWriter(f, Header{Time: ts, Sequence: 1234})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := []element.Node{
+		{OSMElem: element.OSMElem{Id: 1}, Long: 8.1234567, Lat: 53.1},
+		{OSMElem: element.OSMElem{Id: 2, Tags: element.Tags{"amenity": "pub", "name": "Foo"}}, Long: -8.5, Lat: -53.7654321},
+		{OSMElem: element.OSMElem{Id: 10}, Long: 179.9999999, Lat: 0},
+	}
+	for i := range nodes {
+		if err := w.WriteNode(&nodes[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	way := element.Way{OSMElem: element.OSMElem{Id: 100, Tags: element.Tags{"highway": "primary"}}, Refs: []int64{10, 1, 2}}
+	if err := w.WriteWay(&way); err != nil {
+		t.Fatal(err)
+	}
+	rel := element.Relation{
+		OSMElem: element.OSMElem{Id: 1000, Tags: element.Tags{"type": "multipolygon"}},
+		Members: []element.Member{
+			{Id: 100, Type: element.WAY, Role: "outer"},
+			{Id: 2, Type: element.NODE, Role: ""},
+		},
+	}
+	if err := w.WriteRelation(&rel); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteNode(&nodes[0]); err != errWrongOrder {
+		t.Error("expected errWrongOrder, got", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	p, err := NewParser(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := p.Header()
+	if !header.Time.Equal(ts) || header.Sequence != 1234 {
+		t.Errorf("unexpected header %v", header)
+	}
+
+	coordsC := make(chan []element.Node)
+	nodesC := make(chan []element.Node)
+	waysC := make(chan []element.Way)
+	relsC := make(chan []element.Relation)
+
+	var coords, taggedNodes []element.Node
+	var ways []element.Way
+	var rels []element.Relation
+	wg := sync.WaitGroup{}
+	wg.Add(4)
+	go func() {
+		for nds := range coordsC {
+			coords = append(coords, nds...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for nds := range nodesC {
+			taggedNodes = append(taggedNodes, nds...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for ws := range waysC {
+			ways = append(ways, ws...)
+		}
+		wg.Done()
+	}()
+	go func() {
+		for rs := range relsC {
+			rels = append(rels, rs...)
+		}
+		wg.Done()
+	}()
+	p.Parse(coordsC, nodesC, waysC, relsC)
+	close(coordsC)
+	close(nodesC)
+	close(waysC)
+	close(relsC)
+	wg.Wait()
+
+	if len(coords) != 3 {
+		t.Fatalf("unexpected coords %v", coords)
+	}
+	for i := range coords {
+		if coords[i].Id != nodes[i].Id ||
+			math.Abs(coords[i].Long-nodes[i].Long) > 1e-7 ||
+			math.Abs(coords[i].Lat-nodes[i].Lat) > 1e-7 {
+			t.Errorf("unexpected coord %v != %v", coords[i], nodes[i])
+		}
+	}
+	if len(taggedNodes) != 1 || !reflect.DeepEqual(taggedNodes[0].Tags, nodes[1].Tags) {
+		t.Errorf("unexpected nodes %v", taggedNodes)
+	}
+	if len(ways) != 1 || ways[0].Id != 100 ||
+		!reflect.DeepEqual(ways[0].Refs, way.Refs) ||
+		!reflect.DeepEqual(ways[0].Tags, way.Tags) {
+		t.Errorf("unexpected ways %v", ways)
+	}
+	if len(rels) != 1 || rels[0].Id != 1000 ||
+		!reflect.DeepEqual(rels[0].Members, rel.Members) ||
+		!reflect.DeepEqual(rels[0].Tags, rel.Tags) {
+		t.Errorf("unexpected relations %v", rels)
+	}
+}
+
+func TestWriterLocationsOnWays(t *testing.T) {
+	f, err := ioutil.TempFile("", "imposm3-pbf-writer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	w, err := NewWriter(f, Header{LocationsOnWays: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	way := element.Way{
+		OSMElem: element.OSMElem{Id: 100, Tags: element.Tags{"highway": "primary"}},
+		Refs:    []int64{10, 1},
+		Nodes: []element.Node{
+			{OSMElem: element.OSMElem{Id: 10}, Long: 8.1234567, Lat: 53.1},
+			{OSMElem: element.OSMElem{Id: 1}, Long: -8.5, Lat: -53.7654321},
+		},
+	}
+	if err := w.WriteWay(&way); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	p, err := NewParser(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Header().LocationsOnWays {
+		t.Error("LocationsOnWays not set in header")
+	}
+
+	waysC := make(chan []element.Way, 1)
+	p.Parse(nil, nil, waysC, nil)
+	close(waysC)
+	ways := <-waysC
+	if len(ways) != 1 || len(ways[0].Nodes) != 2 {
+		t.Fatalf("unexpected ways %v", ways)
+	}
+	for i, nd := range ways[0].Nodes {
+		if nd.Id != way.Nodes[i].Id ||
+			math.Abs(nd.Long-way.Nodes[i].Long) > 1e-7 ||
+			math.Abs(nd.Lat-way.Nodes[i].Lat) > 1e-7 {
+			t.Errorf("unexpected node %v != %v", nd, way.Nodes[i])
+		}
+	}
+}
@@ -27,3 +27,43 @@ func TestMercToWgs(t *testing.T) {
 		t.Fatalf("%v %v", long, lat)
 	}
 }
+
+func TestUTMToWgs(t *testing.T) {
+	for _, tc := range []struct {
+		zone      int
+		south     bool
+		x, y      float64
+		long, lat float64
+	}{
+		{32, false, 500000, 0, 9, 0},
+		{32, false, 466522.5129, 5883511.6115, 8.5, 53.1},
+		{33, false, 391390.7313, 5817855.2413, 13.4, 52.5},
+		{23, true, 684623.6733, 7466421.4007, -43.2, -22.9},
+	} {
+		long, lat := UTMToWgs(tc.zone, tc.south, tc.x, tc.y)
+		if math.Abs(long-tc.long) > 1e-6 || math.Abs(lat-tc.lat) > 1e-6 {
+			t.Errorf("unexpected coords for %v: %v %v", tc, long, lat)
+		}
+	}
+}
+
+func TestUTMZone(t *testing.T) {
+	for _, tc := range []struct {
+		srid  int
+		zone  int
+		south bool
+		ok    bool
+	}{
+		{32632, 32, false, true},
+		{32723, 23, true, true},
+		{25832, 32, false, true},
+		{26918, 18, false, true},
+		{3857, 0, false, false},
+		{31467, 0, false, false},
+	} {
+		zone, south, ok := UTMZone(tc.srid)
+		if zone != tc.zone || south != tc.south || ok != tc.ok {
+			t.Errorf("unexpected zone for %d: %d %v %v", tc.srid, zone, south, ok)
+		}
+	}
+}
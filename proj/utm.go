@@ -0,0 +1,65 @@
+package proj
+
+import "math"
+
+// WGS84 ellipsoid, also used for ETRS89 and NAD83 (GRS80), the difference
+// is below 1 mm.
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+)
+
+// rectifying radius and series coefficients of the inverse transverse
+// Mercator projection (Krüger series)
+var utmA, utmBeta, utmDelta = kruegerCoefficients(wgs84A, wgs84F)
+
+func kruegerCoefficients(a, f float64) (float64, [3]float64, [3]float64) {
+	n := f / (2 - f)
+	n2, n3 := n*n, n*n*n
+	rect := a / (1 + n) * (1 + n2/4 + n2*n2/64)
+	beta := [3]float64{n/2 - 2*n2/3 + 37*n3/96, n2/48 + n3/15, 17 * n3 / 480}
+	delta := [3]float64{2*n - 2*n2/3 - 2*n3, 7*n2/3 - 8*n3/5, 56 * n3 / 15}
+	return rect, beta, delta
+}
+
+// UTMToWgs transforms x/y in UTM zone (1-60) to long/lat. south is true
+// for zones on the southern hemisphere.
+func UTMToWgs(zone int, south bool, x, y float64) (long, lat float64) {
+	const k0 = 0.9996
+	if south {
+		y -= 10000000
+	}
+	xi := y / (k0 * utmA)
+	eta := (x - 500000) / (k0 * utmA)
+
+	xi1, eta1 := xi, eta
+	for j, beta := range utmBeta {
+		j2 := 2 * float64(j+1)
+		xi1 -= beta * math.Sin(j2*xi) * math.Cosh(j2*eta)
+		eta1 -= beta * math.Cos(j2*xi) * math.Sinh(j2*eta)
+	}
+	chi := math.Asin(math.Sin(xi1) / math.Cosh(eta1))
+	phi := chi + utmDelta[0]*math.Sin(2*chi) + utmDelta[1]*math.Sin(4*chi) + utmDelta[2]*math.Sin(6*chi)
+	lambda := math.Atan2(math.Sinh(eta1), math.Cos(xi1))
+
+	long = float64(zone*6-183) + lambda*180/math.Pi
+	lat = phi * 180 / math.Pi
+	return long, lat
+}
+
+// UTMZone returns the zone of an EPSG code for UTM with WGS84
+// (326xx/327xx), ETRS89 (258xx) or NAD83 (269xx). ok is false for all
+// other codes.
+func UTMZone(srid int) (zone int, south bool, ok bool) {
+	switch {
+	case srid >= 32601 && srid <= 32660:
+		return srid - 32600, false, true
+	case srid >= 32701 && srid <= 32760:
+		return srid - 32700, true, true
+	case srid >= 25828 && srid <= 25838:
+		return srid - 25800, false, true
+	case srid >= 26901 && srid <= 26923:
+		return srid - 26900, false, true
+	}
+	return 0, false, false
+}
@@ -0,0 +1,103 @@
+package reader
+
+import (
+	"sync"
+
+	osmcache "github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/element"
+)
+
+// deduplicator decides which element is stored if an element is present
+// in multiple input files. The element with the highest version wins.
+// The element from the later file wins if the versions are equal or if
+// one of the elements has no metadata.
+type deduplicator struct {
+	versions *osmcache.VersionsCache
+	// index of the current input file
+	file int
+
+	mu sync.Mutex
+	// nodes from the current file that replaced nodes from a previous file
+	replacedNodes map[int64]struct{}
+	// replaced nodes that are stored in the nodes cache
+	keptNodes map[int64]struct{}
+}
+
+func newDeduplicator(versions *osmcache.VersionsCache) *deduplicator {
+	return &deduplicator{versions: versions}
+}
+
+// startFile resets the state for the next input file.
+func (d *deduplicator) startFile(file int) {
+	d.file = file
+	d.replacedNodes = make(map[int64]struct{})
+	d.keptNodes = make(map[int64]struct{})
+}
+
+// check returns whether the element from the current file should be
+// stored and whether it replaces an element from a previous file.
+// The result for an element is the same for all goroutines (e.g. coords
+// and nodes), as check only compares with versions from previous files.
+func (d *deduplicator) check(typ element.MemberType, id int64, md *element.Metadata) (store bool, replaced bool, err error) {
+	prev, err := d.versions.GetVersion(typ, id)
+	if err == osmcache.NotFound {
+		return true, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if prev.File == d.file {
+		// already checked by another goroutine
+		return true, prev.Replaced, nil
+	}
+	if md != nil && md.Version != 0 && prev.Version != 0 && md.Version < prev.Version {
+		return false, false, nil
+	}
+	return true, true, nil
+}
+
+// checkOrLog calls check and logs errors. Elements are stored in case
+// of errors.
+func (d *deduplicator) checkOrLog(typ element.MemberType, id int64, md *element.Metadata) (store bool, replaced bool) {
+	store, replaced, err := d.check(typ, id, md)
+	if err != nil {
+		log.Errorf("error while checking version of %d: %v", id, err)
+		return true, false
+	}
+	return store, replaced
+}
+
+func (d *deduplicator) version(id int64, md *element.Metadata, replaced bool) osmcache.ElementVersion {
+	v := osmcache.ElementVersion{Id: id, File: d.file, Replaced: replaced}
+	if md != nil {
+		v.Version = md.Version
+	}
+	return v
+}
+
+func (d *deduplicator) nodeReplaced(id int64) {
+	d.mu.Lock()
+	d.replacedNodes[id] = struct{}{}
+	d.mu.Unlock()
+}
+
+func (d *deduplicator) nodeKept(id int64) {
+	d.mu.Lock()
+	d.keptNodes[id] = struct{}{}
+	d.mu.Unlock()
+}
+
+// removeReplacedNodes removes tagged nodes from previous files from the
+// nodes cache, if they were replaced by a node that is not stored in the
+// nodes cache (e.g. without tags).
+func (d *deduplicator) removeReplacedNodes(cache *osmcache.OSMCache) error {
+	for id := range d.replacedNodes {
+		if _, ok := d.keptNodes[id]; ok {
+			continue
+		}
+		if err := cache.Nodes.DeleteNode(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
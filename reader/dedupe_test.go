@@ -0,0 +1,65 @@
+package reader
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	osmcache "github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/element"
+)
+
+func TestDeduplicator(t *testing.T) {
+	cacheDir, _ := ioutil.TempDir("", "imposm3_test")
+	defer os.RemoveAll(cacheDir)
+
+	cache := osmcache.NewOSMCache(cacheDir)
+	versions, err := cache.NewVersionsCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer versions.Remove()
+
+	d := newDeduplicator(versions)
+	d.startFile(0)
+	v3 := &element.Metadata{Version: 3}
+	if store, replaced, err := d.check(element.WAY, 1, v3); err != nil || !store || replaced {
+		t.Fatal("unexpected result for new element", store, replaced, err)
+	}
+	versions.PutVersions(element.WAY, []osmcache.ElementVersion{
+		d.version(1, v3, false),
+		d.version(2, nil, false),
+	})
+
+	d.startFile(1)
+	for _, tc := range []struct {
+		typ      element.MemberType
+		id       int64
+		md       *element.Metadata
+		store    bool
+		replaced bool
+	}{
+		{element.WAY, 1, &element.Metadata{Version: 2}, false, false},
+		{element.WAY, 1, &element.Metadata{Version: 3}, true, true},
+		{element.WAY, 1, &element.Metadata{Version: 4}, true, true},
+		{element.WAY, 1, nil, true, true},
+		{element.WAY, 2, &element.Metadata{Version: 1}, true, true},
+		{element.WAY, 3, nil, true, false},
+		// same ID but different type
+		{element.NODE, 1, &element.Metadata{Version: 1}, true, false},
+	} {
+		store, replaced, err := d.check(tc.typ, tc.id, tc.md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if store != tc.store || replaced != tc.replaced {
+			t.Errorf("unexpected result for %v: %v %v", tc, store, replaced)
+		}
+	}
+
+	// other goroutines get the same result after the version was stored
+	versions.PutVersions(element.WAY, []osmcache.ElementVersion{d.version(1, &element.Metadata{Version: 4}, true)})
+	if store, replaced, err := d.check(element.WAY, 1, &element.Metadata{Version: 4}); err != nil || !store || !replaced {
+		t.Error("unexpected result for element of current file", store, replaced, err)
+	}
+}
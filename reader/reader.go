@@ -14,7 +14,7 @@ import (
 	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/logging"
 	"github.com/omniscale/imposm3/mapping"
-	"github.com/omniscale/imposm3/parser/pbf"
+	"github.com/omniscale/imposm3/parser"
 	"github.com/omniscale/imposm3/stats"
 )
 
@@ -50,12 +50,57 @@ func readersForCpus(cpus int) (int64, int64, int64, int64, int64) {
 	return int64(math.Ceil(cpuf * 0.75)), int64(math.Ceil(cpuf * 0.25)), int64(math.Ceil(cpuf * 0.25)), int64(math.Ceil(cpuf * 0.25)), int64(math.Ceil(cpuf * 0.25))
 }
 
-func ReadPbf(
+// ReadFiles reads all elements from one or more OSM files (PBF, XML or
+// o5m) into cache. Elements that are present in multiple files are
+// deduplicated and the element with the highest version is stored.
+// The linear import optimization of the coords cache is only used for
+// the first file.
+// The coords cache is skipped for single PBF files with LocationsOnWays,
+// unless coordsRequired is true (e.g. for diff imports). The coords are
+// stored in the ways cache instead.
+func ReadFiles(
+	filenames []string,
+	cache *osmcache.OSMCache,
+	progress *stats.Statistics,
+	tagmapping *mapping.Mapping,
+	limiter *limit.Limiter,
+	coordsRequired bool,
+) error {
+	if len(filenames) == 1 {
+		return readFile(filenames[0], cache, progress, tagmapping, limiter, coordsRequired, nil)
+	}
+
+	versions, err := cache.NewVersionsCache()
+	if err != nil {
+		return err
+	}
+	defer versions.Remove()
+
+	dedupe := newDeduplicator(versions)
+	for i, filename := range filenames {
+		if i > 0 {
+			// linear import does not support updated coords
+			cache.Coords.SetLinearImport(false)
+		}
+		dedupe.startFile(i)
+		if err := readFile(filename, cache, progress, tagmapping, limiter, true, dedupe); err != nil {
+			return err
+		}
+		if err := dedupe.removeReplacedNodes(cache); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(
 	filename string,
 	cache *osmcache.OSMCache,
 	progress *stats.Statistics,
 	tagmapping *mapping.Mapping,
 	limiter *limit.Limiter,
+	coordsRequired bool,
+	dedupe *deduplicator,
 ) error {
 	nodes := make(chan []element.Node, 4)
 	coords := make(chan []element.Node, 4)
@@ -67,19 +112,29 @@ func ReadPbf(
 		withLimiter = true
 	}
 
-	parser, err := pbf.NewParser(filename)
+	p, err := parser.NewParser(filename)
 	if err != nil {
 		return err
 	}
 
-	if header := parser.Header(); header.Time.Unix() != 0 {
+	if header := p.Header(); !header.Time.IsZero() && header.Time.Unix() != 0 {
 		log.Printf("reading %s with data till %v", filename, header.Time.Local())
 	}
+	if dedupe != nil {
+		// versions are required for deduplication
+		p.SetWithMetadata(true)
+	}
+
+	// all coords are included in the ways, no need for the coords cache
+	withoutCoords := !coordsRequired && p.Header().LocationsOnWays
+	if withoutCoords {
+		log.Printf("%s contains locations on ways, skipping coords cache", filename)
+	}
 
 	// wait for all coords/nodes to be processed before continuing with
 	// ways. required for -limitto checks
 	coordsSync := sync.WaitGroup{}
-	parser.RegisterFirstWayCallback(func() {
+	p.RegisterFirstWayCallback(func() {
 		for i := 0; int64(i) < nCoords; i++ {
 			coords <- nil
 		}
@@ -92,7 +147,7 @@ func ReadPbf(
 	// wait for all ways to be processed before continuing with
 	// relations. required for -limitto checks
 	waysSync := sync.WaitGroup{}
-	parser.RegisterFirstRelationCallback(func() {
+	p.RegisterFirstRelationCallback(func() {
 		for i := 0; int64(i) < nWays; i++ {
 			ways <- nil
 		}
@@ -106,6 +161,8 @@ func ReadPbf(
 		waitWriter.Add(1)
 		go func() {
 			var skip, hit int
+			g := geos.NewGeos()
+			defer g.Finish()
 
 			m := tagmapping.WayTagFilter()
 			for ws := range ways {
@@ -117,13 +174,36 @@ func ReadPbf(
 				if skipWays {
 					continue
 				}
+				var versions []osmcache.ElementVersion
 				for i, _ := range ws {
+					id := ws[i].Id
+					replaced := false
+					if dedupe != nil {
+						var store bool
+						store, replaced = dedupe.checkOrLog(element.WAY, id, ws[i].Metadata)
+						if !store {
+							ws[i].Id = osmcache.SKIP
+							continue
+						}
+						versions = append(versions, dedupe.version(id, ws[i].Metadata, replaced))
+					}
 					m.Filter(&ws[i].Tags)
+					if !withoutCoords {
+						// coords cache is used for all ways
+						ws[i].Nodes = nil
+					}
 					if withLimiter {
-						cached, err := cache.Coords.FirstRefIsCached(ws[i].Refs)
-						if err != nil {
-							log.Errorf("error while checking for cached refs of way %d: %v", ws[i].Id, err)
-							cached = true // don't skip in case of error
+						var cached bool
+						if withoutCoords {
+							// same as FirstRefIsCached
+							cached = len(ws[i].Nodes) > 0 && limiter.IntersectsBuffer(g, ws[i].Nodes[0].Long, ws[i].Nodes[0].Lat)
+						} else {
+							var err error
+							cached, err = cache.Coords.FirstRefIsCached(ws[i].Refs)
+							if err != nil {
+								log.Errorf("error while checking for cached refs of way %d: %v", ws[i].Id, err)
+								cached = true // don't skip in case of error
+							}
 						}
 						if cached {
 							hit += 1
@@ -132,11 +212,22 @@ func ReadPbf(
 							skip += 1
 						}
 					}
+					if replaced && ws[i].Id == osmcache.SKIP {
+						// remove way from previous file
+						if err := cache.Ways.DeleteWay(id); err != nil {
+							log.Errorf("error while removing replaced way %d: %v", id, err)
+						}
+					}
 				}
 				err := cache.Ways.PutWays(ws)
 				if err != nil {
 					log.Errorf("error while caching ways: %v", err)
 				}
+				if dedupe != nil {
+					if err := dedupe.versions.PutVersions(element.WAY, versions); err != nil {
+						log.Errorf("error while caching way versions: %v", err)
+					}
+				}
 				progress.AddWays(len(ws))
 			}
 
@@ -152,13 +243,31 @@ func ReadPbf(
 			m := tagmapping.RelationTagFilter()
 			for rels := range relations {
 				numWithTags := 0
+				var versions []osmcache.ElementVersion
 				for i, _ := range rels {
+					id := rels[i].Id
+					replaced := false
+					if dedupe != nil {
+						var store bool
+						store, replaced = dedupe.checkOrLog(element.RELATION, id, rels[i].Metadata)
+						if !store {
+							rels[i].Id = osmcache.SKIP
+							continue
+						}
+						versions = append(versions, dedupe.version(id, rels[i].Metadata, replaced))
+					}
 					m.Filter(&rels[i].Tags)
 					if len(rels[i].Tags) > 0 {
 						numWithTags += 1
 					}
 					if withLimiter {
-						cached, err := cache.FirstMemberIsCached(rels[i].Members)
+						var cached bool
+						var err error
+						if withoutCoords {
+							cached, err = cache.FirstWayMemberIsCached(rels[i].Members)
+						} else {
+							cached, err = cache.FirstMemberIsCached(rels[i].Members)
+						}
 						if err != nil {
 							log.Errorf("error while checking for cached members of relation %d: %v", rels[i].Id, err)
 							cached = true // don't skip in case of error
@@ -170,11 +279,22 @@ func ReadPbf(
 							rels[i].Id = osmcache.SKIP
 						}
 					}
+					if replaced && (rels[i].Id == osmcache.SKIP || len(rels[i].Tags) == 0) {
+						// remove relation from previous file
+						if err := cache.Relations.DeleteRelation(id); err != nil {
+							log.Errorf("error while removing replaced relation %d: %v", id, err)
+						}
+					}
 				}
 				err := cache.Relations.PutRelations(rels)
 				if err != nil {
 					log.Errorf("error while caching relation: %v", err)
 				}
+				if dedupe != nil {
+					if err := dedupe.versions.PutVersions(element.RELATION, versions); err != nil {
+						log.Errorf("error while caching relation versions: %v", err)
+					}
+				}
 				progress.AddRelations(numWithTags)
 			}
 
@@ -195,8 +315,32 @@ func ReadPbf(
 					coordsSync.Wait()
 					continue
 				}
+				var versions []osmcache.ElementVersion
+				if dedupe != nil {
+					for i, _ := range nds {
+						id := nds[i].Id
+						store, replaced := dedupe.checkOrLog(element.NODE, id, nds[i].Metadata)
+						if !store {
+							nds[i].Id = osmcache.SKIP
+							continue
+						}
+						versions = append(versions, dedupe.version(id, nds[i].Metadata, replaced))
+						if replaced {
+							dedupe.nodeReplaced(id)
+							if withLimiter && !limiter.IntersectsBuffer(g, nds[i].Long, nds[i].Lat) {
+								// remove coord from previous file
+								if err := cache.Coords.DeleteCoord(id); err != nil {
+									log.Errorf("error while removing replaced coord %d: %v", id, err)
+								}
+							}
+						}
+					}
+				}
 				if withLimiter {
 					for i, _ := range nds {
+						if nds[i].Id == osmcache.SKIP {
+							continue
+						}
 						if !limiter.IntersectsBuffer(g, nds[i].Long, nds[i].Lat) {
 							skip += 1
 							nds[i].Id = osmcache.SKIP
@@ -206,6 +350,11 @@ func ReadPbf(
 					}
 				}
 				cache.Coords.PutCoords(nds)
+				if dedupe != nil {
+					if err := dedupe.versions.PutVersions(element.NODE, versions); err != nil {
+						log.Errorf("error while caching node versions: %v", err)
+					}
+				}
 				progress.AddCoords(len(nds))
 			}
 			waitWriter.Done()
@@ -227,6 +376,15 @@ func ReadPbf(
 				}
 				numWithTags := 0
 				for i, _ := range nds {
+					replaced := false
+					if dedupe != nil {
+						var store bool
+						store, replaced = dedupe.checkOrLog(element.NODE, nds[i].Id, nds[i].Metadata)
+						if !store {
+							nds[i].Id = osmcache.SKIP
+							continue
+						}
+					}
 					m.Filter(&nds[i].Tags)
 					if len(nds[i].Tags) > 0 {
 						numWithTags += 1
@@ -236,6 +394,9 @@ func ReadPbf(
 							nds[i].Id = osmcache.SKIP
 						}
 					}
+					if replaced && nds[i].Id != osmcache.SKIP && len(nds[i].Tags) > 0 {
+						dedupe.nodeKept(nds[i].Id)
+					}
 				}
 				cache.Nodes.PutNodes(nds)
 				progress.AddNodes(numWithTags)
@@ -244,12 +405,16 @@ func ReadPbf(
 		}()
 	}
 
-	parser.Parse(coords, nodes, ways, relations)
+	parserCoords := coords
+	if withoutCoords {
+		parserCoords = nil
+	}
+	err = p.Parse(parserCoords, nodes, ways, relations)
 	close(nodes)
 	close(coords)
 	close(ways)
 	close(relations)
 	waitWriter.Wait()
 
-	return nil
+	return err
 }
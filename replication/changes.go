@@ -17,6 +17,8 @@ func NewChangesetDownloader(dest, url string, seq int, interval time.Duration) *
 	dl.fileExt = ".osm.gz"
 	dl.stateExt = ".state.txt"
 	dl.stateTime = parseYamlTime
+	dl.validateFile = validateGzipXml("osm")
+	dl.validateState = validateYamlState
 	go dl.fetchNextLoop()
 	return dl
 }
@@ -0,0 +1,148 @@
+package replication
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/omniscale/imposm3/update/state"
+)
+
+// Retention defines which diffs are kept in a replication directory.
+// Diffs are removed if they are older than Seqs sequences and older than
+// Age. Zero values are ignored. Diffs that are not imported are never
+// removed.
+type Retention struct {
+	Seqs int
+	Age  time.Duration
+}
+
+// IsZero returns true if no retention is defined.
+func (r Retention) IsZero() bool {
+	return r.Seqs == 0 && r.Age == 0
+}
+
+// FeedDir returns the sub-directory of dest for the diffs of a
+// replication feed. See NewFeedsDiffDownloader.
+func FeedDir(dest, url string) string {
+	return path.Join(dest, Feed{Url: url}.name())
+}
+
+// seqFromPath returns the sequence for files like AAA/BBB/CCC.osc.gz.
+func seqFromPath(rel string) (int, bool) {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	idx := strings.Index(parts[2], ".")
+	if idx < 0 || strings.Contains(parts[2], "~") {
+		return 0, false
+	}
+	parts[2] = parts[2][:idx]
+	seq := 0
+	for _, p := range parts {
+		if len(p) != 3 {
+			return 0, false
+		}
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return 0, false
+		}
+		seq = seq*1000 + v
+	}
+	return seq, true
+}
+
+// Cleanup removes all diff and state files from dir that are not needed
+// by the retention. Diffs after lastSeq and the diff of lastSeq itself
+// are always kept. Empty directories are removed as well. It returns the
+// number of removed files.
+func Cleanup(dir string, lastSeq int, retention Retention) (int, error) {
+	if retention.IsZero() {
+		return 0, nil
+	}
+	now := time.Now()
+	removed := 0
+	dirs := make(map[string]struct{})
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		seq, ok := seqFromPath(rel)
+		if !ok || seq >= lastSeq {
+			return nil
+		}
+		if retention.Seqs != 0 && seq > lastSeq-retention.Seqs {
+			return nil
+		}
+		if retention.Age != 0 && now.Sub(info.ModTime()) < retention.Age {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		removed += 1
+		dirs[filepath.Dir(p)] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return removed, err
+	}
+	// remove empty BBB and AAA directories, os.Remove fails for
+	// non-empty directories
+	for d := range dirs {
+		if os.Remove(d) == nil {
+			os.Remove(filepath.Dir(d))
+		}
+	}
+	return removed, nil
+}
+
+var cleanupFlags = flag.NewFlagSet("replication cleanup", flag.ExitOnError)
+
+func cleanupUsage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s replication cleanup:\n\n", os.Args[0])
+	cleanupFlags.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "\nRemove imported diffs from the replication directory.")
+	os.Exit(2)
+}
+
+// CleanupCmd implements the replication cleanup command.
+func CleanupCmd(args []string) {
+	diffDir := cleanupFlags.String("diffdir", "", "diff directory with last.state.txt")
+	dir := cleanupFlags.String("dir", "", "replication directory (defaults to -diffdir)")
+	var retention Retention
+	cleanupFlags.IntVar(&retention.Seqs, "keep-seqs", 0, "keep diffs of the last n sequences")
+	cleanupFlags.DurationVar(&retention.Age, "keep-age", 0, "keep diffs downloaded within this duration (e.g. 72h)")
+	cleanupFlags.Usage = cleanupUsage
+	cleanupFlags.Parse(args)
+
+	if *diffDir == "" || retention.IsZero() {
+		cleanupUsage()
+	}
+	if *dir == "" {
+		*dir = *diffDir
+	}
+
+	s, err := state.ParseLastState(*diffDir)
+	if err != nil {
+		log.Fatal("unable to read last.state.txt: ", err)
+	}
+	removed, err := Cleanup(*dir, s.Sequence, retention)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("removed %d files from %s", removed, *dir)
+}
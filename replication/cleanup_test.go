@@ -0,0 +1,87 @@
+package replication
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSeqFromPath(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		seq  int
+		ok   bool
+	}{
+		{"000/003/069.osc.gz", 3069, true},
+		{"123/456/789.state.txt", 123456789, true},
+		{"000/003/069.osc.gz~1234", 0, false},
+		{"last.state.txt", 0, false},
+		{"minute/000/003/069.osc.gz", 0, false},
+		{"000/03/069.osc.gz", 0, false},
+	} {
+		seq, ok := seqFromPath(tc.path)
+		if seq != tc.seq || ok != tc.ok {
+			t.Errorf("unexpected result for %s: %d %v", tc.path, seq, ok)
+		}
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for seq := 995; seq <= 1010; seq++ {
+		writeDiff(t, dir, seq, `2016-01-01T00\:00\:00Z`)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "last.state.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exists := func(seq int) bool {
+		_, err := os.Stat(filepath.Join(dir, seqPath(seq)+".osc.gz"))
+		return err == nil
+	}
+
+	// files are too new
+	removed, err := Cleanup(dir, 1005, Retention{Seqs: 2, Age: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 0 {
+		t.Error("removed new files", removed)
+	}
+
+	removed, err = Cleanup(dir, 1005, Retention{Seqs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 995-1003 with diff and state file
+	if removed != 18 {
+		t.Error("unexpected number of removed files", removed)
+	}
+	for seq := 995; seq <= 1010; seq++ {
+		if exists(seq) != (seq >= 1004) {
+			t.Error("unexpected state for", seq)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "000/000")); !os.IsNotExist(err) {
+		t.Error("empty directory not removed", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "last.state.txt")); err != nil {
+		t.Error(err)
+	}
+
+	// never remove diffs that are not imported
+	removed, err = Cleanup(dir, 1003, Retention{Age: time.Nanosecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 0 {
+		t.Error("removed diffs that are not imported", removed)
+	}
+}
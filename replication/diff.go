@@ -14,6 +14,8 @@ func NewDiffDownloader(dest, url string, seq int, interval time.Duration) *downl
 	dl.fileExt = ".osc.gz"
 	dl.stateExt = ".state.txt"
 	dl.stateTime = parseTxtTime
+	dl.validateFile = validateGzipXml("osmChange")
+	dl.validateState = validateTxtState
 	go dl.fetchNextLoop()
 	return dl
 }
@@ -42,8 +44,12 @@ func parseTxtTime(filename string) (time.Time, error) {
 	return ds.Time, nil
 }
 
-func NewDiffReader(dest string, seq int) *reader {
-	r := newReader(dest, seq)
+// NewDiffReader returns a Source for all diffs after seq from a local
+// replication directory (AAA/BBB/CCC.osc.gz). It waits for new diffs if
+// follow is true, otherwise the Sequences channel is closed as soon as
+// the next diff is missing.
+func NewDiffReader(dest string, seq int, follow bool) *reader {
+	r := newReader(dest, seq, follow)
 	r.fileExt = ".osc.gz"
 	r.stateExt = ".state.txt"
 	r.stateTime = parseTxtTime
@@ -0,0 +1,239 @@
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/omniscale/imposm3/update/state"
+)
+
+// Feed is a replication feed (e.g. the hourly diffs from planet.osm.org).
+type Feed struct {
+	Url      string
+	Interval time.Duration
+}
+
+// name returns the last element of the URL (e.g. minute, hour or day).
+// Diffs of each feed are stored in a sub-directory with this name.
+func (f Feed) name() string {
+	return path.Base(strings.TrimSuffix(f.Url, "/"))
+}
+
+var _ Source = &feedsDownloader{}
+
+// feedsDownloader downloads diffs from a set of related feeds with
+// different granularity (e.g. day, hour and minute). It catches up with
+// the coarsest feed that has diffs after the last imported diff and
+// switches to the next finer feed as soon as there are no more diffs
+// available. It stays at the finest feed.
+type feedsDownloader struct {
+	dest      string
+	feeds     []Feed
+	lastTime  time.Time
+	lastUrl   string
+	lastSeq   int
+	sequences chan Sequence
+}
+
+// NewFeedsDiffDownloader returns a Source for diffs from feeds, starting
+// after the last imported diff. feeds need to be ordered from coarsest
+// to finest. Diffs are stored in a sub-directory of dest for each feed.
+// The Url of each Sequence is the URL of the feed.
+func NewFeedsDiffDownloader(dest string, feeds []Feed, last *state.DiffState) *feedsDownloader {
+	fd := &feedsDownloader{
+		dest:      dest,
+		feeds:     feeds,
+		lastTime:  last.Time,
+		lastUrl:   last.Url,
+		lastSeq:   last.Sequence,
+		sequences: make(chan Sequence, 1),
+	}
+	go fd.fetchNextLoop()
+	return fd
+}
+
+func (fd *feedsDownloader) Sequences() <-chan Sequence {
+	return fd.sequences
+}
+
+func (fd *feedsDownloader) newDownloader(feed Feed, seq int) *downloader {
+	dl := newDownloader(FeedDir(fd.dest, feed.Url), feed.Url, seq, feed.Interval)
+	dl.fileExt = ".osc.gz"
+	dl.stateExt = ".state.txt"
+	dl.stateTime = parseTxtTime
+	dl.validateFile = validateGzipXml("osmChange")
+	dl.validateState = validateTxtState
+	return dl
+}
+
+// startSequence returns the sequence of the last diff in feed before or at
+// lastTime. It returns the last sequence directly, if it is from feed.
+func (fd *feedsDownloader) startSequence(dl *downloader, feed Feed) (int, error) {
+	if feed.Url == fd.lastUrl && fd.lastSeq != 0 {
+		return fd.lastSeq, nil
+	}
+	current, err := dl.fetchCurrentState()
+	if err != nil {
+		return 0, err
+	}
+	return sequenceBefore(current, dl.fetchState, feed.Interval, fd.lastTime)
+}
+
+// selectFeed returns the index of the coarsest feed that is at least one
+// interval ahead of lastTime, starting with feed idx.
+func (fd *feedsDownloader) selectFeed(idx int) int {
+	for ; idx < len(fd.feeds)-1; idx++ {
+		dl := fd.newDownloader(fd.feeds[idx], 0)
+		current, err := dl.fetchCurrentState()
+		if err != nil {
+			log.Warn("unable to fetch current state of ", fd.feeds[idx].Url, ": ", err)
+			continue
+		}
+		if current.Time.Sub(fd.lastTime) >= fd.feeds[idx].Interval {
+			return idx
+		}
+	}
+	return len(fd.feeds) - 1
+}
+
+func (fd *feedsDownloader) fetchNextLoop() {
+	idx := 0
+	for {
+		idx = fd.selectFeed(idx)
+		feed := fd.feeds[idx]
+		dl := fd.newDownloader(feed, 0)
+
+		var seq int
+		for {
+			var err error
+			seq, err = fd.startSequence(dl, feed)
+			if err == nil {
+				break
+			}
+			log.Warn("unable to find sequence for ", fd.lastTime, " in ", feed.Url, ": ", err)
+			time.Sleep(dl.errWaittime)
+		}
+		if feed.Url != fd.lastUrl {
+			log.Printf("switching to %s at #%d", feed.Url, seq)
+		}
+		fd.lastUrl = feed.Url
+		fd.lastSeq = seq
+		dl.lastSequence = seq
+
+		if idx == len(fd.feeds)-1 {
+			// stay with the finest feed
+			go dl.fetchNextLoop()
+			for s := range dl.sequences {
+				fd.sequences <- s
+			}
+			return
+		}
+
+		// download from coarse feed till no more diffs are available
+		for {
+			nextSeq := fd.lastSeq + 1
+			err := dl.download(nextSeq, dl.stateExt)
+			if err == NotAvailable {
+				break
+			}
+			if err != nil {
+				log.Warn(err)
+				time.Sleep(dl.errWaittime)
+				continue
+			}
+			dl.downloadTillSuccess(nextSeq, dl.fileExt)
+			base := path.Join(dl.dest, seqPath(nextSeq))
+			lastTime, err := dl.stateTime(base + dl.stateExt)
+			if err != nil {
+				log.Warn(err)
+				time.Sleep(dl.errWaittime)
+				continue
+			}
+			fd.lastSeq = nextSeq
+			fd.lastTime = lastTime
+			fd.sequences <- Sequence{
+				Sequence:      nextSeq,
+				Filename:      base + dl.fileExt,
+				StateFilename: base + dl.stateExt,
+				Time:          lastTime,
+				Url:           feed.Url,
+			}
+		}
+		idx += 1
+	}
+}
+
+// fetchCurrentState returns the state of the latest diff.
+func (d *downloader) fetchCurrentState() (*state.DiffState, error) {
+	return d.fetchStateUrl(d.baseUrl + "state.txt")
+}
+
+// fetchState returns the state of seq.
+func (d *downloader) fetchState(seq int) (*state.DiffState, error) {
+	return d.fetchStateUrl(d.baseUrl + seqPath(seq) + ".state.txt")
+}
+
+func (d *downloader) fetchStateUrl(url string) (*state.DiffState, error) {
+	resp, err := d.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil, NotAvailable
+	}
+	if resp.StatusCode != 200 {
+		return nil, errors.New(fmt.Sprintf("invalid repsonse: %v", resp))
+	}
+	return state.Parse(resp.Body)
+}
+
+// sequenceBefore returns the last sequence with a timestamp before or at t.
+// current is the latest state and fetchState returns the state of a
+// sequence.
+func sequenceBefore(current *state.DiffState, fetchState func(int) (*state.DiffState, error), interval time.Duration, t time.Time) (int, error) {
+	if !current.Time.After(t) {
+		return current.Sequence, nil
+	}
+
+	// find lower bound, starting with an estimate from the interval
+	hi := current.Sequence
+	step := int(current.Time.Sub(t)/interval) + 1
+	var lo int
+	for {
+		lo = hi - step
+		if lo < 0 {
+			lo = 0
+		}
+		s, err := fetchState(lo)
+		if err != nil {
+			return 0, err
+		}
+		if !s.Time.After(t) {
+			break
+		}
+		if lo == 0 {
+			return 0, fmt.Errorf("no sequence before %s", t)
+		}
+		hi = lo
+		step *= 2
+	}
+
+	// binary search with time(lo) <= t < time(hi)
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		s, err := fetchState(mid)
+		if err != nil {
+			return 0, err
+		}
+		if s.Time.After(t) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return lo, nil
+}
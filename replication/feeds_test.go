@@ -0,0 +1,134 @@
+package replication
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/update/state"
+)
+
+var feedsBaseTime = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestSequenceBefore(t *testing.T) {
+	fetches := 0
+	fetch := func(seq int) (*state.DiffState, error) {
+		fetches += 1
+		// irregular intervals to test the binary search
+		return &state.DiffState{Sequence: seq, Time: feedsBaseTime.Add(time.Duration(seq*seq) * time.Second)}, nil
+	}
+	current, _ := fetch(10000)
+
+	for _, tc := range []struct {
+		t   time.Time
+		seq int
+	}{
+		{feedsBaseTime.Add(100 * 100 * time.Second), 100},
+		{feedsBaseTime.Add(5000*5000*time.Second - 1), 4999},
+		{feedsBaseTime.Add(5000 * 5000 * time.Second), 5000},
+		{feedsBaseTime.Add(9999*9999*time.Second + 1), 9999},
+		{feedsBaseTime.Add(20000 * 20000 * time.Second), 10000},
+	} {
+		fetches = 0
+		seq, err := sequenceBefore(current, fetch, time.Minute, tc.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != tc.seq {
+			t.Errorf("unexpected sequence for %s: %d != %d", tc.t, seq, tc.seq)
+		}
+		if fetches > 40 {
+			t.Errorf("too many fetches for %s: %d", tc.t, fetches)
+		}
+	}
+
+	if _, err := sequenceBefore(current, fetch, time.Minute, feedsBaseTime.Add(-time.Second)); err == nil {
+		t.Error("expected error for time before first sequence")
+	}
+}
+
+// feedHandler serves states and diffs for a feed with seqs sequences.
+func feedHandler(interval time.Duration, seqs int) http.HandlerFunc {
+	stateTxt := func(seq int) string {
+		return fmt.Sprintf("sequenceNumber=%d\ntimestamp=%s\n", seq,
+			feedsBaseTime.Add(time.Duration(seq)*interval).Format(`2006-01-02T15\:04\:05Z`))
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if name == "state.txt" {
+			w.Write([]byte(stateTxt(seqs)))
+			return
+		}
+		parts := strings.SplitN(name, ".", 2)
+		c, err := strconv.Atoi(parts[0])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		seq := c
+		if p := strings.Split(r.URL.Path, "/"); len(p) >= 3 {
+			b, _ := strconv.Atoi(p[len(p)-2])
+			a, _ := strconv.Atoi(p[len(p)-3])
+			seq = a*1000000 + b*1000 + c
+		}
+		if seq > seqs {
+			http.NotFound(w, r)
+			return
+		}
+		if parts[1] == "state.txt" {
+			w.Write([]byte(stateTxt(seq)))
+		} else {
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte(`<osmChange version="0.6"></osmChange>`))
+			gz.Close()
+		}
+	}
+}
+
+func TestFeedsDownloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mux := http.NewServeMux()
+	mux.Handle("/day/", feedHandler(24*time.Hour, 3))
+	mux.Handle("/hour/", feedHandler(time.Hour, 80))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	feeds := []Feed{
+		{Url: ts.URL + "/day/", Interval: 24 * time.Hour},
+		{Url: ts.URL + "/hour/", Interval: time.Hour},
+	}
+	last := &state.DiffState{Time: feedsBaseTime.Add(24*time.Hour + 30*time.Minute)}
+	fd := NewFeedsDiffDownloader(dir, feeds, last)
+
+	expected := []Sequence{
+		{Sequence: 2, Url: feeds[0].Url},
+		{Sequence: 3, Url: feeds[0].Url},
+		{Sequence: 73, Url: feeds[1].Url},
+		{Sequence: 74, Url: feeds[1].Url},
+	}
+	for _, exp := range expected {
+		select {
+		case seq := <-fd.Sequences():
+			if seq.Sequence != exp.Sequence || seq.Url != exp.Url {
+				t.Fatalf("unexpected sequence %v, expected %v", seq, exp)
+			}
+			if _, err := os.Stat(seq.Filename); err != nil {
+				t.Error(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package replication
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/update/state"
+)
+
+// StateBefore returns the state of the last diff from the replication
+// url with a timestamp before or at t. The sequence is searched with the
+// state files of the replication source, so it works for any interval.
+func StateBefore(url string, t time.Time) (*state.DiffState, error) {
+	if !strings.HasSuffix(url, "/") {
+		url += "/"
+	}
+	dl := newDownloader("", url, 0, time.Minute)
+	current, err := dl.fetchCurrentState()
+	if err != nil {
+		return nil, err
+	}
+
+	// estimate the interval from the last two diffs
+	interval := time.Minute
+	if current.Sequence > 0 {
+		if prev, err := dl.fetchState(current.Sequence - 1); err == nil {
+			if d := current.Time.Sub(prev.Time); d > 0 {
+				interval = d
+			}
+		}
+	}
+
+	seq, err := sequenceBefore(current, dl.fetchState, interval, t)
+	if err != nil {
+		return nil, err
+	}
+	s := current
+	if seq != current.Sequence {
+		s, err = dl.fetchState(seq)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.Url = url
+	return s, nil
+}
+
+var stateFlags = flag.NewFlagSet("state", flag.ExitOnError)
+
+func stateUsage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s state:\n\n", os.Args[0])
+	stateFlags.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "\nPrint the state of the last diff before -time (in last.state.txt format).")
+	os.Exit(2)
+}
+
+// StateCmd implements the state command.
+func StateCmd(args []string) {
+	var t time.Time
+	url := stateFlags.String("url", "http://planet.openstreetmap.org/replication/minute/", "replication url")
+	stateFlags.Var(config.TimeFlag{Time: &t}, "time", "timestamp (UTC)")
+	stateFlags.Usage = stateUsage
+	stateFlags.Parse(args)
+
+	if t.IsZero() {
+		stateUsage()
+	}
+	s, err := StateBefore(*url, t)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := s.Write(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
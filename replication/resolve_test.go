@@ -0,0 +1,34 @@
+package replication
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStateBefore(t *testing.T) {
+	ts := httptest.NewServer(feedHandler(time.Hour, 5000))
+	defer ts.Close()
+
+	s, err := StateBefore(ts.URL+"/hour", feedsBaseTime.Add(1234*time.Hour+59*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Sequence != 1234 {
+		t.Error("unexpected sequence", s)
+	}
+	if !s.Time.Equal(feedsBaseTime.Add(1234 * time.Hour)) {
+		t.Error("unexpected time", s)
+	}
+	if s.Url != ts.URL+"/hour/" {
+		t.Error("unexpected url", s)
+	}
+
+	s, err = StateBefore(ts.URL+"/hour/", feedsBaseTime.Add(6000*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Sequence != 5000 {
+		t.Error("unexpected sequence", s)
+	}
+}
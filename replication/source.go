@@ -3,7 +3,6 @@ package replication
 import (
 	"errors"
 	"fmt"
-	"gopkg.in/fsnotify.v1"
 	"io"
 	"net"
 	"net/http"
@@ -24,6 +23,8 @@ type Sequence struct {
 	StateFilename string
 	Time          time.Time
 	Sequence      int
+	// Url of the replication feed, empty for local diffs.
+	Url string
 }
 
 type Source interface {
@@ -48,11 +49,14 @@ type downloader struct {
 	stateExt     string
 	lastSequence int
 	stateTime    func(string) (time.Time, error)
-	interval     time.Duration
-	errWaittime  time.Duration
-	naWaittime   time.Duration
-	sequences    chan Sequence
-	client       *http.Client
+	// validateFile and validateState check downloaded files, can be nil
+	validateFile  func(filename string) error
+	validateState func(filename string, seq int) error
+	interval      time.Duration
+	errWaittime   time.Duration
+	naWaittime    time.Duration
+	sequences     chan Sequence
+	client        *http.Client
 }
 
 func newDownloader(dest, url string, seq int, interval time.Duration) *downloader {
@@ -87,12 +91,31 @@ func (d *downloader) Sequences() <-chan Sequence {
 	return d.sequences
 }
 
+// validate checks the downloaded file for seq.
+func (d *downloader) validate(filename string, seq int, ext string) error {
+	if ext == d.stateExt {
+		if d.validateState != nil {
+			return d.validateState(filename, seq)
+		}
+	} else if d.validateFile != nil {
+		return d.validateFile(filename)
+	}
+	return nil
+}
+
 func (d *downloader) download(seq int, ext string) error {
 	dest := path.Join(d.dest, seqPath(seq)+ext)
 	url := d.baseUrl + seqPath(seq) + ext
 
 	if _, err := os.Stat(dest); err == nil {
-		return nil
+		err := d.validate(dest, seq, ext)
+		if err == nil {
+			return nil
+		}
+		log.Warn(err, ", downloading again")
+		if err := os.Remove(dest); err != nil {
+			return err
+		}
 	}
 
 	if err := os.MkdirAll(path.Dir(dest), 0755); err != nil {
@@ -105,6 +128,8 @@ func (d *downloader) download(seq int, ext string) error {
 		return err
 	}
 	defer out.Close()
+	// remove incomplete or invalid downloads, fails after rename
+	defer os.Remove(tmpDest)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -132,6 +157,10 @@ func (d *downloader) download(seq int, ext string) error {
 	}
 	out.Close()
 
+	if err := d.validate(tmpDest, seq, ext); err != nil {
+		return err
+	}
+
 	err = os.Rename(tmpDest, dest)
 	if err != nil {
 		return err
@@ -181,6 +210,7 @@ func (d *downloader) fetchNextLoop() {
 			Filename:      base + d.fileExt,
 			StateFilename: base + d.stateExt,
 			Time:          lastTime,
+			Url:           d.baseUrl,
 		}
 	}
 }
@@ -193,13 +223,19 @@ type reader struct {
 	stateExt     string
 	lastSequence int
 	stateTime    func(string) (time.Time, error)
+	// follow waits for new files. Otherwise, the sequences channel is
+	// closed as soon as the next file is missing.
+	follow       bool
+	pollWaittime time.Duration
 	sequences    chan Sequence
 }
 
-func newReader(dest string, seq int) *reader {
+func newReader(dest string, seq int, follow bool) *reader {
 	r := &reader{
 		dest:         dest,
 		lastSequence: seq,
+		follow:       follow,
+		pollWaittime: 2 * time.Second,
 		sequences:    make(chan Sequence, 1),
 	}
 
@@ -210,40 +246,31 @@ func (d *reader) Sequences() <-chan Sequence {
 	return d.sequences
 }
 
-func (d *reader) waitTillPresent(seq int, ext string) error {
+func (d *reader) isPresent(seq int, ext string) bool {
 	filename := path.Join(d.dest, seqPath(seq)+ext)
-	if _, err := os.Stat(filename); err == nil {
-		return nil
-	}
-
-	w, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-	defer w.Close()
-	w.Add(filename)
-
-	// check again, in case file was created before we added the file
-	if _, err := os.Stat(filename); err == nil {
-		return nil
-	}
+	_, err := os.Stat(filename)
+	return err == nil
+}
 
-	for {
-		select {
-		case evt := <-w.Events:
-			if evt.Op == fsnotify.Create {
-				return nil
-			}
-		}
+// waitTillPresent waits till the file for seq is present. The directory
+// (e.g. AAA/BBB) does not need to exist, so we poll instead of watching
+// for changes.
+func (d *reader) waitTillPresent(seq int, ext string) {
+	for !d.isPresent(seq, ext) {
+		time.Sleep(d.pollWaittime)
 	}
-	return nil
 }
 
 func (d *reader) fetchNextLoop() {
 	for {
 		nextSeq := d.lastSequence + 1
-		d.waitTillPresent(nextSeq, d.stateExt)
-		d.waitTillPresent(nextSeq, d.fileExt)
+		if d.follow {
+			d.waitTillPresent(nextSeq, d.stateExt)
+			d.waitTillPresent(nextSeq, d.fileExt)
+		} else if !d.isPresent(nextSeq, d.stateExt) || !d.isPresent(nextSeq, d.fileExt) {
+			close(d.sequences)
+			return
+		}
 		d.lastSequence = nextSeq
 		base := path.Join(d.dest, seqPath(d.lastSequence))
 		lastTime, _ := d.stateTime(base + d.stateExt)
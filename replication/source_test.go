@@ -1,6 +1,13 @@
 package replication
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
 
 func TestSeqPath(t *testing.T) {
 	if path := seqPath(0); path != "000/000/000" {
@@ -13,3 +20,81 @@ func TestSeqPath(t *testing.T) {
 		t.Fatal(path)
 	}
 }
+
+func writeDiff(t *testing.T, dir string, seq int, timestamp string) {
+	base := filepath.Join(dir, seqPath(seq))
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		t.Fatal(err)
+	}
+	state := "sequenceNumber=" + strconv.Itoa(seq) + "\ntimestamp=" + timestamp + "\n"
+	if err := ioutil.WriteFile(base+".state.txt", []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(base+".osc.gz", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeDiff(t, dir, 1, `2016-01-01T00\:00\:00Z`)
+	writeDiff(t, dir, 2, `2016-01-01T00\:01\:00Z`)
+	writeDiff(t, dir, 3, `2016-01-01T00\:02\:00Z`)
+	// state file without diff
+	writeDiff(t, dir, 5, `2016-01-01T00\:04\:00Z`)
+
+	r := NewDiffReader(dir, 1, false)
+	var seqs []Sequence
+	for seq := range r.Sequences() {
+		seqs = append(seqs, seq)
+	}
+	if len(seqs) != 2 {
+		t.Fatal(seqs)
+	}
+	if seqs[0].Sequence != 2 || seqs[1].Sequence != 3 {
+		t.Error(seqs)
+	}
+	if seqs[1].Filename != filepath.Join(dir, "000/000/003.osc.gz") {
+		t.Error(seqs[1].Filename)
+	}
+	if !seqs[1].Time.Equal(time.Date(2016, 1, 1, 0, 2, 0, 0, time.UTC)) {
+		t.Error(seqs[1].Time)
+	}
+}
+
+func TestDiffReaderFollow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := newReader(dir, 41, true)
+	r.fileExt = ".osc.gz"
+	r.stateExt = ".state.txt"
+	r.stateTime = parseTxtTime
+	r.pollWaittime = 10 * time.Millisecond
+	go r.fetchNextLoop()
+
+	select {
+	case seq := <-r.Sequences():
+		t.Fatal("unexpected sequence", seq)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	writeDiff(t, dir, 42, `2016-01-01T00\:00\:00Z`)
+
+	select {
+	case seq := <-r.Sequences():
+		if seq.Sequence != 42 {
+			t.Error(seq)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("diff not found")
+	}
+}
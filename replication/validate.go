@@ -0,0 +1,85 @@
+package replication
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/omniscale/imposm3/update/state"
+)
+
+// InvalidDownload is returned for incomplete or invalid files (e.g. HTML
+// error pages).
+type InvalidDownload struct {
+	Filename string
+	Reason   string
+}
+
+func (e *InvalidDownload) Error() string {
+	return fmt.Sprintf("invalid download %s: %s", e.Filename, e.Reason)
+}
+
+// validateGzipXml returns a function that checks that a file is a complete
+// gzip file with an XML document with the root element.
+func validateGzipXml(root string) func(filename string) error {
+	return func(filename string) error {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			return &InvalidDownload{filename, err.Error()}
+		}
+		decoder := xml.NewDecoder(r)
+		for {
+			tok, err := decoder.Token()
+			if err != nil {
+				return &InvalidDownload{filename, "no XML root element: " + err.Error()}
+			}
+			if start, ok := tok.(xml.StartElement); ok {
+				if start.Name.Local != root {
+					return &InvalidDownload{filename, fmt.Sprintf("XML root element is %s, expected %s", start.Name.Local, root)}
+				}
+				break
+			}
+		}
+		// read till the end to check the gzip checksum
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
+			return &InvalidDownload{filename, err.Error()}
+		}
+		return nil
+	}
+}
+
+// validateTxtState checks that the state file is for seq.
+func validateTxtState(filename string, seq int) error {
+	s, err := state.ParseFile(filename)
+	if err != nil {
+		return &InvalidDownload{filename, err.Error()}
+	}
+	if s.Sequence != seq {
+		return &InvalidDownload{filename, fmt.Sprintf("sequence %d, expected %d", s.Sequence, seq)}
+	}
+	return nil
+}
+
+// validateYamlState checks that the changeset state file is for seq.
+func validateYamlState(filename string, seq int) error {
+	s, err := parseYamlStateFile(filename)
+	if err != nil {
+		return &InvalidDownload{filename, err.Error()}
+	}
+	if s.Sequence != seq {
+		return &InvalidDownload{filename, fmt.Sprintf("sequence %d, expected %d", s.Sequence, seq)}
+	}
+	if s.Time.IsZero() {
+		return &InvalidDownload{filename, "missing timestamp"}
+	}
+	return nil
+}
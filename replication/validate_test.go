@@ -0,0 +1,83 @@
+package replication
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeGzip(t *testing.T, filename string, content string, truncate bool) {
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := gzip.NewWriter(f)
+	w.Write([]byte(content))
+	if truncate {
+		w.Flush()
+		return
+	}
+	w.Close()
+}
+
+func TestValidateGzipXml(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	validate := validateGzipXml("osmChange")
+	fname := filepath.Join(dir, "001.osc.gz")
+
+	writeGzip(t, fname, `<?xml version="1.0"?><osmChange version="0.6"><create/></osmChange>`, false)
+	if err := validate(fname); err != nil {
+		t.Error(err)
+	}
+
+	writeGzip(t, fname, `<?xml version="1.0"?><osmChange version="0.6"><create/></osmChange>`, true)
+	if err := validate(fname); err == nil {
+		t.Error("truncated file not detected")
+	}
+
+	writeGzip(t, fname, `<html><body>Error</body></html>`, false)
+	if err := validate(fname); err == nil {
+		t.Error("HTML not detected")
+	}
+
+	if err := ioutil.WriteFile(fname, []byte(`<osmChange version="0.6"></osmChange>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := validate(fname); err == nil {
+		t.Error("uncompressed file not detected")
+	}
+}
+
+func TestValidateTxtState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "001.state.txt")
+	if err := ioutil.WriteFile(fname, []byte("sequenceNumber=1\ntimestamp=2016-01-01T00\\:00\\:00Z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateTxtState(fname, 1); err != nil {
+		t.Error(err)
+	}
+	if err := validateTxtState(fname, 2); err == nil {
+		t.Error("wrong sequence not detected")
+	}
+
+	if err := ioutil.WriteFile(fname, []byte("<html></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateTxtState(fname, 1); err == nil {
+		t.Error("invalid state not detected")
+	}
+}
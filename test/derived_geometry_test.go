@@ -0,0 +1,114 @@
+package test
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestDerivedGeometry_Prepare(t *testing.T) {
+	var err error
+
+	ts.dir, err = ioutil.TempDir("", "imposm3test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.config = importConfig{
+		connection:      "postgis://",
+		cacheDir:        ts.dir,
+		osmFileName:     "build/derived_geometry.pbf",
+		mappingFileName: "derived_geometry_mapping.yml",
+	}
+	ts.g = geos.NewGeos()
+
+	ts.db, err = sql.Open("postgres", "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.dropSchemas()
+}
+
+func TestDerivedGeometry_Import(t *testing.T) {
+	if ts.tableExists(t, dbschemaImport, "osm_buildings") != false {
+		t.Fatalf("table osm_buildings exists in schema %s", dbschemaImport)
+	}
+	ts.importOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_buildings") != true {
+		t.Fatalf("table osm_buildings does not exists in schema %s", dbschemaImport)
+	}
+}
+
+func TestDerivedGeometry_Deploy(t *testing.T) {
+	ts.deployOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_buildings") != false {
+		t.Fatalf("table osm_buildings exists in schema %s", dbschemaImport)
+	}
+	if ts.tableExists(t, dbschemaProduction, "osm_buildings") != true {
+		t.Fatalf("table osm_buildings does not exists in schema %s", dbschemaProduction)
+	}
+}
+
+// queryBool returns the result of a boolean SQL expression for id.
+func queryBool(t *testing.T, table string, id int64, expr string) bool {
+	stmt := fmt.Sprintf(`SELECT %s FROM "%s"."%s" WHERE osm_id=$1`, expr, dbschemaProduction, table)
+	var result sql.NullBool
+	if err := ts.db.QueryRow(stmt, id).Scan(&result); err != nil {
+		t.Fatal(err)
+	}
+	return result.Bool
+}
+
+func assertDerivedBuilding(t *testing.T) {
+	for _, expr := range []string{
+		"NOT ST_Contains(geometry, centroid)",
+		"ST_Contains(geometry, point_on_surface)",
+		"ST_Contains(geometry, label_point)",
+		"ST_Equals(bbox, ST_Envelope(geometry))",
+		"ST_Equals(ST_GeomFromText(geom_wkt, ST_SRID(geometry)), geometry)",
+		"ST_SRID(label_point) = ST_SRID(geometry)",
+	} {
+		if !queryBool(t, "osm_buildings", 330201, expr) {
+			t.Errorf("%s is false for 330201", expr)
+		}
+	}
+}
+
+func TestDerivedGeometry_Columns(t *testing.T) {
+	assertDerivedBuilding(t)
+	// label point in the bottom part of the building
+	if !queryBool(t, "osm_buildings", 330201, "ST_Y(ST_Transform(label_point, 4326)) < 53.005") {
+		t.Error("unexpected label point for 330201")
+	}
+
+	for _, expr := range []string{
+		"ST_Equals(label_point, geometry)",
+		"ST_DWithin(ST_SetSRID(ST_GeomFromGeoJSON(geojson), 4326), ST_Transform(geometry, 4326), 1e-9)",
+	} {
+		if !queryBool(t, "osm_pois", 330111, expr) {
+			t.Errorf("%s is false for 330111", expr)
+		}
+	}
+}
+
+func TestDerivedGeometry_Update(t *testing.T) {
+	ts.updateOsm(t, "./build/derived_geometry.osc.gz")
+}
+
+func TestDerivedGeometry_ColumnsUpdated(t *testing.T) {
+	assertDerivedBuilding(t)
+	// label point moved to the extended right leg
+	if !queryBool(t, "osm_buildings", 330201, "ST_X(ST_Transform(label_point, 4326)) > 9.02") {
+		t.Error("unexpected label point for 330201")
+	}
+}
+
+func TestDerivedGeometry_Cleanup(t *testing.T) {
+	ts.dropSchemas()
+	if err := os.RemoveAll(ts.dir); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestGeometryTransform_Prepare(t *testing.T) {
+	var err error
+
+	ts.dir, err = ioutil.TempDir("", "imposm3test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.config = importConfig{
+		connection:      "postgis://",
+		cacheDir:        ts.dir,
+		osmFileName:     "build/geometry_transform.pbf",
+		mappingFileName: "geometry_transform_mapping.yml",
+	}
+	ts.g = geos.NewGeos()
+
+	ts.db, err = sql.Open("postgres", "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.dropSchemas()
+}
+
+func TestGeometryTransform_Import(t *testing.T) {
+	if ts.tableExists(t, dbschemaImport, "osm_shops") != false {
+		t.Fatalf("table osm_shops exists in schema %s", dbschemaImport)
+	}
+	ts.importOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_shops") != true {
+		t.Fatalf("table osm_shops does not exists in schema %s", dbschemaImport)
+	}
+}
+
+func TestGeometryTransform_Deploy(t *testing.T) {
+	ts.deployOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_shops") != false {
+		t.Fatalf("table osm_shops exists in schema %s", dbschemaImport)
+	}
+	if ts.tableExists(t, dbschemaProduction, "osm_shops") != true {
+		t.Fatalf("table osm_shops does not exists in schema %s", dbschemaProduction)
+	}
+}
+
+func TestGeometryTransform_Columns(t *testing.T) {
+	for _, tc := range []struct {
+		table string
+		id    int64
+		expr  string
+	}{
+		// node, closed way and multipolygon as points
+		{"osm_shops", 340101, "ST_GeometryType(geometry) = 'ST_Point' AND shop = 'bakery'"},
+		{"osm_shops", -340201, "ST_GeometryType(geometry) = 'ST_Point' AND shop = 'supermarket'"},
+		{"osm_shops", -100000000000340301, "ST_GeometryType(geometry) = 'ST_Point' AND shop = 'mall'"},
+		// building split into two parts
+		{"osm_buildings", -340203, "count(*) = 2 AND bool_and(ST_NPoints(geometry) <= 8)"},
+		// park with 0.01° (~1113m) width, buffered by 100m
+		{"osm_parks", -340204, "ST_XMax(geometry) - ST_XMin(geometry) BETWEEN 1300 AND 1320"},
+	} {
+		if !queryBool(t, tc.table, tc.id, tc.expr) {
+			t.Errorf("%s is false for %d in %s", tc.expr, tc.id, tc.table)
+		}
+	}
+}
+
+func TestGeometryTransform_Update(t *testing.T) {
+	ts.updateOsm(t, "./build/geometry_transform.osc.gz")
+}
+
+func TestGeometryTransform_ColumnsUpdated(t *testing.T) {
+	for _, tc := range []struct {
+		table string
+		id    int64
+		expr  string
+	}{
+		{"osm_shops", -340201, "count(*) = 0"},
+		{"osm_shops", 340101, "count(*) = 1"},
+		{"osm_buildings", -340203, "count(*) = 1"},
+	} {
+		if !queryBool(t, tc.table, tc.id, tc.expr) {
+			t.Errorf("%s is false for %d in %s", tc.expr, tc.id, tc.table)
+		}
+	}
+}
+
+func TestGeometryTransform_Cleanup(t *testing.T) {
+	ts.dropSchemas()
+	if err := os.RemoveAll(ts.dir); err != nil {
+		t.Error(err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/omniscale/imposm3/element"
 
 	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/cache/prune"
 
 	"github.com/lib/pq/hstore"
 
@@ -34,6 +35,10 @@ type importConfig struct {
 	cacheDir        string
 	verbose         bool
 	expireTileDir   string
+	// limitTo and limitToCacheBuffer are passed to import, diff and
+	// prune. updateOsm defaults to clipping.geojson.
+	limitTo            string
+	limitToCacheBuffer float64
 }
 
 type importTestSuite struct {
@@ -60,6 +65,8 @@ func (s *importTestSuite) importOsm(t *testing.T) {
 		"-revertdeploy=false",
 		"-deployproduction=false",
 		"-removebackup=false",
+		"-limitto", s.config.limitTo, // reset limitto of previous tests
+		"-limittocachebuffer", fmt.Sprint(s.config.limitToCacheBuffer),
 	}
 
 	config.ParseImport(importArgs)
@@ -147,10 +154,15 @@ func (s *importTestSuite) removeBackupOsm(t *testing.T) {
 }
 
 func (s *importTestSuite) updateOsm(t *testing.T, diffFile string) {
+	limitTo := s.config.limitTo
+	if limitTo == "" {
+		limitTo = "clipping.geojson"
+	}
 	args := []string{
 		"-connection", s.config.connection,
 		"-cachedir", s.config.cacheDir,
-		"-limitto", "clipping.geojson",
+		"-limitto", limitTo,
+		"-limittocachebuffer", fmt.Sprint(s.config.limitToCacheBuffer),
 		"-dbschema-production", dbschemaProduction,
 		"-mapping", s.config.mappingFileName,
 	}
@@ -162,6 +174,18 @@ func (s *importTestSuite) updateOsm(t *testing.T, diffFile string) {
 	update.Diff()
 }
 
+func (s *importTestSuite) pruneOsm(t *testing.T) {
+	args := []string{
+		"-cachedir", s.config.cacheDir,
+		"-limitto", s.config.limitTo,
+		"-limittocachebuffer", fmt.Sprint(s.config.limitToCacheBuffer),
+		"-mapping", s.config.mappingFileName,
+		"-quiet",
+	}
+	config.ParsePrune(args)
+	prune.Prune()
+}
+
 func (s *importTestSuite) dropSchemas() {
 	var err error
 	_, err = s.db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE`, dbschemaImport))
@@ -0,0 +1,157 @@
+package test
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+const multiPolygonErrorsFile = "build/multipolygon_options_errors.geojson"
+
+func TestMultiPolygonOptions_Prepare(t *testing.T) {
+	var err error
+
+	ts.dir, err = ioutil.TempDir("", "imposm3test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.config = importConfig{
+		connection:      "postgis://",
+		cacheDir:        ts.dir,
+		osmFileName:     "build/multipolygon_options.pbf",
+		mappingFileName: "multipolygon_options_mapping.yml",
+	}
+	ts.g = geos.NewGeos()
+
+	ts.db, err = sql.Open("postgres", "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.dropSchemas()
+	if err := os.Remove(multiPolygonErrorsFile); err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func TestMultiPolygonOptions_Import(t *testing.T) {
+	if ts.tableExists(t, dbschemaImport, "osm_buildings") != false {
+		t.Fatalf("table osm_buildings exists in schema %s", dbschemaImport)
+	}
+	ts.importOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_buildings") != true {
+		t.Fatalf("table osm_buildings does not exists in schema %s", dbschemaImport)
+	}
+}
+
+func TestMultiPolygonOptions_Deploy(t *testing.T) {
+	ts.deployOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_buildings") != false {
+		t.Fatalf("table osm_buildings exists in schema %s", dbschemaImport)
+	}
+	if ts.tableExists(t, dbschemaProduction, "osm_buildings") != true {
+		t.Fatalf("table osm_buildings does not exists in schema %s", dbschemaProduction)
+	}
+}
+
+func TestMultiPolygonOptions_Buildings(t *testing.T) {
+	for _, tc := range []struct {
+		id   int64
+		expr string
+	}{
+		// invalid relation is dropped
+		{-100000000000350101, "count(*) = 0"},
+		// old-style relation is ignored, way is inserted
+		{-100000000000350201, "count(*) = 0"},
+		{-350201, "count(*) = 1"},
+		// unclosed ring is skipped
+		{-100000000000350301, "count(*) = 1"},
+		// ring closed with ring_gap
+		{-100000000000350401, "count(*) = 1"},
+	} {
+		if !queryBool(t, "osm_buildings", tc.id, tc.expr) {
+			t.Errorf("%s is false for %d", tc.expr, tc.id)
+		}
+	}
+}
+
+func TestMultiPolygonOptions_Errors(t *testing.T) {
+	issues := readMultiPolygonErrors(t)
+	if len(issues) != 2 {
+		t.Error("expected two issues", issues)
+	}
+	if issues[350101] != "dropped" {
+		t.Error("expected dropped 350101", issues)
+	}
+	if issues[350301] != "skipped" {
+		t.Error("expected skipped 350301", issues)
+	}
+}
+
+func TestMultiPolygonOptions_Update(t *testing.T) {
+	ts.updateOsm(t, "./build/multipolygon_options.osc.gz")
+}
+
+func TestMultiPolygonOptions_BuildingsUpdated(t *testing.T) {
+	if !queryBool(t, "osm_buildings", -100000000000350101, "count(*) = 1") {
+		t.Error("fixed relation 350101 not inserted")
+	}
+	// valid relation is not reported again
+	if issues := readMultiPolygonErrors(t); len(issues) != 2 {
+		t.Error("expected two issues", issues)
+	}
+}
+
+func TestMultiPolygonOptions_Cleanup(t *testing.T) {
+	ts.dropSchemas()
+	if err := os.RemoveAll(ts.dir); err != nil {
+		t.Error(err)
+	}
+	if err := os.Remove(multiPolygonErrorsFile); err != nil {
+		t.Error(err)
+	}
+}
+
+// readMultiPolygonErrors returns the action of each reported relation.
+func readMultiPolygonErrors(t *testing.T) map[int64]string {
+	f, err := os.Open(multiPolygonErrorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	issues := make(map[int64]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var feature struct {
+			Geometry struct {
+				Type        string      `json:"type"`
+				Coordinates [][]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties struct {
+				RelationId int64  `json:"relation_id"`
+				Action     string `json:"action"`
+				Time       string `json:"time"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &feature); err != nil {
+			t.Fatal(err)
+		}
+		if feature.Geometry.Type != "MultiPoint" || len(feature.Geometry.Coordinates) == 0 {
+			t.Error("expected issue locations", scanner.Text())
+		}
+		if _, err := time.Parse(time.RFC3339, feature.Properties.Time); err != nil {
+			t.Error("expected issue time", scanner.Text())
+		}
+		issues[feature.Properties.RelationId] = feature.Properties.Action
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return issues
+}
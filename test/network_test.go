@@ -0,0 +1,137 @@
+package test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestNetwork_Prepare(t *testing.T) {
+	var err error
+
+	ts.dir, err = ioutil.TempDir("", "imposm3test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.config = importConfig{
+		connection:      "postgis://",
+		cacheDir:        ts.dir,
+		osmFileName:     "build/network.pbf",
+		mappingFileName: "network_mapping.yml",
+	}
+	ts.g = geos.NewGeos()
+
+	ts.db, err = sql.Open("postgres", "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.dropSchemas()
+}
+
+func TestNetwork_Import(t *testing.T) {
+	if ts.tableExists(t, dbschemaImport, "osm_network") != false {
+		t.Fatalf("table osm_network exists in schema %s", dbschemaImport)
+	}
+	ts.importOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_network") != true {
+		t.Fatalf("table osm_network does not exists in schema %s", dbschemaImport)
+	}
+}
+
+func TestNetwork_Deploy(t *testing.T) {
+	ts.deployOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_network") != false {
+		t.Fatalf("table osm_network exists in schema %s", dbschemaImport)
+	}
+	if ts.tableExists(t, dbschemaProduction, "osm_network") != true {
+		t.Fatalf("table osm_network does not exists in schema %s", dbschemaProduction)
+	}
+}
+
+// networkEdges returns the source and target of all edges of the way,
+// ordered by source.
+func networkEdges(t *testing.T, id string) [][2]string {
+	rows := ts.queryDynamic(t, "osm_network", "osm_id = "+id+" ORDER BY source")
+	var edges [][2]string
+	for _, row := range rows {
+		edges = append(edges, [2]string{row["source"], row["target"]})
+	}
+	return edges
+}
+
+func assertEdges(t *testing.T, id string, expected [][2]string) {
+	edges := networkEdges(t, id)
+	if len(edges) != len(expected) {
+		t.Fatalf("unexpected edges for %s: %v", id, edges)
+	}
+	for i := range expected {
+		if edges[i] != expected[i] {
+			t.Errorf("unexpected edges for %s: %v", id, edges)
+		}
+	}
+}
+
+func TestNetwork_Edges(t *testing.T) {
+	assertEdges(t, "200501", [][2]string{{"200101", "200102"}, {"200102", "200103"}})
+	assertEdges(t, "200502", [][2]string{{"200102", "200105"}, {"200104", "200102"}})
+	// node shared with a barrier
+	assertEdges(t, "200503", [][2]string{{"200106", "200108"}})
+
+	rows := ts.queryDynamic(t, "osm_network", "osm_id = 200501")
+	for _, row := range rows {
+		if row["oneway"] != "1" || row["type"] != "primary" {
+			t.Error(row)
+		}
+		if l := ts.g.FromWkt(row["wkt"]).Length(); math.Abs(l-111.32448543701321) > 0.00001 {
+			t.Error(l, row)
+		}
+	}
+}
+
+func TestNetwork_Vertices(t *testing.T) {
+	rows := ts.queryDynamic(t, "osm_network_vertices", "true")
+	if len(rows) != 7 {
+		t.Fatal(rows)
+	}
+	rows = ts.queryDynamic(t, "osm_network_vertices", "id = 200102")
+	if len(rows) != 1 || rows[0]["wkt"] == "" {
+		t.Error(rows)
+	}
+}
+
+func TestNetwork_Update(t *testing.T) {
+	ts.updateOsm(t, "./build/network.osc.gz")
+}
+
+func TestNetwork_EdgesUpdated(t *testing.T) {
+	// merged after 200502 was removed
+	assertEdges(t, "200501", [][2]string{{"200101", "200103"}})
+	assertEdges(t, "200502", nil)
+	// split by new way 200505
+	assertEdges(t, "200503", [][2]string{{"200106", "200107"}, {"200107", "200108"}})
+	assertEdges(t, "200505", [][2]string{{"200110", "200107"}})
+
+	rows := ts.queryDynamic(t, "osm_network_vertices", "true")
+	if len(rows) != 6 {
+		t.Error(rows)
+	}
+
+	rows = ts.queryDynamic(t, "osm_network", "osm_id = 200501")
+	if len(rows) != 1 {
+		t.Fatal(rows)
+	}
+	if l := ts.g.FromWkt(rows[0]["wkt"]).Length(); math.Abs(l-2*111.32448543701321) > 0.00001 {
+		t.Error(l, rows[0])
+	}
+}
+
+func TestNetwork_Cleanup(t *testing.T) {
+	ts.dropSchemas()
+	if err := os.RemoveAll(ts.dir); err != nil {
+		t.Error(err)
+	}
+}
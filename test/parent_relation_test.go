@@ -0,0 +1,85 @@
+package test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestParentRelation_Prepare(t *testing.T) {
+	var err error
+
+	ts.dir, err = ioutil.TempDir("", "imposm3test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.config = importConfig{
+		connection:      "postgis://",
+		cacheDir:        ts.dir,
+		osmFileName:     "build/parent_relation.pbf",
+		mappingFileName: "parent_relation_mapping.yml",
+	}
+	ts.g = geos.NewGeos()
+
+	ts.db, err = sql.Open("postgres", "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.dropSchemas()
+}
+
+func TestParentRelation_Import(t *testing.T) {
+	if ts.tableExists(t, dbschemaImport, "osm_roads") != false {
+		t.Fatalf("table osm_roads exists in schema %s", dbschemaImport)
+	}
+	ts.importOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_roads") != true {
+		t.Fatalf("table osm_roads does not exists in schema %s", dbschemaImport)
+	}
+}
+
+func TestParentRelation_Deploy(t *testing.T) {
+	ts.deployOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_roads") != false {
+		t.Fatalf("table osm_roads exists in schema %s", dbschemaImport)
+	}
+	if ts.tableExists(t, dbschemaProduction, "osm_roads") != true {
+		t.Fatalf("table osm_roads does not exists in schema %s", dbschemaProduction)
+	}
+}
+
+func assertParentRelationTags(t *testing.T, id string, refs, network string) {
+	rows := ts.queryDynamic(t, "osm_roads", "osm_id = "+id)
+	if len(rows) != 1 {
+		t.Fatalf("unexpected roads for %s: %v", id, rows)
+	}
+	if rows[0]["bus_refs"] != refs || rows[0]["bus_network"] != network {
+		t.Errorf("unexpected parent relation tags for %s: %v", id, rows[0])
+	}
+}
+
+func TestParentRelation_Tags(t *testing.T) {
+	assertParentRelationTags(t, "320201", "5", "VBN")
+	assertParentRelationTags(t, "320202", "5;12", "VBN")
+	assertParentRelationTags(t, "320203", "", "")
+}
+
+func TestParentRelation_Update(t *testing.T) {
+	ts.updateOsm(t, "./build/parent_relation.osc.gz")
+}
+
+func TestParentRelation_TagsUpdated(t *testing.T) {
+	assertParentRelationTags(t, "320201", "6", "VBN")
+	assertParentRelationTags(t, "320202", "12", "")
+	assertParentRelationTags(t, "320203", "6", "VBN")
+}
+
+func TestParentRelation_Cleanup(t *testing.T) {
+	ts.dropSchemas()
+	if err := os.RemoveAll(ts.dir); err != nil {
+		t.Error(err)
+	}
+}
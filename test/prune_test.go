@@ -0,0 +1,152 @@
+package test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestPrune_Prepare(t *testing.T) {
+	var err error
+
+	ts.dir, err = ioutil.TempDir("", "imposm3test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// import without -limittocachebuffer caches all elements
+	ts.config = importConfig{
+		connection:      "postgis://",
+		cacheDir:        ts.dir,
+		osmFileName:     "build/prune.pbf",
+		mappingFileName: "prune_mapping.yml",
+		limitTo:         "prune_limitto.geojson",
+	}
+	ts.g = geos.NewGeos()
+
+	ts.db, err = sql.Open("postgres", "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.dropSchemas()
+}
+
+func TestPrune_Import(t *testing.T) {
+	if ts.tableExists(t, dbschemaImport, "osm_roads") != false {
+		t.Fatalf("table osm_roads exists in schema %s", dbschemaImport)
+	}
+	ts.importOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_roads") != true {
+		t.Fatalf("table osm_roads does not exists in schema %s", dbschemaImport)
+	}
+}
+
+func TestPrune_Deploy(t *testing.T) {
+	ts.deployOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_roads") != false {
+		t.Fatalf("table osm_roads exists in schema %s", dbschemaImport)
+	}
+	if ts.tableExists(t, dbschemaProduction, "osm_roads") != true {
+		t.Fatalf("table osm_roads does not exists in schema %s", dbschemaProduction)
+	}
+}
+
+func TestPrune_Imported(t *testing.T) {
+	c := ts.cache(t)
+	defer c.Close()
+	// outside elements are cached, but not inserted
+	assertCachedWay(t, c, 390203)
+	assertCachedNode(t, c, 390106)
+
+	assertRecords(t, []checkElem{
+		{"osm_pois", 390101, "cafe", nil},
+		{"osm_pois", 390106, Missing, nil},
+		{"osm_roads", 390201, "residential", nil},
+		{"osm_roads", 390202, "primary", nil},
+		{"osm_roads", 390203, Missing, nil},
+		{"osm_buildings", 390211, "yes", nil},
+		{"osm_buildings", 390212, Missing, nil},
+	})
+	assertGeomLength(t, checkElem{"osm_roads", 390202, "primary", nil}, 2226.39)
+	assertGeomArea(t, checkElem{"osm_buildings", 390211, "yes", nil}, 2060302.48)
+}
+
+func TestPrune_Prune(t *testing.T) {
+	ts.config.limitToCacheBuffer = 0.02
+	ts.pruneOsm(t)
+}
+
+func TestPrune_Pruned(t *testing.T) {
+	c := ts.cache(t)
+	defer c.Close()
+
+	// ways that cross the buffer are kept with all coords
+	assertCachedWay(t, c, 390201)
+	assertCachedWay(t, c, 390202)
+	assertCachedWay(t, c, 390211)
+	assertCachedNode(t, c, 390101)
+	assertCachedNode(t, c, 390104)
+	assertCachedNode(t, c, 390113)
+
+	for _, id := range []int64{390203, 390212} {
+		if _, err := c.Ways.GetWay(id); err != cache.NotFound {
+			t.Errorf("way %d not pruned", id)
+		}
+	}
+	for _, id := range []int64{390105, 390106, 390121} {
+		if _, err := c.Coords.GetCoord(id); err != cache.NotFound {
+			t.Errorf("coord %d not pruned", id)
+		}
+	}
+	if _, err := c.Nodes.GetNode(390106); err != cache.NotFound {
+		t.Error("node 390106 not pruned")
+	}
+
+	diffCache := ts.diffCache(t)
+	defer diffCache.Close()
+	if ids := diffCache.Coords.Get(390105); len(ids) != 0 {
+		t.Error("pruned coord references way", ids)
+	}
+	if ids := diffCache.Coords.Get(390104); len(ids) != 1 || ids[0] != 390202 {
+		t.Error("unexpected ways for coord 390104", ids)
+	}
+}
+
+func TestPrune_Update(t *testing.T) {
+	ts.updateOsm(t, "./build/prune.osc.gz")
+}
+
+func TestPrune_Updated(t *testing.T) {
+	assertRecords(t, []checkElem{
+		{"osm_pois", 390101, "restaurant", map[string]string{"name": "Inside"}},
+		{"osm_pois", 390108, Missing, nil},
+		{"osm_roads", 390202, "primary", nil},
+		{"osm_roads", 390204, "secondary", nil},
+		{"osm_buildings", 390211, "yes", nil},
+		{"osm_buildings", 390212, Missing, nil},
+	})
+	// moved coord from the buffer
+	assertGeomLength(t, checkElem{"osm_roads", 390202, "primary", nil}, 5031.27)
+	// new way with coords inside and near the border
+	assertGeomLength(t, checkElem{"osm_roads", 390204, "secondary", nil}, 7594.76)
+
+	c := ts.cache(t)
+	defer c.Close()
+	// pruned way stays outside of the cache
+	if _, err := c.Ways.GetWay(390212); err != cache.NotFound {
+		t.Error("way 390212 cached")
+	}
+	if _, err := c.Nodes.GetNode(390108); err != cache.NotFound {
+		t.Error("node 390108 cached")
+	}
+}
+
+func TestPrune_Cleanup(t *testing.T) {
+	ts.dropSchemas()
+	if err := os.RemoveAll(ts.dir); err != nil {
+		t.Error(err)
+	}
+}
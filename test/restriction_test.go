@@ -0,0 +1,146 @@
+package test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestRestriction_Prepare(t *testing.T) {
+	var err error
+
+	ts.dir, err = ioutil.TempDir("", "imposm3test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.config = importConfig{
+		connection:      "postgis://",
+		cacheDir:        ts.dir,
+		osmFileName:     "build/restriction.pbf",
+		mappingFileName: "restriction_mapping.yml",
+	}
+	ts.g = geos.NewGeos()
+
+	ts.db, err = sql.Open("postgres", "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.dropSchemas()
+}
+
+func TestRestriction_Import(t *testing.T) {
+	if ts.tableExists(t, dbschemaImport, "osm_restrictions") != false {
+		t.Fatalf("table osm_restrictions exists in schema %s", dbschemaImport)
+	}
+	ts.importOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_restrictions") != true {
+		t.Fatalf("table osm_restrictions does not exists in schema %s", dbschemaImport)
+	}
+}
+
+func TestRestriction_Deploy(t *testing.T) {
+	ts.deployOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_restrictions") != false {
+		t.Fatalf("table osm_restrictions exists in schema %s", dbschemaImport)
+	}
+	if ts.tableExists(t, dbschemaProduction, "osm_restrictions") != true {
+		t.Fatalf("table osm_restrictions does not exists in schema %s", dbschemaProduction)
+	}
+}
+
+func assertRestriction(t *testing.T, id string, expected map[string]string, numGeoms int32) {
+	rows := ts.queryDynamic(t, "osm_restrictions", "osm_id = "+id)
+	if len(rows) != 1 {
+		t.Fatalf("unexpected restrictions for %s: %v", id, rows)
+	}
+	for k, v := range expected {
+		if rows[0][k] != v {
+			t.Errorf("unexpected %s for %s: %v", k, id, rows[0])
+		}
+	}
+	if n := ts.g.NumGeoms(ts.g.FromWkt(rows[0]["wkt"])); n != numGeoms {
+		t.Errorf("unexpected number of geometries %d for %s: %v", n, id, rows[0])
+	}
+}
+
+func assertRestrictionError(t *testing.T, id string, expected string) {
+	rows := ts.queryDynamic(t, "osm_restriction_errors", "osm_id = "+id)
+	if expected == "" {
+		if len(rows) != 0 {
+			t.Errorf("unexpected restriction error for %s: %v", id, rows)
+		}
+		return
+	}
+	if len(rows) != 1 || rows[0]["error"] != expected {
+		t.Errorf("unexpected restriction error for %s: %v", id, rows)
+	}
+}
+
+func TestRestriction_Restrictions(t *testing.T) {
+	assertRestriction(t, "-300301", map[string]string{
+		"restriction": "no_left_turn",
+		"vehicle":     "",
+		"condition":   "",
+		"from_way":    "300201",
+		"via_node":    "300102",
+		"via_ways":    "",
+		"to_way":      "300203",
+		"except":      "bicycle",
+	}, 3)
+	assertRestriction(t, "-300302", map[string]string{
+		"restriction": "no_straight_on",
+		"condition":   "Mo-Fr 07:00-09:00",
+		"from_way":    "300201",
+		"via_node":    "",
+		"via_ways":    "300202",
+		"to_way":      "300205",
+	}, 3)
+	assertRestriction(t, "-300304", map[string]string{
+		"restriction": "no_right_turn",
+		"vehicle":     "hgv",
+		"to_way":      "300204",
+	}, 3)
+
+	rows := ts.queryDynamic(t, "osm_restrictions", "osm_id = -300303")
+	if len(rows) != 0 {
+		t.Error(rows)
+	}
+	assertRestrictionError(t, "-300301", "")
+	assertRestrictionError(t, "-300303", "from way 300201 not connected to via")
+}
+
+func TestRestriction_Update(t *testing.T) {
+	ts.updateOsm(t, "./build/restriction.osc.gz")
+}
+
+func TestRestriction_RestrictionsUpdated(t *testing.T) {
+	assertRestriction(t, "-300302", map[string]string{
+		"from_way": "300201",
+		"via_ways": "300202",
+		"to_way":   "300205",
+	}, 3)
+	assertRestriction(t, "-300303", map[string]string{
+		"restriction": "no_straight_on",
+		"from_way":    "300201",
+		"via_node":    "300103",
+		"to_way":      "300205",
+	}, 3)
+	for _, id := range []string{"-300301", "-300304"} {
+		rows := ts.queryDynamic(t, "osm_restrictions", "osm_id = "+id)
+		if len(rows) != 0 {
+			t.Error(rows)
+		}
+		assertRestrictionError(t, id, "from way 300201 not connected to via")
+	}
+	assertRestrictionError(t, "-300303", "")
+}
+
+func TestRestriction_Cleanup(t *testing.T) {
+	ts.dropSchemas()
+	if err := os.RemoveAll(ts.dir); err != nil {
+		t.Error(err)
+	}
+}
@@ -79,6 +79,54 @@ func TestRouteRelation_MemberGeomUpdated1(t *testing.T) {
 	}
 }
 
+func TestRouteRelation_RouteGeometry(t *testing.T) {
+	rows := ts.queryDynamic(t, "osm_route_lines", "osm_id = -100901")
+	if len(rows) != 1 {
+		t.Fatal(rows)
+	}
+	g := ts.g.FromWkt(rows[0]["wkt"])
+	if ts.g.Type(g) != "MultiLineString" || ts.g.NumGeoms(g) != 1 {
+		t.Fatal(rows[0]["wkt"])
+	}
+	// three member ways without platforms
+	if math.Abs(g.Length()-3*111.32448543701321) > 0.00000001 {
+		t.Error(g.Length())
+	}
+
+	rows = ts.queryDynamic(t, "osm_route_stops", "osm_id = -100901")
+	if len(rows) != 1 {
+		t.Fatal(rows)
+	}
+	g = ts.g.FromWkt(rows[0]["wkt"])
+	if ts.g.Type(g) != "MultiPoint" || ts.g.NumGeoms(g) != 8 {
+		t.Error(rows[0]["wkt"])
+	}
+}
+
+func TestRouteRelation_SuperRelation(t *testing.T) {
+	// member geometries of sub-relations
+	rows := ts.queryDynamic(t, "osm_master_routes", "osm_id = -100911")
+	if len(rows) != 2 {
+		t.Fatal(rows)
+	}
+	for _, row := range rows {
+		g := ts.g.FromWkt(row["wkt"])
+		if ts.g.Type(g) != "GeometryCollection" || ts.g.NumGeoms(g) != 11 {
+			t.Error(row)
+		}
+	}
+
+	// route of both sub-relations
+	rows = ts.queryDynamic(t, "osm_master_route_lines", "osm_id = -100911")
+	if len(rows) != 1 {
+		t.Fatal(rows)
+	}
+	g := ts.g.FromWkt(rows[0]["wkt"])
+	if math.Abs(g.Length()-6*111.32448543701321) > 0.00000001 {
+		t.Error(g.Length())
+	}
+}
+
 func TestRouteRelation_NoRouteWithMissingMember(t *testing.T) {
 	// current implementation: route members are all or nothing.
 	// if one member is missing, no member is imported
@@ -131,6 +179,65 @@ func TestRouteRelation_MemberGeomUpdated2(t *testing.T) {
 
 }
 
+func TestRouteRelation_RouteGeometryUpdated(t *testing.T) {
+	// route is merged from the reversed member ways
+	rows := ts.queryDynamic(t, "osm_route_lines", "osm_id = -100902")
+	if len(rows) != 1 {
+		t.Fatal(rows)
+	}
+	g := ts.g.FromWkt(rows[0]["wkt"])
+	if ts.g.Type(g) != "MultiLineString" || ts.g.NumGeoms(g) != 1 {
+		t.Fatal(rows[0]["wkt"])
+	}
+	// length is updated with the moved node
+	length := 0.0
+	for _, member := range []string{"100501", "100502", "100503"} {
+		rows := ts.queryDynamic(t, "osm_route_members", "osm_id = -100902 AND member = "+member)
+		if len(rows) != 1 {
+			t.Fatal(rows)
+		}
+		length += ts.g.FromWkt(rows[0]["wkt"]).Length()
+	}
+	if math.Abs(g.Length()-length) > 0.00000001 {
+		t.Error(g.Length(), length)
+	}
+
+	// removed platform and stop with new role
+	rows = ts.queryDynamic(t, "osm_route_stops", "osm_id = -100902")
+	if len(rows) != 1 {
+		t.Fatal(rows)
+	}
+	g = ts.g.FromWkt(rows[0]["wkt"])
+	if ts.g.NumGeoms(g) != 6 {
+		t.Error(rows[0]["wkt"])
+	}
+}
+
+func TestRouteRelation_SuperRelationUpdated(t *testing.T) {
+	// modified sub-relation 100902 updates parent relation
+	rows := ts.queryDynamic(t, "osm_master_routes", "osm_id = -100911 AND member = 100902")
+	if len(rows) != 1 {
+		t.Fatal(rows)
+	}
+	// one platform removed
+	if g := ts.g.FromWkt(rows[0]["wkt"]); ts.g.NumGeoms(g) != 10 {
+		t.Error(rows[0])
+	}
+
+	rows = ts.queryDynamic(t, "osm_route_lines", "osm_id = -100902")
+	if len(rows) != 1 {
+		t.Fatal(rows)
+	}
+	length := ts.g.FromWkt(rows[0]["wkt"]).Length()
+	rows = ts.queryDynamic(t, "osm_master_route_lines", "osm_id = -100911")
+	if len(rows) != 1 {
+		t.Fatal(rows)
+	}
+	if g := ts.g.FromWkt(rows[0]["wkt"]); math.Abs(g.Length()-2*length) > 0.00000001 {
+		t.Error(g.Length(), length)
+	}
+}
+
 func TestRouteRelation_MemberUpdatedByNode(t *testing.T) {
 	// check that member is updated after node was modified
 	rows := ts.queryDynamic(t, "osm_route_members", "osm_id = -110901 AND member = 110101")
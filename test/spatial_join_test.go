@@ -0,0 +1,107 @@
+package test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/omniscale/imposm3/geom/geos"
+)
+
+func TestSpatialJoin_Prepare(t *testing.T) {
+	var err error
+
+	ts.dir, err = ioutil.TempDir("", "imposm3test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.config = importConfig{
+		connection:      "postgis://",
+		cacheDir:        ts.dir,
+		osmFileName:     "build/spatial_join.pbf",
+		mappingFileName: "spatial_join_mapping.yml",
+	}
+	ts.g = geos.NewGeos()
+
+	ts.db, err = sql.Open("postgres", "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.dropSchemas()
+}
+
+func TestSpatialJoin_Import(t *testing.T) {
+	if ts.tableExists(t, dbschemaImport, "osm_pois") != false {
+		t.Fatalf("table osm_pois exists in schema %s", dbschemaImport)
+	}
+	ts.importOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_pois") != true {
+		t.Fatalf("table osm_pois does not exists in schema %s", dbschemaImport)
+	}
+}
+
+func TestSpatialJoin_Deploy(t *testing.T) {
+	ts.deployOsm(t)
+	if ts.tableExists(t, dbschemaImport, "osm_pois") != false {
+		t.Fatalf("table osm_pois exists in schema %s", dbschemaImport)
+	}
+	if ts.tableExists(t, dbschemaProduction, "osm_pois") != true {
+		t.Fatalf("table osm_pois does not exists in schema %s", dbschemaProduction)
+	}
+}
+
+func assertSpatialJoin(t *testing.T, table, id string, expected map[string]string) {
+	rows := ts.queryDynamic(t, table, "osm_id = "+id)
+	if len(rows) != 1 {
+		t.Fatalf("unexpected rows for %s in %s: %v", id, table, rows)
+	}
+	for k, v := range expected {
+		if rows[0][k] != v {
+			t.Errorf("unexpected %s for %s in %s: %v", k, id, table, rows[0])
+		}
+	}
+}
+
+func TestSpatialJoin_Columns(t *testing.T) {
+	assertSpatialJoin(t, "osm_pois", "310111", map[string]string{
+		"country": "Country", "state": "West", "state_id": "310202",
+	})
+	assertSpatialJoin(t, "osm_pois", "310112", map[string]string{
+		"country": "Country", "state": "East", "state_id": "310203",
+	})
+	// outside of all boundaries
+	assertSpatialJoin(t, "osm_pois", "310113", map[string]string{
+		"country": "", "state": "", "state_id": "",
+	})
+	assertSpatialJoin(t, "osm_roads", "310204", map[string]string{
+		"state": "West",
+	})
+}
+
+func TestSpatialJoin_Update(t *testing.T) {
+	ts.updateOsm(t, "./build/spatial_join.osc.gz")
+}
+
+func TestSpatialJoin_ColumnsUpdated(t *testing.T) {
+	// 310112 is unchanged, but the modified border moved it to West
+	assertSpatialJoin(t, "osm_pois", "310112", map[string]string{
+		"country": "Country", "state": "West", "state_id": "310202",
+	})
+	assertSpatialJoin(t, "osm_pois", "310111", map[string]string{
+		"state": "West",
+	})
+	assertSpatialJoin(t, "osm_pois", "310116", map[string]string{
+		"country": "Country", "state": "East", "state_id": "310203",
+	})
+	assertSpatialJoin(t, "osm_pois", "310113", map[string]string{
+		"state": "",
+	})
+}
+
+func TestSpatialJoin_Cleanup(t *testing.T) {
+	ts.dropSchemas()
+	if err := os.RemoveAll(ts.dir); err != nil {
+		t.Error(err)
+	}
+}
@@ -16,11 +16,13 @@ type Deleter struct {
 	tmPoints         mapping.NodeMatcher
 	tmLineStrings    mapping.WayMatcher
 	tmPolygons       mapping.RelWayMatcher
+	tmNetworks       mapping.WayMatcher
 	expireor         expire.Expireor
 	singleIdSpace    bool
 	deletedRelations map[int64]struct{}
 	deletedWays      map[int64]struct{}
 	deletedMembers   map[int64]struct{}
+	deletedNetwork   map[int64]struct{}
 }
 
 func NewDeleter(db database.Deleter, osmCache *cache.OSMCache, diffCache *cache.DiffCache,
@@ -28,6 +30,7 @@ func NewDeleter(db database.Deleter, osmCache *cache.OSMCache, diffCache *cache.
 	tmPoints mapping.NodeMatcher,
 	tmLineStrings mapping.WayMatcher,
 	tmPolygons mapping.RelWayMatcher,
+	tmNetworks mapping.WayMatcher,
 ) *Deleter {
 	return &Deleter{
 		delDb:            db,
@@ -36,10 +39,12 @@ func NewDeleter(db database.Deleter, osmCache *cache.OSMCache, diffCache *cache.
 		tmPoints:         tmPoints,
 		tmLineStrings:    tmLineStrings,
 		tmPolygons:       tmPolygons,
+		tmNetworks:       tmNetworks,
 		singleIdSpace:    singleIdSpace,
 		deletedRelations: make(map[int64]struct{}),
 		deletedWays:      make(map[int64]struct{}),
 		deletedMembers:   make(map[int64]struct{}),
+		deletedNetwork:   make(map[int64]struct{}),
 	}
 }
 
@@ -51,6 +56,12 @@ func (d *Deleter) DeletedMemberWays() map[int64]struct{} {
 	return d.deletedMembers
 }
 
+// DeletedNetworkWays returns all network ways that were deleted, because
+// a way with a shared node changed. These ways need to be split again.
+func (d *Deleter) DeletedNetworkWays() map[int64]struct{} {
+	return d.deletedNetwork
+}
+
 func (d *Deleter) nodeId(id int64) int64 {
 	return id
 }
@@ -96,6 +107,10 @@ func (d *Deleter) deleteRelation(id int64, deleteRefs bool, deleteMembers bool)
 				if err := d.diffCache.Ways.DeleteRef(m.Id, id); err != nil {
 					return err
 				}
+			} else if m.Type == element.RELATION {
+				if err := d.diffCache.Relations.DeleteRef(m.Id, id); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -142,6 +157,41 @@ func (d *Deleter) deleteRelation(id int64, deleteRefs bool, deleteMembers bool)
 	return nil
 }
 
+// DeleteMemberWay deletes way id and all its relations, before the way
+// is reinserted as new member of a relation.
+func (d *Deleter) DeleteMemberWay(id int64) error {
+	d.deletedMembers[id] = struct{}{}
+	if _, ok := d.deletedWays[id]; ok {
+		return nil
+	}
+	for _, r := range d.diffCache.Ways.Get(id) {
+		if _, ok := d.deletedRelations[r]; ok {
+			continue
+		}
+		if err := d.deleteRelation(r, false, false); err != nil {
+			return err
+		}
+	}
+	return d.deleteWay(id, false)
+}
+
+// deleteParentRelations deletes all relations that contain relation id
+// as a (nested) member.
+func (d *Deleter) deleteParentRelations(id int64) error {
+	for _, parent := range d.diffCache.Relations.Get(id) {
+		if _, ok := d.deletedRelations[parent]; ok {
+			continue
+		}
+		if err := d.deleteRelation(parent, false, false); err != nil {
+			return err
+		}
+		if err := d.deleteParentRelations(parent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *Deleter) deleteWay(id int64, deleteRefs bool) error {
 	d.deletedWays[id] = struct{}{}
 
@@ -170,6 +220,12 @@ func (d *Deleter) deleteWay(id int64, deleteRefs bool) error {
 		}
 		deleted = true
 	}
+	if matches := d.tmNetworks.MatchWay(elem); len(matches) > 0 {
+		if err := d.delDb.Delete(d.WayId(elem.Id), matches); err != nil {
+			return err
+		}
+		deleted = true
+	}
 	if deleted && deleteRefs {
 		for _, n := range elem.Refs {
 			if err := d.diffCache.Coords.DeleteRef(n, id); err != nil {
@@ -187,6 +243,59 @@ func (d *Deleter) deleteWay(id int64, deleteRefs bool) error {
 	return nil
 }
 
+// deleteNetworkNeighbours deletes all network ways that share a node with
+// the old or new version of the network way, as their edges depend on
+// this way.
+func (d *Deleter) deleteNetworkNeighbours(way *element.Way, deleted bool) error {
+	refs := make(map[int64]struct{})
+	isNetwork := false
+	if !deleted && len(d.tmNetworks.MatchWay(way)) > 0 {
+		isNetwork = true
+		for _, ref := range way.Refs {
+			refs[ref] = struct{}{}
+		}
+	}
+	old, err := d.osmCache.Ways.GetWay(way.Id)
+	if err != nil && err != cache.NotFound {
+		return err
+	}
+	if old != nil && len(d.tmNetworks.MatchWay(old)) > 0 {
+		isNetwork = true
+		for _, ref := range old.Refs {
+			refs[ref] = struct{}{}
+		}
+	}
+	if !isNetwork {
+		return nil
+	}
+
+	for ref := range refs {
+		for _, id := range d.diffCache.Coords.Get(ref) {
+			if id == way.Id {
+				continue
+			}
+			if _, ok := d.deletedWays[id]; ok {
+				continue
+			}
+			other, err := d.osmCache.Ways.GetWay(id)
+			if err != nil {
+				if err == cache.NotFound {
+					continue
+				}
+				return err
+			}
+			if len(d.tmNetworks.MatchWay(other)) == 0 {
+				continue
+			}
+			if err := d.deleteWay(id, false); err != nil {
+				return err
+			}
+			d.deletedNetwork[id] = struct{}{}
+		}
+	}
+	return nil
+}
+
 func (d *Deleter) deleteNode(id int64) error {
 	elem, err := d.osmCache.Nodes.GetNode(id)
 	if err != nil {
@@ -218,7 +327,13 @@ func (d *Deleter) Delete(delElem diff.Element) error {
 		if err := d.deleteRelation(delElem.Rel.Id, true, true); err != nil {
 			return err
 		}
+		if err := d.deleteParentRelations(delElem.Rel.Id); err != nil {
+			return err
+		}
 	} else if delElem.Way != nil {
+		if err := d.deleteNetworkNeighbours(delElem.Way, delElem.Del); err != nil {
+			return err
+		}
 		if err := d.deleteWay(delElem.Way.Id, true); err != nil {
 			return err
 		}
@@ -0,0 +1,245 @@
+package update
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/expire"
+	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/parser/diff"
+	diffstate "github.com/omniscale/imposm3/update/state"
+)
+
+const overlayStateFile = "overlay.state"
+
+// overlay contains local edits from all .osc and .osc.gz files of the
+// -overlay-dir. Overlay elements take precedence over upstream elements:
+// Upstream changes of elements in the overlay are skipped and reported as
+// conflicts. Files are applied in alphabetical order.
+type overlay struct {
+	elems []diff.Element
+	keys  map[string]struct{}
+	// files with size and modification time, to detect changes
+	files []string
+}
+
+// loadOverlay reads all overlay files from dir. Returns nil if dir is
+// empty.
+func loadOverlay(dir string) (*overlay, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	o := &overlay{keys: make(map[string]struct{})}
+	var fnames []string
+	for _, fi := range infos {
+		if fi.IsDir() || !(strings.HasSuffix(fi.Name(), ".osc") || strings.HasSuffix(fi.Name(), ".osc.gz")) {
+			continue
+		}
+		fnames = append(fnames, filepath.Join(dir, fi.Name()))
+		o.files = append(o.files, fmt.Sprintf("%s %d %d", fi.Name(), fi.Size(), fi.ModTime().UnixNano()))
+	}
+	if len(fnames) == 0 {
+		return o, nil
+	}
+
+	parser := diff.NewMergeParser(fnames)
+	for {
+		elem, err := parser.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading overlay: %v", err)
+		}
+		o.elems = append(o.elems, elem)
+		o.keys[elemKey(elem)] = struct{}{}
+	}
+	return o, nil
+}
+
+// filter returns a parser that skips all elements of the overlay.
+func (o *overlay) filter(parser changeParser) *overlayFilter {
+	return &overlayFilter{parser: parser, keys: o.keys}
+}
+
+// apply imports the overlay if it changed since the last apply. Elements
+// that were removed from the overlay are deleted if they have a negative
+// ID. Other removed elements keep the overlay version till the next
+// upstream change.
+func (o *overlay) apply(geometryLimiter *limit.Limiter, expireor expire.Expireor, osmCache *cache.OSMCache, diffCache *cache.DiffCache, stateDir string) error {
+	prev, err := readOverlayState(stateDir)
+	if err != nil {
+		return err
+	}
+	if prev != nil && strings.Join(prev.Files, "\n") == strings.Join(o.files, "\n") {
+		return nil
+	}
+
+	defer log.StopStep(log.StartStep("Applying overlay"))
+
+	elems := append([]diff.Element{}, o.elems...)
+	if prev != nil {
+		// delete relations before ways before nodes
+		for _, typ := range []string{"r", "w", "n"} {
+			for _, k := range prev.Elements {
+				if _, ok := o.keys[k]; ok || !strings.HasPrefix(k, typ) {
+					continue
+				}
+				elem, err := deletedElem(k)
+				if err != nil {
+					return err
+				}
+				if elem == nil {
+					log.Warnf("%s removed from overlay, keeping overlay version till next upstream change", k)
+					continue
+				}
+				elems = append(elems, *elem)
+			}
+		}
+	}
+
+	if err := update(&elemParser{elems: elems}, nil, nil, nil, geometryLimiter, expireor, osmCache, diffCache); err != nil {
+		return fmt.Errorf("applying overlay: %v", err)
+	}
+
+	state := overlayState{Files: o.files}
+	for k := range o.keys {
+		state.Elements = append(state.Elements, k)
+	}
+	sort.Strings(state.Elements)
+	return writeOverlayState(stateDir, &state)
+}
+
+// overlayFilter skips all upstream changes of overlay elements.
+type overlayFilter struct {
+	parser    changeParser
+	keys      map[string]struct{}
+	conflicts int
+}
+
+func (f *overlayFilter) Next() (diff.Element, error) {
+	for {
+		elem, err := f.parser.Next()
+		if err != nil {
+			return elem, err
+		}
+		if elem.Node == nil && elem.Way == nil && elem.Rel == nil {
+			return elem, nil
+		}
+		k := elemKey(elem)
+		if _, ok := f.keys[k]; !ok {
+			return elem, nil
+		}
+		f.conflicts += 1
+		action := "modified"
+		if elem.Add {
+			action = "created"
+		} else if elem.Del {
+			action = "deleted"
+		}
+		log.Warnf("overlay conflict: upstream %s %s, keeping overlay version", action, k)
+	}
+}
+
+// updateWithOverlay imports all changes from parser and applies the
+// -overlay-dir afterwards.
+func updateWithOverlay(parser changeParser, state, lastState *diffstate.DiffState, undo *undoLog, geometryLimiter *limit.Limiter, expireor expire.Expireor, osmCache *cache.OSMCache, diffCache *cache.DiffCache) error {
+	o, err := loadOverlay(config.BaseOptions.OverlayDir)
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		return update(parser, state, lastState, undo, geometryLimiter, expireor, osmCache, diffCache)
+	}
+
+	filter := o.filter(parser)
+	if err := update(filter, state, lastState, undo, geometryLimiter, expireor, osmCache, diffCache); err != nil {
+		return err
+	}
+	if filter.conflicts > 0 {
+		log.Warnf("skipped %d upstream changes of overlay elements", filter.conflicts)
+	}
+	return o.apply(geometryLimiter, expireor, osmCache, diffCache, config.BaseOptions.DiffDir)
+}
+
+// elemKey returns a key like n123 or w-42 for the element.
+func elemKey(elem diff.Element) string {
+	if elem.Node != nil {
+		return fmt.Sprintf("n%d", elem.Node.Id)
+	} else if elem.Way != nil {
+		return fmt.Sprintf("w%d", elem.Way.Id)
+	}
+	return fmt.Sprintf("r%d", elem.Rel.Id)
+}
+
+// deletedElem returns a delete for the elemKey k, or nil if the ID is not
+// negative.
+func deletedElem(k string) (*diff.Element, error) {
+	var typ rune
+	var id int64
+	if _, err := fmt.Sscanf(k, "%c%d", &typ, &id); err != nil {
+		return nil, fmt.Errorf("invalid overlay element %q: %v", k, err)
+	}
+	if id >= 0 {
+		return nil, nil
+	}
+	elem := &diff.Element{Del: true}
+	switch typ {
+	case 'n':
+		elem.Node = &element.Node{OSMElem: element.OSMElem{Id: id}}
+	case 'w':
+		elem.Way = &element.Way{OSMElem: element.OSMElem{Id: id}}
+	case 'r':
+		elem.Rel = &element.Relation{OSMElem: element.OSMElem{Id: id}}
+	default:
+		return nil, fmt.Errorf("invalid overlay element %q", k)
+	}
+	return elem, nil
+}
+
+// overlayState is the last applied overlay.
+type overlayState struct {
+	Files    []string `json:"files"`
+	Elements []string `json:"elements"`
+}
+
+func readOverlayState(dir string) (*overlayState, error) {
+	f, err := os.Open(filepath.Join(dir, overlayStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	state := &overlayState{}
+	if err := json.NewDecoder(f).Decode(state); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", overlayStateFile, err)
+	}
+	return state, nil
+}
+
+func writeOverlayState(dir string, state *overlayState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, overlayStateFile+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, overlayStateFile))
+}
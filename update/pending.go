@@ -0,0 +1,192 @@
+package update
+
+import (
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/element"
+)
+
+// outsideElems are the nodes and ways of a diff that are outside of the
+// -limitto buffer. They are not cached and never become available.
+type outsideElems struct {
+	nodes map[int64]struct{}
+	ways  map[int64]struct{}
+}
+
+func newOutsideElems() *outsideElems {
+	return &outsideElems{
+		nodes: make(map[int64]struct{}),
+		ways:  make(map[int64]struct{}),
+	}
+}
+
+func (o *outsideElems) contains(m element.Member) bool {
+	if o == nil {
+		return false
+	}
+	var ok bool
+	switch m.Type {
+	case element.NODE:
+		_, ok = o.nodes[m.Id]
+	case element.WAY:
+		_, ok = o.ways[m.Id]
+	}
+	return ok
+}
+
+// missingRefs returns whether the way should be cached and the refs
+// that are not cached. The way should be cached if any ref is cached, as
+// the way can cross the -limitto area (e.g. when it was extended into
+// this area).
+// Refs that are outside of the -limitto buffer are never cached and they
+// are not returned as missing, see filterMissing.
+func missingRefs(osmCache *cache.OSMCache, pending *cache.PendingCache, way *element.Way, outside *outsideElems) (bool, []element.Member, error) {
+	var missing []element.Member
+	cachedRefs := 0
+	for _, ref := range way.Refs {
+		m := element.Member{Id: ref, Type: element.NODE}
+		cached, err := isCached(osmCache, m)
+		if err != nil {
+			return false, nil, err
+		}
+		if cached {
+			cachedRefs += 1
+		} else {
+			missing = append(missing, m)
+		}
+	}
+	missing, err := filterMissing(osmCache, pending, element.WAY, way.Id, missing, outside)
+	if err != nil {
+		return false, nil, err
+	}
+	return cachedRefs > 0, missing, nil
+}
+
+// missingMembers returns whether the relation should be cached and the
+// way and node members that are not cached. The relation should be
+// cached if any way or node member is cached, or if there are no way or
+// node members.
+// Members that are outside of the -limitto buffer are not returned as
+// missing, see filterMissing.
+func missingMembers(osmCache *cache.OSMCache, pending *cache.PendingCache, rel *element.Relation, outside *outsideElems) (bool, []element.Member, error) {
+	var missing []element.Member
+	refs := 0
+	for _, m := range rel.Members {
+		if m.Type != element.WAY && m.Type != element.NODE {
+			continue
+		}
+		refs += 1
+		m := element.Member{Id: m.Id, Type: m.Type}
+		cached, err := isCached(osmCache, m)
+		if err != nil {
+			return false, nil, err
+		}
+		if !cached {
+			missing = append(missing, m)
+		}
+	}
+	cached := refs == 0 || len(missing) < refs
+	missing, err := filterMissing(osmCache, pending, element.RELATION, rel.Id, missing, outside)
+	if err != nil {
+		return false, nil, err
+	}
+	return cached, missing, nil
+}
+
+// filterMissing removes all elements from missing that are outside of
+// the -limitto buffer: elements that were already missing in the cached
+// version of the way or relation, but not pending, and all elements in
+// outside.
+func filterMissing(osmCache *cache.OSMCache, pending *cache.PendingCache, typ element.MemberType, id int64, missing []element.Member, outside *outsideElems) ([]element.Member, error) {
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	known, err := knownMembers(osmCache, pending, typ, id)
+	if err != nil {
+		return nil, err
+	}
+	var result []element.Member
+	for _, m := range missing {
+		if _, ok := known[m]; ok {
+			continue
+		}
+		if outside.contains(m) {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// knownMembers returns the refs of the cached way or the members of the
+// cached relation that are not pending.
+func knownMembers(osmCache *cache.OSMCache, pending *cache.PendingCache, typ element.MemberType, id int64) (map[element.Member]struct{}, error) {
+	var members []element.Member
+	var err error
+	switch typ {
+	case element.WAY:
+		var way *element.Way
+		way, err = osmCache.Ways.GetWay(id)
+		if err == nil {
+			for _, ref := range way.Refs {
+				members = append(members, element.Member{Id: ref, Type: element.NODE})
+			}
+		}
+	case element.RELATION:
+		var rel *element.Relation
+		rel, err = osmCache.Relations.GetRelation(id)
+		if err == nil {
+			for _, m := range rel.Members {
+				members = append(members, element.Member{Id: m.Id, Type: m.Type})
+			}
+		}
+	}
+	if err == cache.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	missing, err := pending.Get(typ, id)
+	if err != nil && err != cache.NotFound {
+		return nil, err
+	}
+	known := make(map[element.Member]struct{}, len(members))
+	for _, m := range members {
+		known[m] = struct{}{}
+	}
+	for _, m := range missing {
+		delete(known, element.Member{Id: m.Id, Type: m.Type})
+	}
+	return known, nil
+}
+
+// isCached returns whether the referenced way or node is cached.
+func isCached(osmCache *cache.OSMCache, m element.Member) (bool, error) {
+	var err error
+	switch m.Type {
+	case element.WAY:
+		_, err = osmCache.Ways.GetWay(m.Id)
+	case element.NODE:
+		_, err = osmCache.Coords.GetCoord(m.Id)
+	default:
+		return false, nil
+	}
+	if err == cache.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// updatePending records the missing references of a cached element in
+// the pending store, so that reconcile can re-insert the element as
+// soon as the missing elements are cached. Elements that are not cached
+// or that are complete are removed from the pending store.
+func updatePending(pending *cache.PendingCache, typ element.MemberType, id int64, cached bool, missing []element.Member) error {
+	if !cached {
+		missing = nil
+	}
+	return pending.Put(typ, id, missing)
+}
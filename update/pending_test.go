@@ -0,0 +1,142 @@
+package update
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/element"
+)
+
+// openTestCaches opens an OSMCache and a DiffCache in dir.
+func openTestCaches(t *testing.T, dir string) (*cache.OSMCache, *cache.DiffCache) {
+	osmCache := cache.NewOSMCache(filepath.Join(dir, "osm"))
+	if err := osmCache.Open(); err != nil {
+		t.Fatal(err)
+	}
+	diffCache := cache.NewDiffCache(filepath.Join(dir, "diff"))
+	if err := diffCache.Open(); err != nil {
+		osmCache.Close()
+		t.Fatal(err)
+	}
+	return osmCache, diffCache
+}
+
+func TestMissingRefsOutsideOfBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	osmCache, diffCache := openTestCaches(t, dir)
+	defer osmCache.Close()
+	defer diffCache.Close()
+
+	// way crosses the limitto buffer, node 3 is not cached
+	if err := osmCache.Coords.PutCoords([]element.Node{
+		{OSMElem: element.OSMElem{Id: 1}, Long: 9, Lat: 53},
+		{OSMElem: element.OSMElem{Id: 2}, Long: 9.1, Lat: 53},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	way := &element.Way{OSMElem: element.OSMElem{Id: 10}, Refs: []int64{1, 2, 3}}
+	if err := osmCache.Ways.PutWay(way); err != nil {
+		t.Fatal(err)
+	}
+
+	// modified tags, node 3 is still outside
+	modified := &element.Way{
+		OSMElem: element.OSMElem{Id: 10, Tags: element.Tags{"highway": "primary"}},
+		Refs:    []int64{1, 2, 3},
+	}
+	cached, missing, err := missingRefs(osmCache, diffCache.Pending, modified, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached || len(missing) != 0 {
+		t.Fatal("unexpected missing refs", cached, missing)
+	}
+	if err := updatePending(diffCache.Pending, element.WAY, 10, cached, missing); err != nil {
+		t.Fatal(err)
+	}
+	for p := range diffCache.Pending.Iter() {
+		t.Error("unexpected pending element", p)
+	}
+
+	// new node 4 is outside of the buffer, node 5 is not cached yet
+	modified.Refs = []int64{1, 2, 3, 4, 5}
+	outside := newOutsideElems()
+	outside.nodes[4] = struct{}{}
+	cached, missing, err = missingRefs(osmCache, diffCache.Pending, modified, outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached || !reflect.DeepEqual(missing, []element.Member{{Id: 5, Type: element.NODE}}) {
+		t.Error("unexpected missing refs", cached, missing)
+	}
+}
+
+func TestMissingMembersOutsideOfBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imposm3_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	osmCache, diffCache := openTestCaches(t, dir)
+	defer osmCache.Close()
+	defer diffCache.Close()
+
+	// relation crosses the limitto buffer, way 11 is not cached
+	if err := osmCache.Ways.PutWay(&element.Way{OSMElem: element.OSMElem{Id: 10}, Refs: []int64{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	rel := &element.Relation{
+		OSMElem: element.OSMElem{Id: 20},
+		Members: []element.Member{
+			{Id: 10, Type: element.WAY, Role: "outer"},
+			{Id: 11, Type: element.WAY, Role: "outer"},
+		},
+	}
+	if err := osmCache.Relations.PutRelation(rel); err != nil {
+		t.Fatal(err)
+	}
+
+	// modified tags, way 11 is still outside
+	modified := &element.Relation{
+		OSMElem: element.OSMElem{Id: 20, Tags: element.Tags{"type": "boundary"}},
+		Members: rel.Members,
+	}
+	cached, missing, err := missingMembers(osmCache, diffCache.Pending, modified, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached || len(missing) != 0 {
+		t.Fatal("unexpected missing members", cached, missing)
+	}
+	if err := updatePending(diffCache.Pending, element.RELATION, 20, cached, missing); err != nil {
+		t.Fatal(err)
+	}
+	for p := range diffCache.Pending.Iter() {
+		t.Error("unexpected pending element", p)
+	}
+
+	// new way 12 is outside of the buffer, node 5 is not cached yet
+	modified.Members = append(modified.Members,
+		element.Member{Id: 12, Type: element.WAY, Role: "outer"},
+		element.Member{Id: 5, Type: element.NODE, Role: "label"},
+	)
+	outside := newOutsideElems()
+	outside.ways[12] = struct{}{}
+	cached, missing, err = missingMembers(osmCache, diffCache.Pending, modified, outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cached || !reflect.DeepEqual(missing, []element.Member{{Id: 5, Type: element.NODE}}) {
+		t.Error("unexpected missing members", cached, missing)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/omniscale/imposm3/cache"
 	"github.com/omniscale/imposm3/config"
@@ -16,6 +17,7 @@ import (
 	"github.com/omniscale/imposm3/expire"
 	"github.com/omniscale/imposm3/geom/geos"
 	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/geom/spatialjoin"
 	"github.com/omniscale/imposm3/logging"
 	"github.com/omniscale/imposm3/mapping"
 	"github.com/omniscale/imposm3/parser/diff"
@@ -31,22 +33,12 @@ func Diff() {
 		logging.SetQuiet(true)
 	}
 
-	var geometryLimiter *limit.Limiter
-	if config.BaseOptions.LimitTo != "" {
-		var err error
-		step := log.StartStep("Reading limitto geometries")
-		geometryLimiter, err = limit.NewFromGeoJSON(
-			config.BaseOptions.LimitTo,
-			config.BaseOptions.LimitToCacheBuffer,
-			config.BaseOptions.Srid,
-		)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.StopStep(step)
+	geometryLimiter, err := newLimiter()
+	if err != nil {
+		log.Fatal(err)
 	}
 	osmCache := cache.NewOSMCache(config.BaseOptions.CacheDir)
-	err := osmCache.Open()
+	err = osmCache.Open()
 	if err != nil {
 		log.Fatal("osm cache: ", err)
 	}
@@ -107,6 +99,62 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 		return err
 	}
 
+	undo := newUndoLog(osmCache, state, lastState)
+	return updateWithOverlay(parser, state, lastState, undo, geometryLimiter, expireor, osmCache, diffCache)
+}
+
+// UpdateFiles merges multiple .osc.gz files into one change set and
+// imports it in a single transaction. The files need to be consecutive
+// and ordered from oldest to newest. Files that are already imported
+// are skipped, unless force is true. The state of the last file is
+// stored as the last state.
+func UpdateFiles(oscFiles []string, geometryLimiter *limit.Limiter, expireor expire.Expireor, osmCache *cache.OSMCache, diffCache *cache.DiffCache, force bool) error {
+	if len(oscFiles) == 1 {
+		return Update(oscFiles[0], geometryLimiter, expireor, osmCache, diffCache, force)
+	}
+
+	lastState, err := diffstate.ParseLastState(config.BaseOptions.DiffDir)
+	if err != nil {
+		log.Warn(err)
+	}
+
+	var state *diffstate.DiffState
+	var files []string
+	for _, oscFile := range oscFiles {
+		s, err := diffstate.FromOscGz(oscFile)
+		if err != nil {
+			return err
+		}
+		if lastState != nil && lastState.Sequence != 0 && s != nil && s.Sequence <= lastState.Sequence {
+			if !force {
+				log.Warn(s, " already imported")
+				continue
+			}
+		}
+		files = append(files, oscFile)
+		state = s
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	defer log.StopStep(log.StartStep(fmt.Sprintf("Processing %d files (%s - %s)",
+		len(files), files[0], files[len(files)-1])))
+
+	parser := diff.NewMergeParser(files)
+
+	undo := newUndoLog(osmCache, state, lastState)
+	return updateWithOverlay(parser, state, lastState, undo, geometryLimiter, expireor, osmCache, diffCache)
+}
+
+// changeParser is implemented by diff.Parser and diff.MergeParser.
+type changeParser interface {
+	Next() (diff.Element, error)
+}
+
+// update imports all changes from parser. It records the inverse changes
+// in undo, if undo is not nil.
+func update(parser changeParser, state, lastState *diffstate.DiffState, undo *undoLog, geometryLimiter *limit.Limiter, expireor expire.Expireor, osmCache *cache.OSMCache, diffCache *cache.DiffCache) error {
 	tagmapping, err := mapping.NewMapping(config.BaseOptions.MappingFile)
 	if err != nil {
 		return err
@@ -119,6 +167,7 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 		ImportSchema:     config.BaseOptions.Schemas.Production,
 		ProductionSchema: config.BaseOptions.Schemas.Production,
 		BackupSchema:     config.BaseOptions.Schemas.Backup,
+		RegionSchemas:    config.BaseOptions.RegionSchemas(),
 	}
 	db, err := database.Open(dbConf, tagmapping)
 	if err != nil {
@@ -136,11 +185,26 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 		return errors.New("database not deletable")
 	}
 
+	// inserter for all writers, updates the spatial join index if required
+	var inserter database.Inserter = db
+	spatialJoinFile := filepath.Join(config.BaseOptions.CacheDir, spatialjoin.IndexFileName)
+	if tagmapping.SpatialJoin != nil {
+		if err := tagmapping.SpatialJoin.Load(spatialJoinFile); err != nil {
+			return fmt.Errorf("loading spatial join index: %v", err)
+		}
+		delDb = database.NewSpatialJoinIndexer(delDb, tagmapping.SpatialJoin)
+		inserter = delDb
+	}
+
 	genDb, ok := db.(database.Generalizer)
 	if ok {
 		genDb.EnableGeneralizeUpdates()
 	}
 
+	if histDb, ok := db.(database.Historian); ok {
+		histDb.SetHistoryTime(historyTime(state, lastState))
+	}
+
 	deleter := NewDeleter(
 		delDb,
 		osmCache,
@@ -149,6 +213,7 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 		tagmapping.PointMatcher(),
 		tagmapping.LineStringMatcher(),
 		tagmapping.PolygonMatcher(),
+		tagmapping.NetworkMatcher(),
 	)
 	deleter.SetExpireor(expireor)
 
@@ -162,29 +227,60 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 	ways := make(chan *element.Way)
 	nodes := make(chan *element.Node)
 
+	parentRelations := tagmapping.ParentRelations()
+
+	var mpErrors *writer.MultiPolygonErrors
+	if tagmapping.MultiPolygons.ErrorsFile != "" {
+		mpErrors, err = writer.NewMultiPolygonErrors(tagmapping.MultiPolygons.ErrorsFile, config.BaseOptions.Srid, false)
+		if err != nil {
+			return err
+		}
+		defer mpErrors.Close()
+	}
+
 	relWriter := writer.NewRelationWriter(osmCache, diffCache,
 		tagmapping.SingleIdSpace,
 		relations,
-		db, progress,
+		inserter, progress,
 		tagmapping.PolygonMatcher(),
 		tagmapping.RelationMatcher(),
 		tagmapping.RelationMemberMatcher(),
+		tagmapping.RestrictionMatcher(),
 		config.BaseOptions.Srid)
 	relWriter.SetLimiter(geometryLimiter)
 	relWriter.SetExpireor(expireor)
+	relWriter.SetParentRelations(parentRelations)
+	relWriter.SetMultiPolygonOptions(tagmapping.MultiPolygonOptions(config.BaseOptions.Srid))
+	relWriter.SetMultiPolygonErrors(mpErrors)
 	relWriter.Start()
 
 	wayWriter := writer.NewWayWriter(osmCache, diffCache,
 		tagmapping.SingleIdSpace,
-		ways, db,
+		ways, inserter,
 		progress,
 		tagmapping.PolygonMatcher(),
 		tagmapping.LineStringMatcher(),
 		config.BaseOptions.Srid)
 	wayWriter.SetLimiter(geometryLimiter)
 	wayWriter.SetExpireor(expireor)
+	wayWriter.SetParentRelations(parentRelations)
 	wayWriter.Start()
 
+	var networkWays chan *element.Way
+	var networkWriter *writer.OsmElemWriter
+	hasNetwork := tagmapping.HasNetworkTables()
+	if hasNetwork {
+		networkWays = make(chan *element.Way)
+		networkWriter = writer.NewNetworkWriter(osmCache, diffCache,
+			tagmapping.SingleIdSpace,
+			networkWays, db,
+			tagmapping.NetworkMatcher(),
+			config.BaseOptions.Srid)
+		networkWriter.SetLimiter(geometryLimiter)
+		networkWriter.SetExpireor(expireor)
+		networkWriter.Start()
+	}
+
 	nodeWriter := writer.NewNodeWriter(osmCache, nodes, db,
 		progress,
 		tagmapping.PointMatcher(),
@@ -196,6 +292,8 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 	nodeIds := make(map[int64]struct{})
 	wayIds := make(map[int64]struct{})
 	relIds := make(map[int64]struct{})
+	// nodes and ways outside of the -limitto buffer, they are not cached
+	outside := newOutsideElems()
 
 	step := log.StartStep("Parsing changes, updating cache and removing elements")
 
@@ -223,6 +321,12 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 			progress.AddCoords(1)
 		}
 
+		if undo != nil {
+			if err := undo.record(elem); err != nil {
+				return diffError(err, "record undo %v", elem)
+			}
+		}
+
 		// always delete, to prevent duplicate elements from overlap of initial
 		// import and diff import
 		if err := deleter.Delete(elem); err != nil && err != cache.NotFound {
@@ -234,10 +338,23 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 				if err := osmCache.Relations.DeleteRelation(elem.Rel.Id); err != nil && err != cache.NotFound {
 					return diffError(err, "delete relation %v", elem.Rel)
 				}
+				if err := diffCache.Pending.Delete(element.RELATION, elem.Rel.Id); err != nil {
+					return diffError(err, "delete pending relation %v", elem.Rel)
+				}
+				// mark parent relations for re-insert without this relation
+				for _, parent := range diffCache.Relations.Get(elem.Rel.Id) {
+					relIds[parent] = struct{}{}
+				}
+				if err := diffCache.Relations.Delete(elem.Rel.Id); err != nil && err != cache.NotFound {
+					return diffError(err, "delete relation references %v", elem.Rel)
+				}
 			} else if elem.Way != nil {
 				if err := osmCache.Ways.DeleteWay(elem.Way.Id); err != nil && err != cache.NotFound {
 					return diffError(err, "delete way %v", elem.Way)
 				}
+				if err := diffCache.Pending.Delete(element.WAY, elem.Way.Id); err != nil {
+					return diffError(err, "delete pending way %v", elem.Way)
+				}
 				if err := diffCache.Ways.Delete(elem.Way.Id); err != nil && err != cache.NotFound {
 					return diffError(err, "delete way references %v", elem.Way)
 				}
@@ -258,11 +375,11 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 		}
 		if elem.Add || elem.Mod {
 			if elem.Rel != nil {
-				// check if first member is cached to avoid caching
+				// check if any member is cached to avoid caching
 				// unneeded relations (typical outside of our coverage)
-				cached, err := osmCache.FirstMemberIsCached(elem.Rel.Members)
+				cached, missing, err := missingMembers(osmCache, diffCache.Pending, elem.Rel, outside)
 				if err != nil {
-					return diffError(err, "query first member %v", elem.Rel)
+					return diffError(err, "query members %v", elem.Rel)
 				}
 				if cached {
 					err := osmCache.Relations.PutRelation(elem.Rel)
@@ -270,13 +387,34 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 						return diffError(err, "put relation %v", elem.Rel)
 					}
 					relIds[elem.Rel.Id] = struct{}{}
+					if parentRelations != nil && parentRelations.Match(elem.Rel.Tags) {
+						// the member ways of the old relation are reinserted by
+						// the deleter, add the new members before the ways
+						// are written and delete them for the reinsert
+						diffCache.Ways.AddFromMembers(elem.Rel.Id, elem.Rel.Members)
+						for _, m := range elem.Rel.Members {
+							if m.Type == element.WAY {
+								if err := deleter.DeleteMemberWay(m.Id); err != nil {
+									return diffError(err, "delete member way %d", m.Id)
+								}
+							}
+						}
+					}
+				} else {
+					// relation moved out of our coverage, remove old version
+					if err := osmCache.Relations.DeleteRelation(elem.Rel.Id); err != nil && err != cache.NotFound {
+						return diffError(err, "delete relation %v", elem.Rel)
+					}
+				}
+				if err := updatePending(diffCache.Pending, element.RELATION, elem.Rel.Id, cached, missing); err != nil {
+					return diffError(err, "update pending relation %v", elem.Rel)
 				}
 			} else if elem.Way != nil {
-				// check if first coord is cached to avoid caching
+				// check if any coord is cached to avoid caching
 				// unneeded ways (typical outside of our coverage)
-				cached, err := osmCache.Coords.FirstRefIsCached(elem.Way.Refs)
+				cached, missing, err := missingRefs(osmCache, diffCache.Pending, elem.Way, outside)
 				if err != nil {
-					return diffError(err, "query first ref %v", elem.Way)
+					return diffError(err, "query refs %v", elem.Way)
 				}
 				if cached {
 					err := osmCache.Ways.PutWay(elem.Way)
@@ -284,12 +422,31 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 						return diffError(err, "put way %v", elem.Way)
 					}
 					wayIds[elem.Way.Id] = struct{}{}
+					if hasNetwork {
+						// network ways are split at shared nodes of
+						// all new ways
+						for _, ref := range elem.Way.Refs {
+							if err := diffCache.Coords.Add(ref, elem.Way.Id); err != nil {
+								return diffError(err, "add way references %v", elem.Way)
+							}
+						}
+					}
+				} else {
+					// way moved out of our coverage, remove old version
+					if err := osmCache.Ways.DeleteWay(elem.Way.Id); err != nil && err != cache.NotFound {
+						return diffError(err, "delete way %v", elem.Way)
+					}
+					outside.ways[elem.Way.Id] = struct{}{}
+				}
+				if err := updatePending(diffCache.Pending, element.WAY, elem.Way.Id, cached, missing); err != nil {
+					return diffError(err, "update pending way %v", elem.Way)
 				}
 			} else if elem.Node != nil {
 				addNode := true
 				if geometryLimiter != nil {
 					if !geometryLimiter.IntersectsBuffer(g, elem.Node.Long, elem.Node.Lat) {
 						addNode = false
+						outside.nodes[elem.Node.Id] = struct{}{}
 					}
 				}
 				if addNode {
@@ -311,6 +468,10 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 	for id, _ := range deleter.DeletedMemberWays() {
 		wayIds[id] = struct{}{}
 	}
+	// mark network ways with changed neighbours for re-insert
+	for id, _ := range deleter.DeletedNetworkWays() {
+		wayIds[id] = struct{}{}
+	}
 
 	progress.Stop()
 	log.StopStep(step)
@@ -341,6 +502,8 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 		}
 	}
 
+	addParentRelations(relIds, diffCache.Relations)
+
 	for relId, _ := range relIds {
 		rel, err := osmCache.Relations.GetRelation(relId)
 		if err != nil {
@@ -369,6 +532,14 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 		// insert new way
 		progress.AddWays(1)
 		ways <- way
+		if hasNetwork {
+			// get a copy, as the way writer modifies the way
+			way, err := osmCache.Ways.GetWay(wayId)
+			if err != nil {
+				return diffError(err, "could not get way %v", wayId)
+			}
+			networkWays <- way
+		}
 	}
 
 	for nodeId, _ := range nodeIds {
@@ -390,10 +561,31 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 	close(relations)
 	close(ways)
 	close(nodes)
+	if hasNetwork {
+		close(networkWays)
+	}
 
 	nodeWriter.Wait()
 	relWriter.Wait()
 	wayWriter.Wait()
+	if hasNetwork {
+		networkWriter.Wait()
+	}
+
+	if tagmapping.SpatialJoin != nil {
+		if changed := tagmapping.SpatialJoin.Apply(); len(changed) > 0 {
+			if sjDb, ok := db.(database.SpatialJoinUpdater); ok {
+				if err := sjDb.UpdateSpatialJoins(changed); err != nil {
+					return err
+				}
+			}
+			// save before the commit, the import is aborted if the
+			// index can't be written
+			if err := tagmapping.SpatialJoin.Save(spatialJoinFile); err != nil {
+				return err
+			}
+		}
+	}
 
 	if genDb != nil {
 		genDb.GeneralizeUpdates()
@@ -403,6 +595,7 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 	if err != nil {
 		return err
 	}
+
 	err = db.Close()
 	if err != nil {
 		return err
@@ -412,8 +605,14 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 
 	progress.Stop()
 
+	if undo != nil {
+		if err := undo.write(state, lastState); err != nil {
+			log.Warn("unable to write undo log: ", err) // warn only
+		}
+	}
+
 	if state != nil {
-		if lastState != nil {
+		if lastState != nil && state.Url == "" {
 			state.Url = lastState.Url
 		}
 		err = diffstate.WriteLastState(config.BaseOptions.DiffDir, state)
@@ -424,8 +623,62 @@ func Update(oscFile string, geometryLimiter *limit.Limiter, expireor expire.Expi
 	return nil
 }
 
+// historyTime returns the timestamp for rows in history tables. This is
+// the time of the imported diff, but never before the last imported diff
+// (e.g. for rollbacks). It returns the current time for diffs without
+// state.
+func historyTime(state, lastState *diffstate.DiffState) time.Time {
+	if state == nil || state.Time.IsZero() {
+		return time.Now()
+	}
+	if lastState != nil && lastState.Time.After(state.Time) {
+		return lastState.Time
+	}
+	return state.Time
+}
+
 func diffError(err error, msg string, args ...interface{}) error {
 	_, file, line, _ := runtime.Caller(1)
 	return fmt.Errorf("diff process error (%s:%d): %s %v",
 		filepath.Base(file), line, fmt.Sprintf(msg, args...), err)
 }
+
+// newLimiter returns the Limiter for -limitto, -bbox or the
+// limitto_regions. Returns nil if elements are not limited.
+func newLimiter() (*limit.Limiter, error) {
+	var regions []limit.Region
+	for _, r := range config.BaseOptions.Regions() {
+		regions = append(regions, limit.Region{
+			Name:    r.Name,
+			LimitTo: r.LimitTo,
+			Bbox:    r.Bbox,
+			Buffer:  r.CacheBuffer,
+			Schema:  r.Schema,
+		})
+	}
+	if len(regions) == 0 {
+		return nil, nil
+	}
+	step := log.StartStep("Reading limitto geometries")
+	defer log.StopStep(step)
+	return limit.New(regions, config.BaseOptions.Srid)
+}
+
+// addParentRelations adds all relations that contain a relation from
+// relIds as a (nested) member.
+func addParentRelations(relIds map[int64]struct{}, index *cache.RelationsRefIndex) {
+	queue := make([]int64, 0, len(relIds))
+	for id := range relIds {
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		id := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, parent := range index.Get(id) {
+			if _, ok := relIds[parent]; !ok {
+				relIds[parent] = struct{}{}
+				queue = append(queue, parent)
+			}
+		}
+	}
+}
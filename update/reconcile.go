@@ -0,0 +1,157 @@
+package update
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/expire"
+	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/logging"
+	"github.com/omniscale/imposm3/parser/diff"
+)
+
+// Reconcile re-inserts all pending ways and relations with references
+// that are cached now.
+func Reconcile() {
+	if config.BaseOptions.Quiet {
+		logging.SetQuiet(true)
+	}
+
+	geometryLimiter, err := newLimiter()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	osmCache := cache.NewOSMCache(config.BaseOptions.CacheDir)
+	if err := osmCache.Open(); err != nil {
+		log.Fatal("osm cache: ", err)
+	}
+	defer osmCache.Close()
+
+	diffCache := cache.NewDiffCache(config.BaseOptions.CacheDir)
+	if err := diffCache.Open(); err != nil {
+		log.Fatal("diff cache: ", err)
+	}
+	defer diffCache.Close()
+
+	var exp expire.Expireor
+	if config.BaseOptions.ExpireTilesDir != "" {
+		tileexpire := expire.NewTileList(config.BaseOptions.ExpireTilesZoom, config.BaseOptions.ExpireTilesDir)
+		exp = tileexpire
+		defer func() {
+			if err := tileexpire.Flush(); err != nil {
+				log.Error("error while writing tile expire file:", err)
+			}
+		}()
+	}
+
+	_, err = reconcile(geometryLimiter, exp, osmCache, diffCache)
+	osmCache.Coords.Flush()
+	diffCache.Flush()
+	if err != nil {
+		osmCache.Close()
+		diffCache.Close()
+		log.Fatal("unable to reconcile pending elements: ", err)
+	}
+}
+
+// reconcile checks all elements from the pending store against the
+// current cache. Elements with missing references that are cached now
+// are re-inserted, as if they were modified by a diff. Elements that are
+// no longer cached are removed from the pending store. It returns the
+// number of re-inserted elements.
+func reconcile(geometryLimiter *limit.Limiter, expireor expire.Expireor, osmCache *cache.OSMCache, diffCache *cache.DiffCache) (int, error) {
+	step := log.StartStep("Reconciling pending elements")
+	defer log.StopStep(step)
+
+	var elems []diff.Element
+	var removed []cache.PendingElement
+	pending := diffCache.Pending.Iter()
+	for p := range pending {
+		elem, err := resolvedPending(osmCache, p)
+		if err == cache.NotFound {
+			removed = append(removed, p)
+			continue
+		}
+		if err != nil {
+			// drain iterator before returning
+			for range pending {
+			}
+			return 0, err
+		}
+		if elem != nil {
+			elems = append(elems, *elem)
+		}
+	}
+
+	for _, p := range removed {
+		if err := diffCache.Pending.Delete(p.Type, p.Id); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(elems) == 0 {
+		return 0, nil
+	}
+	log.Printf("re-inserting %d pending elements", len(elems))
+	// without state: last.state.txt is not changed and no undo log is
+	// recorded
+	err := update(&elemParser{elems: elems}, nil, nil, nil, geometryLimiter, expireor, osmCache, diffCache)
+	if err != nil {
+		return 0, fmt.Errorf("re-inserting pending elements: %v", err)
+	}
+	return len(elems), nil
+}
+
+// resolvedPending returns the pending element as a modified element if
+// any missing reference is cached now, or nil if all references are
+// still missing. Returns cache.NotFound if the element itself is not
+// cached.
+func resolvedPending(osmCache *cache.OSMCache, p cache.PendingElement) (*diff.Element, error) {
+	resolved := false
+	for _, m := range p.Missing {
+		cached, err := isCached(osmCache, m)
+		if err != nil {
+			return nil, err
+		}
+		if cached {
+			resolved = true
+			break
+		}
+	}
+
+	elem := diff.Element{Mod: true}
+	var err error
+	switch p.Type {
+	case element.WAY:
+		elem.Way, err = osmCache.Ways.GetWay(p.Id)
+	case element.RELATION:
+		elem.Rel, err = osmCache.Relations.GetRelation(p.Id)
+	default:
+		return nil, cache.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !resolved {
+		return nil, nil
+	}
+	return &elem, nil
+}
+
+// elemParser returns a fixed list of elements.
+type elemParser struct {
+	elems []diff.Element
+}
+
+func (p *elemParser) Next() (diff.Element, error) {
+	if len(p.elems) == 0 {
+		return diff.Element{}, io.EOF
+	}
+	e := p.elems[0]
+	p.elems = p.elems[1:]
+	return e, nil
+}
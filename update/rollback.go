@@ -0,0 +1,111 @@
+package update
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/expire"
+	"github.com/omniscale/imposm3/logging"
+	"github.com/omniscale/imposm3/parser/diff"
+	diffstate "github.com/omniscale/imposm3/update/state"
+)
+
+// rollbackPlan returns the sequences of the undo logs that need to be
+// applied (newest first) to roll back from lastSeq to toSeq.
+func rollbackPlan(dir string, lastSeq, toSeq int) ([]int, error) {
+	var seqs []int
+	seq := lastSeq
+	for seq > toSeq {
+		prev, err := diffstate.ParseFile(filepath.Join(dir, strconv.Itoa(seq)+".state.txt"))
+		if err != nil {
+			return nil, fmt.Errorf("no undo log for #%d: %v", seq, err)
+		}
+		seqs = append(seqs, seq)
+		seq = prev.Sequence
+	}
+	if seq != toSeq {
+		return nil, fmt.Errorf("cannot roll back to #%d, undo log of #%d rolls back to #%d", toSeq, seqs[len(seqs)-1], seq)
+	}
+	return seqs, nil
+}
+
+// Rollback applies the undo logs of all diffs after -to-seq and resets
+// last.state.txt to this sequence.
+func Rollback() {
+	if config.BaseOptions.Quiet {
+		logging.SetQuiet(true)
+	}
+
+	lastState, err := diffstate.ParseLastState(config.BaseOptions.DiffDir)
+	if err != nil {
+		log.Fatal("unable to read last.state.txt: ", err)
+	}
+	toSeq := config.RollbackOptions.ToSeq
+	if lastState.Sequence <= toSeq {
+		log.Printf("already at #%d", lastState.Sequence)
+		return
+	}
+
+	dir := undoDir()
+	seqs, err := rollbackPlan(dir, lastState.Sequence, toSeq)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	geometryLimiter, err := newLimiter()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	osmCache := cache.NewOSMCache(config.BaseOptions.CacheDir)
+	if err := osmCache.Open(); err != nil {
+		log.Fatal("osm cache: ", err)
+	}
+	defer osmCache.Close()
+
+	diffCache := cache.NewDiffCache(config.BaseOptions.CacheDir)
+	if err := diffCache.Open(); err != nil {
+		log.Fatal("diff cache: ", err)
+	}
+	defer diffCache.Close()
+
+	var exp expire.Expireor
+	if config.BaseOptions.ExpireTilesDir != "" {
+		tileexpire := expire.NewTileList(config.BaseOptions.ExpireTilesZoom, config.BaseOptions.ExpireTilesDir)
+		exp = tileexpire
+		defer func() {
+			if err := tileexpire.Flush(); err != nil {
+				log.Error("error while writing tile expire file:", err)
+			}
+		}()
+	}
+
+	for _, seq := range seqs {
+		base := filepath.Join(dir, strconv.Itoa(seq))
+		prevState, err := diffstate.ParseFile(base + ".state.txt")
+		if err != nil {
+			log.Fatal(err)
+		}
+		step := log.StartStep(fmt.Sprintf("Rolling back #%d to #%d", seq, prevState.Sequence))
+		parser, err := diff.NewOscGzParser(base + ".osc.gz")
+		if err == nil {
+			// undo logs are not recorded for rollbacks
+			err = update(parser, prevState, lastState, nil, geometryLimiter, exp, osmCache, diffCache)
+		}
+		osmCache.Coords.Flush()
+		diffCache.Flush()
+		if err != nil {
+			osmCache.Close()
+			diffCache.Close()
+			log.Fatalf("unable to roll back #%d: %v", seq, err)
+		}
+		if err := removeUndoLog(dir, seq); err != nil {
+			log.Warn(err)
+		}
+		lastState = prevState
+		log.StopStep(step)
+	}
+}
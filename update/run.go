@@ -10,7 +10,6 @@ import (
 	"github.com/omniscale/imposm3/cache"
 	"github.com/omniscale/imposm3/config"
 	"github.com/omniscale/imposm3/expire"
-	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/logging"
 	"github.com/omniscale/imposm3/replication"
 	"github.com/omniscale/imposm3/update/state"
@@ -23,41 +22,53 @@ func Run() {
 		logging.SetQuiet(true)
 	}
 
-	var geometryLimiter *limit.Limiter
-	if config.BaseOptions.LimitTo != "" {
-		var err error
-		step := logger.StartStep("Reading limitto geometries")
-		geometryLimiter, err = limit.NewFromGeoJSON(
-			config.BaseOptions.LimitTo,
-			config.BaseOptions.LimitToCacheBuffer,
-			config.BaseOptions.Srid,
-		)
-		if err != nil {
-			logger.Fatal(err)
-		}
-		logger.StopStep(step)
+	geometryLimiter, err := newLimiter()
+	if err != nil {
+		logger.Fatal(err)
 	}
 
 	s, err := state.ParseLastState(config.BaseOptions.DiffDir)
 	if err != nil {
 		log.Fatal("unable to read last.state.txt", err)
 	}
-	replicationUrl := config.BaseOptions.ReplicationUrl
-	if replicationUrl == "" {
-		replicationUrl = s.Url
-	}
-	if replicationUrl == "" {
-		log.Fatal("no replicationUrl in last.state.txt " +
-			"or replication_url in -config file")
+
+	opts := &config.RunOptions
+	if opts.TargetReached(s.Sequence, s.Time) {
+		logger.Printf("already imported #%d till %s", s.Sequence, s.Time)
+		return
 	}
 
-	downloader := replication.NewDiffDownloader(
-		config.BaseOptions.DiffDir,
-		replicationUrl,
-		s.Sequence,
-		config.BaseOptions.ReplicationInterval,
-	)
-	nextSeq := downloader.Sequences()
+	var source replication.Source
+	if opts.FromDir != "" {
+		// only wait for new diffs if we do not stop at a target
+		source = replication.NewDiffReader(opts.FromDir, s.Sequence, !opts.HasTarget())
+	} else if len(config.BaseOptions.ReplicationFeeds) > 0 {
+		feeds := make([]replication.Feed, len(config.BaseOptions.ReplicationFeeds))
+		for i, f := range config.BaseOptions.ReplicationFeeds {
+			feeds[i] = replication.Feed{Url: f.Url, Interval: f.Interval.Duration}
+		}
+		source = replication.NewFeedsDiffDownloader(config.BaseOptions.DiffDir, feeds, s)
+	} else {
+		replicationUrl := config.BaseOptions.ReplicationUrl
+		if replicationUrl == "" {
+			replicationUrl = s.Url
+		}
+		if replicationUrl == "" {
+			log.Fatal("no replicationUrl in last.state.txt " +
+				"or replication_url in -config file")
+		}
+
+		source = replication.NewDiffDownloader(
+			config.BaseOptions.DiffDir,
+			replicationUrl,
+			s.Sequence,
+			config.BaseOptions.ReplicationInterval,
+		)
+	}
+	nextSeq := source.Sequences()
+	lastSeq := s.Sequence
+	lastTime := s.Time
+	lastUrl := s.Url
 
 	osmCache := cache.NewOSMCache(config.BaseOptions.CacheDir)
 	err = osmCache.Open()
@@ -84,72 +95,200 @@ func Run() {
 		tileExpireor = tilelist
 	}
 
-	shutdown := func() {
-		logger.Print("Exiting. (SIGTERM/SIGINT/SIGHUB)")
-		logging.Shutdown()
-		osmCache.Close()
-		diffCache.Close()
+	flushTilelist := func() {
 		if tilelist != nil {
 			err := tilelist.Flush()
 			if err != nil {
 				logger.Print("error writing tile expire list", err)
 			}
 		}
-		os.Exit(0)
+	}
+
+	exit := func(status int) {
+		logging.Shutdown()
+		osmCache.Close()
+		diffCache.Close()
+		flushTilelist()
+		os.Exit(status)
+	}
+
+	shutdown := func() {
+		logger.Print("Exiting. (SIGTERM/SIGINT/SIGHUB)")
+		if opts.HasTarget() {
+			exit(ExitIncomplete)
+		}
+		exit(0)
 	}
 
 	exp := newExpBackoff(2*time.Second, 5*time.Minute)
 
+	// remove downloaded diffs, but not from -from-dir
+	retention := replication.Retention{Seqs: opts.KeepSeqs, Age: opts.KeepAge}
+	var lastCleanup time.Time
+
+	// re-check pending elements, first after one interval
+	lastReconcile := time.Now()
+
+	// pending is a sequence from collectPending for the next import
+	var pending *replication.Sequence
 	for {
-		select {
-		case <-sigc:
-			shutdown()
-		case seq := <-nextSeq:
-			fname := seq.Filename
-			seqId := seq.Sequence
-			seqTime := seq.Time
-			for {
-				p := logger.StartStep(fmt.Sprintf("importing #%d till %s", seqId, seqTime))
-
-				err := Update(fname, geometryLimiter, tileExpireor, osmCache, diffCache, false)
-
-				osmCache.Coords.Flush()
-				diffCache.Flush()
-
-				if err == nil && tilelist != nil && time.Since(lastTlFlush) > time.Second*30 {
-					// call at most once every 30 seconds to reduce files during the
-					// catch-up phase after the initial import
-					lastTlFlush = time.Now()
-					err := tilelist.Flush()
-					if err != nil {
-						logger.Print("error writing tile expire list", err)
-					}
+		var seq replication.Sequence
+		if pending != nil {
+			seq, pending = *pending, nil
+		} else {
+			select {
+			case <-sigc:
+				shutdown()
+			case next, ok := <-nextSeq:
+				if !ok {
+					logger.Printf("missing diff #%d in %s", lastSeq+1, opts.FromDir)
+					exit(ExitIncomplete)
 				}
+				seq = next
+			}
+		}
+		seqs := []replication.Sequence{seq}
+		if !opts.Once {
+			seqs, pending = collectPending(seqs, nextSeq, opts.CatchUp,
+				opts.TargetReached, config.BaseOptions.ReplicationInterval)
+		}
+		if seq.Url != "" && seq.Url != lastUrl {
+			// switched to another replication feed, store the
+			// sequence before this diff in the new feed
+			err := state.WriteLastState(config.BaseOptions.DiffDir, &state.DiffState{
+				Time:     lastTime,
+				Sequence: seq.Sequence - 1,
+				Url:      seq.Url,
+			})
+			if err != nil {
+				logger.Fatal("unable to write last.state.txt", err)
+			}
+			lastUrl = seq.Url
+		}
+		fnames := make([]string, len(seqs))
+		for i := range seqs {
+			fnames[i] = seqs[i].Filename
+		}
+		seqId := seqs[len(seqs)-1].Sequence
+		seqTime := seqs[len(seqs)-1].Time
+		for {
+			msg := fmt.Sprintf("importing #%d till %s", seqId, seqTime)
+			if len(seqs) > 1 {
+				msg = fmt.Sprintf("importing #%d-#%d till %s", seqs[0].Sequence, seqId, seqTime)
+			}
+			p := logger.StartStep(msg)
 
-				logger.StopStep(p)
+			err := UpdateFiles(fnames, geometryLimiter, tileExpireor, osmCache, diffCache, false)
 
-				select {
-				case <-sigc:
-					shutdown()
-				default:
-				}
+			osmCache.Coords.Flush()
+			diffCache.Flush()
+
+			if err == nil && tilelist != nil && time.Since(lastTlFlush) > time.Second*30 {
+				// call at most once every 30 seconds to reduce files during the
+				// catch-up phase after the initial import
+				lastTlFlush = time.Now()
+				flushTilelist()
+			}
+
+			logger.StopStep(p)
+
+			select {
+			case <-sigc:
+				shutdown()
+			default:
+			}
 
-				if err != nil {
-					logger.Error(err)
-					logger.Print("retrying in ", exp.Duration())
-					exp.Wait()
-				} else {
-					exp.Reset()
-					break
+			if err != nil {
+				logger.Error(err)
+				if opts.HasTarget() {
+					// let the caller decide about retries
+					exit(1)
 				}
+				logger.Print("retrying in ", exp.Duration())
+				exp.Wait()
+			} else {
+				exp.Reset()
+				break
+			}
+		}
+		lastSeq = seqId
+		lastTime = seqTime
+		if !retention.IsZero() && opts.FromDir == "" && time.Since(lastCleanup) > cleanupInterval {
+			lastCleanup = time.Now()
+			dir := config.BaseOptions.DiffDir
+			if len(config.BaseOptions.ReplicationFeeds) > 0 {
+				dir = replication.FeedDir(dir, lastUrl)
 			}
-			if os.Getenv("IMPOSM3_SINGLE_DIFF") != "" {
-				return
+			if _, err := replication.Cleanup(dir, lastSeq, retention); err != nil {
+				logger.Warn("unable to remove old diffs: ", err)
 			}
 		}
+		if opts.ReconcileInterval > 0 && time.Since(lastReconcile) > opts.ReconcileInterval {
+			lastReconcile = time.Now()
+			_, err := reconcile(geometryLimiter, tileExpireor, osmCache, diffCache)
+			osmCache.Coords.Flush()
+			diffCache.Flush()
+			if err != nil {
+				logger.Warn("unable to reconcile pending elements: ", err)
+			}
+		}
+		if opts.Once || opts.TargetReached(seqId, seqTime) {
+			flushTilelist()
+			return
+		}
 	}
 }
 
+// pendingWait is the maximum duration collectPending waits for the next
+// sequence.
+const pendingWait = 5 * time.Second
+
+// collectPending appends pending sequences from nextSeq to seqs, till
+// seqs contains max sequences or the target is reached. It only waits
+// for the next sequence if the last sequence is older than two
+// replication intervals, i.e. if we are still catching up.
+// A sequence from another replication feed is returned as pending and
+// needs to be imported separately.
+func collectPending(
+	seqs []replication.Sequence,
+	nextSeq <-chan replication.Sequence,
+	max int,
+	targetReached func(int, time.Time) bool,
+	interval time.Duration,
+) ([]replication.Sequence, *replication.Sequence) {
+	for len(seqs) < max {
+		last := seqs[len(seqs)-1]
+		if targetReached(last.Sequence, last.Time) {
+			break
+		}
+		if last.Time.IsZero() || time.Since(last.Time) < 2*interval {
+			break
+		}
+		select {
+		case seq, ok := <-nextSeq:
+			if !ok {
+				return seqs, nil
+			}
+			if seq.Url != last.Url {
+				return seqs, &seq
+			}
+			seqs = append(seqs, seq)
+		case <-time.After(pendingWait):
+			return seqs, nil
+		}
+	}
+	return seqs, nil
+}
+
+// cleanupInterval is the minimum duration between two cleanups of the
+// downloaded diffs.
+const cleanupInterval = 10 * time.Minute
+
+// ExitIncomplete is the exit status of run if it stopped before
+// reaching the -until-seq, -until-time or -once target, e.g. if the next
+// diff is missing in -from-dir.
+const ExitIncomplete = 3
+
 type expBackoff struct {
 	current time.Duration
 	min     time.Duration
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path"
 	"strconv"
@@ -13,7 +12,7 @@ import (
 	"time"
 
 	"github.com/omniscale/imposm3/logging"
-	"github.com/omniscale/imposm3/parser/pbf"
+	"github.com/omniscale/imposm3/parser"
 )
 
 var log = logging.NewLogger("diff")
@@ -76,32 +75,22 @@ func FromOscGz(oscFile string) (*DiffState, error) {
 	return ParseFile(stateFile)
 }
 
-func FromPbf(filename string, before time.Duration) (*DiffState, error) {
-	pbfFile, err := pbf.NewParser(filename)
-	if err != nil {
-		return nil, err
-	}
+// FilesTimestamp returns the timestamp from the header of the OSM files.
+// The oldest timestamp is used for multiple files, so that no changes are
+// missed. The modification time of a file is used if the file has no
+// timestamp.
+func FilesTimestamp(filenames []string) (time.Time, error) {
 	var timestamp time.Time
-	if pbfFile.Header().Time.Unix() != 0 {
-		timestamp = pbfFile.Header().Time
-	} else {
-		fstat, err := os.Stat(filename)
+	for _, filename := range filenames {
+		t, err := fileTimestamp(filename)
 		if err != nil {
-			return nil, err
+			return time.Time{}, err
+		}
+		if timestamp.IsZero() || t.Before(timestamp) {
+			timestamp = t
 		}
-		timestamp = fstat.ModTime()
-	}
-
-	replicationUrl := "http://planet.openstreetmap.org/replication/minute/"
-
-	seq := estimateSequence(replicationUrl, timestamp)
-	if seq == 0 {
-		return nil, nil
 	}
-
-	// start earlier
-	seq -= int(before.Minutes())
-	return &DiffState{Time: timestamp, Url: replicationUrl, Sequence: seq}, nil
+	return timestamp, nil
 }
 
 func ParseFile(stateFile string) (*DiffState, error) {
@@ -180,31 +169,18 @@ func parseSequence(value string) (int, error) {
 	return int(val), err
 }
 
-func currentState(url string) (*DiffState, error) {
-	resp, err := http.Get(url + "state.txt")
+func fileTimestamp(filename string) (time.Time, error) {
+	osmFile, err := parser.NewParser(filename)
 	if err != nil {
-		return nil, err
+		return time.Time{}, err
 	}
-	if resp.StatusCode != 200 {
-		return nil, errors.New(fmt.Sprintf("invalid repsonse: %v", resp))
+	defer osmFile.Close()
+	if t := osmFile.Header().Time; !t.IsZero() && t.Unix() != 0 {
+		return t, nil
 	}
-	defer resp.Body.Close()
-	return Parse(resp.Body)
-}
-
-func estimateSequence(url string, timestamp time.Time) int {
-	state, err := currentState(url)
+	fstat, err := os.Stat(filename)
 	if err != nil {
-		// try a second time befor failing
-		log.Warn("unable to fetch current state from ", url, ":", err, ", retry in 30s")
-		time.Sleep(time.Second * 30)
-		state, err = currentState(url)
-		if err != nil {
-			log.Warn("unable to fetch current state from ", url, ":", err, ", giving up")
-			return 0
-		}
+		return time.Time{}, err
 	}
-
-	behind := state.Time.Sub(timestamp)
-	return state.Sequence - int(behind.Minutes())
+	return fstat.ModTime(), nil
 }
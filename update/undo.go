@@ -0,0 +1,246 @@
+package update
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/config"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/parser/diff"
+	"github.com/omniscale/imposm3/parser/osmxml"
+	diffstate "github.com/omniscale/imposm3/update/state"
+)
+
+// undoChange is the inverse change of a single element.
+type undoChange struct {
+	action string
+	node   *element.Node
+	way    *element.Way
+	rel    *element.Relation
+}
+
+// undoLog records the cached state of all elements before they are
+// changed by a diff import. The undo log is written as a reverse .osc
+// file, with the state before the import, into the undo directory
+// (<diffdir>/undo/<sequence>.osc.gz and <sequence>.state.txt).
+type undoLog struct {
+	osmCache *cache.OSMCache
+	seen     [3]map[int64]struct{}
+	changes  []undoChange
+}
+
+// newUndoLog returns an undoLog if undo logs are enabled with -undo-seqs.
+// It returns nil for diffs without state, as the undo log is stored by
+// sequence.
+func newUndoLog(osmCache *cache.OSMCache, state, lastState *diffstate.DiffState) *undoLog {
+	if config.BaseOptions.UndoSeqs <= 0 {
+		return nil
+	}
+	if state == nil || state.Sequence == 0 || lastState == nil {
+		log.Warn("not recording undo log for diff without state")
+		return nil
+	}
+	u := &undoLog{osmCache: osmCache}
+	for i := range u.seen {
+		u.seen[i] = make(map[int64]struct{})
+	}
+	return u
+}
+
+func undoDir() string {
+	return filepath.Join(config.BaseOptions.DiffDir, "undo")
+}
+
+// record stores the cached state of the element before the change.
+// Only the first change of each element is recorded.
+func (u *undoLog) record(elem diff.Element) error {
+	var typ element.MemberType
+	var id int64
+	switch {
+	case elem.Node != nil:
+		typ, id = element.NODE, elem.Node.Id
+	case elem.Way != nil:
+		typ, id = element.WAY, elem.Way.Id
+	case elem.Rel != nil:
+		typ, id = element.RELATION, elem.Rel.Id
+	default:
+		return nil
+	}
+	if _, ok := u.seen[typ][id]; ok {
+		return nil
+	}
+	u.seen[typ][id] = struct{}{}
+
+	change := undoChange{}
+	var err error
+	switch typ {
+	case element.NODE:
+		change.node, err = u.osmCache.Nodes.GetNode(id)
+		if err == cache.NotFound {
+			// untagged nodes are only stored as coords
+			change.node, err = u.osmCache.Coords.GetCoord(id)
+		}
+	case element.WAY:
+		change.way, err = u.osmCache.Ways.GetWay(id)
+	case element.RELATION:
+		change.rel, err = u.osmCache.Relations.GetRelation(id)
+	}
+	if err == cache.NotFound {
+		if elem.Del {
+			// nothing to restore
+			return nil
+		}
+		// remove the new element
+		change.action = "delete"
+		change.node, change.way, change.rel = elem.Node, elem.Way, elem.Rel
+	} else if err != nil {
+		return err
+	} else if elem.Del {
+		change.action = "create"
+	} else {
+		change.action = "modify"
+	}
+	if change.node != nil {
+		change.node.Id = id
+	} else if change.way != nil {
+		change.way.Id = id
+		change.way.Nodes = nil
+	} else if change.rel != nil {
+		change.rel.Id = id
+	}
+	u.changes = append(u.changes, change)
+	return nil
+}
+
+// write writes the undo log for state. lastState is the state before the
+// import. Undo logs older than -undo-seqs sequences are removed.
+func (u *undoLog) write(state, lastState *diffstate.DiffState) error {
+	dir := undoDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	base := filepath.Join(dir, strconv.Itoa(state.Sequence))
+
+	tmpState := fmt.Sprintf("%s.state.txt~%d", base, os.Getpid())
+	f, err := os.Create(tmpState)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpState)
+	if err := lastState.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	tmpOsc := fmt.Sprintf("%s.osc.gz~%d", base, os.Getpid())
+	f, err = os.Create(tmpOsc)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpOsc)
+	gz := gzip.NewWriter(f)
+	w, err := osmxml.NewWriter(gz, true)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	// reverse order, e.g. to restore deleted nodes before the ways
+	// that referenced them
+	for i := len(u.changes) - 1; i >= 0; i-- {
+		c := u.changes[i]
+		w.SetAction(c.action)
+		if c.node != nil {
+			err = w.WriteNode(c.node)
+		} else if c.way != nil {
+			err = w.WriteWay(c.way)
+		} else {
+			err = w.WriteRelation(c.rel)
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpState, base+".state.txt"); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpOsc, base+".osc.gz"); err != nil {
+		return err
+	}
+	return removeUndoLogs(dir, state.Sequence-config.BaseOptions.UndoSeqs)
+}
+
+// undoSequences returns the sequences of all undo logs in dir, sorted.
+func undoSequences(dir string) ([]int, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var seqs []int
+	for _, fi := range files {
+		name := fi.Name()
+		if !strings.HasSuffix(name, ".osc.gz") {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimSuffix(name, ".osc.gz"))
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+// removeUndoLogs removes all undo logs up to seq.
+func removeUndoLogs(dir string, seq int) error {
+	seqs, err := undoSequences(dir)
+	if err != nil {
+		return err
+	}
+	for _, s := range seqs {
+		if s > seq {
+			break
+		}
+		if err := removeUndoLog(dir, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeUndoLog(dir string, seq int) error {
+	base := filepath.Join(dir, strconv.Itoa(seq))
+	if err := os.Remove(base + ".osc.gz"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(base + ".state.txt"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
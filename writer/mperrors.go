@@ -0,0 +1,104 @@
+package writer
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	geomp "github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/proj"
+)
+
+// MultiPolygonErrors appends the issues of failed or repaired
+// multipolygons to a file, as one GeoJSON feature per line. The locations
+// of the issues are written as MultiPoint in EPSG:4326. Each feature
+// contains the time when it was written.
+type MultiPolygonErrors struct {
+	mu   sync.Mutex
+	f    *os.File
+	enc  *json.Encoder
+	srid int
+}
+
+// NewMultiPolygonErrors opens filename for appending. The file is
+// truncated first if truncate is true (e.g. for imports). srid is the SRID
+// of all issue locations.
+func NewMultiPolygonErrors(filename string, srid int, truncate bool) (*MultiPolygonErrors, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if truncate {
+		flag |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(filename, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiPolygonErrors{f: f, enc: json.NewEncoder(f), srid: srid}, nil
+}
+
+type issueFeature struct {
+	Type       string          `json:"type"`
+	Geometry   *issueGeometry  `json:"geometry"`
+	Properties issueProperties `json:"properties"`
+}
+
+type issueGeometry struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+type issueProperties struct {
+	RelationId int64  `json:"relation_id"`
+	Reason     string `json:"reason"`
+	Action     string `json:"action"`
+	Time       string `json:"time"`
+}
+
+// Write appends all issues of relation relId. err is the error from
+// building the multipolygon, it is added as failed issue if the
+// multipolygon was not already dropped or failed.
+func (e *MultiPolygonErrors) Write(relId int64, issues []geomp.Issue, err error) error {
+	if err != nil && !hasFailed(issues) {
+		issues = append(issues, geomp.Issue{Reason: err.Error(), Action: geomp.IssueFailed})
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, issue := range issues {
+		feature := issueFeature{
+			Type:       "Feature",
+			Properties: issueProperties{RelationId: relId, Reason: issue.Reason, Action: issue.Action, Time: now},
+		}
+		if len(issue.Locations) > 0 {
+			feature.Geometry = &issueGeometry{Type: "MultiPoint"}
+			for _, nd := range issue.Locations {
+				long, lat := nd.Long, nd.Lat
+				if e.srid == 3857 {
+					long, lat = proj.MercToWgs(long, lat)
+				}
+				feature.Geometry.Coordinates = append(feature.Geometry.Coordinates, [2]float64{long, lat})
+			}
+		}
+		if err := e.enc.Encode(feature); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *MultiPolygonErrors) Close() error {
+	return e.f.Close()
+}
+
+func hasFailed(issues []geomp.Issue) bool {
+	for _, issue := range issues {
+		if issue.Action == geomp.IssueFailed || issue.Action == geomp.IssueDropped {
+			return true
+		}
+	}
+	return false
+}
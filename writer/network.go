@@ -0,0 +1,163 @@
+package writer
+
+import (
+	"sync"
+
+	"github.com/omniscale/imposm3/cache"
+	"github.com/omniscale/imposm3/database"
+	"github.com/omniscale/imposm3/element"
+	"github.com/omniscale/imposm3/expire"
+	geomp "github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/limit"
+	"github.com/omniscale/imposm3/mapping"
+)
+
+// NetworkWriter inserts the edges of all ways that match a network table.
+// Ways are split at all nodes that are shared with other network ways.
+// Shared nodes are queried from the Coords index of the diffCache, which
+// needs to contain all ways.
+type NetworkWriter struct {
+	OsmElemWriter
+	singleIdSpace bool
+	ways          chan *element.Way
+	matcher       mapping.WayMatcher
+}
+
+func NewNetworkWriter(
+	osmCache *cache.OSMCache,
+	diffCache *cache.DiffCache,
+	singleIdSpace bool,
+	ways chan *element.Way,
+	inserter database.Inserter,
+	matcher mapping.WayMatcher,
+	srid int,
+) *OsmElemWriter {
+	nw := NetworkWriter{
+		OsmElemWriter: OsmElemWriter{
+			osmCache:  osmCache,
+			diffCache: diffCache,
+			wg:        &sync.WaitGroup{},
+			inserter:  inserter,
+			srid:      srid,
+		},
+		singleIdSpace: singleIdSpace,
+		matcher:       matcher,
+		ways:          ways,
+	}
+	nw.OsmElemWriter.writer = &nw
+	return &nw.OsmElemWriter
+}
+
+func (nw *NetworkWriter) wayId(id int64) int64 {
+	if !nw.singleIdSpace {
+		return id
+	}
+	return -id
+}
+
+func (nw *NetworkWriter) loop() {
+	geos := geos.NewGeos()
+	geos.SetHandleSrid(nw.srid)
+	defer geos.Finish()
+	for w := range nw.ways {
+		if len(w.Tags) == 0 {
+			continue
+		}
+		matches := nw.matcher.MatchWay(w)
+		if len(matches) == 0 {
+			continue
+		}
+		if len(w.Nodes) != len(w.Refs) {
+			// coords are already included for PBF files with LocationsOnWays
+			if err := nw.osmCache.Coords.FillWay(w); err != nil {
+				continue
+			}
+		}
+		nw.NodesToSrid(w.Nodes)
+
+		wayId := w.Id
+		w.Id = nw.wayId(w.Id)
+
+		inserted := false
+		junction := func(id int64) bool { return nw.isJunction(wayId, id) }
+		for _, edge := range geomp.SplitEdges(w.Nodes, junction) {
+			if err := nw.insertEdge(geos, w, edge, matches); err != nil {
+				if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
+					log.Warn(err)
+				}
+				continue
+			}
+			inserted = true
+		}
+		if inserted && nw.expireor != nil {
+			expire.ExpireProjectedNodes(nw.expireor, w.Nodes, nw.srid, false)
+		}
+	}
+	nw.wg.Done()
+}
+
+// isJunction returns true if the node is part of another network way.
+func (nw *NetworkWriter) isJunction(wayId, nodeId int64) bool {
+	for _, id := range nw.diffCache.Coords.Get(nodeId) {
+		if id == wayId {
+			continue
+		}
+		other, err := nw.osmCache.Ways.GetWay(id)
+		if err != nil {
+			if err != cache.NotFound {
+				log.Warn(err)
+			}
+			continue
+		}
+		if !containsRef(other.Refs, nodeId) {
+			// outdated reference
+			continue
+		}
+		if len(nw.matcher.MatchWay(other)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func containsRef(refs []int64, id int64) bool {
+	for _, ref := range refs {
+		if ref == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (nw *NetworkWriter) insertEdge(g *geos.Geos, w *element.Way, nodes []element.Node, matches []mapping.Match) error {
+	geosgeom, err := geomp.LineString(g, nodes)
+	if err != nil {
+		return err
+	}
+	source, target := nodes[0].Id, nodes[len(nodes)-1].Id
+
+	var parts []limit.Part
+	if nw.limiter != nil {
+		parts, err = nw.limiter.ClipRegions(geosgeom)
+		if err != nil {
+			return err
+		}
+	} else {
+		parts = []limit.Part{{Geom: geosgeom}}
+	}
+	for _, p := range parts {
+		way := element.Way(*w)
+		geom := geomp.Geometry{
+			Geom:   p.Geom,
+			Wkb:    g.AsEwkbHex(p.Geom),
+			Region: p.Region,
+			Source: source,
+			Target: target,
+		}
+		if err := nw.inserter.InsertLineString(way.OSMElem, geom, matches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -68,12 +68,13 @@ func (nw *NodeWriter) loop() {
 			}
 
 			if nw.limiter != nil {
-				parts, err := nw.limiter.Clip(geom.Geom)
+				parts, err := nw.limiter.ClipRegions(geom.Geom)
 				if err != nil {
 					log.Warn(err)
 					continue
 				}
-				if len(parts) >= 1 {
+				for _, p := range parts {
+					geom.Region = p.Region
 					if err := nw.inserter.InsertPoint(n.OSMElem, geom, matches); err != nil {
 						log.Warn(err)
 						continue
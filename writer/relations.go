@@ -10,6 +10,7 @@ import (
 	"github.com/omniscale/imposm3/expire"
 	geomp "github.com/omniscale/imposm3/geom"
 	geosp "github.com/omniscale/imposm3/geom/geos"
+	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/mapping"
 	"github.com/omniscale/imposm3/stats"
 )
@@ -21,7 +22,7 @@ type RelationWriter struct {
 	polygonMatcher        mapping.RelWayMatcher
 	relationMatcher       mapping.RelationMatcher
 	relationMemberMatcher mapping.RelationMatcher
-	maxGap                float64
+	restrictionMatcher    mapping.RelationMatcher
 }
 
 func NewRelationWriter(
@@ -34,6 +35,7 @@ func NewRelationWriter(
 	matcher mapping.RelWayMatcher,
 	relMatcher mapping.RelationMatcher,
 	relMemberMatcher mapping.RelationMatcher,
+	restrictionMatcher mapping.RelationMatcher,
 	srid int,
 ) *OsmElemWriter {
 	maxGap := 1e-1 // 0.1m
@@ -53,9 +55,10 @@ func NewRelationWriter(
 		polygonMatcher:        matcher,
 		relationMatcher:       relMatcher,
 		relationMemberMatcher: relMemberMatcher,
-		rel:    rel,
-		maxGap: maxGap,
+		restrictionMatcher:    restrictionMatcher,
+		rel:                   rel,
 	}
+	rw.multiPolygonOptions = geomp.MultiPolygonOptions{MaxRingGap: maxGap}
 	rw.OsmElemWriter.writer = &rw
 	return &rw.OsmElemWriter
 }
@@ -67,41 +70,33 @@ func (rw *RelationWriter) relId(id int64) int64 {
 	return element.RelIdOffset - id
 }
 
+// maxRelationDepth limits the nesting of sub-relations that are resolved
+// for each relation.
+const maxRelationDepth = 8
+
 func (rw *RelationWriter) loop() {
 	geos := geosp.NewGeos()
 	geos.SetHandleSrid(rw.srid)
 	defer geos.Finish()
 
-NextRel:
 	for r := range rw.rel {
 		rw.progress.AddRelations(1)
-		err := rw.osmCache.Ways.FillMembers(r.Members)
-		if err != nil {
-			if err != cache.NotFound {
-				log.Warn(err)
-			}
+		if !rw.fillWays(r) {
 			continue
 		}
-		for i, m := range r.Members {
-			if m.Way == nil {
-				continue
-			}
-			err := rw.osmCache.Coords.FillWay(m.Way)
-			if err != nil {
-				if err != cache.NotFound {
-					log.Warn(err)
-				}
-				continue NextRel
-			}
-			rw.NodesToSrid(m.Way.Nodes)
-			r.Members[i].Elem = &m.Way.OSMElem
+		subRelations := rw.needsSubRelations(r)
+		if subRelations {
+			rw.resolveRelations(r, map[int64]struct{}{r.Id: struct{}{}})
 		}
 
 		// handleRelation updates r.Members but we need all of them
-		// for the diffCache
-		allMembers := r.Members
+		// for the diffCache, including members of resolved sub-relations
+		allMembers := r.FlatMembers()
 
 		inserted := false
+		// parent relations are required for the member ways, even if the
+		// relation itself is not inserted
+		tracked := rw.parentRelations != nil && rw.parentRelations.Match(r.Tags)
 
 		if handleRelationMembers(rw, r, geos) {
 			inserted = true
@@ -112,10 +107,16 @@ NextRel:
 		if handleMultiPolygon(rw, r, geos) {
 			inserted = true
 		}
+		if handleRestriction(rw, r, geos) {
+			inserted = true
+		}
 
-		if inserted && rw.diffCache != nil {
+		if (inserted || tracked) && rw.diffCache != nil {
 			rw.diffCache.Ways.AddFromMembers(r.Id, allMembers)
 			rw.diffCache.CoordsRel.AddFromMembers(r.Id, allMembers)
+			if subRelations {
+				rw.diffCache.Relations.AddFromMembers(r.Id, allMembers)
+			}
 			for _, member := range allMembers {
 				if member.Way != nil {
 					rw.diffCache.Coords.AddFromWay(member.Way)
@@ -133,11 +134,97 @@ NextRel:
 	rw.wg.Done()
 }
 
+// fillWays fills all way members of r with their nodes. Returns false if
+// a way or coord is missing.
+func (rw *RelationWriter) fillWays(r *element.Relation) bool {
+	err := rw.osmCache.Ways.FillMembers(r.Members)
+	if err != nil {
+		if err != cache.NotFound {
+			log.Warn(err)
+		}
+		return false
+	}
+	for i, m := range r.Members {
+		if m.Way == nil {
+			continue
+		}
+		if len(m.Way.Nodes) != len(m.Way.Refs) {
+			// coords are already included for PBF files with LocationsOnWays
+			err := rw.osmCache.Coords.FillWay(m.Way)
+			if err != nil {
+				if err != cache.NotFound {
+					log.Warn(err)
+				}
+				return false
+			}
+		}
+		rw.NodesToSrid(m.Way.Nodes)
+		r.Members[i].Elem = &m.Way.OSMElem
+	}
+	return true
+}
+
+// needsSubRelations returns true if r matches a table that requires the
+// members of sub-relations: relation_member tables and relation tables
+// with route geometries.
+func (rw *RelationWriter) needsSubRelations(r *element.Relation) bool {
+	if rw.relationMemberMatcher.MatchRelation(r) != nil {
+		return true
+	}
+	for _, m := range rw.relationMatcher.MatchRelation(r) {
+		switch m.RelationGeometry() {
+		case mapping.RouteGeometry, mapping.RouteStopsGeometry:
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRelations sets Member.Rel for all relation members of r and
+// fills their way members, recursively up to maxRelationDepth.
+// parents contains the IDs of r and all relations above r, to skip
+// cyclic references. Sub-relations with missing ways are skipped.
+func (rw *RelationWriter) resolveRelations(r *element.Relation, parents map[int64]struct{}) {
+	for i, m := range r.Members {
+		if m.Type != element.RELATION {
+			continue
+		}
+		if _, ok := parents[m.Id]; ok {
+			log.Warnf("skipping cyclic member relation %d of relation %d", m.Id, r.Id)
+			continue
+		}
+		if len(parents) >= maxRelationDepth {
+			log.Warnf("relation %d nested deeper than %d relations", m.Id, maxRelationDepth)
+			continue
+		}
+		mrel, err := rw.osmCache.Relations.GetRelation(m.Id)
+		if err != nil {
+			if err != cache.NotFound {
+				log.Warn(err)
+			}
+			continue
+		}
+		if !rw.fillWays(mrel) {
+			continue
+		}
+		parents[mrel.Id] = struct{}{}
+		rw.resolveRelations(mrel, parents)
+		delete(parents, mrel.Id)
+
+		r.Members[i].Rel = mrel
+		r.Members[i].Elem = &mrel.OSMElem
+	}
+}
+
 func handleMultiPolygon(rw *RelationWriter, r *element.Relation, geos *geosp.Geos) bool {
 	// prepare relation first (build rings and compute actual
 	// relation tags)
-	prepedRel, err := geomp.PrepareRelation(r, rw.srid, rw.maxGap)
+	prepedRel, err := geomp.PrepareRelation(r, rw.srid, rw.multiPolygonOptions)
 	if err != nil {
+		// only report relations that would be imported
+		if rw.multiPolygonErrors != nil && rw.polygonMatcher.MatchRelation(r) != nil {
+			rw.writeMultiPolygonErrors(r.Id, prepedRel.Issues, err)
+		}
 		if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 			log.Warn(err)
 		}
@@ -155,6 +242,9 @@ func handleMultiPolygon(rw *RelationWriter, r *element.Relation, geos *geosp.Geo
 	if geom.Geom != nil {
 		defer geos.Destroy(geom.Geom)
 	}
+	if rw.multiPolygonErrors != nil {
+		rw.writeMultiPolygonErrors(r.Id, prepedRel.Issues, err)
+	}
 	if err != nil {
 		if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 			log.Warn(err)
@@ -164,7 +254,7 @@ func handleMultiPolygon(rw *RelationWriter, r *element.Relation, geos *geosp.Geo
 
 	if rw.limiter != nil {
 		start := time.Now()
-		parts, err := rw.limiter.Clip(geom.Geom)
+		parts, err := rw.limiter.ClipRegions(geom.Geom)
 		if err != nil {
 			log.Warn(err)
 			return false
@@ -172,11 +262,11 @@ func handleMultiPolygon(rw *RelationWriter, r *element.Relation, geos *geosp.Geo
 		if duration := time.Now().Sub(start); duration > time.Minute {
 			log.Warnf("clipping relation %d to -limitto took %s", r.Id, duration)
 		}
-		for _, g := range parts {
+		for _, p := range parts {
 			rel := element.Relation(*r)
 			rel.Id = rw.relId(r.Id)
-			geom = geomp.Geometry{Geom: g, Wkb: geos.AsEwkbHex(g)}
-			err := rw.inserter.InsertPolygon(rel.OSMElem, geom, matches)
+			geom = geomp.Geometry{Geom: p.Geom, Wkb: geos.AsEwkbHex(p.Geom), Region: p.Region}
+			err := rw.insertGeometry(geos, rel.OSMElem, geom, matches, rw.inserter.InsertPolygon)
 			if err != nil {
 				if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 					log.Warn(err)
@@ -187,7 +277,7 @@ func handleMultiPolygon(rw *RelationWriter, r *element.Relation, geos *geosp.Geo
 	} else {
 		rel := element.Relation(*r)
 		rel.Id = rw.relId(r.Id)
-		err := rw.inserter.InsertPolygon(rel.OSMElem, geom, matches)
+		err := rw.insertGeometry(geos, rel.OSMElem, geom, matches, rw.inserter.InsertPolygon)
 		if err != nil {
 			if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 				log.Warn(err)
@@ -205,15 +295,177 @@ func handleMultiPolygon(rw *RelationWriter, r *element.Relation, geos *geosp.Geo
 	return true
 }
 
+func (rw *RelationWriter) writeMultiPolygonErrors(id int64, issues []geomp.Issue, err error) {
+	if err := rw.multiPolygonErrors.Write(id, issues, err); err != nil {
+		log.Warn("writing multipolygon errors: ", err)
+	}
+}
+
 func handleRelation(rw *RelationWriter, r *element.Relation, geos *geosp.Geos) bool {
 	relMatches := rw.relationMatcher.MatchRelation(r)
 	if relMatches == nil {
 		return false
 	}
-	rel := element.Relation(*r)
-	rel.Id = rw.relId(r.Id)
-	rw.inserter.InsertPolygon(rel.OSMElem, geomp.Geometry{}, relMatches)
-	return true
+	var matches, routeMatches, stopMatches []mapping.Match
+	for _, m := range relMatches {
+		switch m.RelationGeometry() {
+		case mapping.RouteGeometry:
+			routeMatches = append(routeMatches, m)
+		case mapping.RouteStopsGeometry:
+			stopMatches = append(stopMatches, m)
+		default:
+			matches = append(matches, m)
+		}
+	}
+
+	inserted := false
+	if matches != nil {
+		rel := element.Relation(*r)
+		rel.Id = rw.relId(r.Id)
+		rw.inserter.InsertPolygon(rel.OSMElem, geomp.Geometry{}, matches)
+		inserted = true
+	}
+	if routeMatches != nil {
+		geom, gaps, err := geomp.Route(geos, r)
+		if err != nil {
+			if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
+				log.Warn(err)
+			}
+		} else {
+			if gaps > 0 {
+				log.Warnf("route relation %d has %d gap(s)", r.Id, gaps)
+			}
+			if rw.insertRelationGeom(r, geos, geom, nil, routeMatches) {
+				inserted = true
+			}
+		}
+	}
+	if stopMatches != nil {
+		rw.fillNodes(r, func(m element.Member) bool { return geomp.IsRouteStop(m.Role) })
+		geom, err := geomp.RouteStops(geos, r)
+		if err != nil {
+			if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
+				log.Warn(err)
+			}
+		} else if rw.insertRelationGeom(r, geos, geom, nil, stopMatches) {
+			inserted = true
+		}
+	}
+	return inserted
+}
+
+// insertRelationGeom inserts the geometry of a relation or restriction
+// table, clipped to the -limitto regions. restriction is nil for relation
+// tables. Destroys geom.
+func (rw *RelationWriter) insertRelationGeom(r *element.Relation, geos *geosp.Geos, geom *geosp.Geom, restriction *geomp.Restriction, matches []mapping.Match) bool {
+	defer geos.Destroy(geom)
+
+	var parts []limit.Part
+	if rw.limiter != nil {
+		var err error
+		parts, err = rw.limiter.ClipRegions(geom)
+		if err != nil {
+			log.Warn(err)
+			return false
+		}
+	} else {
+		parts = []limit.Part{{Geom: geom}}
+	}
+
+	inserted := false
+	for _, p := range parts {
+		rel := element.Relation(*r)
+		rel.Id = rw.relId(r.Id)
+		g := geomp.Geometry{
+			Geom:        p.Geom,
+			Wkb:         geos.AsEwkbHex(p.Geom),
+			Region:      p.Region,
+			Restriction: restriction,
+		}
+		if err := rw.inserter.InsertPolygon(rel.OSMElem, g, matches); err != nil {
+			log.Warn(err)
+			continue
+		}
+		inserted = true
+	}
+	return inserted
+}
+
+// handleRestriction inserts the restrictions of r into all matching
+// restriction tables. Invalid restrictions are only inserted into tables
+// with invalid_restrictions, with the geometry of all available members.
+func handleRestriction(rw *RelationWriter, r *element.Relation, geos *geosp.Geos) bool {
+	var matches, invalidMatches []mapping.Match
+	for _, m := range rw.restrictionMatcher.MatchRelation(r) {
+		if m.InvalidRestrictions() {
+			invalidMatches = append(invalidMatches, m)
+		} else {
+			matches = append(matches, m)
+		}
+	}
+	if matches == nil && invalidMatches == nil {
+		return false
+	}
+
+	restrictions, err := geomp.Restrictions(r)
+	if err != nil {
+		if invalidMatches == nil {
+			return false
+		}
+		geom, err2 := geomp.RelationCollection(geos, r)
+		if err2 != nil {
+			log.Warn(err2)
+			return false
+		}
+		if geom == nil {
+			geom = geos.FromWkt("GEOMETRYCOLLECTION EMPTY")
+		}
+		return rw.insertRelationGeom(r, geos, geom, &geomp.Restriction{Error: err.Error()}, invalidMatches)
+	}
+	if matches == nil {
+		return false
+	}
+
+	inserted := false
+	for i := range restrictions {
+		geom, err := geomp.RestrictionGeometry(geos, r, restrictions[i])
+		if err != nil {
+			if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
+				log.Warn(err)
+			}
+			continue
+		}
+		if rw.insertRelationGeom(r, geos, geom, &restrictions[i], matches) {
+			inserted = true
+		}
+	}
+	return inserted
+}
+
+// fillNodes sets Member.Node for all node members of r and of all
+// resolved sub-relations that match filter.
+func (rw *RelationWriter) fillNodes(r *element.Relation, filter func(element.Member) bool) {
+	for i, m := range r.Members {
+		if m.Rel != nil {
+			rw.fillNodes(m.Rel, filter)
+			continue
+		}
+		if m.Type != element.NODE || m.Node != nil || !filter(m) {
+			continue
+		}
+		nd, err := rw.osmCache.Nodes.GetNode(m.Id)
+		if err == cache.NotFound {
+			nd, err = rw.osmCache.Coords.GetCoord(m.Id)
+		}
+		if err != nil {
+			if err != cache.NotFound {
+				log.Warn(err)
+			}
+			continue
+		}
+		rw.NodeToSrid(nd)
+		r.Members[i].Node = nd
+	}
 }
 
 func handleRelationMembers(rw *RelationWriter, r *element.Relation, geos *geosp.Geos) bool {
@@ -222,7 +474,9 @@ func handleRelationMembers(rw *RelationWriter, r *element.Relation, geos *geosp.
 		return false
 	}
 	for i, m := range r.Members {
-		if m.Type == element.RELATION {
+		if m.Rel != nil {
+			rw.fillNodes(m.Rel, func(element.Member) bool { return true })
+		} else if m.Type == element.RELATION {
 			mrel, err := rw.osmCache.Relations.GetRelation(m.Id)
 			if err != nil {
 				if err != cache.NotFound {
@@ -260,6 +514,11 @@ func handleRelationMembers(rw *RelationWriter, r *element.Relation, geos *geosp.
 			g, err = geomp.Point(geos, *m.Node)
 		} else if m.Way != nil {
 			g, err = geomp.LineString(geos, m.Way.Nodes)
+		} else if m.Rel != nil {
+			g, err = geomp.RelationCollection(geos, m.Rel)
+			if g != nil {
+				geos.DestroyLater(g)
+			}
 		}
 
 		if err != nil {
@@ -78,18 +78,26 @@ func (ww *WayWriter) loop() {
 			continue
 		}
 
-		err = ww.osmCache.Coords.FillWay(w)
-		if err != nil {
-			continue
+		if len(w.Nodes) != len(w.Refs) {
+			// coords are already included for PBF files with LocationsOnWays
+			err = ww.osmCache.Coords.FillWay(w)
+			if err != nil {
+				continue
+			}
 		}
 		ww.NodesToSrid(w.Nodes)
 
+		var parents []*element.Relation
+		if ww.parentRelations != nil && ww.diffCache != nil {
+			parents = ww.parentRelationsOf(w.Id)
+		}
+
 		w.Id = ww.wayId(w.Id)
 
 		inserted := false
 		insertedPolygon := false
 		if matches := ww.lineMatcher.MatchWay(w); len(matches) > 0 {
-			err := ww.buildAndInsert(geos, w, matches, false)
+			err := ww.buildAndInsert(geos, w, matches, false, parents)
 			if err != nil {
 				if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 					log.Warn(err)
@@ -101,7 +109,7 @@ func (ww *WayWriter) loop() {
 		if !insertedAsRelation && (w.IsClosed() || w.TryClose(ww.maxGap)) {
 			// only add polygons that were not inserted as a MultiPolygon relation
 			if matches := ww.polygonMatcher.MatchWay(w); len(matches) > 0 {
-				err := ww.buildAndInsert(geos, w, matches, true)
+				err := ww.buildAndInsert(geos, w, matches, true, parents)
 				if err != nil {
 					if errl, ok := err.(ErrorLevel); !ok || errl.Level() > 0 {
 						log.Warn(err)
@@ -123,7 +131,32 @@ func (ww *WayWriter) loop() {
 	ww.wg.Done()
 }
 
-func (ww *WayWriter) buildAndInsert(g *geos.Geos, w *element.Way, matches []mapping.Match, isPolygon bool) error {
+// parentRelationsOf returns all parent relations that contain the way as
+// a direct member.
+func (ww *WayWriter) parentRelationsOf(id int64) []*element.Relation {
+	var parents []*element.Relation
+	for _, relId := range ww.diffCache.Ways.Get(id) {
+		rel, err := ww.osmCache.Relations.GetRelation(relId)
+		if err != nil {
+			if err != cache.NotFound {
+				log.Warn(err)
+			}
+			continue
+		}
+		if !ww.parentRelations.Match(rel.Tags) {
+			continue
+		}
+		for _, m := range rel.Members {
+			if m.Type == element.WAY && m.Id == id {
+				parents = append(parents, rel)
+				break
+			}
+		}
+	}
+	return parents
+}
+
+func (ww *WayWriter) buildAndInsert(g *geos.Geos, w *element.Way, matches []mapping.Match, isPolygon bool, parents []*element.Relation) error {
 	var err error
 	var geosgeom *geos.Geom
 	// make copy to avoid interference with polygon/linestring matches
@@ -146,33 +179,27 @@ func (ww *WayWriter) buildAndInsert(g *geos.Geos, w *element.Way, matches []mapp
 		return err
 	}
 
+	insert := ww.inserter.InsertLineString
+	if isPolygon {
+		insert = ww.inserter.InsertPolygon
+	}
+
 	if ww.limiter != nil {
-		parts, err := ww.limiter.Clip(geom.Geom)
+		parts, err := ww.limiter.ClipRegions(geom.Geom)
 		if err != nil {
 			return err
 		}
 		for _, p := range parts {
 			way := element.Way(*w)
-			geom = geomp.Geometry{Geom: p, Wkb: g.AsEwkbHex(p)}
-			if isPolygon {
-				if err := ww.inserter.InsertPolygon(way.OSMElem, geom, matches); err != nil {
-					return err
-				}
-			} else {
-				if err := ww.inserter.InsertLineString(way.OSMElem, geom, matches); err != nil {
-					return err
-				}
+			geom = geomp.Geometry{Geom: p.Geom, Wkb: g.AsEwkbHex(p.Geom), Region: p.Region, ParentRelations: parents}
+			if err := ww.insertGeometry(g, way.OSMElem, geom, matches, insert); err != nil {
+				return err
 			}
 		}
 	} else {
-		if isPolygon {
-			if err := ww.inserter.InsertPolygon(way.OSMElem, geom, matches); err != nil {
-				return err
-			}
-		} else {
-			if err := ww.inserter.InsertLineString(way.OSMElem, geom, matches); err != nil {
-				return err
-			}
+		geom.ParentRelations = parents
+		if err := ww.insertGeometry(g, way.OSMElem, geom, matches, insert); err != nil {
+			return err
 		}
 	}
 	return nil
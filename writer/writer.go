@@ -8,8 +8,11 @@ import (
 	"github.com/omniscale/imposm3/database"
 	"github.com/omniscale/imposm3/element"
 	"github.com/omniscale/imposm3/expire"
+	geomp "github.com/omniscale/imposm3/geom"
+	"github.com/omniscale/imposm3/geom/geos"
 	"github.com/omniscale/imposm3/geom/limit"
 	"github.com/omniscale/imposm3/logging"
+	"github.com/omniscale/imposm3/mapping"
 	"github.com/omniscale/imposm3/proj"
 	"github.com/omniscale/imposm3/stats"
 )
@@ -35,6 +38,13 @@ type OsmElemWriter struct {
 	srid       int
 	expireor   expire.Expireor
 	concurrent bool
+	// parentRelations are tracked in the diffCache, even if they are not
+	// inserted, and passed to the inserter with their member ways
+	parentRelations *mapping.ParentRelations
+	// multiPolygonOptions and multiPolygonErrors are only used by the
+	// RelationWriter
+	multiPolygonOptions geomp.MultiPolygonOptions
+	multiPolygonErrors  *MultiPolygonErrors
 }
 
 func (writer *OsmElemWriter) SetLimiter(limiter *limit.Limiter) {
@@ -60,10 +70,70 @@ func (writer *OsmElemWriter) SetExpireor(exp expire.Expireor) {
 	writer.expireor = exp
 }
 
+// SetParentRelations enables parent_relation_tags columns. Requires a
+// diffCache.
+func (writer *OsmElemWriter) SetParentRelations(parentRelations *mapping.ParentRelations) {
+	writer.parentRelations = parentRelations
+}
+
+// SetMultiPolygonOptions configures how multipolygons are build.
+func (writer *OsmElemWriter) SetMultiPolygonOptions(opts geomp.MultiPolygonOptions) {
+	writer.multiPolygonOptions = opts
+}
+
+// SetMultiPolygonErrors enables the reporting of failed or repaired
+// multipolygons.
+func (writer *OsmElemWriter) SetMultiPolygonErrors(errs *MultiPolygonErrors) {
+	writer.multiPolygonErrors = errs
+}
+
 func (writer *OsmElemWriter) Wait() {
 	writer.wg.Wait()
 }
 
+type insertFunc func(element.OSMElem, geomp.Geometry, []mapping.Match) error
+
+// insertGeometry inserts geom into all matched tables with insert. The
+// geometry_transform of the tables is applied before, transformed points
+// are inserted with InsertPoint.
+func (writer *OsmElemWriter) insertGeometry(g *geos.Geos, elem element.OSMElem, geom geomp.Geometry, matches []mapping.Match, insert insertFunc) error {
+	var untransformed []mapping.Match
+	for _, m := range matches {
+		if m.Transform() == nil {
+			untransformed = append(untransformed, m)
+		}
+	}
+	if len(untransformed) > 0 {
+		if err := insert(elem, geom, untransformed); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range matches {
+		transform := m.Transform()
+		if transform == nil {
+			continue
+		}
+		parts, err := transform.Apply(g, geom.Geom)
+		if err != nil {
+			return err
+		}
+		for _, p := range parts {
+			transformed := geom
+			transformed.Geom = p
+			transformed.Wkb = g.AsEwkbHex(p)
+			insertPart := insert
+			if g.Type(p) == "Point" {
+				insertPart = writer.inserter.InsertPoint
+			}
+			if err := insertPart(elem, transformed, []mapping.Match{m}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (writer *OsmElemWriter) NodesToSrid(nodes []element.Node) {
 	if writer.srid == 4326 {
 		return